@@ -0,0 +1,93 @@
+package calsync
+
+import (
+	"fmt"
+	"time"
+
+	calendar "google.golang.org/api/calendar/v3"
+
+	"golang.org/x/net/context"
+)
+
+// adopt finds the events in srcEvents that are not in calEvents, and
+// looks for untagged upcoming events in the calendar with the same
+// title and start, claiming each by writing our private extended
+// properties to it, unless c.nop is set.  It returns the claimed
+// events, as if they had been fetched.  See Adopt.
+func (c cal) adopt(ctx context.Context, now time.Time, calEvents, srcEvents []*Event) ([]*Event, error) {
+	synced := map[string]bool{}
+	for _, ev := range calEvents {
+		synced[ev.SrcID] = true
+	}
+	wanted := map[string][]*Event{}
+	for _, ev := range srcEvents {
+		if !synced[ev.SrcID] && !ev.End.Before(now) {
+			key := adoptKey(ev)
+			wanted[key] = append(wanted[key], ev)
+		}
+	}
+	if len(wanted) == 0 {
+		return nil, nil
+	}
+
+	var candidates []*calendar.Event
+	err := c.svc.Events.List(c.calID).
+		ShowDeleted(false).
+		SingleEvents(true).
+		TimeMin(now.Format(time.RFC3339)).
+		Pages(ctx, func(page *calendar.Events) error {
+			for _, each := range page.Items {
+				if each.ExtendedProperties == nil || len(eventScopes(each.ExtendedProperties.Private)) == 0 {
+					candidates = append(candidates, each)
+				}
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve google calendar events to adopt: %v", err)
+	}
+
+	var adopted []*Event
+	for _, each := range candidates {
+		ev, err := c.parseEvent(each)
+		if err != nil {
+			return nil, fmt.Errorf("parseEvent %q, %v", each.Summary, err)
+		}
+		key := adoptKey(ev)
+		matches := wanted[key]
+		if len(matches) == 0 {
+			continue
+		}
+		wanted[key] = matches[1:]
+
+		ev.SrcID = matches[0].SrcID
+		// Record the event as synced as it is, so that it isn't
+		// mistaken for a calendar edit, and is updated to match the
+		// source if it differs.
+		ev.syncedHash = c.contentHash(ev)
+		if !c.nop {
+			props := c.idProps(ev.SrcID)
+			props[c.scope] = "True"
+			props[c.hashKey()] = c.sealEventProp(ev.SrcID, c.hashKey(), ev.syncedHash)
+			claimed, err := c.svc.Events.Patch(c.calID, ev.calEventID, &calendar.Event{
+				ExtendedProperties: &calendar.EventExtendedProperties{
+					Private: props,
+				},
+			}).Context(ctx).Do()
+			if err != nil {
+				return nil, fmt.Errorf("adopting %q: %v", ev.Title, err)
+			}
+			ev.raw = claimed
+		}
+		adopted = append(adopted, ev)
+	}
+	return adopted, nil
+}
+
+// adoptKey returns what Adopt matches events by.
+func adoptKey(ev *Event) string {
+	if ev.AllDay {
+		return fmt.Sprintf("%q %s", ev.Title, ev.Start.Format(dateLayout))
+	}
+	return fmt.Sprintf("%q %d", ev.Title, ev.Start.Unix())
+}
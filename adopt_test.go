@@ -0,0 +1,61 @@
+package calsync
+
+import (
+	"testing"
+	"time"
+
+	calendar "google.golang.org/api/calendar/v3"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+func TestAdopt(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	manual := func(title string, start time.Time) string {
+		ev, err := s.Put("primary", &calendar.Event{
+			Summary:     title,
+			Description: "typed in by hand",
+			Start:       &calendar.EventDateTime{DateTime: start.Format(time.RFC3339)},
+			End:         &calendar.EventDateTime{DateTime: start.Add(time.Hour).Format(time.RFC3339)},
+		})
+		ok(t, err)
+		return ev.Id
+	}
+	claimedID := manual("a title", start)
+	manual("a title", start.Add(time.Minute))
+	_, err := Sync(ctx, s.Client(), "other", []*Event{newSrcEvent("b", start)})
+	ok(t, err)
+
+	src := []*Event{newSrcEvent("a", start), newSrcEvent("b", start)}
+	changes, err := Sync(ctx, s.Client(), "scope", src, Adopt(), Nop())
+	ok(t, err)
+	equals(t, 1, len(changes.Adopted))
+	equals(t, 1, len(changes.Updates))
+	equals(t, 1, len(changes.Adds))
+	for _, ev := range s.Events("primary") {
+		assert(t, ev.ExtendedProperties == nil || ev.ExtendedProperties.Private["scope"] == "",
+			"expected a dry run not to claim anything")
+	}
+
+	changes, err = Sync(ctx, s.Client(), "scope", src, Adopt())
+	ok(t, err)
+	equals(t, 1, len(changes.Adopted))
+	equals(t, claimedID, changes.Adopted[0].calEventID)
+	equals(t, 1, len(changes.Adds))
+	equals(t, "b title", changes.Adds[0].Title)
+	equals(t, 4, len(s.Events("primary")))
+	for _, ev := range s.Events("primary") {
+		if ev.Id == claimedID {
+			equals(t, "a srcId", ev.ExtendedProperties.Private["scopeID"])
+			equals(t, src[0].exportedDescription(false), ev.Description)
+		}
+	}
+
+	changes, err = Sync(ctx, s.Client(), "scope", src, Adopt())
+	ok(t, err)
+	assert(t, changes.empty(), "expected no changes, got %s", changes)
+}
@@ -0,0 +1,91 @@
+package calsync
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+// Attendee is someone invited to an Event.
+type Attendee struct {
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email"`
+}
+
+// rosterPrefix starts the line PrivateCopies adds to descriptions.
+const rosterPrefix = "Attendees: "
+
+// privateCopies returns srcEvents, with the attendees of each event
+// listed by name in its description rather than invited.  See
+// PrivateCopies.
+func privateCopies(srcEvents []*Event) []*Event {
+	copies := make([]*Event, len(srcEvents))
+	for i, ev := range srcEvents {
+		copies[i] = ev
+		if len(ev.Attendees) == 0 {
+			continue
+		}
+		var names []string
+		for _, a := range ev.Attendees {
+			if a.Name != "" {
+				names = append(names, a.Name)
+			}
+		}
+		cp := *ev
+		cp.Attendees = nil
+		if len(names) != 0 {
+			roster := rosterPrefix + strings.Join(names, ", ")
+			sep := "\n\n"
+			if cp.DescriptionHTML {
+				roster, sep = textToHTML(roster), "<br><br>"
+			}
+			if cp.Description == "" {
+				cp.Description = roster
+			} else {
+				cp.Description += sep + roster
+			}
+		}
+		copies[i] = &cp
+	}
+	return copies
+}
+
+// attendeeKey identifies the attendees of an event, ignoring their
+// order, names and the case of their email addresses, as google
+// calendar may change those.
+func attendeeKey(attendees []Attendee) string {
+	emails := make([]string, len(attendees))
+	for i, a := range attendees {
+		emails[i] = strings.ToLower(a.Email)
+	}
+	sort.Strings(emails)
+	return fmt.Sprintf("%q", emails)
+}
+
+func sameAttendees(a, b []Attendee) bool {
+	return attendeeKey(a) == attendeeKey(b)
+}
+
+func makeAttendees(attendees []Attendee) []*calendar.EventAttendee {
+	var out []*calendar.EventAttendee
+	for _, a := range attendees {
+		out = append(out, &calendar.EventAttendee{DisplayName: a.Name, Email: a.Email})
+	}
+	return out
+}
+
+// parseAttendees returns the attendees of a google calendar event.
+// The calendar's own entry, which google calendar adds for the
+// organizer, isn't one of ours.
+func parseAttendees(in []*calendar.EventAttendee) []Attendee {
+	var out []Attendee
+	for _, a := range in {
+		if a.Self {
+			continue
+		}
+		out = append(out, Attendee{Name: a.DisplayName, Email: a.Email})
+	}
+	return out
+}
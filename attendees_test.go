@@ -0,0 +1,73 @@
+package calsync
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+var testAttendees = []Attendee{
+	{Name: "Ana", Email: "ana@example.com"},
+	{Email: "nameless@example.com"},
+	{Name: "Bo", Email: "bo@example.org"},
+}
+
+func TestAttendees(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	ev := newSrcEvent("meeting", time.Now().Add(time.Hour).Truncate(time.Second))
+	ev.Attendees = testAttendees
+	src := []*Event{ev}
+
+	_, err := Sync(ctx, s.Client(), "scope", src)
+	ok(t, err)
+	stored := s.Events("primary")[0]
+	equals(t, 3, len(stored.Attendees))
+	equals(t, "ana@example.com", stored.Attendees[0].Email)
+
+	// Order and case don't matter.
+	reordered := *ev
+	reordered.Attendees = []Attendee{testAttendees[2], testAttendees[1], {Name: "Ana", Email: "ANA@example.com"}}
+	changes, err := Sync(ctx, s.Client(), "scope", []*Event{&reordered})
+	ok(t, err)
+	assert(t, changes.empty(), "expected no changes, got %s", changes)
+
+	reordered.Attendees = reordered.Attendees[1:]
+	changes, err = Sync(ctx, s.Client(), "scope", []*Event{&reordered})
+	ok(t, err)
+	equals(t, 1, len(changes.Updates))
+	equals(t, 2, len(s.Events("primary")[0].Attendees))
+}
+
+func TestPrivateCopies(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	ev := newSrcEvent("meeting", time.Now().Add(time.Hour).Truncate(time.Second))
+	ev.Attendees = testAttendees
+	src := []*Event{ev}
+
+	// Switching an existing scope over rewrites its events.
+	_, err := Sync(ctx, s.Client(), "scope", src)
+	ok(t, err)
+	changes, err := Sync(ctx, s.Client(), "scope", src, PrivateCopies())
+	ok(t, err)
+	equals(t, 1, len(changes.Updates))
+
+	stored := s.Events("primary")[0]
+	equals(t, 0, len(stored.Attendees))
+	assert(t, strings.HasSuffix(stored.Description, "meeting description\n\nAttendees: Ana, Bo"),
+		"unexpected description %q", stored.Description)
+	assert(t, !strings.Contains(stored.Description, "@"), "description leaks addresses: %q", stored.Description)
+	equals(t, testAttendees, ev.Attendees)
+
+	changes, err = Sync(ctx, s.Client(), "scope", src, PrivateCopies())
+	ok(t, err)
+	assert(t, changes.empty(), "expected no changes, got %s", changes)
+	edits, err := PullChanges(ctx, s.Client(), "scope", src, PrivateCopies())
+	ok(t, err)
+	equals(t, 0, len(edits))
+}
@@ -0,0 +1,127 @@
+package calsync
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// AuditRecord describes one operation that Sync, SyncAll, Apply or
+// Purge made in google calendar.
+type AuditRecord struct {
+	// Time is when the operation was made.
+	Time time.Time `json:"time"`
+
+	// Operation is "delete", "cancel", "update" or "add".  Cancel is
+	// what OnDelete(Cancel) does in place of deleting.
+	Operation string `json:"operation"`
+
+	Scope      string `json:"scope"`
+	CalendarID string `json:"calendar_id"`
+
+	// EventID is the google calendar id of the event.
+	EventID string `json:"event_id"`
+	SrcID   string `json:"src_id"`
+
+	// Old is the event before the operation, and is nil for adds, and
+	// for updates from a ResumePlan plan.
+	Old *Event `json:"old,omitempty"`
+
+	// New is the event after the operation, and is nil for deletes and
+	// cancels.
+	New *Event `json:"new,omitempty"`
+}
+
+// AuditWriter records the operations made in google calendar, for
+// example to keep a durable record of what was done to whose calendar.
+// See Audit.
+type AuditWriter interface {
+	// WriteAudit records r.  If it returns an error, nothing more is
+	// modified, and the error is returned.
+	WriteAudit(r *AuditRecord) error
+}
+
+type jsonLinesAudit struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewJSONLinesAudit returns an AuditWriter that writes each record to
+// w as one line of JSON.
+func NewJSONLinesAudit(w io.Writer) AuditWriter {
+	return &jsonLinesAudit{w: w}
+}
+
+func (a *jsonLinesAudit) WriteAudit(r *AuditRecord) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, err = a.w.Write(append(b, '\n'))
+	return err
+}
+
+type fileAudit struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileAudit returns an AuditWriter that appends each record to the
+// file at path, creating it if needed, as one line of JSON.  Each record
+// is flushed to disk before the next operation is made.
+func NewFileAudit(path string) AuditWriter {
+	return &fileAudit{path: path}
+}
+
+func (a *fileAudit) WriteAudit(r *AuditRecord) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	f, err := os.OpenFile(a.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// audit records an operation with c.auditor, if there is one.  before
+// or after may be nil.
+func (c cal) audit(op string, eventID string, before, after *Event) error {
+	if c.auditor == nil {
+		return nil
+	}
+	ev := after
+	if ev == nil {
+		ev = before
+	}
+	err := c.auditor.WriteAudit(&AuditRecord{
+		Time:       c.now(),
+		Operation:  op,
+		Scope:      c.scope,
+		CalendarID: c.calendarOf(ev),
+		EventID:    eventID,
+		SrcID:      ev.SrcID,
+		Old:        before,
+		New:        after,
+	})
+	if err != nil {
+		return fmt.Errorf("writing audit record: %v", err)
+	}
+	return nil
+}
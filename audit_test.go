@@ -0,0 +1,120 @@
+package calsync
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+func readAudit(t *testing.T, b []byte) []*AuditRecord {
+	var records []*AuditRecord
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		r := &AuditRecord{}
+		ok(t, json.Unmarshal(scanner.Bytes(), r))
+		records = append(records, r)
+	}
+	ok(t, scanner.Err())
+	return records
+}
+
+func TestAudit(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	a, b := newSrcEvent("a", start), newSrcEvent("b", start.Add(time.Hour))
+	var buf bytes.Buffer
+	audit := Audit(NewJSONLinesAudit(&buf))
+
+	changes, err := Sync(ctx, s.Client(), "scope", []*Event{a, b}, audit)
+	ok(t, err)
+	equals(t, []string{"Audit"}, changes.Manifest.Options)
+	events := s.Events("primary")
+
+	changed := *a
+	changed.Title = "changed title"
+	_, err = Sync(ctx, s.Client(), "scope", []*Event{&changed}, audit)
+	ok(t, err)
+
+	_, err = Sync(ctx, s.Client(), "scope", []*Event{a, b}, audit, Nop())
+	ok(t, err)
+
+	records := readAudit(t, buf.Bytes())
+	equals(t, 4, len(records))
+	for i, want := range []struct {
+		op, srcID, eventID string
+		old, new           string
+	}{
+		{"add", "a srcId", events[0].Id, "", "a title"},
+		{"add", "b srcId", events[1].Id, "", "b title"},
+		{"delete", "b srcId", events[1].Id, "b title", ""},
+		{"update", "a srcId", events[0].Id, "a title", "changed title"},
+	} {
+		r := records[i]
+		equals(t, want.op, r.Operation)
+		equals(t, "scope", r.Scope)
+		equals(t, "primary", r.CalendarID)
+		equals(t, want.srcID, r.SrcID)
+		equals(t, want.eventID, r.EventID)
+		assert(t, !r.Time.IsZero(), "expected a time in %+v", r)
+		if want.old == "" {
+			assert(t, r.Old == nil, "expected no old event in %+v", r)
+		} else {
+			equals(t, want.old, r.Old.Title)
+		}
+		if want.new == "" {
+			assert(t, r.New == nil, "expected no new event in %+v", r)
+		} else {
+			equals(t, want.new, r.New.Title)
+		}
+	}
+}
+
+func TestFileAudit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "calsync")
+	ok(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.jsonl")
+
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	ev := newSrcEvent("a", time.Now().Add(time.Hour).Truncate(time.Second))
+	for i := 0; i < 2; i++ {
+		_, err = Purge(ctx, s.Client(), "scope", Audit(NewFileAudit(path)))
+		ok(t, err)
+		_, err = Sync(ctx, s.Client(), "scope", []*Event{ev}, Audit(NewFileAudit(path)))
+		ok(t, err)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	ok(t, err)
+	var ops []string
+	for _, r := range readAudit(t, b) {
+		ops = append(ops, r.Operation)
+	}
+	equals(t, []string{"add", "delete", "add"}, ops)
+}
+
+func TestAuditFailure(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	src := []*Event{newSrcEvent("a", start), newSrcEvent("b", start)}
+	dir, err := ioutil.TempDir("", "calsync")
+	ok(t, err)
+	defer os.RemoveAll(dir)
+
+	// The audit file can't be created, so nothing more is modified.
+	_, err = Sync(ctx, s.Client(), "scope", src, Audit(NewFileAudit(dir)))
+	assert(t, err != nil, "expected an error")
+	equals(t, 1, len(s.Events("primary")))
+}
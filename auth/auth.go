@@ -0,0 +1,168 @@
+/*
+Package auth authorizes programs to manage google calendars with
+calsync, on behalf of a google account, and caches the token it is
+given so that later runs are unattended:
+
+	client, err := auth.Client(ctx, "client_secret.json", "token.json")
+	if err != nil {
+		log.Fatal(err)
+	}
+	changes, err := calsync.Sync(ctx, client, "myscope", events)
+
+The client credentials come from a google cloud project with the
+calendar api enabled.  See
+https://developers.google.com/google-apps/calendar/quickstart/go
+
+The first time, the user is asked to visit a URL in a browser on the
+same machine, which redirects back to a port calsync listens on.  Where
+that can't work, the device flow has the user enter a code on any other
+device instead.  See Flow.Device.
+*/
+package auth
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/ginabythebay/calsync"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+
+	"golang.org/x/net/context"
+)
+
+// Flow authorizes access to google calendar and caches the resulting
+// token in a file.
+type Flow struct {
+	Config *oauth2.Config
+
+	// TokenFile is where the token is cached.  It is written with
+	// permissions that only let its owner read it, and locked while it
+	// is written, so several processes may share it.
+	TokenFile string
+
+	// Prompt is where instructions for the user are written when
+	// authorization is needed.  Nil means os.Stderr.
+	Prompt io.Writer
+
+	// Device makes Authorize use the device flow, where the user enters
+	// a code on another device, rather than redirecting a browser on this
+	// machine.  Use it over ssh, or wherever no browser can reach this
+	// machine.  The device flow is also used if no local port can be
+	// listened on.  It needs client credentials of the "TVs and Limited
+	// Input devices" type.
+	Device bool
+
+	// DeviceAuthURL is the device authorization endpoint.  Empty means
+	// DefaultDeviceAuthURL.
+	DeviceAuthURL string
+}
+
+// NewFlow returns a Flow for the client credentials in credentialsFile,
+// as downloaded from the google api console, requesting calsync.Scope,
+// along with extraScopes, such as calsync.SheetsScope, and caching the
+// token in tokenFile.
+func NewFlow(credentialsFile, tokenFile string, extraScopes ...string) (*Flow, error) {
+	b, err := ioutil.ReadFile(credentialsFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read client credentials: %v", err)
+	}
+	config, err := google.ConfigFromJSON(b, append([]string{calsync.Scope}, extraScopes...)...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse client credentials %s: %v", credentialsFile, err)
+	}
+	return &Flow{Config: config, TokenFile: tokenFile}, nil
+}
+
+// Client is shorthand for NewFlow followed by Flow.Client.
+func Client(ctx context.Context, credentialsFile, tokenFile string, extraScopes ...string) (*http.Client, error) {
+	f, err := NewFlow(credentialsFile, tokenFile, extraScopes...)
+	if err != nil {
+		return nil, err
+	}
+	return f.Client(ctx)
+}
+
+// Client returns an http client that can be passed to calsync.  It uses
+// the cached token, or else runs Authorize first.  Whenever the token
+// is refreshed, the new one is cached.
+func (f *Flow) Client(ctx context.Context) (*http.Client, error) {
+	tok, err := f.CachedToken()
+	if os.IsNotExist(err) {
+		tok, err = f.Authorize(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	src := &cachingSource{
+		f:    f,
+		src:  f.Config.TokenSource(ctx, tok),
+		last: tok,
+	}
+	return oauth2.NewClient(ctx, src), nil
+}
+
+// Authorize has the user grant access, caches the token and returns
+// it.
+func (f *Flow) Authorize(ctx context.Context) (*oauth2.Token, error) {
+	var tok *oauth2.Token
+	var err error
+	if f.Device {
+		tok, err = f.deviceToken(ctx)
+	} else {
+		tok, err = f.loopbackToken(ctx)
+		if err == errNoListener {
+			tok, err = f.deviceToken(ctx)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = f.saveToken(tok); err != nil {
+		return nil, err
+	}
+	return tok, nil
+}
+
+func (f *Flow) prompt() io.Writer {
+	if f.Prompt == nil {
+		return os.Stderr
+	}
+	return f.Prompt
+}
+
+// cachingSource caches each new token src returns.
+type cachingSource struct {
+	f   *Flow
+	src oauth2.TokenSource
+
+	mu   sync.Mutex
+	last *oauth2.Token
+}
+
+func (s *cachingSource) Token() (*oauth2.Token, error) {
+	tok, err := s.src.Token()
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.last != nil && tok.AccessToken == s.last.AccessToken {
+		return tok, nil
+	}
+	if tok.RefreshToken == "" && s.last != nil {
+		// Refreshes don't always come with a new refresh token.
+		kept := *tok
+		kept.RefreshToken = s.last.RefreshToken
+		tok = &kept
+	}
+	if err = s.f.saveToken(tok); err != nil {
+		return nil, err
+	}
+	s.last = tok
+	return tok, nil
+}
@@ -0,0 +1,262 @@
+package auth
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"golang.org/x/net/context"
+)
+
+// fakeGoogle serves the oauth endpoints the flows use.
+type fakeGoogle struct {
+	mu      sync.Mutex
+	pending int // device token polls to answer with authorization_pending
+}
+
+func (g *fakeGoogle) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	w.Header().Set("Content-Type", "application/json")
+	switch r.URL.Path {
+	case "/device/code":
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"device_code":      "dev",
+			"user_code":        "ABCD-EFGH",
+			"verification_url": "https://www.google.com/device",
+			"expires_in":       60,
+			"interval":         0,
+		})
+	case "/token":
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		if r.Form.Get("device_code") != "" && g.pending > 0 {
+			g.pending--
+			w.WriteHeader(http.StatusPreconditionRequired)
+			w.Write([]byte(`{"error": "authorization_pending"}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token":  "access-" + r.Form.Get("code") + r.Form.Get("device_code"),
+			"refresh_token": "refresh",
+			"expires_in":    3600,
+		})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newTestFlow(t *testing.T, g *fakeGoogle) (*Flow, func()) {
+	srv := httptest.NewServer(g)
+	dir, err := ioutil.TempDir("", "auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &Flow{
+		Config: &oauth2.Config{
+			ClientID: "client",
+			Endpoint: oauth2.Endpoint{AuthURL: srv.URL + "/auth", TokenURL: srv.URL + "/token"},
+			Scopes:   []string{"scope"},
+		},
+		TokenFile:     filepath.Join(dir, "token.json"),
+		Prompt:        ioutil.Discard,
+		DeviceAuthURL: srv.URL + "/device/code",
+	}
+	return f, func() {
+		srv.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// browser plays the part of a user who visits the first URL written to
+// it and grants access.
+type browser struct {
+	t *testing.T
+}
+
+var urlRE = regexp.MustCompile(`http\S+`)
+
+func (b browser) Write(p []byte) (int, error) {
+	u, err := url.Parse(urlRE.FindString(string(p)))
+	if err != nil {
+		b.t.Error(err)
+		return len(p), nil
+	}
+	q := u.Query()
+	go func() {
+		resp, err := http.Get(q.Get("redirect_uri") + "?code=granted&state=" + q.Get("state"))
+		if err != nil {
+			b.t.Error(err)
+			return
+		}
+		resp.Body.Close()
+	}()
+	return len(p), nil
+}
+
+func TestLoopback(t *testing.T) {
+	f, cleanup := newTestFlow(t, &fakeGoogle{})
+	defer cleanup()
+	f.Prompt = browser{t}
+
+	tok, err := f.Authorize(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.AccessToken != "access-granted" {
+		t.Errorf("got access token %q", tok.AccessToken)
+	}
+	cached, err := f.CachedToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cached.AccessToken != tok.AccessToken || cached.RefreshToken != "refresh" {
+		t.Errorf("cached %+v", cached)
+	}
+	fi, err := os.Stat(f.TokenFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := fi.Mode().Perm(); perm != 0600 {
+		t.Errorf("token file has permissions %v", perm)
+	}
+}
+
+func TestDevice(t *testing.T) {
+	f, cleanup := newTestFlow(t, &fakeGoogle{pending: 2})
+	defer cleanup()
+	f.Device = true
+	defer func(d time.Duration) { defaultPollInterval = d }(defaultPollInterval)
+	defaultPollInterval = time.Millisecond
+
+	tok, err := f.Authorize(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.AccessToken != "access-dev" {
+		t.Errorf("got access token %q", tok.AccessToken)
+	}
+}
+
+func TestClientUsesCache(t *testing.T) {
+	f, cleanup := newTestFlow(t, &fakeGoogle{})
+	defer cleanup()
+	if _, err := f.CachedToken(); !os.IsNotExist(err) {
+		t.Fatalf("got %v, want a not exist error", err)
+	}
+	want := &oauth2.Token{AccessToken: "cached", Expiry: time.Now().Add(time.Hour)}
+	if err := f.saveToken(want); err != nil {
+		t.Fatal(err)
+	}
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer api.Close()
+
+	// Authorize would fail, as nothing visits the URL it prompts with.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	client, err := f.Client(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Get(api.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, _ := ioutil.ReadAll(resp.Body)
+	if string(b) != "Bearer cached" {
+		t.Errorf("got authorization %q", b)
+	}
+}
+
+type tokens []*oauth2.Token
+
+func (ts *tokens) Token() (*oauth2.Token, error) {
+	tok := (*ts)[0]
+	if len(*ts) > 1 {
+		*ts = (*ts)[1:]
+	}
+	return tok, nil
+}
+
+func TestCachingSource(t *testing.T) {
+	f, cleanup := newTestFlow(t, &fakeGoogle{})
+	defer cleanup()
+
+	first := &oauth2.Token{AccessToken: "first", RefreshToken: "refresh"}
+	src := &cachingSource{
+		f:    f,
+		src:  &tokens{first, {AccessToken: "second"}},
+		last: first,
+	}
+	for _, want := range []string{"first", "second"} {
+		tok, err := src.Token()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tok.AccessToken != want {
+			t.Errorf("got %q, want %q", tok.AccessToken, want)
+		}
+	}
+
+	cached, err := f.CachedToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cached.AccessToken != "second" || cached.RefreshToken != "refresh" {
+		t.Errorf("cached %+v, want the refreshed token with the old refresh token", cached)
+	}
+}
+
+func TestLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "token.json")
+
+	unlock, err := lock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	locked := make(chan bool)
+	go func() {
+		unlock2, err := lock(path)
+		if err != nil {
+			t.Error(err)
+		} else {
+			unlock2()
+		}
+		locked <- true
+	}()
+	select {
+	case <-locked:
+		t.Fatal("locked twice")
+	case <-time.After(100 * time.Millisecond):
+	}
+	unlock()
+	<-locked
+
+	// A lock left behind by a process that died is broken.
+	ioutil.WriteFile(path+".lock", nil, 0600)
+	old := time.Now().Add(-2 * staleLock)
+	os.Chtimes(path+".lock", old, old)
+	unlock, err = lock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unlock()
+}
@@ -0,0 +1,204 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"golang.org/x/net/context"
+)
+
+// DefaultDeviceAuthURL is google's device authorization endpoint.
+const DefaultDeviceAuthURL = "https://oauth2.googleapis.com/device/code"
+
+// defaultPollInterval is how often the device flow polls for a token,
+// unless google says otherwise.
+var defaultPollInterval = 5 * time.Second
+
+var errNoListener = errors.New("unable to listen on a local port")
+
+// loopbackToken has the user authorize in a browser, which google
+// redirects back to a local port with the authorization code.
+func (f *Flow) loopbackToken(ctx context.Context) (*oauth2.Token, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, errNoListener
+	}
+	defer l.Close()
+
+	b := make([]byte, 16)
+	if _, err = rand.Read(b); err != nil {
+		return nil, err
+	}
+	state := hex.EncodeToString(b)
+
+	cfg := *f.Config
+	cfg.RedirectURL = "http://" + l.Addr().String()
+
+	type result struct {
+		code string
+		err  error
+	}
+	results := make(chan result, 1)
+	go http.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		var res result
+		switch {
+		case q.Get("state") != state:
+			http.Error(w, "unexpected state", http.StatusBadRequest)
+			return
+		case q.Get("error") != "":
+			res.err = fmt.Errorf("authorization failed: %s", q.Get("error"))
+		default:
+			res.code = q.Get("code")
+		}
+		fmt.Fprintln(w, "You may close this window.")
+		select {
+		case results <- res:
+		default:
+		}
+	}))
+
+	fmt.Fprintf(f.prompt(), "Visit this URL in a browser to authorize access to your calendars:\n\n%s\n\n",
+		cfg.AuthCodeURL(state, oauth2.AccessTypeOffline))
+
+	var res result
+	select {
+	case res = <-results:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if res.err != nil {
+		return nil, res.err
+	}
+	tok, err := cfg.Exchange(ctx, res.code)
+	if err != nil {
+		return nil, fmt.Errorf("unable to exchange the authorization code for a token: %v", err)
+	}
+	return tok, nil
+}
+
+// deviceToken has the user authorize on another device, by entering a
+// code google gives us, while we poll for the result.  See RFC 8628.
+func (f *Flow) deviceToken(ctx context.Context) (*oauth2.Token, error) {
+	authURL := f.DeviceAuthURL
+	if authURL == "" {
+		authURL = DefaultDeviceAuthURL
+	}
+	var code struct {
+		DeviceCode      string `json:"device_code"`
+		UserCode        string `json:"user_code"`
+		VerificationURL string `json:"verification_url"`
+		VerificationURI string `json:"verification_uri"`
+		ExpiresIn       int    `json:"expires_in"`
+		Interval        int    `json:"interval"`
+	}
+	err := postForm(ctx, authURL, url.Values{
+		"client_id": {f.Config.ClientID},
+		"scope":     {strings.Join(f.Config.Scopes, " ")},
+	}, &code)
+	if err != nil {
+		return nil, fmt.Errorf("unable to start device authorization: %v", err)
+	}
+	verify := code.VerificationURL
+	if verify == "" {
+		verify = code.VerificationURI
+	}
+	fmt.Fprintf(f.prompt(), "Visit %s on any device and enter the code %s to authorize access to your calendars.\n",
+		verify, code.UserCode)
+
+	interval := time.Duration(code.Interval) * time.Second
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	deadline := time.Now().Add(time.Duration(code.ExpiresIn) * time.Second)
+	for {
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		var tok tokenResponse
+		err := postForm(ctx, f.Config.Endpoint.TokenURL, url.Values{
+			"client_id":     {f.Config.ClientID},
+			"client_secret": {f.Config.ClientSecret},
+			"device_code":   {code.DeviceCode},
+			"grant_type":    {"urn:ietf:params:oauth:grant-type:device_code"},
+		}, &tok)
+		switch e, _ := err.(*oauthError); {
+		case err == nil:
+			return tok.token(), nil
+		case e != nil && e.Code == "authorization_pending":
+		case e != nil && e.Code == "slow_down":
+			interval += 5 * time.Second
+		default:
+			return nil, fmt.Errorf("device authorization failed: %v", err)
+		}
+		if code.ExpiresIn > 0 && time.Now().After(deadline) {
+			return nil, errors.New("device authorization failed: the code expired before it was entered")
+		}
+	}
+}
+
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+func (t *tokenResponse) token() *oauth2.Token {
+	tok := &oauth2.Token{
+		AccessToken:  t.AccessToken,
+		TokenType:    t.TokenType,
+		RefreshToken: t.RefreshToken,
+	}
+	if t.ExpiresIn > 0 {
+		tok.Expiry = time.Now().Add(time.Duration(t.ExpiresIn) * time.Second)
+	}
+	return tok
+}
+
+// oauthError is an error response from an oauth endpoint.
+type oauthError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e *oauthError) Error() string {
+	if e.Description != "" {
+		return e.Code + ": " + e.Description
+	}
+	return e.Code
+}
+
+// postForm posts form to u and decodes the json response into out.
+func postForm(ctx context.Context, u string, form url.Values, out interface{}) error {
+	req, err := http.NewRequest("POST", u, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		e := &oauthError{}
+		if json.NewDecoder(resp.Body).Decode(e) != nil || e.Code == "" {
+			return fmt.Errorf("unexpected status %s", resp.Status)
+		}
+		return e
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	// lockWait is how long to wait for another process to unlock the
+	// token file.
+	lockWait = 10 * time.Second
+
+	// staleLock is how old a lock must be before we assume its process
+	// died without unlocking it.
+	staleLock = time.Minute
+)
+
+// CachedToken returns the token cached in f.TokenFile.  If there is no
+// cached token, the error satisfies os.IsNotExist.
+func (f *Flow) CachedToken() (*oauth2.Token, error) {
+	b, err := ioutil.ReadFile(f.TokenFile)
+	if err != nil {
+		return nil, err
+	}
+	tok := &oauth2.Token{}
+	if err = json.Unmarshal(b, tok); err != nil {
+		return nil, fmt.Errorf("unable to parse token %s: %v", f.TokenFile, err)
+	}
+	return tok, nil
+}
+
+// saveToken caches tok in f.TokenFile.  The file is replaced in one
+// step, so readers never see a partial token.
+func (f *Flow) saveToken(tok *oauth2.Token) error {
+	b, err := json.Marshal(tok)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(f.TokenFile)
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	unlock, err := lock(f.TokenFile)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	tmp, err := ioutil.TempFile(dir, filepath.Base(f.TokenFile)+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(b)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0600)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), f.TokenFile)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("unable to save token %s: %v", f.TokenFile, err)
+	}
+	return nil
+}
+
+// lock takes an exclusive lock on path, shared with other processes,
+// by creating path.lock.  It works the same way on every platform.
+func lock(path string) (unlock func(), err error) {
+	name := path + ".lock"
+	deadline := time.Now().Add(lockWait)
+	for {
+		f, err := os.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(name) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if fi, statErr := os.Stat(name); statErr == nil && time.Since(fi.ModTime()) > staleLock {
+			os.Remove(name)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for %s to be unlocked", path)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
@@ -0,0 +1,74 @@
+package calsync
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// Backend is a destination other than google calendar that Sync,
+// SyncAll, Apply, Plan, Fetch, Verify and PullChanges can work with,
+// planning changes just as they do for google calendar.  See
+// WithBackend.
+//
+// A backend keeps the events synced into each scope, each found by its
+// SrcID, which is unique within a scope.  NewFileBackend is a simple
+// example.
+type Backend interface {
+	// Name names the kind of destination, such as "json file", in
+	// manifests and capabilities.
+	Name() string
+
+	// Fetch returns the events of scope that end after now.  Events
+	// that ended before are left out, as Sync would otherwise delete
+	// them.
+	Fetch(ctx context.Context, scope string, now time.Time) ([]*Event, error)
+
+	// Add adds ev to scope, returning the id the backend gave it, if
+	// any.
+	Add(ctx context.Context, scope string, ev *Event) (string, error)
+
+	// Update replaces the event of scope with the SrcID of ev with ev.
+	Update(ctx context.Context, scope string, ev *Event) error
+
+	// Delete removes the event of scope with the SrcID of ev.  It is
+	// not an error for there to be none.
+	Delete(ctx context.Context, scope string, ev *Event) error
+}
+
+// checkBackend reports the options set on c that a Backend can't
+// honor, as they rely on google calendar.
+func (c cal) checkBackend() error {
+	for _, o := range []struct {
+		set  bool
+		name string
+	}{
+		{c.sandbox, "Sandbox"},
+		{c.calName != "", "CalendarName"},
+		{c.ensure != nil, "EnsureCalendar"},
+		{c.route != nil, "RouteTo"},
+		{c.state != nil, "Incremental"},
+		{c.adoption, "Adopt"},
+		{c.resurrection, "Resurrect"},
+		{c.sealer != nil, "Encrypt"},
+		{c.lockTTL > 0, "CalendarLock"},
+		{c.freeBusy, "CheckFreeBusy"},
+		{c.match != MatchProperties, fmt.Sprintf("MatchBy(%s)", c.match)},
+		{c.deletePolicy == Cancel, fmt.Sprintf("OnDelete(%s)", c.deletePolicy)},
+	} {
+		if o.set {
+			return fmt.Errorf("%s can't be used with a Backend", o.name)
+		}
+	}
+	return nil
+}
+
+// needsGoogle returns an error if c syncs into a Backend, for calls
+// that only work with google calendar.
+func (c cal) needsGoogle(call string) error {
+	if c.backend != nil {
+		return fmt.Errorf("%s only works with google calendar, not a %s Backend", call, c.backend.Name())
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package calsync
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// memBackend is a Backend that keeps events in memory, by scope and
+// SrcID.
+type memBackend struct {
+	events map[string]map[string]*Event
+	ops    []string
+}
+
+func newMemBackend() *memBackend {
+	return &memBackend{events: map[string]map[string]*Event{}}
+}
+
+func (b *memBackend) Name() string { return "memory" }
+
+func (b *memBackend) Fetch(ctx context.Context, scope string, now time.Time) ([]*Event, error) {
+	var events []*Event
+	for _, ev := range b.events[scope] {
+		if ev.End.After(now) {
+			cp := *ev
+			events = append(events, &cp)
+		}
+	}
+	sort.Sort(byStart(events))
+	return events, nil
+}
+
+func (b *memBackend) Add(ctx context.Context, scope string, ev *Event) (string, error) {
+	if b.events[scope] == nil {
+		b.events[scope] = map[string]*Event{}
+	}
+	cp := *ev
+	b.events[scope][ev.SrcID] = &cp
+	b.ops = append(b.ops, "add "+ev.SrcID)
+	return "mem-" + ev.SrcID, nil
+}
+
+func (b *memBackend) Update(ctx context.Context, scope string, ev *Event) error {
+	if _, ok := b.events[scope][ev.SrcID]; !ok {
+		return fmt.Errorf("no event %s", ev.SrcID)
+	}
+	cp := *ev
+	b.events[scope][ev.SrcID] = &cp
+	b.ops = append(b.ops, "update "+ev.SrcID)
+	return nil
+}
+
+func (b *memBackend) Delete(ctx context.Context, scope string, ev *Event) error {
+	delete(b.events[scope], ev.SrcID)
+	b.ops = append(b.ops, "delete "+ev.SrcID)
+	return nil
+}
+
+func TestBackend(t *testing.T) {
+	ctx := context.Background()
+	b := newMemBackend()
+	now := when("2017-04-29T20:00:00-07:00")
+	kept := newSrcEvent("kept", now.Add(time.Hour))
+	changed := newSrcEvent("changed", now.Add(2*time.Hour))
+	removed := newSrcEvent("removed", now.Add(3*time.Hour))
+	opts := []Opt{WithBackend(b), WithNow(func() time.Time { return now })}
+
+	changes, err := Sync(ctx, nil, "scope", []*Event{kept, changed, removed}, opts...)
+	ok(t, err)
+	equals(t, 3, len(changes.Adds))
+	equals(t, "memory", changes.Manifest.Backend)
+	equals(t, "", changes.Manifest.CalendarID)
+
+	changedSrc := *changed
+	changedSrc.Description = "new description"
+	plan, err := Plan(ctx, nil, "scope", []*Event{kept, &changedSrc}, opts...)
+	ok(t, err)
+	equals(t, 1, len(plan.Updates))
+	equals(t, 1, len(plan.Deletes))
+	equals(t, 3, len(b.ops))
+
+	changes, err = Apply(ctx, nil, "scope", plan, opts...)
+	ok(t, err)
+	equals(t, []string{"add " + kept.SrcID, "add " + changed.SrcID, "add " + removed.SrcID,
+		"delete " + removed.SrcID, "update " + changed.SrcID}, b.ops)
+	// Descriptions are kept as the source has them.
+	equals(t, "new description", b.events["scope"][changed.SrcID].Description)
+
+	changes, err = Sync(ctx, nil, "scope", []*Event{kept, &changedSrc}, opts...)
+	ok(t, err)
+	assert(t, changes.empty(), "expected no changes, got %s", changes)
+
+	events, err := Fetch(ctx, nil, "scope", opts...)
+	ok(t, err)
+	equals(t, 2, len(events))
+
+	cs, err := Capabilities(opts...)
+	ok(t, err)
+	equals(t, "memory", cs.Backend)
+	assert(t, !cs.Supports(FeatureIncremental), "expected no incremental support")
+}
+
+func TestBackendErrors(t *testing.T) {
+	ctx := context.Background()
+	b := newMemBackend()
+
+	_, err := Sync(ctx, nil, "scope", nil)
+	assert(t, err != nil, "expected an error without a client or a backend")
+
+	for _, opt := range []Opt{CalendarName("Work"), RouteTo(func(*Event) string { return "work" }), Sandbox()} {
+		_, err = Sync(ctx, nil, "scope", nil, WithBackend(b), opt)
+		assert(t, err != nil && strings.Contains(err.Error(), "can't be used with a Backend"),
+			"expected an error, got %v", err)
+	}
+
+	_, err = Purge(ctx, nil, "scope", WithBackend(b))
+	assert(t, err != nil && strings.Contains(err.Error(), "only works with google calendar"),
+		"expected an error, got %v", err)
+
+	_, err = Sync(ctx, nil, "scope", nil, WithBackend(nil))
+	assert(t, err != nil, "expected an error for a nil backend")
+}
@@ -0,0 +1,120 @@
+package calsync
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// Breaker is a circuit breaker for calls to google calendar, shared by
+// the syncs given it with CircuitBreaker, so that during an outage
+// they fail fast rather than each waiting for its calls to fail one by
+// one:
+//
+//	b := calsync.NewBreaker(5, 10*time.Minute)
+//	for _, user := range users {
+//		changes, err := calsync.Sync(ctx, client, user.scope, user.events, calsync.CircuitBreaker(b))
+//		...
+//	}
+//
+// After threshold consecutive calls fail, the breaker opens, and calls
+// fail with a *BreakerOpenError, without being made, for coolDown.
+// Then one call is let through: if it succeeds, the breaker closes
+// again, and otherwise it stays open for another coolDown.  Calls
+// cancelled by their context don't count.
+//
+// It is safe for concurrent use.
+type Breaker struct {
+	threshold int
+	coolDown  time.Duration
+
+	// now returns the current time.  Tests replace it.
+	now func() time.Time
+
+	mu       sync.Mutex
+	failures int
+	last     error
+	openedAt time.Time
+	// trying is set while the one call let through by an open breaker
+	// is made.
+	trying bool
+}
+
+// NewBreaker returns a closed Breaker that opens after threshold
+// consecutive failures, for coolDown.
+func NewBreaker(threshold int, coolDown time.Duration) *Breaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &Breaker{threshold: threshold, coolDown: coolDown, now: time.Now}
+}
+
+// BreakerOpenError is returned for calls that an open Breaker didn't
+// let through.
+type BreakerOpenError struct {
+	// Failures counts the consecutive failures so far.
+	Failures int
+
+	// Last is the latest of them.
+	Last error
+
+	// Until is when the breaker lets a call through again.
+	Until time.Time
+}
+
+func (e *BreakerOpenError) Error() string {
+	return fmt.Sprintf("not calling google calendar until %s, after %d failures in a row; the latest was: %v",
+		e.Until.Format(time.RFC3339), e.Failures, e.Last)
+}
+
+// Open reports whether b is open, failing calls without making them.
+func (b *Breaker) Open() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.failures >= b.threshold
+}
+
+// allow returns a *BreakerOpenError if b doesn't let a call through
+// now.  A nil Breaker lets every call through.
+func (b *Breaker) allow() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.threshold {
+		return nil
+	}
+	until := b.openedAt.Add(b.coolDown)
+	if b.trying || b.now().Before(until) {
+		return &BreakerOpenError{Failures: b.failures, Last: b.last, Until: until}
+	}
+	b.trying = true
+	return nil
+}
+
+// record counts the outcome of a call that allow let through, made
+// with ctx.
+func (b *Breaker) record(ctx context.Context, err error) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trying = false
+	switch {
+	case err == nil:
+		b.failures = 0
+		b.last = nil
+	case ctx.Err() == context.Canceled:
+		// Not a failure of google calendar.
+	default:
+		b.failures++
+		b.last = err
+		if b.failures >= b.threshold {
+			b.openedAt = b.now()
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package calsync
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestBreaker(t *testing.T) {
+	now := when("2017-04-29T20:00:00-07:00")
+	b := NewBreaker(2, time.Minute)
+	b.now = func() time.Time { return now }
+	ctx := context.Background()
+	fail := errors.New("backend error")
+
+	ok(t, b.allow())
+	b.record(ctx, fail)
+	assert(t, !b.Open(), "expected the breaker to stay closed after one failure")
+	ok(t, b.allow())
+	b.record(ctx, fail)
+	assert(t, b.Open(), "expected the breaker to open after two failures")
+
+	err := b.allow()
+	open, isOpen := err.(*BreakerOpenError)
+	assert(t, isOpen, "expected a *BreakerOpenError, got %v", err)
+	equals(t, 2, open.Failures)
+	equals(t, fail, open.Last)
+	equals(t, now.Add(time.Minute), open.Until)
+
+	// After the cool down, one call is let through at a time.
+	now = now.Add(time.Minute)
+	ok(t, b.allow())
+	assert(t, b.allow() != nil, "expected only one call to be let through")
+	b.record(ctx, fail)
+	assert(t, b.allow() != nil, "expected the breaker to stay open after a failed trial")
+
+	now = now.Add(time.Minute)
+	ok(t, b.allow())
+	b.record(ctx, nil)
+	assert(t, !b.Open(), "expected the breaker to close after a successful trial")
+
+	// Cancelled calls don't count.
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	for i := 0; i < 3; i++ {
+		b.record(cancelled, cancelled.Err())
+	}
+	assert(t, !b.Open(), "expected cancelled calls not to count")
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	ctx := context.Background()
+	now := when("2017-04-29T20:00:00-07:00")
+	b := &flakyBackend{memBackend: newMemBackend(), failures: 100}
+	breaker := NewBreaker(2, time.Hour)
+	opts := []Opt{WithBackend(b), WithNow(func() time.Time { return now }), CircuitBreaker(breaker)}
+	src := []*Event{newSrcEvent("a", now.Add(time.Hour))}
+
+	for i := 0; i < 2; i++ {
+		_, err := Sync(ctx, nil, "scope", src, opts...)
+		assert(t, err != nil, "expected the fetch error")
+	}
+	equals(t, 2, b.fetches)
+
+	// Later syncs fail without calling the backend.
+	_, err := Sync(ctx, nil, "other", src, opts...)
+	_, isOpen := err.(*BreakerOpenError)
+	assert(t, isOpen, "expected a *BreakerOpenError, got %v", err)
+	equals(t, 2, b.fetches)
+
+	_, err = Sync(ctx, nil, "scope", src, WithBackend(b), CircuitBreaker(nil))
+	assert(t, err != nil, "expected an error for a nil breaker")
+}
@@ -1,17 +1,21 @@
 package calsync
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
 
 	calendar "google.golang.org/api/calendar/v3"
+	"google.golang.org/api/googleapi"
 
 	"golang.org/x/net/context"
 )
 
 // cal implements read and write operations against a google calendar.
 type cal struct {
+	planner
+
 	svc *calendar.Service
 
 	// short name to uniquely identify the application syncing events into
@@ -25,104 +29,587 @@ type cal struct {
 	// if this is set, we will will not perform any remove/update/add
 	// operations, but will return success, as if we had.
 	nop bool
+
+	// if this is set, fetch only asks google calendar for events that
+	// changed since the last fetch, using a sync token kept here.
+	state StateStore
+
+	// if customFields is set, extraFields replaces DefaultFetchFields.
+	// See FetchFields.
+	customFields bool
+	extraFields  []string
+
+	// if this is set, it tells the current time, in place of time.Now.
+	// See WithNow.
+	clock func() time.Time
+
+	// the timezone of the calendar, used to interpret all day events.
+	// Loaded by loadLocation.
+	loc *time.Location
+
+	// if this is set, the values of our private extended properties,
+	// other than the one we query by, are encrypted.  See Encrypt.
+	sealer *sealer
+
+	// if this is set, setup looks up the calendar to sync by name.  See
+	// CalendarName.
+	calName string
+
+	// if this is set, setup looks up the calendar to sync by name, and
+	// creates it if it is missing.  See EnsureCalendar.
+	ensure *newCalendar
+
+	// if this is set, descriptions are laid out with it in google
+	// calendar.  See DescriptionLayout.
+	layout *layout
+
+	// if this is set, a LastSyncedFooter naming it is added to synced
+	// descriptions.  See LastSynced.
+	lastSynced string
+
+	// if this is set, a closing delimiter is written after synced
+	// descriptions.  See TrailingComments.
+	trailingComments bool
+
+	// if share is set, the SrcID, and the PrivateProps named by
+	// shareKeys, are also written to shared extended properties.  See
+	// Share.
+	share     bool
+	shareKeys []string
+
+	// how synced events are recognized.  See MatchBy.
+	match MatchStrategy
+
+	// what to do with events that are no longer in the source.
+	deletePolicy DeletePolicy
+
+	// who google calendar emails about our changes.  See SendUpdates.
+	notify Notify
+
+	// if this is set, each operation applied is recorded with it.  See
+	// Audit.
+	auditor AuditWriter
+
+	// if this is set, plans are recorded in it while they are applied,
+	// so that interrupted ones can be finished.  See Journal.
+	journal StateStore
+
+	// if this is set, adds are checked against the free/busy times of
+	// their calendar and attendees.  See CheckFreeBusy.
+	freeBusy bool
+
+	// if this is set, at most this many operations are made, and the
+	// rest are left for a later sync.  See MaxEvents.
+	maxEvents int
+
+	// if this is set, SyncChunked records how far it got in it.  See
+	// Checkpoint.
+	checkpoints StateStore
+
+	// if these are set, only events that start in [from, until) are
+	// fetched from google calendar.  SyncChunked sets them for each
+	// chunk.
+	from, until time.Time
+
+	// if this is set, events are synced into it rather than into
+	// google calendar.  See WithBackend.
+	backend Backend
+
+	// if this is set, calls to google calendar are made on behalf of
+	// this user.  See QuotaUser.
+	quotaUser string
+
+	// if this is set, each fetch, add, update and delete must finish
+	// within it.  See OperationTimeout.
+	opTimeout time.Duration
+
+	// if this is set, calls are made through it.  See CircuitBreaker.
+	breaker *Breaker
+
+	// if this is set, syncs that modify the calendar hold its lock for
+	// their scope.  See WithLock.
+	locker Locker
+
+	// if this is set, syncs that modify the calendar hold a lock event
+	// for their scope that expires after it.  See CalendarLock.
+	lockTTL time.Duration
+
+	// how many times, and after how long a first delay, fetching
+	// calendar events is tried.  Zero means once.  See RetryFetch.
+	fetchAttempts int
+	fetchDelay    time.Duration
+
+	// if this is set, it is told how many of the operations of a plan
+	// were applied, after each one.  Runner sets it, for its status.
+	progress func(done, total int)
+
+	// if this is set, it is told what the upcoming events are after
+	// each sync.  See MirrorTo.
+	mirrorer Mirror
+
+	// if this is set, it is told about the changes made.  See Report.
+	reporter Reporter
+
+	// if this is set, it is called with each plan before it is
+	// applied.  See Confirm.
+	confirm func(*Changes) error
+
+	// if this is set, Sync checks source events with Validate.  See
+	// ValidateSource.
+	validation bool
+
+	// if this is set, Sync claims matching calendar events that no
+	// scope owns.  See Adopt.
+	adoption bool
+
+	// if this is set, Sync restores deleted calendar events rather than
+	// adding new ones.  See Resurrect.
+	resurrection bool
+
+	// if this is set, every write goes to the SandboxCalendar, and
+	// events are tagged with SandboxProp.  See Sandbox.
+	sandbox bool
+
+	// set if ensure found no calendar, and we didn't create one because
+	// of nop.  We then act as if the calendar were empty.
+	missing bool
+
+	// the first error from an Opt that was given a bad argument,
+	// reported by setup.
+	optErr error
 }
 
-func newCal(client *http.Client, scope string) (*cal, error) {
-	svc, err := calendar.New(client)
-	if err != nil {
-		return nil, fmt.Errorf("failed creating service: %v", err)
+// opContext returns the context for one operation, with the deadline
+// set by OperationTimeout, if any.
+func (c cal) opContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.opTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.opTimeout)
+}
+
+// now returns the current time, as told by c.clock if it is set.
+func (c cal) now() time.Time {
+	if c.clock != nil {
+		return c.clock()
+	}
+	return time.Now()
+}
+
+func (c cal) fetchOnce(ctx context.Context, now time.Time) (events []*Event, err error) {
+	ctx, cancel := c.opContext(ctx)
+	defer cancel()
+	if err = c.breaker.allow(); err != nil {
+		return nil, err
+	}
+	defer func() { c.breaker.record(ctx, err) }()
+	if c.backend != nil {
+		return c.backend.Fetch(ctx, c.scope, now)
+	}
+	if c.missing {
+		return nil, nil
+	}
+	if c.route != nil {
+		return c.fetchRouted(ctx, now)
 	}
-	return &cal{
-		svc:   svc,
-		scope: scope,
-		calID: "primary"}, nil
+	return c.fetchCalendar(ctx, now)
 }
 
-func (c cal) fetch(ctx context.Context, now time.Time) ([]*Event, error) {
-	listResult, err := c.svc.Events.List(c.calID).
+// fetchCalendar fetches the upcoming events in scope from c.calID.
+func (c cal) fetchCalendar(ctx context.Context, now time.Time) ([]*Event, error) {
+	if c.state != nil {
+		return c.fetchIncremental(ctx, now)
+	}
+	var events []*Event
+	call := c.withFields(c.listScope(c.svc.Events.List(c.calID))).
 		ShowDeleted(false).
-		Context(ctx).
 		SingleEvents(true).
-		TimeMin(now.Format(time.RFC3339)).
-		PrivateExtendedProperty(c.scope + "=True").
-		Do()
+		TimeMin(now.Format(time.RFC3339))
+	if c.from.After(now) {
+		// TimeMin is compared with the end of events, which may be
+		// the same as their start, and must be before it.
+		call = call.TimeMin(c.from.Add(-time.Second).Format(time.RFC3339))
+	}
+	if !c.until.IsZero() {
+		call = call.TimeMax(c.until.Format(time.RFC3339))
+	}
+	err := call.Pages(ctx, func(page *calendar.Events) error {
+		for _, each := range page.Items {
+			if !c.owns(each) {
+				continue
+			}
+			ev, err := c.parseEvent(each)
+			if err != nil {
+				return fmt.Errorf("parseEvent %q, %v", each.Summary, err)
+			}
+			events = append(events, ev)
+		}
+		return nil
+	})
 	if err != nil {
 		return nil, fmt.Errorf("unable to retrieve google calendar events: %v", err)
 	}
 
-	idKey := c.idKey()
-	var events []*Event
-	for _, each := range listResult.Items {
-		ev, err := parseEvent(each, idKey)
-		if err != nil {
-			return nil, fmt.Errorf("parseEvent %q, %v", each.Summary, err)
+	return events, nil
+}
+
+// confirmPlan asks c.confirm whether to apply changes.
+func (c cal) confirmPlan(changes *Changes) error {
+	if c.confirm == nil || c.nop ||
+		len(changes.Deletes)+len(changes.Updates)+len(changes.Adds) == 0 {
+		return nil
+	}
+	return c.confirm(changes)
+}
+
+// ErrCancelled is returned by Sync, SyncAll, Apply and Purge when
+// their context is cancelled, or its deadline passes, while they are
+// modifying the calendar.  They check the context before each
+// operation, so they stop cleanly in between, returning the changes
+// that were made, with the rest in Changes.Pending.
+var ErrCancelled = errors.New("calsync: cancelled before all changes were made")
+
+// apply executes the deletes, then the updates, then the adds in
+// changes, stopping at the first failure, or once ctx is done, when it
+// returns ErrCancelled.  If the failure is because quota ran out, it
+// returns a *QuotaError.  On failure, changes is left holding the
+// operations that were done, with the rest in changes.Pending.  The
+// outcome of each is recorded in changes.Results, unless c.nop is set.
+// With Journal, changes are journaled until they have all been
+// applied.
+func (c cal) apply(ctx context.Context, changes *Changes) error {
+	deleteOp := "delete"
+	if c.deletePolicy == Cancel {
+		deleteOp = "cancel"
+	}
+	record := func(ev *Event, op string, o Outcome, err error) {
+		if !c.nop {
+			changes.record(ev, op, o, err)
 		}
-		events = append(events, ev)
+	}
+	for _, ev := range changes.Conflicts {
+		record(ev, "conflict", Skipped, nil)
+	}
+	for _, ev := range changes.Orphans {
+		record(ev, "orphan", Skipped, nil)
 	}
 
-	return events, nil
+	var deletes, updates, adds int
+	total := len(changes.Deletes) + len(changes.Updates) + len(changes.Adds)
+	progressed := func() error {
+		if c.progress != nil && !c.nop {
+			c.progress(deletes+updates+adds, total)
+		}
+		return c.logProgress(deletes, updates, adds)
+	}
+	// the event whose operation is being made, if any.
+	var failing *Event
+	var failingOp string
+	err := func() error {
+		if err := c.beginJournal(changes); err != nil {
+			return err
+		}
+		if c.progress != nil && !c.nop {
+			c.progress(0, total)
+		}
+		for _, ev := range changes.Deletes {
+			if ctx.Err() != nil {
+				return ErrCancelled
+			}
+			failing, failingOp = ev, deleteOp
+			if err := c.remove(ctx, ev); err != nil {
+				return err
+			}
+			if err := c.applied(changes, deleteOp, ev.calEventID, ev, nil); err != nil {
+				return err
+			}
+			deletes++
+			failing = nil
+			record(ev, deleteOp, Applied, nil)
+			if err := progressed(); err != nil {
+				return err
+			}
+		}
+		for _, ev := range changes.Updates {
+			if ctx.Err() != nil {
+				return ErrCancelled
+			}
+			failing, failingOp = ev, "update"
+			if err := c.update(ctx, ev); err != nil {
+				return err
+			}
+			if err := c.applied(changes, "update", ev.calEventID, ev.previous, ev); err != nil {
+				return err
+			}
+			updates++
+			failing = nil
+			record(ev, "update", Applied, nil)
+			if err := progressed(); err != nil {
+				return err
+			}
+		}
+		for _, ev := range changes.Adds {
+			if ctx.Err() != nil {
+				return ErrCancelled
+			}
+			failing, failingOp = ev, "add"
+			id, err := c.add(ctx, ev)
+			if err != nil {
+				return err
+			}
+			if err := c.applied(changes, "add", id, nil, ev); err != nil {
+				return err
+			}
+			adds++
+			failing = nil
+			record(ev, "add", Applied, nil)
+			if changes.addedIDs == nil {
+				changes.addedIDs = map[string]string{}
+			}
+			changes.addedIDs[ev.SrcID] = id
+			if err := progressed(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}()
+	if err != nil && ctx.Err() != nil {
+		// The operation in flight noticed first.
+		err = ErrCancelled
+	}
+	if err != nil {
+		remaining := &Changes{
+			Deletes: changes.Deletes[deletes:],
+			Updates: changes.Updates[updates:],
+			Adds:    changes.Adds[adds:],
+		}
+		changes.Deletes = changes.Deletes[:deletes]
+		changes.Updates = changes.Updates[:updates]
+		changes.Adds = changes.Adds[:adds]
+		changes.Pending = remaining
+		for _, ops := range []struct {
+			op     string
+			events []*Event
+		}{{deleteOp, remaining.Deletes}, {"update", remaining.Updates}, {"add", remaining.Adds}} {
+			for _, ev := range ops.events {
+				record(ev, ops.op, Skipped, nil)
+			}
+		}
+		if failing != nil {
+			record(failing, failingOp, Failed, err)
+		}
+		if isRateLimited(err) {
+			done := &Changes{
+				Deletes: changes.Deletes,
+				Updates: changes.Updates,
+				Adds:    changes.Adds,
+				Undo:    changes.Undo,
+			}
+			return c.quotaError(err, done, remaining)
+		}
+		return err
+	}
+	return c.endJournal()
 }
 
-func (c cal) remove(ctx context.Context, ev *Event) error {
+func (c cal) remove(ctx context.Context, ev *Event) (err error) {
 	if c.nop {
 		return nil
 	}
-	err := c.svc.Events.Delete(c.calID, ev.calEventID).
-		Context(ctx).
-		Do()
+	ctx, cancel := c.opContext(ctx)
+	defer cancel()
+	if err = c.breaker.allow(); err != nil {
+		return err
+	}
+	defer func() { c.breaker.record(ctx, err) }()
+	if c.backend != nil {
+		return c.backend.Delete(ctx, c.scope, ev)
+	}
+	if c.deletePolicy == Cancel {
+		return c.cancel(ctx, ev)
+	}
+	call := c.svc.Events.Delete(c.calendarOf(ev), ev.calEventID)
+	if send := c.notify.sendUpdates(); send != "" {
+		call = call.SendUpdates(send)
+	}
+	err = call.Context(ctx).Do()
+	if isNotFound(err) || isGone(err) {
+		// Already deleted, which is what we wanted.
+		return nil
+	}
+	if isRateLimited(err) {
+		return err
+	}
 	if err != nil {
-		return fmt.Errorf("deleting %s: %v", ev.calEventID, err)
+		return &opError{fmt.Sprintf("deleting %s", ev.calEventID), err}
 	}
 	return nil
 }
 
-func (c cal) update(ctx context.Context, ev *Event) error {
+func (c cal) update(ctx context.Context, ev *Event) (err error) {
 	if c.nop {
 		return nil
 	}
+	ctx, cancel := c.opContext(ctx)
+	defer cancel()
+	if err = c.breaker.allow(); err != nil {
+		return err
+	}
+	defer func() { c.breaker.record(ctx, err) }()
+	if c.backend != nil {
+		// Backends keep descriptions as the source has them, without
+		// delimiters.
+		written := *ev
+		written.Description = parseDescription(ev.Description).suffix
+		return c.backend.Update(ctx, c.scope, &written)
+	}
 	calEvent := c.makeCalEvent(ev)
-	_, err := c.svc.Events.Update(c.calID, ev.calEventID, calEvent).
-		Context(ctx).
-		Do()
+	send := c.notify.sendUpdates()
+	if patch := c.patchFor(ev, calEvent); patch != nil {
+		call := c.svc.Events.Patch(c.calendarOf(ev), ev.calEventID, patch)
+		if send != "" {
+			call = call.SendUpdates(send)
+		}
+		_, err = call.Context(ctx).Do()
+	} else {
+		c.preserveFields(calEvent, ev.raw)
+		call := c.svc.Events.Update(c.calendarOf(ev), ev.calEventID, calEvent)
+		if send != "" {
+			call = call.SendUpdates(send)
+		}
+		_, err = call.Context(ctx).Do()
+	}
+	if isRateLimited(err) {
+		return err
+	}
 	if err != nil {
-		return fmt.Errorf("update %q: %v", ev.Title, err)
+		return &opError{fmt.Sprintf("update %q", ev.Title), err}
 	}
 	return nil
 }
 
-func (c cal) add(ctx context.Context, ev *Event) error {
+// add adds ev to google calendar, returning the id it was given.
+func (c cal) add(ctx context.Context, ev *Event) (id string, err error) {
 	if c.nop {
-		return nil
+		return "", nil
+	}
+	ctx, cancel := c.opContext(ctx)
+	defer cancel()
+	if err = c.breaker.allow(); err != nil {
+		return "", err
+	}
+	defer func() { c.breaker.record(ctx, err) }()
+	if c.backend != nil {
+		return c.backend.Add(ctx, c.scope, ev)
 	}
 	calEvent := c.makeCalEvent(ev)
-	_, err := c.svc.Events.Insert(c.calID, calEvent).
-		Context(ctx).
-		Do()
+	var added *calendar.Event
+	if c.match == MatchICalUID {
+		// Imports never send updates.
+		added, err = c.svc.Events.Import(c.calendarOf(ev), calEvent).Context(ctx).Do()
+	} else {
+		call := c.svc.Events.Insert(c.calendarOf(ev), calEvent)
+		if send := c.notify.sendUpdates(); send != "" {
+			call = call.SendUpdates(send)
+		}
+		added, err = call.Context(ctx).Do()
+	}
+	if isRateLimited(err) {
+		return "", err
+	}
 	if err != nil {
-		return fmt.Errorf("insert %q: %v", ev.Title, err)
+		return "", &opError{fmt.Sprintf("insert %q", ev.Title), err}
 	}
-	return nil
+	return added.Id, nil
 }
 
 func (c cal) makeCalEvent(ev *Event) *calendar.Event {
+	synced := ev
+	if ev.syncedSource != nil {
+		synced = ev.syncedSource
+	}
+	props := map[string]string{}
+	for k, v := range ev.PrivateProps {
+		props[k] = c.sealEventProp(ev.SrcID, k, v)
+	}
+	if c.sandbox {
+		props[SandboxProp] = "True"
+	}
+	var uid string
+	if c.match == MatchICalUID {
+		uid = icalUID(c.scope, ev.SrcID)
+	} else {
+		props[c.scope] = "True"
+		for k, v := range c.idProps(ev.SrcID) {
+			props[k] = v
+		}
+		props[c.hashKey()] = c.sealEventProp(ev.SrcID, c.hashKey(), c.contentHash(synced))
+	}
+	guests := ev.guestPermissions()
 	return &calendar.Event{
+		ICalUID:     uid,
 		Summary:     ev.Title,
 		Location:    ev.Where,
-		Description: ev.exportedDescription(),
+		Description: c.exportedDescription(ev),
+
+		Start:     c.formatEventTime(ev.Start, ev.AllDay),
+		End:       c.formatEventTime(ev.End, ev.AllDay),
+		Attendees: makeAttendees(ev.Attendees),
+		Status:    string(ev.status()),
+		Source:    makeSource(ev),
+
+		GuestsCanModify:         guests.canModify,
+		GuestsCanInviteOthers:   &guests.canInviteOthers,
+		GuestsCanSeeOtherGuests: &guests.canSeeOtherGuests,
 
-		Start: &calendar.EventDateTime{
-			DateTime: ev.Start.Format(time.RFC3339),
-		},
-		End: &calendar.EventDateTime{
-			DateTime: ev.End.Format(time.RFC3339),
-		},
 		ExtendedProperties: &calendar.EventExtendedProperties{
-			Private: map[string]string{
-				c.scope:   "True",
-				c.idKey(): ev.SrcID,
-			},
+			Private: props,
+			Shared:  c.sharedProps(ev),
 		},
 	}
 }
 
+// exportedDescription returns the description of ev, as written to
+// google calendar.
+func (c cal) exportedDescription(ev *Event) string {
+	if c.layout == nil {
+		return ev.exportedDescription(c.trailingComments)
+	}
+	return c.layout.export(ev, c.now(), c.trailingComments)
+}
+
+// opError is an error from google calendar for one operation, prefixed
+// with what the operation was.
+type opError struct {
+	what string
+	err  error
+}
+
+func (e *opError) Error() string {
+	return e.what + ": " + e.err.Error()
+}
+
+// permanent reports whether err, from an operation, will happen again
+// however often the operation is retried, because google calendar
+// refused the request itself, rather than being unavailable, rate
+// limiting us, or not accepting our credentials.
+func permanent(err error) bool {
+	if oe, ok := err.(*opError); ok {
+		err = oe.err
+	}
+	e, ok := err.(*googleapi.Error)
+	if !ok || e.Code < 400 || e.Code >= 500 || isRateLimited(e) || isInsufficientScope(e) {
+		return false
+	}
+	return e.Code != http.StatusUnauthorized && e.Code != http.StatusRequestTimeout
+}
+
+// isNotFound reports whether err means the event does not exist.
+func isNotFound(err error) bool {
+	e, ok := err.(*googleapi.Error)
+	return ok && e.Code == http.StatusNotFound
+}
+
 func (c cal) idKey() string { return c.scope + "ID" }
+
+func (c cal) hashKey() string { return c.scope + "Hash" }
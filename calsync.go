@@ -4,7 +4,9 @@ Package calsync helps sync events into a Google Calendar.
 Syncing the same set of events into a google calendar a second time
 will have no effect if the events have not been modified in google
 calendar.  If the events have been modified in google calendar and
-then are imported again, they will be overwritten, in general.
+then are imported again, the calendar edits are kept and the events
+are reported in Changes.Conflicts, unless the Force option is used.
+See ConflictPolicy.
 
 After you sync events into a google calendar, if you do another sync
 with the same scope, and you don't include the same events as
@@ -27,13 +29,17 @@ the form <scope>ID=<srcID>.  The first private property allows
 us to query for all matching events in subsequent syncs.  The second
 private propery lets us match up srcEvents with google calendar events
 in subsequent syncs so we can properly add/update/delete as
-appropriate.
+appropriate.  A third private property, of the form <scope>Hash=<hash>,
+records what we wrote so we can tell when an event was edited in
+google calendar afterwards.  See ConflictPolicy.  The Encrypt option
+keeps the values of the second and third properties private.
 */
 package calsync
 
 import (
 	"fmt"
 	"net/http"
+	"sort"
 	"strings"
 	"time"
 
@@ -58,23 +64,100 @@ const (
 )
 
 // Changes represents a set of changes that were made as the result of
-// an Sync call.
+// an Sync call.  Sync lists the events of each kind of operation in
+// order of start time, then of SrcID.
 type Changes struct {
 	Deletes, Updates, Adds []*Event
+
+	// Conflicts holds events that were left alone because they were
+	// edited in google calendar since they were last synced.  See
+	// ConflictPolicy.
+	Conflicts []*Event
+
+	// Adopted holds events that were already in google calendar, not
+	// synced by any scope, and that were claimed for source events with
+	// the same title and start.  See Adopt.
+	Adopted []*Event
+
+	// Orphans holds events that are no longer in the source, but that
+	// were left alone rather than deleted, for a human to review.  See
+	// ReportOrphans.
+	Orphans []*Event
+
+	// Undo records the operations that were made, in order, so that
+	// Rollback can revert them.  It is empty with Nop, and in plans
+	// that haven't been applied.
+	Undo []*UndoOp
+
+	// Results records the outcome of each operation, by SrcID.  It is
+	// nil with Nop, and in plans that haven't been applied.
+	Results SyncResult
+
+	// addedIDs maps the SrcID of each event added to the google
+	// calendar id it was given.
+	addedIDs map[string]string
+
+	// Pending holds the operations that were not made because Sync,
+	// SyncAll, Apply or Purge failed partway through making them,
+	// starting with the one that failed.  Deletes, Updates and Adds
+	// then hold only those that were made.  It is nil otherwise.
+	Pending *Changes
+
+	// Warnings holds warnings about the operations, which were made
+	// all the same.  See CheckFreeBusy.
+	Warnings []*Warning
+
+	// Overflow holds the operations that were not made because of
+	// MaxEvents, which a later sync will make.  It is nil otherwise.
+	Overflow *Changes
+
+	// Manifest records the configuration of the Sync or Apply that
+	// returned these changes.  It is nil for plans built by hand.
+	Manifest *Manifest
 }
 
+// minSeries is how many operations of one kind must share a title
+// before String groups them as a series.
+const minSeries = 3
+
+// String lists the changes, one per line.  Operations of the same kind
+// on at least minSeries events with the same title, such as the
+// occurrences of a recurring class, are listed as one line for the
+// series, followed by an indented line for each occurrence.
 func (c *Changes) String() string {
 	var lines []string
-	for _, ev := range c.Deletes {
-		lines = append(lines, fmt.Sprintf("Delete %s", ev))
+	lines = appendOps(lines, "Delete", c.Deletes)
+	lines = appendOps(lines, "Update", c.Updates)
+	lines = appendOps(lines, "Add", c.Adds)
+	lines = appendOps(lines, "Conflict", c.Conflicts)
+	lines = appendOps(lines, "Adopt", c.Adopted)
+	lines = appendOps(lines, "Orphan", c.Orphans)
+	for _, w := range c.Warnings {
+		lines = append(lines, fmt.Sprintf("Warning %s", w))
 	}
-	for _, ev := range c.Updates {
-		lines = append(lines, fmt.Sprintf("Update %s", ev))
+	return strings.Join(lines, "\n")
+}
+
+// appendOps appends the lines describing the op operations on events
+// to lines.  Series are listed where their first occurrence is.
+func appendOps(lines []string, op string, events []*Event) []string {
+	series := map[string][]*Event{}
+	for _, ev := range events {
+		series[ev.Title] = append(series[ev.Title], ev)
 	}
-	for _, ev := range c.Adds {
-		lines = append(lines, fmt.Sprintf("Add %s", ev))
+	for _, ev := range events {
+		occurrences := series[ev.Title]
+		switch {
+		case len(occurrences) < minSeries:
+			lines = append(lines, fmt.Sprintf("%s %s", op, ev))
+		case occurrences[0] == ev:
+			lines = append(lines, fmt.Sprintf("%s %d occurrences of '%s'", op, len(occurrences), ev.Title))
+			for _, o := range occurrences {
+				lines = append(lines, fmt.Sprintf("    %s", o.Start.Format("2006/01/02")))
+			}
+		}
 	}
-	return strings.Join(lines, "\n")
+	return lines
 }
 
 // Sync synchronizes srcEvents into a google calendar.  See the package
@@ -86,91 +169,613 @@ func (c *Changes) String() string {
 //
 // scope is described in the package comments.  It should be
 // short and unique.
+//
+// If Sync fails partway through modifying the calendar, it returns the
+// changes it made along with the error, with the operations it didn't
+// make in Changes.Pending.  It returns nil changes for errors before
+// anything was modified.
 func Sync(
 	ctx context.Context,
 	client *http.Client,
 	scope string,
 	srcEvents []*Event,
 	opts ...Opt) (*Changes, error) {
-	now := time.Now()
-
-	if len(scope) > MaxScopeLen {
-		return nil, fmt.Errorf("scope %q is too long.  The maximum supported length is %d",
-			scope, MaxScopeLen)
+	c, err := setup(ctx, client, scope, opts)
+	if err != nil {
+		return nil, err
+	}
+	now := c.now()
+	if c.validation {
+		if err = Validate(srcEvents); err != nil {
+			return nil, err
+		}
 	}
 
-	c, err := newCal(client, scope)
+	unlock, err := c.lock(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed creating cal: %v", err)
+		return nil, err
 	}
-	for _, o := range opts {
-		o(c)
+	defer unlock()
+	resumed, err := c.resumeJournal(ctx, now)
+	if err != nil {
+		return resumed.orNil(), err
 	}
 
 	calEvents, err := c.fetch(ctx, now)
+	if err != nil {
+		return resumed.orNil(), err
+	}
 
-	changes := getOperations(now, calEvents, srcEvents)
-	for _, ev := range changes.Deletes {
-		if err = c.remove(ctx, ev); err != nil {
-			return nil, err
+	var adopted []*Event
+	if c.adoption {
+		if adopted, err = c.adopt(ctx, now, calEvents, srcEvents); err != nil {
+			return resumed.orNil(), err
+		}
+		calEvents = append(calEvents, adopted...)
+	}
+	changes, err := c.getOperations(now, calEvents, srcEvents)
+	if err != nil {
+		return resumed.orNil(), err
+	}
+	changes.Adopted = adopted
+	if c.resurrection {
+		if err = c.resurrect(ctx, now, changes); err != nil {
+			return resumed.orNil(), err
 		}
 	}
+	deferOps(changes, c.budget())
+	if err = c.warnBusy(ctx, calEvents, changes); err != nil {
+		return resumed.orNil(), err
+	}
+	if err = c.confirmPlan(changes); err != nil {
+		return resumed.orNil(), err
+	}
+	err = c.apply(ctx, changes)
+	if err == nil {
+		err = c.mirror(calEvents, changes)
+	}
+	changes.include(resumed)
+	changes.Manifest = c.manifest("sync", now)
+	if err != nil {
+		return changes, err
+	}
+	if err = c.report(ctx, changes); err != nil {
+		return changes, err
+	}
+	return changes, nil
+}
 
-	for _, u := range changes.Updates {
-		if err = c.update(ctx, u); err != nil {
+// SyncAll is like calling Sync for each scope in sources, with the
+// events for that scope, but pays the cost of setting up only once.
+// It plans every scope before modifying anything, so an error in
+// planning, such as a ConflictError, leaves every scope alone.  Plans
+// are then applied in order of scope.
+//
+// It returns the changes for each scope.  If applying a plan fails, it
+// returns the changes for the scopes that were applied, and for the
+// scope that failed partway, with its Changes.Pending set, along with
+// the error, which is a *QuotaError if quota ran out.
+func SyncAll(
+	ctx context.Context,
+	client *http.Client,
+	sources map[string][]*Event,
+	opts ...Opt) (map[string]*Changes, error) {
+	var scopes []string
+	for scope := range sources {
+		if err := checkScope(scope); err != nil {
 			return nil, err
 		}
+		scopes = append(scopes, scope)
+	}
+	sort.Strings(scopes)
+	if len(scopes) == 0 {
+		return map[string]*Changes{}, nil
 	}
 
-	for _, ev := range changes.Adds {
-		if err := c.add(ctx, ev); err != nil {
-			return nil, err
+	base, err := setup(ctx, client, scopes[0], opts)
+	if err != nil {
+		return nil, err
+	}
+	now := base.now()
+	unlock, err := base.lockAll(ctx, scopes)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	cals := map[string]*cal{}
+	plans := map[string]*Changes{}
+	resumed := map[string]*Changes{}
+	fetched := map[string][]*Event{}
+	for _, scope := range scopes {
+		c := *base
+		c.scope = scope
+		if c.validation {
+			if err = Validate(sources[scope]); err != nil {
+				return resumedOnly(resumed), fmt.Errorf("scope %q: %v", scope, err)
+			}
+		}
+		if resumed[scope], err = c.resumeJournal(ctx, now); err != nil {
+			return resumedOnly(resumed), fmt.Errorf("scope %q: %v", scope, err)
 		}
+		calEvents, err := c.fetch(ctx, now)
+		if err != nil {
+			return resumedOnly(resumed), fmt.Errorf("scope %q: %v", scope, err)
+		}
+		fetched[scope] = calEvents
+		plans[scope], err = c.getOperations(now, calEvents, sources[scope])
+		if err != nil {
+			return resumedOnly(resumed), fmt.Errorf("scope %q: %v", scope, err)
+		}
+		if c.resurrection {
+			if err = c.resurrect(ctx, now, plans[scope]); err != nil {
+				return resumedOnly(resumed), fmt.Errorf("scope %q: %v", scope, err)
+			}
+		}
+		cals[scope] = &c
 	}
-	return changes, nil
+
+	budget := base.budget()
+	for _, scope := range scopes {
+		budget = deferOps(plans[scope], budget)
+		if err = cals[scope].warnBusy(ctx, fetched[scope], plans[scope]); err != nil {
+			return resumedOnly(resumed), fmt.Errorf("scope %q: %v", scope, err)
+		}
+	}
+	for _, scope := range scopes {
+		if err = cals[scope].confirmPlan(plans[scope]); err != nil {
+			return resumedOnly(resumed), fmt.Errorf("scope %q: %v", scope, err)
+		}
+	}
+
+	all := map[string]*Changes{}
+	for _, scope := range scopes {
+		c := cals[scope]
+		err = c.apply(ctx, plans[scope])
+		if err == nil {
+			err = c.mirror(fetched[scope], plans[scope])
+		}
+		plans[scope].include(resumed[scope])
+		plans[scope].Manifest = c.manifest("sync", now)
+		all[scope] = plans[scope]
+		if err != nil {
+			return all, err
+		}
+		if err = c.report(ctx, plans[scope]); err != nil {
+			return all, fmt.Errorf("scope %q: %v", scope, err)
+		}
+	}
+	return all, nil
+}
+
+// Apply executes plan against a google calendar.  This lets a plan be
+// computed ahead of time, for example by Sync with Nop, then reviewed
+// or edited, and only executed once approved.  A plan may also be
+// constructed by hand.
+//
+// Before executing anything, Apply fetches the upcoming events in
+// scope and reconciles plan against them, matching events by google
+// calendar id or else by SrcID.  Operations whose target already
+// reflects the desired state are skipped, so re-applying a plan that
+// was partially executed, for example after a crash, finishes the
+// remaining work without inserting duplicates or deleting twice:
+//
+//   - a delete is skipped if the event is already gone
+//   - an update is skipped if the event already has the new content
+//   - an add is skipped if an equal event exists, and becomes an
+//     update if a different event with the same SrcID exists
+//
+// Updates keep any comment the calendar user added before the
+// delimiter.  Plans read with ReadPlan are also checked for staleness:
+// if an event to be deleted or updated changed in google calendar since
+// the plan was made, nothing is modified, and the error is a
+// *StalePlanError.  A plan for another scope is an error.
+//
+// Apply returns the changes that were actually executed.  If it fails
+// partway through, it returns those along with the error, with the
+// rest of the plan in Changes.Pending.  If quota ran out, the error is
+// a *QuotaError, from which the rest of the plan can be resumed.
+func Apply(
+	ctx context.Context,
+	client *http.Client,
+	scope string,
+	plan *Changes,
+	opts ...Opt) (*Changes, error) {
+	if plan.Manifest != nil && plan.Manifest.Scope != scope {
+		return nil, fmt.Errorf("plan is for scope %q, not %q", plan.Manifest.Scope, scope)
+	}
+	c, err := setup(ctx, client, scope, opts)
+	if err != nil {
+		return nil, err
+	}
+	started := c.now()
+	unlock, err := c.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	resumed, err := c.resumeJournal(ctx, started)
+	if err != nil {
+		return resumed.orNil(), err
+	}
+
+	calEvents, err := c.fetch(ctx, started)
+	if err != nil {
+		return resumed.orNil(), err
+	}
+	if plan, err = c.reconcilePlan(plan, calEvents); err != nil {
+		return resumed.orNil(), err
+	}
+	deferOps(plan, c.budget())
+	if err = c.warnBusy(ctx, calEvents, plan); err != nil {
+		return resumed.orNil(), err
+	}
+	if err = c.confirmPlan(plan); err != nil {
+		return resumed.orNil(), err
+	}
+
+	err = c.apply(ctx, plan)
+	if err == nil {
+		err = c.mirror(calEvents, plan)
+	}
+	plan.include(resumed)
+	plan.Manifest = c.manifest("apply", started)
+	if err != nil {
+		return plan, err
+	}
+	if err = c.report(ctx, plan); err != nil {
+		return plan, err
+	}
+	return plan, nil
 }
 
 // Fetch fetches all upcoming events for a given scope
 func Fetch(ctx context.Context, client *http.Client, scope string, opts ...Opt) (
 	[]*Event, error) {
-	c, err := newCal(client, scope)
+	c, err := setup(ctx, client, scope, opts)
+	if err != nil {
+		return nil, err
+	}
+	return c.fetch(ctx, c.now())
+}
+
+// setup returns a cal for scope, configured with opts and ready to
+// fetch.
+func setup(ctx context.Context, client *http.Client, scope string, opts []Opt) (*cal, error) {
+	if err := checkScope(scope); err != nil {
+		return nil, err
+	}
+	c, err := configure(client, scope, opts)
 	if err != nil {
-		return nil, fmt.Errorf("failed creating cal: %v", err)
+		return nil, err
+	}
+	if c.sandbox {
+		c.useSandbox()
+	}
+	if c.lastSynced != "" {
+		if err = c.addLastSynced(); err != nil {
+			return nil, err
+		}
+	}
+	if err = c.checkOpts(); err != nil {
+		return nil, err
+	}
+	if c.backend != nil {
+		c.calID = ""
+		c.loc = time.UTC
+		return c, nil
 	}
+	if err = c.findCalendar(ctx); err != nil {
+		return nil, err
+	}
+	if err = c.ensureCalendar(ctx); err != nil {
+		return nil, err
+	}
+	c.routeDefault()
+	if err = c.loadLocation(ctx); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// configure returns a cal for scope with opts applied and a service to
+// call google calendar with, without checking scope or looking anything
+// up, for calls that can't use all of setup.
+func configure(client *http.Client, scope string, opts []Opt) (*cal, error) {
+	// Only a Backend can do without a client.  The service is made
+	// after the options are applied, in case WithService gave one.
+	c := &cal{scope: scope, calID: "primary"}
 	for _, o := range opts {
 		o(c)
 	}
-	return c.fetch(ctx, time.Now())
+	if c.optErr != nil {
+		return nil, c.optErr
+	}
+	var err error
+	if c.svc == nil && client != nil {
+		if c.svc, err = calendar.New(client); err != nil {
+			return nil, fmt.Errorf("failed creating cal: failed creating service: %v", err)
+		}
+	}
+	if err = c.useQuotaUser(client); err != nil {
+		return nil, err
+	}
+	if c.backend == nil && c.svc == nil {
+		return nil, fmt.Errorf("a client is needed to sync into google calendar")
+	}
+	return c, nil
+}
+
+// checkOpts returns an error if c's options can't be used together,
+// which setup and Capabilities both refuse.
+func (c cal) checkOpts() error {
+	if c.match == MatchICalUID && (c.state != nil || c.adoption || c.deletePolicy == Cancel) {
+		return fmt.Errorf("MatchBy(%s) can't be combined with Incremental, Adopt or OnDelete(%s)", c.match, Cancel)
+	}
+	if c.backend != nil {
+		return c.checkBackend()
+	}
+	return nil
+}
+
+func checkScope(scope string) error {
+	if len(scope) > MaxScopeLen {
+		return fmt.Errorf("scope %q is too long.  The maximum supported length is %d",
+			scope, MaxScopeLen)
+	}
+	return nil
+}
+
+// planner holds the options that affect how changes are computed.
+type planner struct {
+	conflictPolicy ConflictPolicy
+
+	// if this is set, it decides what to do with edited events, in
+	// place of conflictPolicy.
+	resolver ConflictResolver
+
+	// if this is set, it replaces Event.equal.  See EqualFunc.
+	equalFunc func(a, b *Event) bool
+
+	// if this is set, descriptions are compared after removing HTML
+	// and formatting.  See NormalizeDescriptions.
+	normalizeDescriptions bool
+
+	// start and end times that differ by no more than this are
+	// treated as the same.  See TimeTolerance.
+	timeTolerance time.Duration
+
+	// if this is set, event times are written with their timezone, and
+	// a change to the local time or timezone of an event is a change,
+	// even if the instant is the same.  See WallClock.
+	wallClock bool
+
+	// calendar side fields that updates must not overwrite.
+	preserve []Field
+
+	// if this is set, source attendees are listed in descriptions
+	// rather than invited.  See PrivateCopies.
+	privateCopies bool
+
+	// if this is set, it returns the calendar each source event belongs
+	// in.  See RouteTo.
+	route func(*Event) string
+
+	// limits on how many synced events a plan may delete.  See
+	// MaxDeletes and MaxDeleteFraction.
+	limitDeletes        bool
+	maxDeletes          int
+	limitDeleteFraction bool
+	maxDeleteFraction   float64
+
+	// if this is set, events that are no longer in the source are
+	// reported as orphans rather than deleted.  See ReportOrphans.
+	reportOrphans bool
 }
 
+// getOperations computes changes using the default options.
 func getOperations(now time.Time, calEvents, srcEvents []*Event) *Changes {
+	changes, _ := planner{}.getOperations(now, calEvents, srcEvents)
+	return changes
+}
+
+func (p planner) getOperations(now time.Time, calEvents, srcEvents []*Event) (*Changes, error) {
 	changes := Changes{}
+	if p.privateCopies {
+		srcEvents = privateCopies(srcEvents)
+	}
+	if p.route != nil {
+		srcEvents = p.routed(srcEvents)
+	}
 
 	srcMap := map[string]*Event{}
+	inSource := map[string]bool{}
 	for _, ev := range srcEvents {
 		if ev.End.Before(now) {
 			continue
 		}
-		srcMap[ev.SrcID] = ev
+		srcMap[p.eventKey(ev)] = ev
+		inSource[ev.SrcID] = true
 	}
 
 	for _, calEv := range calEvents {
-		srcEv, ok := srcMap[calEv.SrcID]
-		if ok {
-			if !srcEv.equal(calEv) {
+		srcEv, ok := srcMap[p.eventKey(calEv)]
+		delete(srcMap, p.eventKey(calEv))
+		if ok && p.equal(srcEv, calEv) {
+			if p.edited(calEv) {
+				// The source took on the calendar edits, for example
+				// via PullChanges.  Record them as synced.
 				changes.Updates = append(changes.Updates, calEv.newUpdate(srcEv))
 			}
-			delete(srcMap, calEv.SrcID)
-		} else {
+			continue
+		}
+		if p.edited(calEv) && p.resolver != nil {
+			if err := p.resolve(&changes, srcEv, calEv); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if p.edited(calEv) && p.conflictPolicy != PreferSource &&
+			(ok || p.conflictPolicy != PreferCalendar) {
+			changes.Conflicts = append(changes.Conflicts, calEv)
+			continue
+		}
+		switch {
+		case ok:
+			changes.Updates = append(changes.Updates, calEv.newUpdate(srcEv))
+		case p.reportOrphans && !inSource[calEv.SrcID]:
+			// With RouteTo, an event that is in the source, but moved
+			// to another calendar, is still deleted.
+			changes.Orphans = append(changes.Orphans, calEv)
+		default:
 			changes.Deletes = append(changes.Deletes, calEv)
 		}
 	}
+	if len(changes.Conflicts) != 0 && p.conflictPolicy == FailOnConflict {
+		return nil, &ConflictError{Events: changes.Conflicts}
+	}
+	if err := p.checkDeletes(&changes, len(calEvents)); err != nil {
+		return nil, err
+	}
 
 	for _, srcEv := range srcMap {
+		if srcEv.status() == EventCancelled {
+			// There is nothing in google calendar to cancel.
+			continue
+		}
 		changes.Adds = append(changes.Adds, srcEv)
 	}
 
-	return &changes
+	for _, events := range [][]*Event{changes.Deletes, changes.Updates, changes.Adds, changes.Conflicts, changes.Orphans} {
+		sort.Sort(byStart(events))
+	}
+	return &changes, nil
+}
+
+// byStart orders events by start time, then by SrcID, so that plans
+// list operations in the same order every time.
+type byStart []*Event
+
+func (s byStart) Len() int { return len(s) }
+func (s byStart) Less(i, j int) bool {
+	if !s[i].Start.Equal(s[j].Start) {
+		return s[i].Start.Before(s[j].Start)
+	}
+	return s[i].SrcID < s[j].SrcID
+}
+func (s byStart) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+
+// equal reports whether the source event srcEv and the calendar event
+// calEv have the same content, as far as the planner is concerned.
+func (p planner) equal(srcEv, calEv *Event) bool {
+	if p.normalizeDescriptions {
+		src, cal := *srcEv, *calEv
+		src.Description = p.syncedDescription(srcEv)
+		cal.Description = p.syncedDescription(calEv)
+		srcEv, calEv = &src, &cal
+	}
+	if p.preserves(FieldLocation) || p.preserves(FieldAttendees) || p.timeTolerance > 0 {
+		kept := *calEv
+		if p.preserves(FieldLocation) {
+			kept.Where = srcEv.Where
+		}
+		if p.preserves(FieldAttendees) {
+			kept.Attendees = srcEv.Attendees
+		}
+		if !srcEv.AllDay && p.tolerates(srcEv.Start, calEv.Start) {
+			kept.Start = srcEv.Start
+		}
+		if !srcEv.AllDay && p.tolerates(srcEv.End, calEv.End) {
+			kept.End = srcEv.End
+		}
+		calEv = &kept
+	}
+	if p.equalFunc != nil {
+		a, b := *srcEv, *calEv
+		a.Description, a.DescriptionHTML = a.syncedDescription(), false
+		b.Description, b.DescriptionHTML = b.syncedDescription(), false
+		if !p.equalFunc(&a, &b) {
+			return false
+		}
+	} else if !srcEv.equal(calEv) {
+		return false
+	}
+	if p.wallClock && !srcEv.AllDay {
+		return sameWallClock(srcEv.Start, calEv.Start) &&
+			sameWallClock(srcEv.End, calEv.End)
+	}
+	return true
+}
+
+// tolerates reports whether the times a and b differ, but by no more
+// than TimeTolerance allows.
+func (p planner) tolerates(a, b time.Time) bool {
+	d := a.Sub(b)
+	if d < 0 {
+		d = -d
+	}
+	return d != 0 && d <= p.timeTolerance
+}
+
+// reconcilePlan returns the subset of plan that still needs to be
+// executed, given the current calEvents, which are compared with the
+// planned events as Sync compares them.  See Apply.
+func (p planner) reconcilePlan(plan *Changes, calEvents []*Event) (*Changes, error) {
+	byID := map[string]*Event{}
+	bySrcID := map[string]*Event{}
+	for _, ev := range calEvents {
+		byID[ev.calEventID] = ev
+		bySrcID[ev.SrcID] = ev
+	}
+	current := func(ev *Event) *Event {
+		if ev.calEventID != "" {
+			if calEv, ok := byID[ev.calEventID]; ok {
+				return calEv
+			}
+		}
+		return bySrcID[ev.SrcID]
+	}
+
+	reconciled := &Changes{}
+	var changed []*Event
+	for _, ev := range plan.Deletes {
+		calEv := current(ev)
+		switch {
+		case calEv != nil && stale(ev, calEv):
+			changed = append(changed, calEv)
+		case calEv != nil:
+			reconciled.Deletes = append(reconciled.Deletes, calEv)
+		case ev.calEventID != "":
+			// Not upcoming, but it may still exist in the past.  Let
+			// the delete call find out; it tolerates missing events.
+			reconciled.Deletes = append(reconciled.Deletes, ev)
+		}
+	}
+	for _, ev := range plan.Updates {
+		calEv := current(ev)
+		if calEv == nil {
+			return nil, fmt.Errorf("update %q: no calendar event with SrcID %q", ev.Title, ev.SrcID)
+		}
+		switch {
+		case p.equal(ev, calEv):
+		case stale(ev, calEv):
+			changed = append(changed, calEv)
+		default:
+			reconciled.Updates = append(reconciled.Updates, calEv.newUpdate(ev))
+		}
+	}
+	if len(changed) != 0 {
+		return nil, &StalePlanError{Events: changed}
+	}
+	for _, ev := range plan.Adds {
+		calEv := bySrcID[ev.SrcID]
+		switch {
+		case calEv == nil:
+			reconciled.Adds = append(reconciled.Adds, ev)
+		case !p.equal(ev, calEv):
+			reconciled.Updates = append(reconciled.Updates, calEv.newUpdate(ev))
+		}
+	}
+	return reconciled, nil
 }
 
 // Opt is an optional way to configure the Sync command.
@@ -184,6 +789,579 @@ func CalendarID(calID string) Opt {
 	}
 }
 
+// CalendarName makes Sync use the calendar named name in the user's
+// calendar list, in place of CalendarID.  The name is looked up each
+// time, so it keeps working if the calendar is recreated.  It is an
+// error if the user can write to no calendar, or to several calendars,
+// of that name.  Either the calendar's own name or the name the user
+// gave it in their list matches.
+func CalendarName(name string) Opt {
+	return func(c *cal) {
+		c.calName = name
+	}
+}
+
+// MaxDeletes makes Sync fail with a *DeleteLimitError, without
+// modifying anything, if it would delete more than n events, as a
+// safeguard against a source that fails by returning few or no events.
+// With RouteTo, each event moved to another calendar counts as a
+// delete.
+func MaxDeletes(n int) Opt {
+	return func(c *cal) {
+		c.limitDeletes = true
+		c.maxDeletes = n
+	}
+}
+
+// MaxDeleteFraction is like MaxDeletes, but limits the deletes to the
+// fraction f, between 0 and 1, of the upcoming events the scope owns.
+func MaxDeleteFraction(f float64) Opt {
+	return func(c *cal) {
+		if (f < 0 || f > 1) && c.optErr == nil {
+			c.optErr = fmt.Errorf("MaxDeleteFraction %g is not between 0 and 1", f)
+		}
+		c.limitDeleteFraction = true
+		c.maxDeleteFraction = f
+	}
+}
+
+// ValidateSource makes Sync and SyncAll check the source events with
+// Validate before doing anything else, and return its error if they
+// are invalid.
+func ValidateSource() Opt {
+	return func(c *cal) {
+		c.validation = true
+	}
+}
+
+// ReportOrphans makes Sync leave calendar events that are no longer in
+// the source alone, and report them in Changes.Orphans, rather than
+// deleting them, while still applying updates and adds.  This lets a
+// human review deletions, and then make them, for example with Apply.
+// Events that a ConflictResolver decides to delete are still deleted.
+func ReportOrphans() Opt {
+	return func(c *cal) {
+		c.reportOrphans = true
+	}
+}
+
+// OnDelete sets what Sync does with calendar events that are no longer
+// in the source, and with Purge, with every event in the scope.  The
+// default is HardDelete.  Either way, the events are reported in
+// Changes.Deletes.
+func OnDelete(p DeletePolicy) Opt {
+	return func(c *cal) {
+		c.deletePolicy = p
+	}
+}
+
+// SendUpdates sets which attendees google calendar emails when events
+// are added, updated, deleted, or cancelled under the Cancel policy.
+// The default is NotifyDefault.  Events added with MatchBy(MatchICalUID)
+// are imported, and imports never send updates.
+func SendUpdates(n Notify) Opt {
+	return func(c *cal) {
+		if n.sendUpdates() == "" && n != NotifyDefault && c.optErr == nil {
+			c.optErr = fmt.Errorf("SendUpdates: unknown %s", n)
+		}
+		c.notify = n
+	}
+}
+
+// Audit makes Sync, SyncAll, Apply and Purge record each operation
+// they make in google calendar with w, once it is made, including the
+// event before and after.  If w fails, nothing more is modified, and
+// the error is returned.  With Nop, nothing is recorded.  Recorded
+// events are in the clear, even with Encrypt.
+func Audit(w AuditWriter) Opt {
+	return func(c *cal) {
+		c.auditor = w
+	}
+}
+
+// MirrorTo makes Sync, SyncAll, Apply and Purge tell m what the
+// upcoming events in scope are once they have made their changes, so
+// that m can keep a local copy of them.  The events are worked out
+// from those fetched and the changes made, so no more listing is
+// needed.  With Nop, m isn't told anything.
+func MirrorTo(m Mirror) Opt {
+	return func(c *cal) {
+		c.mirrorer = m
+	}
+}
+
+// Report makes Sync, SyncAll, Apply and Purge tell r about the changes
+// they made, once they are made.  SyncAll tells it about each scope.
+// With Nop, r is told about the changes that would have been made.  If
+// r fails, the changes, which were made, are returned along with the
+// error.
+func Report(r Reporter) Opt {
+	return func(c *cal) {
+		c.reporter = r
+	}
+}
+
+// Confirm makes Sync and Apply call confirm with the changes they are
+// about to make, once they are planned but before anything is
+// modified, so that interactive tools can ask the user, or policy code
+// can veto dangerous plans.  If confirm returns an error, nothing is
+// modified and the error is returned.  confirm isn't called with Nop,
+// or if there is nothing to do.  SyncAll calls it for each scope, and
+// only modifies anything if every scope is confirmed.
+func Confirm(confirm func(changes *Changes) error) Opt {
+	return func(c *cal) {
+		c.confirm = confirm
+	}
+}
+
+// Adopt makes Sync claim events that were entered into google calendar
+// by hand, or by another tool, rather than adding duplicates of them.
+// Before planning, each source event that hasn't been synced is
+// matched with an upcoming calendar event that no scope owns and that
+// has the same title and start, and the match is claimed by writing
+// the scope's private extended properties to it.  Claimed events are
+// reported in Changes.Adopted, and are then updated to match the source
+// like any other.  With Nop, nothing is claimed.
+func Adopt() Opt {
+	return func(c *cal) {
+		c.adoption = true
+	}
+}
+
+// Resurrect makes Sync restore a deleted calendar event, rather than
+// adding a second copy, when a source event with its SrcID is to be
+// added, for example after a calendar user deleted the event, or the
+// source dropped it for a while.  Before applying the plan, Sync
+// fetches the deleted upcoming events in scope, and turns each add
+// that has one into an update of it, which is reported in
+// Changes.Updates.  The restored event keeps its google calendar id,
+// so links to it keep working.
+func Resurrect() Opt {
+	return func(c *cal) {
+		c.resurrection = true
+	}
+}
+
+// RouteTo makes Sync put each source event in the calendar route
+// returns for it, so that one Sync can distribute events across several
+// calendars, for example one per team, while tracking them all under
+// one scope.  If route returns "", the event goes in the calendar Sync
+// would otherwise use.  An event that route sends to a different
+// calendar than before is deleted from the old calendar and added to
+// the new one.
+//
+// Sync then fetches from every calendar the user can write to, so that
+// it finds events wherever they were put, which takes a request per
+// calendar.
+func RouteTo(route func(ev *Event) (calID string)) Opt {
+	return func(c *cal) {
+		c.route = route
+	}
+}
+
+// EnsureCalendar makes Sync use the calendar named summary in the
+// user's calendar list, in place of CalendarID, creating it if there
+// isn't one, so that callers needn't create a dedicated calendar ahead
+// of time and hard code its id.  A new calendar gets timeZone, an IANA
+// name such as "America/Los_Angeles", and colorID, one of the ids
+// google calendar's colors endpoint lists for calendars.  Either may be
+// empty to use google calendar's defaults.  An existing calendar is
+// used as it is.  With Nop, nothing is created, and a missing calendar
+// is treated as empty.
+//
+// It is an error for several of the user's calendars to be named
+// summary.
+func EnsureCalendar(summary, timeZone, colorID string) Opt {
+	return func(c *cal) {
+		c.ensure = &newCalendar{summary: summary, timeZone: timeZone, colorID: colorID}
+	}
+}
+
+// Sandbox makes every call sync into the SandboxCalendar, creating it
+// if it is missing, whatever CalendarID, CalendarName, EnsureCalendar
+// or RouteTo say, so that a sync under development can't touch real
+// calendars.  Events written are tagged with SandboxProp, so that
+// PurgeSandbox can delete them all at once, whatever their scope.
+func Sandbox() Opt {
+	return func(c *cal) {
+		c.sandbox = true
+	}
+}
+
+// Incremental makes Sync and Fetch keep a google calendar sync token,
+// along with the scoped events seen so far, in store, so that later
+// calls only need to retrieve the events that changed.  The first call
+// for a given calendar lists the whole calendar.
+func Incremental(store StateStore) Opt {
+	return func(c *cal) {
+		c.state = store
+	}
+}
+
+// FetchFields sets which google calendar event fields are fetched,
+// beyond those calsync reads, in place of DefaultFetchFields, so that
+// listings transfer no more than is needed.  Fields kept by Preserve
+// are always fetched.  "*" fetches every field.  Rollback restores only
+// the fields that were fetched, so fields that aren't fetched, and
+// that an update clears, can't be rolled back.
+func FetchFields(fields ...string) Opt {
+	return func(c *cal) {
+		for _, f := range fields {
+			if f == "" && c.optErr == nil {
+				c.optErr = fmt.Errorf("FetchFields: empty field name")
+			}
+		}
+		c.customFields = true
+		c.extraFields = fields
+	}
+}
+
+// WithNow makes Sync and the other calls take the current time from
+// now, in place of the system clock.  The current time decides which
+// source events are past, and so skipped, and which calendar events are
+// upcoming, and so fetched.  It is also the time recorded in
+// manifests, audit records and LastSynced footers.  This makes syncs
+// reproducible in tests, and lets a backfill sync as of another time.
+func WithNow(now func() time.Time) Opt {
+	return func(c *cal) {
+		if now == nil && c.optErr == nil {
+			c.optErr = fmt.Errorf("WithNow: now is nil")
+		}
+		c.clock = now
+	}
+}
+
+// WithBackend makes Sync and the other calls that support it sync
+// events into b rather than into google calendar, planning changes the
+// same way.  The client passed to them may then be nil.
+//
+// Options that rely on google calendar, such as CalendarName, RouteTo,
+// Incremental or Encrypt, are errors, and those that only change how
+// events are written to google calendar, such as DescriptionLayout or
+// SendUpdates, are ignored.  Edits can't be told apart from source
+// changes, so there are no conflicts.  Rollback, Purge, MigrateScope,
+// Diagnose and NewWatcher only work with google calendar.
+func WithBackend(b Backend) Opt {
+	return func(c *cal) {
+		if b == nil && c.optErr == nil {
+			c.optErr = fmt.Errorf("WithBackend: backend is nil")
+		}
+		c.backend = b
+	}
+}
+
+// QuotaUser makes every call to google calendar on behalf of the user
+// identified by id, as its quotaUser parameter, so that quota is
+// accounted for each end user rather than all at once when a single
+// service account syncs many users' calendars.  id is any string of up
+// to 40 characters, such as a hash of the user's email address.  It
+// needs the client, and not only WithService.
+func QuotaUser(id string) Opt {
+	return func(c *cal) {
+		if (id == "" || len(id) > maxQuotaUserLen) && c.optErr == nil {
+			c.optErr = fmt.Errorf("QuotaUser: id must be 1 to %d characters, not %q", maxQuotaUserLen, id)
+		}
+		c.quotaUser = id
+	}
+}
+
+// OperationTimeout makes each fetch of the calendar events, and each
+// add, update and delete, fail if it takes longer than d, so that one
+// hung call to google calendar fails the sync rather than stalling it
+// until the deadline of ctx, which still applies.  With RetryFetch,
+// each attempt has its own timeout.
+func OperationTimeout(d time.Duration) Opt {
+	return func(c *cal) {
+		if d <= 0 && c.optErr == nil {
+			c.optErr = fmt.Errorf("OperationTimeout: %s is not positive", d)
+		}
+		c.opTimeout = d
+	}
+}
+
+// CircuitBreaker makes Sync fetch, add, update and delete events
+// through b, which is shared by every sync given it, so that once
+// google calendar has failed enough times in a row, they fail fast with
+// a *BreakerOpenError rather than each making calls that are bound to
+// fail.  With RetryFetch, an open breaker is retried like any other
+// error, so delays should be short in comparison to the cool down.
+func CircuitBreaker(b *Breaker) Opt {
+	return func(c *cal) {
+		if b == nil && c.optErr == nil {
+			c.optErr = fmt.Errorf("CircuitBreaker: breaker is nil")
+		}
+		c.breaker = b
+	}
+}
+
+// WithLock makes Sync, SyncAll and Apply hold the lock for their scope
+// from l while they run, and fail with a *LockedError, without
+// modifying anything, if another sync holds it.  Dry runs don't lock.
+func WithLock(l Locker) Opt {
+	return func(c *cal) {
+		if l == nil && c.optErr == nil {
+			c.optErr = fmt.Errorf("WithLock: locker is nil")
+		}
+		c.locker = l
+	}
+}
+
+// CalendarLock is like WithLock, with a Locker that keeps a lock event
+// in the calendar being synced, so that syncs of the same scope on
+// different machines, such as overlapping runs of a cron job, don't
+// both add the same events.  The lock event is dated 1970-01-01, and is
+// marked with LockProp.  It expires after ttl, in case its sync dies
+// without deleting it, so ttl should be longer than any sync takes.
+func CalendarLock(ttl time.Duration) Opt {
+	return func(c *cal) {
+		if ttl <= 0 && c.optErr == nil {
+			c.optErr = fmt.Errorf("CalendarLock: %s is not positive", ttl)
+		}
+		c.lockTTL = ttl
+	}
+}
+
+// RetryFetch makes Sync try fetching the calendar events up to
+// attempts times before giving up, waiting delay after the first
+// failure, and twice as long after each next one, so that a transient
+// error from google calendar doesn't fail the whole sync.  The default
+// is to try once.
+func RetryFetch(attempts int, delay time.Duration) Opt {
+	return func(c *cal) {
+		if attempts < 1 && c.optErr == nil {
+			c.optErr = fmt.Errorf("RetryFetch: attempts must be at least 1, not %d", attempts)
+		}
+		c.fetchAttempts = attempts
+		c.fetchDelay = delay
+	}
+}
+
+// WithService makes Sync use svc for google calendar, rather than a
+// service made from the client, so that a service made once can be
+// shared by many calls.  The client may then be nil, unless QuotaUser
+// is used too.
+func WithService(svc *calendar.Service) Opt {
+	return func(c *cal) {
+		if svc == nil && c.optErr == nil {
+			c.optErr = fmt.Errorf("WithService: service is nil")
+		}
+		c.svc = svc
+	}
+}
+
+// OnConflict sets what Sync does with events that were edited in
+// google calendar since they were last synced, and that no longer match
+// the source.  The default is PreferCalendar.
+func OnConflict(p ConflictPolicy) Opt {
+	return func(c *cal) {
+		c.conflictPolicy = p
+	}
+}
+
+// ResolveConflicts makes Sync ask r what to do with each event that was
+// edited in google calendar since it was last synced, and that no
+// longer matches the source, or is no longer in the source.  It takes
+// precedence over OnConflict.
+func ResolveConflicts(r ConflictResolver) Opt {
+	return func(c *cal) {
+		c.resolver = r
+	}
+}
+
+// WallClock makes Sync preserve the local times the source intends.
+// Event times whose location is a named timezone, such as one returned
+// by time.LoadLocation, are written to google calendar along with that
+// timezone, so google calendar keeps them at the same local time, for
+// example across daylight saving transitions in recurring events.  A
+// change to an event's timezone is then treated as a change even if
+// the instant stays the same.
+//
+// By default, times are treated as absolute instants, and the calendar
+// event takes on the calendar's timezone.
+func WallClock() Opt {
+	return func(c *cal) {
+		c.wallClock = true
+	}
+}
+
+// EqualFunc makes Sync use equal, in place of DefaultEqual, to decide
+// whether a calendar event already matches its source event, so that
+// differences that don't matter, such as in whitespace, don't cause
+// updates.  equal is called with the source event, then the calendar
+// event, each with only the synced part of its description, as plain
+// text, and with any fields kept by Preserve copied from the source.
+// WallClock still applies on top of it.
+func EqualFunc(equal func(a, b *Event) bool) Opt {
+	return func(c *cal) {
+		c.equalFunc = equal
+	}
+}
+
+// DescriptionLayout makes Sync lay out descriptions in google calendar
+// with l, rather than with the default delimiter, for example to brand
+// them, or to add a footer saying when and from where each event was
+// synced.  The footer isn't synced content, so it changing doesn't
+// cause updates; it is only rewritten when an event is.
+//
+// Events synced with the default delimiter are still recognized.
+// Events synced with another Layout's delimiter are not, so their next
+// update replaces their whole description, including any comments.
+func DescriptionLayout(l Layout) Opt {
+	return func(c *cal) {
+		layout, err := compileLayout(l)
+		if err != nil && c.optErr == nil {
+			c.optErr = fmt.Errorf("DescriptionLayout: %v", err)
+		}
+		c.layout = layout
+	}
+}
+
+// LastSynced makes Sync add a LastSyncedFooter to synced descriptions
+// in google calendar, saying when each event was last written from
+// source, so calendar users can tell how fresh it is.  Like any footer,
+// it isn't synced content: it changing doesn't cause updates, and it is
+// only rewritten when an event is.  It can be combined with a
+// DescriptionLayout without a Footer, whose Source it replaces.
+func LastSynced(source string) Opt {
+	return func(c *cal) {
+		if source == "" && c.optErr == nil {
+			c.optErr = fmt.Errorf("LastSynced: source is empty")
+		}
+		c.lastSynced = source
+	}
+}
+
+// MatchBy sets how Sync recognizes the calendar events it synced.  The
+// default is MatchProperties.
+func MatchBy(s MatchStrategy) Opt {
+	return func(c *cal) {
+		c.match = s
+	}
+}
+
+// Share makes Sync also write the SrcID of each event, as "<scope>ID",
+// and those of its PrivateProps named by keys, to the event's shared
+// extended properties.  Unlike private ones, shared properties are
+// visible on every copy of the event, such as those in attendees'
+// calendars, so integrations there can read them.  The SrcID is shared
+// in the clear, even with Encrypt.  Events are only shared as they are
+// next added or updated.
+func Share(keys ...string) Opt {
+	return func(c *cal) {
+		c.share = true
+		c.shareKeys = keys
+	}
+}
+
+// TrailingComments makes Sync write a second, closing, delimiter after
+// the synced part of each description, so that calendar users can add
+// comments below it, such as notes taken after a meeting, as well as
+// above the first delimiter.  Both are kept across updates.  Comments
+// after a closing delimiter are kept even without TrailingComments,
+// but users then have to add the delimiter themselves.
+func TrailingComments() Opt {
+	return func(c *cal) {
+		c.trailingComments = true
+	}
+}
+
+// NormalizeDescriptions makes Sync compare descriptions after removing
+// HTML markup, trimming whitespace from each line, and collapsing runs
+// of blank lines, because google calendar may rewrite descriptions as
+// HTML, which otherwise looks like a change on every sync.
+//
+// Events synced without it, whose descriptions google calendar has
+// since rewritten, are updated once more.
+func NormalizeDescriptions() Opt {
+	return func(c *cal) {
+		c.normalizeDescriptions = true
+	}
+}
+
+// TimeTolerance makes Sync treat the start and end times of a calendar
+// event as matching those of its source event if they differ by no
+// more than d, so that a source that rounds times differently than
+// when it was synced doesn't cause an update on every run.  Other
+// changes to an event still update its times.  All day events are
+// compared by date, as usual.
+func TimeTolerance(d time.Duration) Opt {
+	return func(c *cal) {
+		c.timeTolerance = d
+	}
+}
+
+// DefaultEqual reports whether a and b have the same synced content.
+// It is what Sync compares events with, unless EqualFunc is used, so
+// equality functions can build on it.
+func DefaultEqual(a, b *Event) bool {
+	return a.equal(b)
+}
+
+// Preserve makes Sync keep the given fields of google calendar events
+// as they are when updating them, rather than overwriting them, much
+// as it keeps comments before the delimiter in descriptions.  Updates
+// only send the fields that changed, so fields this package doesn't
+// sync, such as reminders, are usually kept anyway, but they are
+// cleared by the updates that have to replace the whole event, such as
+// those that clear a field, or change an event to or from all day.  A
+// preserved FieldLocation or FieldAttendees is only
+// written when the event is first added, and later differences in Where
+// or Attendees are ignored.
+func Preserve(fields ...Field) Opt {
+	return func(c *cal) {
+		c.preserve = append(c.preserve, fields...)
+	}
+}
+
+// PrivateCopies makes Sync write events without inviting their
+// Attendees, for organizations whose policy forbids inviting external
+// addresses automatically.  Instead, the names of the attendees are
+// listed at the end of the description, on a line starting
+// "Attendees: ".  Email addresses are left out, as are attendees with
+// no name.
+//
+// Use it for every Sync with a given scope, or none, as switching
+// rewrites every event with attendees.
+func PrivateCopies() Opt {
+	return func(c *cal) {
+		c.privateCopies = true
+	}
+}
+
+// Encrypt makes Sync encrypt the values it stores in private extended
+// properties, such as the SrcID and PrivateProps, with key, and
+// decrypt them again when it reads them back, so that people the
+// calendar is shared with can't read them.  The <scope>=True property
+// is left as it is, as it is used to find events, and so are the
+// shared copies Share makes.  key must be at least MinKeyLen random
+// bytes, and must be the same for every call with the same scope.
+//
+// Encrypted values are longer, which leaves less room for SrcID.
+// Events synced without Encrypt are still recognized, and their values
+// are encrypted as they are next updated.
+//
+// Since events are looked up by it, a SrcID is encrypted the same way
+// every time, so events synced with the same scope and key into
+// different calendars can be seen to share a SrcID, though not what it
+// is.  Other values are bound to their event, and can't be copied to
+// another.
+func Encrypt(key []byte) Opt {
+	return func(c *cal) {
+		s, err := newSealer(key)
+		if err != nil && c.optErr == nil {
+			c.optErr = err
+		}
+		c.sealer = s
+	}
+}
+
+// Force makes Sync overwrite events that were edited in google
+// calendar since they were last synced.  It is shorthand for
+// OnConflict(PreferSource).
+func Force() Opt {
+	return OnConflict(PreferSource)
+}
+
 // Nop makes the Sync call operate in readonly mode, reporting what
 // it would have done without modifying anything.
 func Nop() Opt {
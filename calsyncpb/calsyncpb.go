@@ -0,0 +1,227 @@
+/*
+Package calsyncpb holds a versioned schema of calsync events and
+changes, in calsync.proto, for pipelines written in other languages,
+which can generate types from it with protoc, and for storing changes,
+such as plans, durably.
+
+The types here mirror the messages of the schema, and marshal to JSON
+as the proto3 JSON mapping does with the field names as they are in the
+schema, so that Go programs can exchange them with programs using
+generated types without depending on a protobuf runtime:
+
+	b, err := json.Marshal(calsyncpb.FromChanges(changes))
+
+calsync's own types can't be used for that, as they may change along
+with the package.  These only change by adding fields, as the schema
+does.
+*/
+package calsyncpb
+
+import (
+	"time"
+
+	"github.com/ginabythebay/calsync"
+)
+
+// SchemaVersion is the version of calsync.proto that these types
+// mirror.  FromChanges records it in Changes.
+const SchemaVersion = 1
+
+// Event mirrors the Event message.
+type Event struct {
+	Title                   string            `json:"title,omitempty"`
+	Start                   *time.Time        `json:"start,omitempty"`
+	End                     *time.Time        `json:"end,omitempty"`
+	Where                   string            `json:"where,omitempty"`
+	Description             string            `json:"description,omitempty"`
+	SrcID                   string            `json:"src_id,omitempty"`
+	AllDay                  bool              `json:"all_day,omitempty"`
+	Attendees               []*Attendee       `json:"attendees,omitempty"`
+	DescriptionHTML         bool              `json:"description_html,omitempty"`
+	Metadata                map[string]string `json:"metadata,omitempty"`
+	PrivateProps            map[string]string `json:"private_props,omitempty"`
+	SourceURL               string            `json:"source_url,omitempty"`
+	SourceTitle             string            `json:"source_title,omitempty"`
+	Status                  string            `json:"status,omitempty"`
+	GuestsCanModify         *bool             `json:"guests_can_modify,omitempty"`
+	GuestsCanInviteOthers   *bool             `json:"guests_can_invite_others,omitempty"`
+	GuestsCanSeeOtherGuests *bool             `json:"guests_can_see_other_guests,omitempty"`
+	UserNote                string            `json:"user_note,omitempty"`
+}
+
+// Attendee mirrors the Attendee message.
+type Attendee struct {
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email,omitempty"`
+}
+
+// Warning mirrors the Warning message.
+type Warning struct {
+	Event    *Event `json:"event,omitempty"`
+	Calendar string `json:"calendar,omitempty"`
+	Message  string `json:"message,omitempty"`
+}
+
+// Changes mirrors the Changes message.
+type Changes struct {
+	SchemaVersion uint32     `json:"schema_version,omitempty"`
+	Deletes       []*Event   `json:"deletes,omitempty"`
+	Updates       []*Event   `json:"updates,omitempty"`
+	Adds          []*Event   `json:"adds,omitempty"`
+	Conflicts     []*Event   `json:"conflicts,omitempty"`
+	Adopted       []*Event   `json:"adopted,omitempty"`
+	Orphans       []*Event   `json:"orphans,omitempty"`
+	Failed        []string   `json:"failed,omitempty"`
+	Skipped       []string   `json:"skipped,omitempty"`
+	Pending       *Changes   `json:"pending,omitempty"`
+	Overflow      *Changes   `json:"overflow,omitempty"`
+	Warnings      []*Warning `json:"warnings,omitempty"`
+}
+
+// FromEvent converts ev to an Event, or returns nil if ev is nil.
+func FromEvent(ev *calsync.Event) *Event {
+	if ev == nil {
+		return nil
+	}
+	out := &Event{
+		Title:                   ev.Title,
+		Start:                   optionalTime(ev.Start),
+		End:                     optionalTime(ev.End),
+		Where:                   ev.Where,
+		Description:             ev.Description,
+		SrcID:                   ev.SrcID,
+		AllDay:                  ev.AllDay,
+		DescriptionHTML:         ev.DescriptionHTML,
+		Metadata:                ev.Metadata,
+		PrivateProps:            ev.PrivateProps,
+		SourceURL:               ev.SourceURL,
+		SourceTitle:             ev.SourceTitle,
+		Status:                  string(ev.Status),
+		GuestsCanModify:         ev.GuestsCanModify,
+		GuestsCanInviteOthers:   ev.GuestsCanInviteOthers,
+		GuestsCanSeeOtherGuests: ev.GuestsCanSeeOtherGuests,
+		UserNote:                ev.UserNote,
+	}
+	for _, a := range ev.Attendees {
+		out.Attendees = append(out.Attendees, &Attendee{Name: a.Name, Email: a.Email})
+	}
+	return out
+}
+
+// ToEvent converts e to a calsync.Event, or returns nil if e is nil.
+func (e *Event) ToEvent() *calsync.Event {
+	if e == nil {
+		return nil
+	}
+	ev := &calsync.Event{
+		Title:                   e.Title,
+		Where:                   e.Where,
+		Description:             e.Description,
+		SrcID:                   e.SrcID,
+		AllDay:                  e.AllDay,
+		DescriptionHTML:         e.DescriptionHTML,
+		Metadata:                e.Metadata,
+		PrivateProps:            e.PrivateProps,
+		SourceURL:               e.SourceURL,
+		SourceTitle:             e.SourceTitle,
+		Status:                  calsync.EventStatus(e.Status),
+		GuestsCanModify:         e.GuestsCanModify,
+		GuestsCanInviteOthers:   e.GuestsCanInviteOthers,
+		GuestsCanSeeOtherGuests: e.GuestsCanSeeOtherGuests,
+		UserNote:                e.UserNote,
+	}
+	if e.Start != nil {
+		ev.Start = *e.Start
+	}
+	if e.End != nil {
+		ev.End = *e.End
+	}
+	for _, a := range e.Attendees {
+		ev.Attendees = append(ev.Attendees, calsync.Attendee{Name: a.Name, Email: a.Email})
+	}
+	return ev
+}
+
+// FromChanges converts c to Changes, or returns nil if c is nil.  The
+// SrcIDs of the events whose operations failed or were skipped are
+// taken from c.Results.  Undo and Manifest are not converted.
+func FromChanges(c *calsync.Changes) *Changes {
+	if c == nil {
+		return nil
+	}
+	return &Changes{
+		SchemaVersion: SchemaVersion,
+		Deletes:       fromEvents(c.Deletes),
+		Updates:       fromEvents(c.Updates),
+		Adds:          fromEvents(c.Adds),
+		Conflicts:     fromEvents(c.Conflicts),
+		Adopted:       fromEvents(c.Adopted),
+		Orphans:       fromEvents(c.Orphans),
+		Failed:        c.Results.Failed(),
+		Skipped:       c.Results.Skipped(),
+		Pending:       FromChanges(c.Pending),
+		Overflow:      FromChanges(c.Overflow),
+		Warnings:      fromWarnings(c.Warnings),
+	}
+}
+
+// ToChanges converts c to calsync.Changes, or returns nil if c is nil.
+// Failed and Skipped are not converted, as they don't say which
+// operations they were.  The result can be passed to calsync.Apply, but
+// without the etags that calsync.WritePlan records, so changes made to
+// the calendar since the plan was made are not detected.
+func (c *Changes) ToChanges() *calsync.Changes {
+	if c == nil {
+		return nil
+	}
+	return &calsync.Changes{
+		Deletes:   toEvents(c.Deletes),
+		Updates:   toEvents(c.Updates),
+		Adds:      toEvents(c.Adds),
+		Conflicts: toEvents(c.Conflicts),
+		Adopted:   toEvents(c.Adopted),
+		Orphans:   toEvents(c.Orphans),
+		Pending:   c.Pending.ToChanges(),
+		Overflow:  c.Overflow.ToChanges(),
+		Warnings:  toWarnings(c.Warnings),
+	}
+}
+
+func fromEvents(events []*calsync.Event) []*Event {
+	var out []*Event
+	for _, ev := range events {
+		out = append(out, FromEvent(ev))
+	}
+	return out
+}
+
+func toEvents(events []*Event) []*calsync.Event {
+	var out []*calsync.Event
+	for _, e := range events {
+		out = append(out, e.ToEvent())
+	}
+	return out
+}
+
+func fromWarnings(warnings []*calsync.Warning) []*Warning {
+	var out []*Warning
+	for _, w := range warnings {
+		out = append(out, &Warning{Event: FromEvent(w.Event), Calendar: w.Calendar, Message: w.Message})
+	}
+	return out
+}
+
+func toWarnings(warnings []*Warning) []*calsync.Warning {
+	var out []*calsync.Warning
+	for _, w := range warnings {
+		out = append(out, &calsync.Warning{Event: w.Event.ToEvent(), Calendar: w.Calendar, Message: w.Message})
+	}
+	return out
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,108 @@
+package calsyncpb
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ginabythebay/calsync"
+)
+
+func testEvent() *calsync.Event {
+	yes := true
+	start := time.Date(2030, 1, 2, 10, 0, 0, 0, time.FixedZone("PST", -8*60*60))
+	return &calsync.Event{
+		Title:           "class",
+		Start:           start,
+		End:             start.Add(time.Hour),
+		Where:           "room 1",
+		Description:     "bring a pencil",
+		SrcID:           "class1",
+		Attendees:       []calsync.Attendee{{Name: "Ann", Email: "ann@example.com"}},
+		Metadata:        map[string]string{"teacher": "Bob"},
+		PrivateProps:    map[string]string{"row": "7"},
+		SourceURL:       "https://example.com/class1",
+		SourceTitle:     "Class 1",
+		Status:          calsync.EventTentative,
+		GuestsCanModify: &yes,
+	}
+}
+
+func TestEventRoundTrip(t *testing.T) {
+	ev := testEvent()
+	got := FromEvent(ev).ToEvent()
+	if !reflect.DeepEqual(ev, got) {
+		t.Errorf("got %+v, want %+v", got, ev)
+	}
+	if FromEvent(nil) != nil || (*Event)(nil).ToEvent() != nil {
+		t.Error("expected nil events to convert to nil")
+	}
+}
+
+func TestEventJSON(t *testing.T) {
+	b, err := json.Marshal(FromEvent(testEvent()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := map[string]interface{}{}
+	if err = json.Unmarshal(b, &m); err != nil {
+		t.Fatal(err)
+	}
+	// As the proto3 JSON mapping writes them, with the proto field
+	// names.
+	for k, want := range map[string]interface{}{
+		"src_id":            "class1",
+		"start":             "2030-01-02T10:00:00-08:00",
+		"status":            "tentative",
+		"guests_can_modify": true,
+	} {
+		if m[k] != want {
+			t.Errorf("got %s %v, want %v", k, m[k], want)
+		}
+	}
+	if _, ok := m["all_day"]; ok {
+		t.Error("expected default values to be left out")
+	}
+}
+
+func TestChanges(t *testing.T) {
+	ev := testEvent()
+	c := FromChanges(&calsync.Changes{
+		Adds:     []*calsync.Event{ev},
+		Pending:  &calsync.Changes{Deletes: []*calsync.Event{ev}},
+		Overflow: &calsync.Changes{Adds: []*calsync.Event{ev}},
+		Warnings: []*calsync.Warning{{Event: ev, Calendar: "primary", Message: "busy"}},
+	})
+	if c.SchemaVersion != SchemaVersion {
+		t.Errorf("got schema version %d, want %d", c.SchemaVersion, SchemaVersion)
+	}
+	if len(c.Adds) != 1 || c.Adds[0].SrcID != "class1" || len(c.Pending.Deletes) != 1 {
+		t.Errorf("got %+v, want one add and one pending delete", c)
+	}
+
+	b, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read := &Changes{}
+	if err = json.Unmarshal(b, read); err != nil {
+		t.Fatal(err)
+	}
+	back := read.ToChanges()
+	if len(back.Adds) != 1 || !back.Adds[0].Start.Equal(ev.Start) {
+		t.Errorf("got %+v, want the add", back.Adds)
+	}
+	if back.Pending == nil || len(back.Pending.Deletes) != 1 {
+		t.Errorf("got pending %+v, want one delete", back.Pending)
+	}
+	if back.Overflow == nil || len(back.Overflow.Adds) != 1 {
+		t.Errorf("got overflow %+v, want one add", back.Overflow)
+	}
+	if len(back.Warnings) != 1 || back.Warnings[0].Calendar != "primary" || back.Warnings[0].Event.SrcID != "class1" {
+		t.Errorf("got warnings %+v, want the warning", back.Warnings)
+	}
+	if FromChanges(nil) != nil || (*Changes)(nil).ToChanges() != nil {
+		t.Error("expected nil changes to convert to nil")
+	}
+}
@@ -0,0 +1,140 @@
+package calsynctest
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+// defaultChannelTTL is how long a channel lasts, unless its ttl
+// parameter asks for less, as in the google calendar api.
+const defaultChannelTTL = 7 * 24 * time.Hour
+
+type fakeChannel struct {
+	ch       calendar.Channel
+	calID    string
+	messages int64
+}
+
+// watch registers a notification channel for the events of calID.
+func (s *Server) watch(calID string, r *http.Request) (interface{}, error) {
+	if _, err := s.calendar(calID); err != nil {
+		return nil, err
+	}
+	in := &calendar.Channel{}
+	if err := decode(r, in); err != nil {
+		return nil, err
+	}
+	if in.Id == "" || in.Address == "" || in.Type != "web_hook" {
+		return nil, errorf(http.StatusBadRequest, "invalid", "a web_hook channel needs an id and an address")
+	}
+	if _, ok := s.channels[in.Id]; ok {
+		return nil, errorf(http.StatusBadRequest, "channelIdNotUnique", "Channel id %s not unique", in.Id)
+	}
+	ttl := defaultChannelTTL
+	if p := in.Params["ttl"]; p != "" {
+		secs, err := strconv.ParseInt(p, 10, 64)
+		if err != nil || secs <= 0 {
+			return nil, errorf(http.StatusBadRequest, "invalid", "invalid ttl %q", p)
+		}
+		if d := time.Duration(secs) * time.Second; d < ttl {
+			ttl = d
+		}
+	}
+	s.nextID++
+	ch := calendar.Channel{
+		Kind:        "api#channel",
+		Id:          in.Id,
+		ResourceId:  fmt.Sprintf("resource%d", s.nextID),
+		ResourceUri: fmt.Sprintf("https://www.googleapis.com/calendar/v3/calendars/%s/events", calID),
+		Token:       in.Token,
+		Expiration:  s.now().Add(ttl).UnixNano() / int64(time.Millisecond),
+		Address:     in.Address,
+		Type:        in.Type,
+	}
+	s.channels[in.Id] = &fakeChannel{ch: ch, calID: calID}
+	out := ch
+	out.Address, out.Type = "", ""
+	return &out, nil
+}
+
+// stopChannel stops the channel with the id and resource id in the
+// request.
+func (s *Server) stopChannel(r *http.Request) error {
+	in := &calendar.Channel{}
+	if err := decode(r, in); err != nil {
+		return err
+	}
+	fc, ok := s.channels[in.Id]
+	if !ok || fc.ch.ResourceId != in.ResourceId {
+		return errorf(http.StatusNotFound, "notFound", "Channel '%s' not found for project", in.Id)
+	}
+	delete(s.channels, in.Id)
+	return nil
+}
+
+// Channels returns copies of the notification channels that were
+// registered and not stopped, whether or not they have expired.
+func (s *Server) Channels() []*calendar.Channel {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var channels []*calendar.Channel
+	for _, fc := range s.channels {
+		ch := fc.ch
+		channels = append(channels, &ch)
+	}
+	return channels
+}
+
+// Notify sends an "exists" notification, saying that the events of
+// calID changed, to the address of each unexpired channel watching
+// them, as google calendar does after a change, and returns the first
+// error sending one.  Changes don't send notifications by themselves,
+// so that tests control when they arrive.
+func (s *Server) Notify(calID string) error {
+	type notification struct {
+		ch      calendar.Channel
+		message int64
+	}
+	var pending []notification
+	s.mu.Lock()
+	now := s.now().UnixNano() / int64(time.Millisecond)
+	for _, fc := range s.channels {
+		if fc.calID != calID || fc.ch.Expiration <= now {
+			continue
+		}
+		fc.messages++
+		pending = append(pending, notification{fc.ch, fc.messages})
+	}
+	s.mu.Unlock()
+
+	// Sent without holding the lock, as the receiver may well call s.
+	var first error
+	for _, n := range pending {
+		req, err := http.NewRequest("POST", n.ch.Address, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("X-Goog-Channel-ID", n.ch.Id)
+		req.Header.Set("X-Goog-Channel-Token", n.ch.Token)
+		req.Header.Set("X-Goog-Channel-Expiration", time.Unix(0, n.ch.Expiration*int64(time.Millisecond)).UTC().Format(http.TimeFormat))
+		req.Header.Set("X-Goog-Message-Number", strconv.FormatInt(n.message, 10))
+		req.Header.Set("X-Goog-Resource-ID", n.ch.ResourceId)
+		req.Header.Set("X-Goog-Resource-State", "exists")
+		req.Header.Set("X-Goog-Resource-URI", n.ch.ResourceUri)
+		resp, err := http.DefaultClient.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode >= 300 {
+				err = fmt.Errorf("notifying %s: %s", n.ch.Address, resp.Status)
+			}
+		}
+		if err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
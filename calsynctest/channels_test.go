@@ -0,0 +1,76 @@
+package calsynctest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+func TestServerChannels(t *testing.T) {
+	var got []http.Header
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header)
+	}))
+	defer hook.Close()
+
+	s := NewServer()
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.Now = func() time.Time { return now }
+	svc := newService(t, s)
+	ch, err := svc.Events.Watch("primary", &calendar.Channel{
+		Id:      "chan",
+		Type:    "web_hook",
+		Address: hook.URL,
+		Token:   "secret",
+		Params:  map[string]string{"ttl": "3600"},
+	}).Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := now.Add(time.Hour).UnixNano() / int64(time.Millisecond); ch.Expiration != want {
+		t.Errorf("got expiration %d, want %d", ch.Expiration, want)
+	}
+	if _, err = svc.Events.Watch("primary", &calendar.Channel{Id: "chan", Type: "web_hook", Address: hook.URL}).Do(); err == nil {
+		t.Error("expected an error reusing a channel id")
+	}
+
+	if err = s.Notify("primary"); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("got %d notifications, want 1", len(got))
+	}
+	for k, want := range map[string]string{
+		"X-Goog-Channel-Id":     "chan",
+		"X-Goog-Channel-Token":  "secret",
+		"X-Goog-Resource-Id":    ch.ResourceId,
+		"X-Goog-Resource-State": "exists",
+		"X-Goog-Message-Number": "1",
+	} {
+		if v := got[0].Get(k); v != want {
+			t.Errorf("got %s %q, want %q", k, v, want)
+		}
+	}
+
+	// Expired channels are not notified.
+	now = now.Add(2 * time.Hour)
+	if err = s.Notify("primary"); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 {
+		t.Errorf("got %d notifications, want 1", len(got))
+	}
+
+	if err = svc.Channels.Stop(&calendar.Channel{Id: "chan", ResourceId: ch.ResourceId}).Do(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(s.Channels()); n != 0 {
+		t.Errorf("got %d channels, want 0", n)
+	}
+	if err = svc.Channels.Stop(&calendar.Channel{Id: "chan", ResourceId: ch.ResourceId}).Do(); err == nil {
+		t.Error("expected an error stopping a stopped channel")
+	}
+}
@@ -0,0 +1,221 @@
+/*
+Package calsynctest provides helpers for testing code that uses
+calsync, without depending on the good behavior of the google calendar
+api.
+
+FaultTransport wraps the transport of the http.Client passed to calsync
+and injects failures, such as rate limiting and server errors, in a
+deterministic way, so that retry, partial failure and resume behavior
+can be exercised reliably:
+
+	ft := &calsynctest.FaultTransport{
+		Base: client.Transport,
+		Rules: []calsynctest.Rule{
+			// Fail the third and fourth requests with a 500.
+			{Fault: calsynctest.ServerError, After: 2, Count: 2},
+		},
+	}
+	client.Transport = ft
+
+Recorder captures the requests made to the real google calendar api,
+and their responses, as a Fixture, which Replayer plays back without
+credentials or networking, so that code can be tested against
+realistic responses in CI.
+*/
+package calsynctest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Fault is a kind of failure FaultTransport can inject.
+type Fault int
+
+const (
+	// RateLimit fails the request with a 429 rateLimitExceeded error.
+	RateLimit Fault = iota
+
+	// ServerError fails the request with a 500 backendError error.
+	ServerError
+
+	// Timeout makes the request hang until its context is done, or
+	// until Rule.Delay has passed, and then fail with a timeout.
+	Timeout
+)
+
+func (f Fault) String() string {
+	switch f {
+	case RateLimit:
+		return "RateLimit"
+	case ServerError:
+		return "ServerError"
+	case Timeout:
+		return "Timeout"
+	}
+	return fmt.Sprintf("Fault(%d)", int(f))
+}
+
+// Rule describes when to inject a Fault.
+type Rule struct {
+	Fault Fault
+
+	// Match restricts the rule to requests it returns true for.  If nil,
+	// the rule applies to every request.  See Method and Paged.
+	Match func(*http.Request) bool
+
+	// After is how many matching requests succeed before the rule
+	// starts failing them.
+	After int
+
+	// Count is how many matching requests fail, once the rule starts
+	// failing them.  Zero means all of them.
+	Count int
+
+	// Delay is how long a Timeout hangs if the request's context is
+	// never done.  Zero means a minute.
+	Delay time.Duration
+}
+
+// Method returns a Match function for requests using method, such as
+// "DELETE".
+func Method(method string) func(*http.Request) bool {
+	return func(r *http.Request) bool {
+		return r.Method == method
+	}
+}
+
+// Paged is a Match function for requests that fetch a second or later
+// page of results.  It can be used to simulate failures part way
+// through a listing.
+func Paged(r *http.Request) bool {
+	return r.URL.Query().Get("pageToken") != ""
+}
+
+// FaultTransport is an http.RoundTripper that injects failures into
+// the requests it is given, according to Rules, and passes the rest
+// on to Base.  Rules are checked in order and the first one that fails
+// a request wins.  Every rule that matches a request counts it, whether
+// or not it ends up failing it.
+type FaultTransport struct {
+	// Base performs requests that aren't failed.  If nil,
+	// http.DefaultTransport is used.
+	Base http.RoundTripper
+
+	Rules []Rule
+
+	mu      sync.Mutex
+	seen    []int
+	injects map[Fault]int
+}
+
+// Injected returns how many times f has been injected.
+func (t *FaultTransport) Injected(f Fault) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.injects[f]
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *FaultTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if rule := t.fault(req); rule != nil {
+		return inject(req, rule)
+	}
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req)
+}
+
+// fault returns the rule that should fail req, if any.
+func (t *FaultTransport) fault(req *http.Request) *Rule {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.seen == nil {
+		t.seen = make([]int, len(t.Rules))
+		t.injects = map[Fault]int{}
+	}
+	var failing *Rule
+	for i := range t.Rules {
+		rule := &t.Rules[i]
+		if rule.Match != nil && !rule.Match(req) {
+			continue
+		}
+		t.seen[i]++
+		n := t.seen[i] - rule.After
+		if failing == nil && n > 0 && (rule.Count == 0 || n <= rule.Count) {
+			failing = rule
+		}
+	}
+	if failing != nil {
+		t.injects[failing.Fault]++
+	}
+	return failing
+}
+
+func inject(req *http.Request, rule *Rule) (*http.Response, error) {
+	switch rule.Fault {
+	case RateLimit:
+		return errorResponse(req, http.StatusTooManyRequests, "usageLimits", "rateLimitExceeded", "Rate Limit Exceeded"), nil
+	case ServerError:
+		return errorResponse(req, http.StatusInternalServerError, "global", "backendError", "Backend Error"), nil
+	case Timeout:
+		delay := rule.Delay
+		if delay == 0 {
+			delay = time.Minute
+		}
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-timer.C:
+			return nil, timeoutError{}
+		}
+	}
+	return nil, fmt.Errorf("unknown fault %v", rule.Fault)
+}
+
+// errorResponse returns a response shaped like the errors the google
+// apis return, so that callers see a *googleapi.Error.
+func errorResponse(req *http.Request, code int, domain, reason, message string) *http.Response {
+	type item struct {
+		Domain  string `json:"domain"`
+		Reason  string `json:"reason"`
+		Message string `json:"message"`
+	}
+	var body struct {
+		Error struct {
+			Errors  []item `json:"errors"`
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	body.Error.Errors = []item{{domain, reason, message}}
+	body.Error.Code = code
+	body.Error.Message = message
+	b, _ := json.Marshal(&body)
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", code, http.StatusText(code)),
+		StatusCode:    code,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json; charset=UTF-8"}},
+		Body:          ioutil.NopCloser(bytes.NewReader(b)),
+		ContentLength: int64(len(b)),
+		Request:       req,
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "calsynctest: injected timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
@@ -0,0 +1,117 @@
+package calsynctest
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestFaultTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	ft := &FaultTransport{
+		Rules: []Rule{
+			{Fault: RateLimit, Match: Method("DELETE")},
+			{Fault: ServerError, After: 1, Count: 2},
+		},
+	}
+	client := &http.Client{Transport: ft}
+
+	var codes []int
+	for i := 0; i < 4; i++ {
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		codes = append(codes, resp.StatusCode)
+	}
+	want := []int{200, 500, 500, 200}
+	for i := range want {
+		if codes[i] != want[i] {
+			t.Fatalf("got codes %v, want %v", codes, want)
+		}
+	}
+
+	req, _ := http.NewRequest("DELETE", srv.URL, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("got %d, want %d", resp.StatusCode, http.StatusTooManyRequests)
+	}
+	if !strings.Contains(string(body), "rateLimitExceeded") {
+		t.Errorf("got body %s, want a rateLimitExceeded error", body)
+	}
+
+	if n := ft.Injected(ServerError); n != 2 {
+		t.Errorf("got %d server errors, want 2", n)
+	}
+	if n := ft.Injected(RateLimit); n != 1 {
+		t.Errorf("got %d rate limits, want 1", n)
+	}
+}
+
+func TestFaultTransportPaged(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: &FaultTransport{
+		Rules: []Rule{{Fault: ServerError, Match: Paged}},
+	}}
+	for _, tc := range []struct {
+		query string
+		want  int
+	}{
+		{"", http.StatusOK},
+		{"?pageToken=abc", http.StatusInternalServerError},
+	} {
+		resp, err := client.Get(srv.URL + tc.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.want {
+			t.Errorf("%q: got %d, want %d", tc.query, resp.StatusCode, tc.want)
+		}
+	}
+}
+
+func TestFaultTransportTimeout(t *testing.T) {
+	client := &http.Client{Transport: &FaultTransport{
+		Rules: []Rule{{Fault: Timeout, Delay: time.Millisecond}},
+	}}
+	_, err := client.Get("http://example.invalid/")
+	if e, ok := err.(net.Error); !ok || !e.Timeout() {
+		t.Errorf("got %v, want a timeout", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	client = &http.Client{Transport: &FaultTransport{
+		Rules: []Rule{{Fault: Timeout}},
+	}}
+	req, _ := http.NewRequest("GET", "http://example.invalid/", nil)
+	start := time.Now()
+	_, err = client.Do(req.WithContext(ctx))
+	if err == nil {
+		t.Errorf("expected an error from a cancelled request")
+	}
+	if time.Since(start) > 10*time.Second {
+		t.Errorf("cancelled request hung")
+	}
+}
@@ -0,0 +1,94 @@
+package calsynctest
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+type period struct {
+	start, end time.Time
+}
+
+type byPeriodStart []period
+
+func (s byPeriodStart) Len() int           { return len(s) }
+func (s byPeriodStart) Less(i, j int) bool { return s[i].start.Before(s[j].start) }
+func (s byPeriodStart) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// freeBusy answers a free/busy query with the busy periods of each
+// calendar it names: the times of its events that are neither
+// cancelled nor transparent, cut to the range of the query, and merged
+// where they overlap.  Calendars it doesn't have are reported with a
+// notFound error, as google calendar does for calendars that can't be
+// seen.
+func (s *Server) freeBusy(r *http.Request) (interface{}, error) {
+	req := &calendar.FreeBusyRequest{}
+	if err := decode(r, req); err != nil {
+		return nil, err
+	}
+	min, err := time.Parse(time.RFC3339, req.TimeMin)
+	if err != nil {
+		return nil, errorf(http.StatusBadRequest, "invalid", "Bad timeMin %q", req.TimeMin)
+	}
+	max, err := time.Parse(time.RFC3339, req.TimeMax)
+	if err != nil || !max.After(min) {
+		return nil, errorf(http.StatusBadRequest, "invalid", "Bad timeMax %q", req.TimeMax)
+	}
+	resp := &calendar.FreeBusyResponse{
+		TimeMin:   req.TimeMin,
+		TimeMax:   req.TimeMax,
+		Calendars: map[string]calendar.FreeBusyCalendar{},
+	}
+	for _, item := range req.Items {
+		c, ok := s.calendars[item.Id]
+		if !ok {
+			resp.Calendars[item.Id] = calendar.FreeBusyCalendar{
+				Errors: []*calendar.Error{{Domain: "global", Reason: "notFound"}},
+			}
+			continue
+		}
+		var periods []period
+		for _, fe := range c.events {
+			if fe.ev.Status == "cancelled" || fe.ev.Transparency == "transparent" {
+				continue
+			}
+			start, err := eventTime(fe.ev.Start)
+			if err != nil {
+				return nil, err
+			}
+			end, err := eventTime(fe.ev.End)
+			if err != nil {
+				return nil, err
+			}
+			if !start.Before(max) || !end.After(min) {
+				continue
+			}
+			if start.Before(min) {
+				start = min
+			}
+			if end.After(max) {
+				end = max
+			}
+			periods = append(periods, period{start, end})
+		}
+		sort.Sort(byPeriodStart(periods))
+		var busy []*calendar.TimePeriod
+		for i := 0; i < len(periods); {
+			p := periods[i]
+			for i++; i < len(periods) && !periods[i].start.After(p.end); i++ {
+				if periods[i].end.After(p.end) {
+					p.end = periods[i].end
+				}
+			}
+			busy = append(busy, &calendar.TimePeriod{
+				Start: p.start.UTC().Format(time.RFC3339),
+				End:   p.end.UTC().Format(time.RFC3339),
+			})
+		}
+		resp.Calendars[item.Id] = calendar.FreeBusyCalendar{Busy: busy}
+	}
+	return resp, nil
+}
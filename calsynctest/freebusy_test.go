@@ -0,0 +1,52 @@
+package calsynctest
+
+import (
+	"testing"
+
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+func TestServerFreeBusy(t *testing.T) {
+	s := NewServer()
+	svc := newService(t, s)
+	put := func(start, end, transparency string) {
+		_, err := s.Put("primary", &calendar.Event{
+			Summary:      "busy",
+			Start:        &calendar.EventDateTime{DateTime: start},
+			End:          &calendar.EventDateTime{DateTime: end},
+			Transparency: transparency,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	put("2030-01-01T09:00:00Z", "2030-01-01T10:00:00Z", "")
+	put("2030-01-01T09:30:00Z", "2030-01-01T11:00:00Z", "")
+	put("2030-01-01T12:00:00Z", "2030-01-01T13:00:00Z", "transparent")
+	put("2030-01-01T16:00:00Z", "2030-01-01T18:00:00Z", "")
+
+	resp, err := svc.Freebusy.Query(&calendar.FreeBusyRequest{
+		TimeMin: "2030-01-01T08:00:00Z",
+		TimeMax: "2030-01-01T17:00:00Z",
+		Items:   []*calendar.FreeBusyRequestItem{{Id: "primary"}, {Id: "someone@example.com"}},
+	}).Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	busy := resp.Calendars["primary"].Busy
+	want := []calendar.TimePeriod{
+		{Start: "2030-01-01T09:00:00Z", End: "2030-01-01T11:00:00Z"},
+		{Start: "2030-01-01T16:00:00Z", End: "2030-01-01T17:00:00Z"},
+	}
+	if len(busy) != len(want) {
+		t.Fatalf("got %d busy periods, want %d", len(busy), len(want))
+	}
+	for i, p := range busy {
+		if *p != want[i] {
+			t.Errorf("got busy period %+v, want %+v", *p, want[i])
+		}
+	}
+	if errs := resp.Calendars["someone@example.com"].Errors; len(errs) != 1 || errs[0].Reason != "notFound" {
+		t.Errorf("got errors %+v, want notFound", errs)
+	}
+}
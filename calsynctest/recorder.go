@@ -0,0 +1,220 @@
+package calsynctest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// Interaction is a request and the response it got, as recorded by
+// Recorder.
+type Interaction struct {
+	Method string `json:"method"`
+
+	// URL is the path and query of the request.
+	URL         string `json:"url"`
+	RequestBody string `json:"request_body,omitempty"`
+
+	Status       int         `json:"status"`
+	Header       http.Header `json:"header,omitempty"`
+	ResponseBody string      `json:"response_body,omitempty"`
+}
+
+// Fixture is a sequence of recorded interactions, which Replayer plays
+// back.
+type Fixture struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// ReadFixture reads a fixture saved by Fixture.Save.
+func ReadFixture(path string) (*Fixture, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f := &Fixture{}
+	if err = json.Unmarshal(b, f); err != nil {
+		return nil, fmt.Errorf("malformed fixture %s: %v", path, err)
+	}
+	return f, nil
+}
+
+// Save writes f to path as indented JSON, so that it can be checked in
+// and reviewed.
+func (f *Fixture) Save(path string) error {
+	b, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(b, '\n'), 0644)
+}
+
+// Recorder is an http.RoundTripper that passes requests on to Base,
+// typically the transport of a client authorized against the real
+// google calendar api, and records each request and response, so that
+// they can be saved as a Fixture and replayed with Replayer, for
+// example in CI, where there are no credentials:
+//
+//	rec := &calsynctest.Recorder{Base: client.Transport}
+//	client.Transport = rec
+//	... sync with client, using calsync.WithNow ...
+//	err := rec.Fixture().Save("testdata/sync.json")
+//
+// Request headers, which hold credentials, are not recorded.
+type Recorder struct {
+	// Base performs the requests.  If nil, http.DefaultTransport is
+	// used.
+	Base http.RoundTripper
+
+	mu           sync.Mutex
+	interactions []*Interaction
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	in := &Interaction{Method: req.Method, URL: req.URL.RequestURI()}
+	if req.Body != nil {
+		b, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		in.RequestBody = string(b)
+		req.Body = ioutil.NopCloser(bytes.NewReader(b))
+	}
+	base := r.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(b))
+	in.Status = resp.StatusCode
+	in.Header = resp.Header
+	in.ResponseBody = string(b)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.interactions = append(r.interactions, in)
+	return resp, nil
+}
+
+// Fixture returns what r has recorded so far.
+func (r *Recorder) Fixture() *Fixture {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return &Fixture{Interactions: append([]*Interaction(nil), r.interactions...)}
+}
+
+// Replayer is an http.RoundTripper that answers requests with the
+// responses recorded in a Fixture, without any networking.  Each
+// request is answered by the first interaction not yet replayed with
+// the same method, path and query, so independent requests may come in
+// a different order than they were recorded.  A request that matches
+// nothing fails.
+//
+// Queries depend on the current time, for example through the timeMin
+// parameter of listings, so record and replay with the same
+// calsync.WithNow, or list the parameters in IgnoreParams.
+type Replayer struct {
+	// IgnoreParams lists query parameters that are left out when
+	// matching requests.
+	IgnoreParams []string
+
+	mu       sync.Mutex
+	fixture  *Fixture
+	replayed []bool
+}
+
+// NewReplayer returns a Replayer for f.
+func NewReplayer(f *Fixture) *Replayer {
+	return &Replayer{fixture: f, replayed: make([]bool, len(f.Interactions))}
+}
+
+// Client returns an http.Client that r answers.
+func (r *Replayer) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// Remaining returns how many interactions have not been replayed.
+func (r *Replayer) Remaining() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, done := range r.replayed {
+		if !done {
+			n++
+		}
+	}
+	return n
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	want, err := r.key(req.Method, req.URL.RequestURI())
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, in := range r.fixture.Interactions {
+		if r.replayed[i] {
+			continue
+		}
+		got, err := r.key(in.Method, in.URL)
+		if err != nil {
+			return nil, err
+		}
+		if got != want {
+			continue
+		}
+		r.replayed[i] = true
+		header := http.Header{}
+		for k, v := range in.Header {
+			header[k] = v
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", in.Status, http.StatusText(in.Status)),
+			StatusCode:    in.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          ioutil.NopCloser(bytes.NewReader([]byte(in.ResponseBody))),
+			ContentLength: int64(len(in.ResponseBody)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("calsynctest: no recorded interaction left for %s", want)
+}
+
+// key returns what requests are matched by: the method, path and query,
+// without IgnoreParams, with the query in canonical order.
+func (r *Replayer) key(method, uri string) (string, error) {
+	u, err := url.ParseRequestURI(uri)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	for _, p := range r.IgnoreParams {
+		q.Del(p)
+	}
+	return fmt.Sprintf("%s %s?%s", method, u.Path, q.Encode()), nil
+}
@@ -0,0 +1,76 @@
+package calsynctest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+func TestRecordReplay(t *testing.T) {
+	s := NewServer()
+	rec := &Recorder{Base: s.Client().Transport}
+	svc, err := calendar.New(&http.Client{Transport: rec})
+	if err != nil {
+		t.Fatal(err)
+	}
+	added, err := svc.Events.Insert("primary", testEvent("recorded", 1, nil)).Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = svc.Events.List("primary").TimeMin("2030-01-01T00:00:00Z").Do(); err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "calsynctest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "fixture.json")
+	if err = rec.Fixture().Save(path); err != nil {
+		t.Fatal(err)
+	}
+	f, err := ReadFixture(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Interactions) != 2 {
+		t.Fatalf("got %d interactions, want 2", len(f.Interactions))
+	}
+
+	r := NewReplayer(f)
+	r.IgnoreParams = []string{"timeMin"}
+	svc, err = calendar.New(r.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The listing is replayed first, though it was recorded second, and
+	// with another timeMin.
+	events, err := svc.Events.List("primary").TimeMin("2031-01-01T00:00:00Z").Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events.Items) != 1 || events.Items[0].Id != added.Id {
+		t.Errorf("got %+v, want the recorded event", events.Items)
+	}
+	replayed, err := svc.Events.Insert("primary", testEvent("recorded", 1, nil)).Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed.Id != added.Id {
+		t.Errorf("got id %q, want %q", replayed.Id, added.Id)
+	}
+	if n := r.Remaining(); n != 0 {
+		t.Errorf("got %d interactions remaining, want 0", n)
+	}
+	if err = svc.Events.Delete("primary", added.Id).Do(); err == nil {
+		t.Error("expected an error for a request that wasn't recorded")
+	}
+	if n := s.Requests(); n != 2 {
+		t.Errorf("got %d requests to the server, want 2", n)
+	}
+}
@@ -0,0 +1,737 @@
+package calsynctest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+// DefaultPageSize is how many events Server returns per page of a list,
+// unless asked for fewer.
+const DefaultPageSize = 250
+
+// Server is an in-memory fake of the parts of the google calendar api
+// that calsync uses: listing, getting, inserting, importing, updating,
+// patching, deleting and watching events, including extended property
+// filters, paging and sync tokens, plus calendar list entries,
+// settings and free/busy queries.
+//
+// Use Client to get an http.Client that talks to it directly, without
+// any networking, and pass that to calsync in place of an authorized
+// client.  A Server can also be mounted with httptest.NewServer, as it
+// is an http.Handler.
+type Server struct {
+	// PageSize, if set, overrides DefaultPageSize.
+	PageSize int
+
+	// Now, if set, is used in place of time.Now for the Date header of
+	// responses, for example to simulate a skewed local clock, and for
+	// the expiration of notification channels.
+	Now func() time.Time
+
+	mu        sync.Mutex
+	calendars map[string]*fakeCalendar
+	seq       int64
+	purged    int64
+	nextID    int
+	requests  int
+
+	// channels holds the notification channels that were registered
+	// and not stopped, by id.
+	channels map[string]*fakeChannel
+}
+
+type fakeCalendar struct {
+	entry  calendar.CalendarListEntry
+	events map[string]*fakeEvent
+}
+
+type fakeEvent struct {
+	ev *calendar.Event
+	// seq is the value of Server.seq when the event last changed, used
+	// to implement sync tokens.
+	seq int64
+}
+
+// NewServer returns a Server with a single, empty, primary calendar in
+// UTC.
+func NewServer() *Server {
+	s := &Server{calendars: map[string]*fakeCalendar{}, channels: map[string]*fakeChannel{}}
+	s.AddCalendar("primary", "Primary", "UTC")
+	s.calendars["primary"].entry.Primary = true
+	return s
+}
+
+// AddCalendar adds an empty calendar with the given id, summary and
+// timezone, replacing any existing calendar with that id.
+func (s *Server) AddCalendar(id, summary, timeZone string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calendars[id] = &fakeCalendar{
+		entry: calendar.CalendarListEntry{
+			Id:         id,
+			Summary:    summary,
+			TimeZone:   timeZone,
+			AccessRole: "owner",
+		},
+		events: map[string]*fakeEvent{},
+	}
+}
+
+// SetAccessRole sets the access role the authorized user has on
+// calendar calID, such as "reader" or "writer".  Calendars start out
+// with "owner".  The role is only reported; it is not enforced.
+func (s *Server) SetAccessRole(calID, role string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok := s.calendars[calID]; ok {
+		c.entry.AccessRole = role
+	}
+}
+
+// Calendars returns copies of the entries in the user's calendar list,
+// ordered by id.
+func (s *Server) Calendars() []*calendar.CalendarListEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calendarList().Items
+}
+
+// Events returns copies of the events in calendar calID that have not
+// been deleted, ordered by start time.
+func (s *Server) Events(calID string) []*calendar.Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.calendars[calID]
+	if !ok {
+		return nil
+	}
+	var events []*calendar.Event
+	for _, fe := range c.sorted() {
+		if fe.ev.Status != "cancelled" {
+			events = append(events, copyEvent(fe.ev))
+		}
+	}
+	return events
+}
+
+// Put stores a copy of ev in calendar calID, as if someone had edited
+// it in the google calendar ui.  If ev.Id is empty, one is assigned.
+// It returns the stored event.
+func (s *Server) Put(calID string, ev *calendar.Event) (*calendar.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.calendars[calID]
+	if !ok {
+		return nil, fmt.Errorf("no calendar %q", calID)
+	}
+	return copyEvent(s.store(c, copyEvent(ev)).ev), nil
+}
+
+// Purge forgets deleted events, which are otherwise kept so they can be
+// reported to incremental listings, as google calendar eventually does.
+// Sync tokens issued before the purge become invalid.
+func (s *Server) Purge() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.calendars {
+		for id, fe := range c.events {
+			if fe.ev.Status == "cancelled" {
+				delete(c.events, id)
+			}
+		}
+	}
+	s.purged = s.seq
+}
+
+// Requests returns how many requests the server has handled.
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// Client returns an http.Client that sends every request directly to s,
+// whatever host it is addressed to.
+func (s *Server) Client() *http.Client {
+	return &http.Client{Transport: serverTransport{s}}
+}
+
+type serverTransport struct {
+	s *Server
+}
+
+func (t serverTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+	rec := httptest.NewRecorder()
+	t.s.ServeHTTP(rec, req)
+	resp := rec.Result()
+	resp.Request = req
+	return resp, nil
+}
+
+// apiError is an error response, shaped the way the google apis shape
+// them.
+type apiError struct {
+	code    int
+	reason  string
+	message string
+}
+
+func (e *apiError) Error() string { return e.message }
+
+func errorf(code int, reason, format string, args ...interface{}) *apiError {
+	return &apiError{code, reason, fmt.Sprintf(format, args...)}
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	w.Header().Set("Date", s.now().UTC().Format(http.TimeFormat))
+
+	result, err := s.route(r)
+	if err != nil {
+		apiErr, ok := err.(*apiError)
+		if !ok {
+			apiErr = errorf(http.StatusBadRequest, "badRequest", "%v", err)
+		}
+		writeError(w, apiErr)
+		return
+	}
+	if result == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(result)
+}
+
+// now returns the time, from Now if it is set.
+func (s *Server) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+func writeError(w http.ResponseWriter, e *apiError) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(e.code)
+	fmt.Fprintf(w, `{"error":{"errors":[{"domain":"global","reason":%q,"message":%q}],"code":%d,"message":%q}}`,
+		e.reason, e.message, e.code, e.message)
+}
+
+func (s *Server) route(r *http.Request) (interface{}, error) {
+	path := r.URL.Path
+	if i := strings.Index(path, "/calendar/v3/"); i >= 0 {
+		path = path[i+len("/calendar/v3/"):]
+	}
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+
+	switch {
+	case match(parts, "users", "me", "calendarList") && r.Method == "GET":
+		return s.calendarList(), nil
+	case match(parts, "users", "me", "calendarList", "*") && r.Method == "GET":
+		c, err := s.calendar(parts[3])
+		if err != nil {
+			return nil, err
+		}
+		entry := c.entry
+		return &entry, nil
+	case match(parts, "users", "me", "calendarList", "*") && r.Method == "PATCH":
+		return s.patchCalendar(parts[3], r)
+	case match(parts, "users", "me", "settings", "*") && r.Method == "GET":
+		return s.setting(parts[3])
+	case match(parts, "calendars") && r.Method == "POST":
+		return s.insertCalendar(r)
+	case match(parts, "freeBusy") && r.Method == "POST":
+		return s.freeBusy(r)
+	case match(parts, "calendars", "*", "events", "watch") && r.Method == "POST":
+		return s.watch(parts[1], r)
+	case match(parts, "channels", "stop") && r.Method == "POST":
+		return nil, s.stopChannel(r)
+	case match(parts, "calendars", "*", "events", "import") && r.Method == "POST":
+		return s.importEvent(parts[1], r)
+	case match(parts, "calendars", "*", "events"):
+		switch r.Method {
+		case "GET":
+			return s.list(parts[1], r.URL.Query())
+		case "POST":
+			return s.insert(parts[1], r)
+		}
+	case match(parts, "calendars", "*", "events", "*"):
+		switch r.Method {
+		case "GET":
+			return s.get(parts[1], parts[3])
+		case "PUT":
+			return s.update(parts[1], parts[3], r)
+		case "PATCH":
+			return s.patch(parts[1], parts[3], r)
+		case "DELETE":
+			return nil, s.delete(parts[1], parts[3])
+		}
+	}
+	return nil, errorf(http.StatusNotFound, "notFound", "%s %s is not supported", r.Method, r.URL.Path)
+}
+
+// match reports whether parts matches pattern, where "*" matches any
+// single part.
+func match(parts []string, pattern ...string) bool {
+	if len(parts) != len(pattern) {
+		return false
+	}
+	for i, p := range pattern {
+		if p != "*" && p != parts[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Server) calendar(calID string) (*fakeCalendar, error) {
+	c, ok := s.calendars[calID]
+	if !ok {
+		return nil, errorf(http.StatusNotFound, "notFound", "Not Found")
+	}
+	return c, nil
+}
+
+func (s *Server) calendarList() *calendar.CalendarList {
+	var ids []string
+	for id := range s.calendars {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	list := &calendar.CalendarList{}
+	for _, id := range ids {
+		entry := s.calendars[id].entry
+		list.Items = append(list.Items, &entry)
+	}
+	return list
+}
+
+func (s *Server) setting(name string) (interface{}, error) {
+	switch name {
+	case "timezone":
+		return &calendar.Setting{Id: name, Value: s.calendars["primary"].entry.TimeZone}, nil
+	case "weekStart":
+		return &calendar.Setting{Id: name, Value: "0"}, nil
+	}
+	return nil, errorf(http.StatusNotFound, "notFound", "Not Found")
+}
+
+func (s *Server) insertCalendar(r *http.Request) (interface{}, error) {
+	in := &calendar.Calendar{}
+	if err := decode(r, in); err != nil {
+		return nil, err
+	}
+	s.nextID++
+	in.Id = fmt.Sprintf("cal%d@group.calendar.google.com", s.nextID)
+	if in.TimeZone == "" {
+		in.TimeZone = "UTC"
+	}
+	s.calendars[in.Id] = &fakeCalendar{
+		entry: calendar.CalendarListEntry{
+			Id:         in.Id,
+			Summary:    in.Summary,
+			TimeZone:   in.TimeZone,
+			AccessRole: "owner",
+		},
+		events: map[string]*fakeEvent{},
+	}
+	return in, nil
+}
+
+// patchCalendar updates the user's settings for a calendar.  Only the
+// color can be changed.
+func (s *Server) patchCalendar(calID string, r *http.Request) (interface{}, error) {
+	c, err := s.calendar(calID)
+	if err != nil {
+		return nil, err
+	}
+	in := &calendar.CalendarListEntry{}
+	if err := decode(r, in); err != nil {
+		return nil, err
+	}
+	if in.ColorId != "" {
+		c.entry.ColorId = in.ColorId
+	}
+	entry := c.entry
+	return &entry, nil
+}
+
+func (s *Server) list(calID string, q url.Values) (interface{}, error) {
+	c, err := s.calendar(calID)
+	if err != nil {
+		return nil, err
+	}
+
+	var since int64 = -1
+	if token := q.Get("syncToken"); token != "" {
+		for _, p := range []string{"privateExtendedProperty", "sharedExtendedProperty", "timeMin", "timeMax", "iCalUID", "q"} {
+			if q.Get(p) != "" {
+				return nil, errorf(http.StatusBadRequest, "invalid", "%s can't be combined with syncToken", p)
+			}
+		}
+		since, err = strconv.ParseInt(token, 10, 64)
+		if err != nil || since > s.seq || since < s.purged {
+			return nil, errorf(http.StatusGone, "fullSyncRequired", "Sync token is no longer valid, a full sync is required.")
+		}
+	}
+
+	var matched []*calendar.Event
+	for _, fe := range c.sorted() {
+		if since >= 0 {
+			if fe.seq > since {
+				matched = append(matched, fe.ev)
+			}
+			continue
+		}
+		ok, err := matches(fe.ev, q)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			matched = append(matched, fe.ev)
+		}
+	}
+
+	pageSize := s.PageSize
+	if pageSize == 0 {
+		pageSize = DefaultPageSize
+	}
+	if max, err := strconv.Atoi(q.Get("maxResults")); err == nil && max > 0 && max < pageSize {
+		pageSize = max
+	}
+	start := 0
+	if token := q.Get("pageToken"); token != "" {
+		if start, err = strconv.Atoi(token); err != nil || start > len(matched) {
+			return nil, errorf(http.StatusBadRequest, "invalid", "Invalid page token %q", token)
+		}
+	}
+	end := start + pageSize
+	if end > len(matched) {
+		end = len(matched)
+	}
+
+	result := &calendar.Events{
+		Summary:  c.entry.Summary,
+		TimeZone: c.entry.TimeZone,
+	}
+	for _, ev := range matched[start:end] {
+		result.Items = append(result.Items, copyEvent(ev))
+	}
+	if end < len(matched) {
+		result.NextPageToken = strconv.Itoa(end)
+	} else {
+		result.NextSyncToken = strconv.FormatInt(s.seq, 10)
+	}
+	return result, nil
+}
+
+// matches reports whether ev passes the filters of a full list call.
+func matches(ev *calendar.Event, q url.Values) (bool, error) {
+	if ev.Status == "cancelled" && q.Get("showDeleted") != "true" {
+		return false, nil
+	}
+	var props, shared map[string]string
+	if ev.ExtendedProperties != nil {
+		props = ev.ExtendedProperties.Private
+		shared = ev.ExtendedProperties.Shared
+	}
+	if !hasProps(props, q["privateExtendedProperty"]) || !hasProps(shared, q["sharedExtendedProperty"]) {
+		return false, nil
+	}
+	if uid := q.Get("iCalUID"); uid != "" && ev.ICalUID != uid {
+		return false, nil
+	}
+	if text := q.Get("q"); text != "" &&
+		!strings.Contains(ev.Summary+"\n"+ev.Description+"\n"+ev.Location, text) {
+		return false, nil
+	}
+	if min := q.Get("timeMin"); min != "" {
+		t, err := time.Parse(time.RFC3339, min)
+		if err != nil {
+			return false, errorf(http.StatusBadRequest, "invalid", "Bad timeMin %q", min)
+		}
+		if end, err := eventTime(ev.End); err != nil || !end.After(t) {
+			return false, err
+		}
+	}
+	if max := q.Get("timeMax"); max != "" {
+		t, err := time.Parse(time.RFC3339, max)
+		if err != nil {
+			return false, errorf(http.StatusBadRequest, "invalid", "Bad timeMax %q", max)
+		}
+		if start, err := eventTime(ev.Start); err != nil || !start.Before(t) {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func hasProps(props map[string]string, filters []string) bool {
+	for _, f := range filters {
+		kv := strings.SplitN(f, "=", 2)
+		if len(kv) != 2 || props[kv[0]] != kv[1] {
+			return false
+		}
+	}
+	return true
+}
+
+func eventTime(dt *calendar.EventDateTime) (time.Time, error) {
+	if dt == nil {
+		return time.Time{}, errorf(http.StatusBadRequest, "required", "Missing time")
+	}
+	if dt.Date != "" {
+		return time.Parse("2006-01-02", dt.Date)
+	}
+	return time.Parse(time.RFC3339, dt.DateTime)
+}
+
+func (s *Server) get(calID, eventID string) (interface{}, error) {
+	c, err := s.calendar(calID)
+	if err != nil {
+		return nil, err
+	}
+	fe, ok := c.events[eventID]
+	if !ok {
+		return nil, errorf(http.StatusNotFound, "notFound", "Not Found")
+	}
+	return copyEvent(fe.ev), nil
+}
+
+func (s *Server) insert(calID string, r *http.Request) (interface{}, error) {
+	c, err := s.calendar(calID)
+	if err != nil {
+		return nil, err
+	}
+	ev := &calendar.Event{}
+	if err = decode(r, ev); err != nil {
+		return nil, err
+	}
+	if err = validate(ev); err != nil {
+		return nil, err
+	}
+	ev.Id = ""
+	return copyEvent(s.store(c, ev).ev), nil
+}
+
+func (s *Server) importEvent(calID string, r *http.Request) (interface{}, error) {
+	c, err := s.calendar(calID)
+	if err != nil {
+		return nil, err
+	}
+	ev := &calendar.Event{}
+	if err = decode(r, ev); err != nil {
+		return nil, err
+	}
+	if ev.ICalUID == "" {
+		return nil, errorf(http.StatusBadRequest, "required", "Missing iCalUID")
+	}
+	if err = validate(ev); err != nil {
+		return nil, err
+	}
+	ev.Id = ""
+	for id, fe := range c.events {
+		if fe.ev.ICalUID == ev.ICalUID {
+			ev.Id = id
+		}
+	}
+	return copyEvent(s.store(c, ev).ev), nil
+}
+
+func (s *Server) update(calID, eventID string, r *http.Request) (interface{}, error) {
+	c, err := s.calendar(calID)
+	if err != nil {
+		return nil, err
+	}
+	fe, ok := c.events[eventID]
+	if !ok {
+		return nil, errorf(http.StatusNotFound, "notFound", "Not Found")
+	}
+	ev := &calendar.Event{}
+	if err = decode(r, ev); err != nil {
+		return nil, err
+	}
+	if err = validate(ev); err != nil {
+		return nil, err
+	}
+	ev.Id = eventID
+	ev.ICalUID = fe.ev.ICalUID
+	ev.Created = fe.ev.Created
+	return copyEvent(s.store(c, ev).ev), nil
+}
+
+func (s *Server) patch(calID, eventID string, r *http.Request) (interface{}, error) {
+	c, err := s.calendar(calID)
+	if err != nil {
+		return nil, err
+	}
+	fe, ok := c.events[eventID]
+	if !ok {
+		return nil, errorf(http.StatusNotFound, "notFound", "Not Found")
+	}
+	var patch map[string]interface{}
+	if err = decode(r, &patch); err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(fe.ev)
+	if err != nil {
+		return nil, err
+	}
+	var current map[string]interface{}
+	if err = json.Unmarshal(b, &current); err != nil {
+		return nil, err
+	}
+	merge(current, patch)
+	if b, err = json.Marshal(current); err != nil {
+		return nil, err
+	}
+	ev := &calendar.Event{}
+	if err = json.Unmarshal(b, ev); err != nil {
+		return nil, err
+	}
+	if err = validate(ev); err != nil {
+		return nil, err
+	}
+	ev.Id = eventID
+	return copyEvent(s.store(c, ev).ev), nil
+}
+
+// merge applies patch to dst the way the google apis apply patch
+// requests: objects are merged recursively and everything else,
+// including arrays, is replaced.
+func merge(dst, patch map[string]interface{}) {
+	for k, v := range patch {
+		sub, isObj := v.(map[string]interface{})
+		cur, curIsObj := dst[k].(map[string]interface{})
+		if isObj && curIsObj {
+			merge(cur, sub)
+			continue
+		}
+		dst[k] = v
+	}
+}
+
+func (s *Server) delete(calID, eventID string) error {
+	c, err := s.calendar(calID)
+	if err != nil {
+		return err
+	}
+	fe, ok := c.events[eventID]
+	if !ok {
+		return errorf(http.StatusNotFound, "notFound", "Not Found")
+	}
+	if fe.ev.Status == "cancelled" {
+		return errorf(http.StatusGone, "deleted", "Resource has been deleted")
+	}
+	ev := copyEvent(fe.ev)
+	ev.Status = "cancelled"
+	s.store(c, ev)
+	return nil
+}
+
+// store saves ev in c, assigning an id if it has none, and stamping it
+// as changed.
+func (s *Server) store(c *fakeCalendar, ev *calendar.Event) *fakeEvent {
+	s.seq++
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	if ev.Id == "" {
+		s.nextID++
+		ev.Id = fmt.Sprintf("ev%d", s.nextID)
+		ev.Created = now
+	}
+	if ev.ICalUID == "" {
+		ev.ICalUID = ev.Id + "@calsynctest"
+	}
+	if ev.Status == "" {
+		ev.Status = "confirmed"
+	}
+	ev.Etag = fmt.Sprintf(`"%d"`, s.seq)
+	ev.Updated = now
+	ev.Kind = "calendar#event"
+	fe := &fakeEvent{ev: ev, seq: s.seq}
+	c.events[ev.Id] = fe
+	return fe
+}
+
+func validate(ev *calendar.Event) error {
+	if _, err := eventTime(ev.Start); err != nil {
+		return errorf(http.StatusBadRequest, "required", "Missing or invalid start time.")
+	}
+	if _, err := eventTime(ev.End); err != nil {
+		return errorf(http.StatusBadRequest, "required", "Missing or invalid end time.")
+	}
+	if ev.ExtendedProperties != nil {
+		for _, props := range []map[string]string{ev.ExtendedProperties.Private, ev.ExtendedProperties.Shared} {
+			for k, v := range props {
+				if len(k) > 44 || len(v) > 1024 {
+					return errorf(http.StatusBadRequest, "invalid", "Invalid extended property %q", k)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// sorted returns the events in c ordered by start time, then by id.
+func (c *fakeCalendar) sorted() []*fakeEvent {
+	var events []*fakeEvent
+	for _, fe := range c.events {
+		events = append(events, fe)
+	}
+	sort.Sort(byStart(events))
+	return events
+}
+
+type byStart []*fakeEvent
+
+func (s byStart) Len() int      { return len(s) }
+func (s byStart) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byStart) Less(i, j int) bool {
+	a, _ := eventTime(s[i].ev.Start)
+	b, _ := eventTime(s[j].ev.Start)
+	if !a.Equal(b) {
+		return a.Before(b)
+	}
+	return s[i].ev.Id < s[j].ev.Id
+}
+
+func decode(r *http.Request, v interface{}) error {
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(b, v); err != nil {
+		return errorf(http.StatusBadRequest, "parseError", "Parse Error: %v", err)
+	}
+	return nil
+}
+
+func copyEvent(ev *calendar.Event) *calendar.Event {
+	b, err := json.Marshal(ev)
+	if err != nil {
+		panic(err)
+	}
+	out := &calendar.Event{}
+	if err = json.Unmarshal(b, out); err != nil {
+		panic(err)
+	}
+	return out
+}
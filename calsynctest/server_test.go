@@ -0,0 +1,147 @@
+package calsynctest
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	calendar "google.golang.org/api/calendar/v3"
+	"google.golang.org/api/googleapi"
+
+	"golang.org/x/net/context"
+)
+
+func newService(t *testing.T, s *Server) *calendar.Service {
+	svc, err := calendar.New(s.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return svc
+}
+
+func testEvent(summary string, day int, props map[string]string) *calendar.Event {
+	return &calendar.Event{
+		Summary: summary,
+		Start:   &calendar.EventDateTime{DateTime: fmt.Sprintf("2030-01-%02dT10:00:00Z", day)},
+		End:     &calendar.EventDateTime{DateTime: fmt.Sprintf("2030-01-%02dT11:00:00Z", day)},
+		ExtendedProperties: &calendar.EventExtendedProperties{
+			Private: props,
+		},
+	}
+}
+
+func TestServerListFilters(t *testing.T) {
+	s := NewServer()
+	s.PageSize = 2
+	svc := newService(t, s)
+
+	for i := 1; i <= 5; i++ {
+		_, err := svc.Events.Insert("primary", testEvent(fmt.Sprint(i), i, map[string]string{"test": "True"})).Do()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := svc.Events.Insert("primary", testEvent("other", 1, nil)).Do(); err != nil {
+		t.Fatal(err)
+	}
+
+	var summaries []string
+	pages := 0
+	err := svc.Events.List("primary").
+		PrivateExtendedProperty("test=True").
+		TimeMin("2030-01-02T10:30:00Z").
+		Pages(context.Background(), func(evs *calendar.Events) error {
+			pages++
+			for _, ev := range evs.Items {
+				summaries = append(summaries, ev.Summary)
+			}
+			return nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fmt.Sprint(summaries), "[2 3 4 5]"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if pages != 2 {
+		t.Errorf("got %d pages, want 2", pages)
+	}
+}
+
+func TestServerSyncToken(t *testing.T) {
+	s := NewServer()
+	svc := newService(t, s)
+
+	first, err := svc.Events.Insert("primary", testEvent("first", 1, nil)).Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := svc.Events.List("primary").Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 1 || list.NextSyncToken == "" {
+		t.Fatalf("got %d items and token %q", len(list.Items), list.NextSyncToken)
+	}
+
+	if _, err = svc.Events.Insert("primary", testEvent("second", 2, nil)).Do(); err != nil {
+		t.Fatal(err)
+	}
+	if err = svc.Events.Delete("primary", first.Id).Do(); err != nil {
+		t.Fatal(err)
+	}
+	changes, err := svc.Events.List("primary").SyncToken(list.NextSyncToken).Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes.Items) != 2 {
+		t.Fatalf("got %d changes, want 2", len(changes.Items))
+	}
+	for _, ev := range changes.Items {
+		if ev.Id == first.Id && ev.Status != "cancelled" {
+			t.Errorf("expected %s to be cancelled", ev.Id)
+		}
+	}
+
+	_, err = svc.Events.List("primary").SyncToken("999").Do()
+	if e, ok := err.(*googleapi.Error); !ok || e.Code != http.StatusGone {
+		t.Errorf("got %v, want a 410", err)
+	}
+	err = svc.Events.Delete("primary", first.Id).Do()
+	if e, ok := err.(*googleapi.Error); !ok || e.Code != http.StatusGone {
+		t.Errorf("got %v, want a 410", err)
+	}
+}
+
+func TestServerPatch(t *testing.T) {
+	s := NewServer()
+	svc := newService(t, s)
+
+	ev := testEvent("before", 1, map[string]string{"a": "1", "b": "2"})
+	ev.Location = "somewhere"
+	ev, err := svc.Events.Insert("primary", ev).Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = svc.Events.Patch("primary", ev.Id, &calendar.Event{
+		Summary: "after",
+		ExtendedProperties: &calendar.EventExtendedProperties{
+			Private: map[string]string{"b": "3"},
+		},
+	}).Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := s.Events("primary")
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want 1", len(events))
+	}
+	got := events[0]
+	if got.Summary != "after" || got.Location != "somewhere" {
+		t.Errorf("got summary %q and location %q", got.Summary, got.Location)
+	}
+	if p := got.ExtendedProperties.Private; p["a"] != "1" || p["b"] != "3" {
+		t.Errorf("got properties %v", p)
+	}
+}
@@ -0,0 +1,160 @@
+package calsync
+
+import "sort"
+
+// Feature names something optional that a destination calendar may or
+// may not support.
+type Feature string
+
+const (
+	// FeatureDryRun means Nop can report changes without making them.
+	FeatureDryRun Feature = "dry-run"
+
+	// FeatureApply means a precomputed plan can be executed with Apply.
+	FeatureApply Feature = "apply"
+
+	// FeatureIncremental means Incremental can limit fetches to events
+	// that changed since the last fetch.
+	FeatureIncremental Feature = "incremental"
+
+	// FeatureConflictDetection means events edited in the calendar
+	// since they were synced can be detected.  See ConflictPolicy.
+	FeatureConflictDetection Feature = "conflict-detection"
+
+	// FeatureComments means text the calendar user adds before the
+	// delimiter in a description survives updates.
+	FeatureComments Feature = "comments"
+
+	// FeatureAllDay means events can be written as all day events.
+	FeatureAllDay Feature = "all-day"
+
+	// FeatureWallClock means event times can be kept at the same local
+	// time in their own timezone.  See WallClock.
+	FeatureWallClock Feature = "wall-clock"
+
+	// FeatureAdopt means events created by hand can be adopted.  See
+	// Adopt.
+	FeatureAdopt Feature = "adopt"
+
+	// FeatureResurrect means events deleted by hand can be restored.
+	// See Resurrect.
+	FeatureResurrect Feature = "resurrect"
+
+	// FeatureCancel means events can be cancelled rather than deleted.
+	// See OnDelete.
+	FeatureCancel Feature = "cancel"
+
+	// FeatureICalUID means events can be matched by their iCalendar
+	// UID.  See MatchBy.
+	FeatureICalUID Feature = "ical-uid"
+
+	// FeatureRouting means events can be synced into several
+	// calendars.  See RouteTo.
+	FeatureRouting Feature = "routing"
+
+	// FeatureSandbox means events can be synced into a calendar kept
+	// for testing.  See Sandbox.
+	FeatureSandbox Feature = "sandbox"
+
+	// FeatureEncrypt means the properties that track events can be
+	// encrypted.  See Encrypt.
+	FeatureEncrypt Feature = "encrypt"
+
+	// FeatureCalendarLock means syncs can be kept from overlapping with
+	// a lock kept in the calendar.  See CalendarLock.
+	FeatureCalendarLock Feature = "calendar-lock"
+
+	// FeatureFreeBusy means adds can be checked against the free/busy
+	// times of the calendar and its attendees.  See CheckFreeBusy.
+	FeatureFreeBusy Feature = "free-busy"
+
+	// FeatureRollback means the changes of a sync can be reverted.  See
+	// Rollback.
+	FeatureRollback Feature = "rollback"
+)
+
+// CapabilitySet describes what a destination calendar supports, as
+// configured by a set of Opts.
+type CapabilitySet struct {
+	// Backend names the kind of destination calendar.
+	Backend string
+
+	// Features lists the supported features, sorted.
+	Features []Feature
+}
+
+// Supports reports whether f is in the set.
+func (cs *CapabilitySet) Supports(f Feature) bool {
+	for _, each := range cs.Features {
+		if each == f {
+			return true
+		}
+	}
+	return false
+}
+
+// Capabilities reports what Sync, Fetch and Apply support when called
+// with opts, so that generic callers can adapt their behavior rather
+// than fail at runtime.  Features that opts rule out, such as
+// Incremental once events are matched by iCalendar UID, aren't
+// included.  It returns an error for the opts that Sync would refuse,
+// and makes no api calls.
+func Capabilities(opts ...Opt) (*CapabilitySet, error) {
+	c := &cal{calID: "primary"}
+	for _, o := range opts {
+		o(c)
+	}
+	if c.optErr != nil {
+		return nil, c.optErr
+	}
+	if err := c.checkOpts(); err != nil {
+		return nil, err
+	}
+	return c.capabilities(), nil
+}
+
+func (c cal) capabilities() *CapabilitySet {
+	features := []Feature{FeatureDryRun, FeatureApply}
+	if c.backend != nil {
+		sort.Sort(byName(features))
+		return &CapabilitySet{
+			Backend:  c.backend.Name(),
+			Features: features,
+		}
+	}
+	features = append(features,
+		FeatureConflictDetection,
+		FeatureComments,
+		FeatureAllDay,
+		FeatureWallClock,
+		FeatureResurrect,
+		FeatureRouting,
+		FeatureSandbox,
+		FeatureEncrypt,
+		FeatureCalendarLock,
+		FeatureFreeBusy,
+		FeatureRollback,
+	)
+	// Matching by iCalendar UID rules out the features that need the
+	// properties Sync otherwise tracks events with, and the other way
+	// around, as setup checks.
+	if c.match == MatchICalUID {
+		features = append(features, FeatureICalUID)
+	} else {
+		features = append(features, FeatureIncremental, FeatureAdopt, FeatureCancel)
+		if c.state == nil && !c.adoption && c.deletePolicy != Cancel {
+			features = append(features, FeatureICalUID)
+		}
+	}
+	sort.Sort(byName(features))
+	return &CapabilitySet{
+		Backend:  "google",
+		Features: features,
+	}
+}
+
+type byName []Feature
+
+func (s byName) Len() int           { return len(s) }
+func (s byName) Less(i, j int) bool { return s[i] < s[j] }
+func (s byName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
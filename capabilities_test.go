@@ -0,0 +1,37 @@
+package calsync
+
+import (
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestCapabilities(t *testing.T) {
+	cs, err := Capabilities(CalendarID("someone@example.com"), Nop())
+	ok(t, err)
+	equals(t, "google", cs.Backend)
+	assert(t, cs.Supports(FeatureDryRun), "expected dry run support")
+	assert(t, cs.Supports(FeatureIncremental), "expected incremental support")
+	assert(t, !cs.Supports(Feature("teleportation")), "unexpected support for an unknown feature")
+	assert(t, sort.IsSorted(byName(cs.Features)), "expected sorted features, got %v", cs.Features)
+}
+
+func TestCapabilitiesFollowOpts(t *testing.T) {
+	cs, err := Capabilities(MatchBy(MatchICalUID))
+	ok(t, err)
+	assert(t, cs.Supports(FeatureICalUID), "expected iCalendar UID support")
+	assert(t, !cs.Supports(FeatureIncremental), "expected no incremental support")
+
+	cs, err = Capabilities(Adopt())
+	ok(t, err)
+	assert(t, !cs.Supports(FeatureICalUID), "expected no iCalendar UID support")
+
+	_, err = Capabilities(Incremental(NewMemoryStore()), MatchBy(MatchICalUID))
+	assert(t, err != nil, "expected an error for Incremental with MatchBy(%s)", MatchICalUID)
+
+	_, err = Capabilities(WithBackend(newMemBackend()), CalendarLock(time.Minute))
+	assert(t, err != nil, "expected an error for CalendarLock with a Backend")
+
+	_, err = Capabilities(WithService(nil))
+	assert(t, err != nil, "expected an error for a nil service")
+}
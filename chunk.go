@@ -0,0 +1,283 @@
+package calsync
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// chunk is a range of start times, and the source events that start in
+// it.  A zero from or until leaves that end of the range open.
+type chunk struct {
+	from, until time.Time
+	events      []*Event
+}
+
+// checkpoint is what Checkpoint keeps in its store while SyncChunked
+// runs.
+type checkpoint struct {
+	// Through is the start time before which every event was synced.
+	Through time.Time `json:"through"`
+}
+
+// Checkpoint makes SyncChunked record in store, after each chunk is
+// applied, the start time before which every event was synced, and
+// clear it once every chunk has been.  If a call is interrupted
+// partway, the next SyncChunked of the same scope and calendar with the
+// same store carries on from there, rather than fetching and planning
+// the chunks that were done again.  Source events that start before
+// the checkpoint are then left alone, even if they changed, until the
+// following call.  With Nop, nothing is recorded or skipped.
+func Checkpoint(store StateStore) Opt {
+	return func(c *cal) {
+		c.checkpoints = store
+	}
+}
+
+func (c cal) checkpointKey() string {
+	return fmt.Sprintf("calsync/%s/%s/checkpoint", c.calID, c.scope)
+}
+
+// loadCheckpoint returns the start time before which every event was
+// synced by an interrupted SyncChunked, or the zero time.
+func (c cal) loadCheckpoint() (time.Time, error) {
+	if c.checkpoints == nil || c.nop {
+		return time.Time{}, nil
+	}
+	b, err := c.checkpoints.Get(c.checkpointKey())
+	if err != nil || len(b) == 0 {
+		return time.Time{}, err
+	}
+	var cp checkpoint
+	if err = json.Unmarshal(b, &cp); err != nil {
+		return time.Time{}, fmt.Errorf("decoding checkpoint: %v", err)
+	}
+	return cp.Through, nil
+}
+
+// saveCheckpoint records that every event starting before through was
+// synced, or clears the checkpoint if through is zero.
+func (c cal) saveCheckpoint(through time.Time) error {
+	if c.checkpoints == nil || c.nop {
+		return nil
+	}
+	var b []byte
+	if !through.IsZero() {
+		var err error
+		if b, err = json.Marshal(&checkpoint{Through: through}); err != nil {
+			return fmt.Errorf("encoding checkpoint: %v", err)
+		}
+	}
+	if err := c.checkpoints.Put(c.checkpointKey(), b); err != nil {
+		return fmt.Errorf("saving checkpoint: %v", err)
+	}
+	return nil
+}
+
+// SyncChunked is like Sync, for sources of tens of thousands of events.
+// Rather than fetching every calendar event and planning every change
+// at once, it splits srcEvents by start time into chunks of about size
+// events, and for each chunk in turn, fetches only the calendar events
+// that start in the same range, then plans and applies the changes.
+// So the calendar events it holds at any time are about as many as a
+// chunk.  The first chunk also covers the calendar events that start
+// before any source event, and the last those that start after.  Use
+// Checkpoint so that an interrupted call can carry on where it stopped.
+//
+// Limits such as MaxDeletes, and Confirm, apply to each chunk.  An
+// event whose start moved into another chunk is deleted and added,
+// rather than updated.  MirrorTo can't be used, since no chunk sees
+// every upcoming event.
+//
+// It returns the changes made by every chunk.  If a chunk fails, it
+// returns those along with the error, with the operations of that
+// chunk that it didn't make in Changes.Pending.  Later chunks aren't
+// planned.  Likewise with MaxEvents, it stops after the chunk that
+// reaches the limit, and with Checkpoint, the next call starts again
+// with that chunk.
+func SyncChunked(
+	ctx context.Context,
+	client *http.Client,
+	scope string,
+	srcEvents []*Event,
+	size int,
+	opts ...Opt) (*Changes, error) {
+	if size < 1 {
+		return nil, fmt.Errorf("SyncChunked: size must be at least 1, not %d", size)
+	}
+	c, err := setup(ctx, client, scope, opts)
+	if err != nil {
+		return nil, err
+	}
+	if c.mirrorer != nil {
+		return nil, fmt.Errorf("MirrorTo can't be used with SyncChunked")
+	}
+	now := c.now()
+	if c.validation {
+		if err = Validate(srcEvents); err != nil {
+			return nil, err
+		}
+	}
+
+	unlock, err := c.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	resumed, err := c.resumeJournal(ctx, now)
+	if err != nil {
+		return resumed.orNil(), err
+	}
+	through, err := c.loadCheckpoint()
+	if err != nil {
+		return resumed.orNil(), err
+	}
+
+	all := &Changes{}
+	budget := c.budget()
+	for i, ch := range chunkEvents(srcEvents, size, through) {
+		changes, err := c.syncChunk(ctx, now, ch, budget)
+		if changes != nil {
+			all.merge(changes)
+			if budget >= 0 {
+				budget -= opCount(changes)
+			}
+		}
+		if err == nil && changes.Overflow != nil {
+			// The rest of this chunk, and those after it, are left
+			// for a later call, which starts again with this one.
+			if err = c.saveCheckpoint(ch.from); err != nil {
+				all.include(resumed)
+				all.Manifest = c.manifest("sync", now)
+				return all, err
+			}
+			break
+		}
+		if err == nil {
+			err = c.saveCheckpoint(ch.until)
+		}
+		if err != nil {
+			if changes == nil && i == 0 {
+				return resumed.orNil(), err
+			}
+			all.include(resumed)
+			all.Manifest = c.manifest("sync", now)
+			return all, err
+		}
+	}
+	all.include(resumed)
+	all.Manifest = c.manifest("sync", now)
+	if err = c.report(ctx, all); err != nil {
+		return all, err
+	}
+	return all, nil
+}
+
+// syncChunk fetches the calendar events that start in ch's range, then
+// plans and applies the changes that sync ch's events into them, up to
+// budget of them, as for MaxEvents.  It returns nil changes for errors
+// before anything was modified.
+func (c cal) syncChunk(ctx context.Context, now time.Time, ch chunk, budget int) (*Changes, error) {
+	c.from, c.until = ch.from, ch.until
+	calEvents, err := c.fetch(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	calEvents = c.inRange(calEvents)
+
+	var adopted []*Event
+	if c.adoption {
+		if adopted, err = c.adopt(ctx, now, calEvents, ch.events); err != nil {
+			return nil, err
+		}
+		calEvents = append(calEvents, adopted...)
+	}
+	changes, err := c.getOperations(now, calEvents, ch.events)
+	if err != nil {
+		return nil, err
+	}
+	changes.Adopted = adopted
+	if c.resurrection {
+		if err = c.resurrect(ctx, now, changes); err != nil {
+			return nil, err
+		}
+	}
+	deferOps(changes, budget)
+	if err = c.warnBusy(ctx, calEvents, changes); err != nil {
+		return nil, err
+	}
+	if err = c.confirmPlan(changes); err != nil {
+		return nil, err
+	}
+	return changes, c.apply(ctx, changes)
+}
+
+// inRange returns those of events that start in the range set by
+// syncChunk, which a Backend, or an incremental fetch, doesn't limit
+// them to.
+func (c cal) inRange(events []*Event) []*Event {
+	var in []*Event
+	for _, ev := range events {
+		if (c.from.IsZero() || !ev.Start.Before(c.from)) && (c.until.IsZero() || ev.Start.Before(c.until)) {
+			in = append(in, ev)
+		}
+	}
+	return in
+}
+
+// chunkEvents splits the events that start at or after through, or all
+// of them if it is zero, into chunks of about size events, in order of
+// start.  Events with the same start are kept in one chunk, so a chunk
+// may hold more.  The chunks cover every start time from through on,
+// so there is always at least one.
+func chunkEvents(events []*Event, size int, through time.Time) []chunk {
+	var sorted []*Event
+	for _, ev := range events {
+		if through.IsZero() || !ev.Start.Before(through) {
+			sorted = append(sorted, ev)
+		}
+	}
+	sort.Sort(byStart(sorted))
+
+	chunks := []chunk{{from: through}}
+	for _, ev := range sorted {
+		last := &chunks[len(chunks)-1]
+		if n := len(last.events); n >= size && ev.Start.After(last.events[n-1].Start) {
+			last.until = ev.Start
+			chunks = append(chunks, chunk{from: ev.Start})
+			last = &chunks[len(chunks)-1]
+		}
+		last.events = append(last.events, ev)
+	}
+	return chunks
+}
+
+// merge adds the changes made for a chunk to c.
+func (c *Changes) merge(part *Changes) {
+	c.Deletes = append(c.Deletes, part.Deletes...)
+	c.Updates = append(c.Updates, part.Updates...)
+	c.Adds = append(c.Adds, part.Adds...)
+	c.Conflicts = append(c.Conflicts, part.Conflicts...)
+	c.Adopted = append(c.Adopted, part.Adopted...)
+	c.Orphans = append(c.Orphans, part.Orphans...)
+	c.Warnings = append(c.Warnings, part.Warnings...)
+	c.Undo = append(c.Undo, part.Undo...)
+	if c.Results == nil && part.Results != nil {
+		c.Results = SyncResult{}
+	}
+	for id, result := range part.Results {
+		c.Results[id] = result
+	}
+	for srcID, id := range part.addedIDs {
+		if c.addedIDs == nil {
+			c.addedIDs = map[string]string{}
+		}
+		c.addedIDs[srcID] = id
+	}
+	c.Pending = part.Pending
+	c.Overflow = part.Overflow
+}
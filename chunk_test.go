@@ -0,0 +1,136 @@
+package calsync
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+// fullBackend fails adding events once it holds max of them.
+type fullBackend struct {
+	*memBackend
+	max, adds int
+}
+
+func (b *fullBackend) Add(ctx context.Context, scope string, ev *Event) (string, error) {
+	if b.adds >= b.max {
+		return "", errors.New("backend full")
+	}
+	b.adds++
+	return b.memBackend.Add(ctx, scope, ev)
+}
+
+func hourly(n int, start time.Time) []*Event {
+	var events []*Event
+	for i := 0; i < n; i++ {
+		events = append(events, newSrcEvent(fmt.Sprint(i), start.Add(time.Duration(i)*time.Hour)))
+	}
+	return events
+}
+
+func TestChunkEvents(t *testing.T) {
+	start := when("2017-05-01T09:00:00-07:00")
+	events := hourly(7, start)
+	events = append(events, newSrcEvent("same", events[2].Start))
+
+	var sizes []int
+	chunks := chunkEvents(events, 3, time.Time{})
+	for _, ch := range chunks {
+		sizes = append(sizes, len(ch.events))
+	}
+	equals(t, []int{4, 3, 1}, sizes)
+	assert(t, chunks[0].from.IsZero(), "expected the first chunk to be open")
+	equals(t, events[3].Start, chunks[0].until)
+	equals(t, events[3].Start, chunks[1].from)
+	assert(t, chunks[2].until.IsZero(), "expected the last chunk to be open")
+
+	chunks = chunkEvents(events, 3, events[4].Start)
+	equals(t, 1, len(chunks))
+	equals(t, events[4].Start, chunks[0].from)
+	equals(t, 3, len(chunks[0].events))
+
+	equals(t, 1, len(chunkEvents(nil, 3, time.Time{})))
+}
+
+func TestSyncChunked(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	early, late := newSrcEvent("early", start), newSrcEvent("late", start.Add(time.Hour*24))
+	_, err := Sync(ctx, s.Client(), "scope", []*Event{early, late})
+	ok(t, err)
+
+	src := hourly(10, start.Add(time.Hour))
+	changes, err := SyncChunked(ctx, s.Client(), "scope", src, 3)
+	ok(t, err)
+	equals(t, 10, len(changes.Adds))
+	equals(t, 2, len(changes.Deletes))
+	equals(t, "early srcId", changes.Deletes[0].SrcID)
+	equals(t, "late srcId", changes.Deletes[1].SrcID)
+	equals(t, 10, len(s.Events("primary")))
+
+	src[4].Title = "new title"
+	changes, err = SyncChunked(ctx, s.Client(), "scope", src, 3)
+	ok(t, err)
+	equals(t, 1, len(changes.Updates))
+	equals(t, 0, len(changes.Adds)+len(changes.Deletes))
+
+	_, err = SyncChunked(ctx, s.Client(), "scope", src, 0)
+	assert(t, err != nil, "expected an error for a zero size")
+	_, err = SyncChunked(ctx, s.Client(), "scope", src, 3, MirrorTo(memoryMirror{}))
+	assert(t, err != nil, "expected an error for MirrorTo")
+}
+
+func TestSyncChunkedCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	now := when("2017-04-29T20:00:00-07:00")
+	b := &fullBackend{memBackend: newMemBackend(), max: 4}
+	store := NewMemoryStore()
+	src := hourly(10, now.Add(time.Hour))
+	opts := []Opt{WithBackend(b), WithNow(func() time.Time { return now }), Checkpoint(store)}
+
+	changes, err := SyncChunked(ctx, nil, "scope", src, 3, opts...)
+	assert(t, err != nil, "expected the backend to fill up")
+	equals(t, 4, len(changes.Adds))
+	equals(t, 2, len(changes.Pending.Adds))
+	c := cal{calID: "", scope: "scope", checkpoints: store}
+	through, err := c.loadCheckpoint()
+	ok(t, err)
+	equals(t, src[3].Start, through)
+
+	b.max = 100
+	changes, err = SyncChunked(ctx, nil, "scope", src, 3, opts...)
+	ok(t, err)
+	equals(t, 6, len(changes.Adds))
+	equals(t, src[4].SrcID, changes.Adds[0].SrcID)
+	through, err = c.loadCheckpoint()
+	ok(t, err)
+	assert(t, through.IsZero(), "expected the checkpoint to be cleared, got %s", through)
+
+	changes, err = SyncChunked(ctx, nil, "scope", src, 3, opts...)
+	ok(t, err)
+	assert(t, changes.empty(), "expected no changes, got %s", changes)
+}
+
+func TestSyncChunkedResumedThenFailed(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	store := NewMemoryStore()
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	a, b := newSrcEvent("a", start), newSrcEvent("b", start.Add(time.Hour))
+	_, err := Sync(ctx, s.Client(), "scope", []*Event{a, b}, Journal(store), Audit(&crashingAudit{after: 0}))
+	assert(t, err != nil, "expected the sync to crash")
+
+	refuse := Confirm(func(*Changes) error { return errors.New("refused") })
+	src := []*Event{a, b, newSrcEvent("c", start)}
+	changes, err := SyncChunked(ctx, s.Client(), "scope", src, 1, Journal(store), refuse)
+	assert(t, err != nil, "expected the plan to be refused")
+	assert(t, changes != nil, "expected the resumed changes")
+	equals(t, 1, len(changes.Adds))
+	equals(t, b.SrcID, changes.Adds[0].SrcID)
+}
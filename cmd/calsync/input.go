@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/ginabythebay/calsync"
+)
+
+// inputFormat returns format, or else the format implied by the
+// extension of name, or else json.
+func inputFormat(format, name string) string {
+	if format != "" {
+		return format
+	}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".ics", ".ical", ".ifb":
+		return "ics"
+	case ".csv":
+		return "csv"
+	}
+	return "json"
+}
+
+// readEvents reads events from r in format.  layout and dateLayout are
+// used for times and dates in csv input.
+func readEvents(r io.Reader, format, layout, dateLayout string) ([]*calsync.Event, error) {
+	switch format {
+	case "json":
+		var events []*calsync.Event
+		if err := json.NewDecoder(r).Decode(&events); err != nil {
+			return nil, fmt.Errorf("reading json: %v", err)
+		}
+		return events, nil
+	case "ics":
+		return calsync.ReadICS(r)
+	case "csv":
+		return readCSV(r, layout, dateLayout)
+	}
+	return nil, fmt.Errorf("unknown format %q; use json, ics or csv", format)
+}
+
+// readCSV reads csv with columns named after the json fields of
+// calsync.Event.  The end, where and description columns may be left
+// out.
+func readCSV(r io.Reader, layout, dateLayout string) ([]*calsync.Event, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	header, err := csv.NewReader(bytes.NewReader(b)).Read()
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("reading csv: %v", err)
+	}
+	present := map[string]bool{}
+	for _, h := range header {
+		present[strings.ToLower(strings.TrimSpace(h))] = true
+	}
+	optional := func(name string) string {
+		if present[name] {
+			return name
+		}
+		return ""
+	}
+	return calsync.ReadCSV(bytes.NewReader(b), calsync.ColumnMap{
+		Title:       "title",
+		Start:       "start",
+		End:         optional("end"),
+		Where:       optional("where"),
+		Description: optional("description"),
+		SrcID:       "src_id",
+		Layouts:     []string{layout},
+		DateLayout:  dateLayout,
+	})
+}
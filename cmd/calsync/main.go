@@ -0,0 +1,304 @@
+/*
+Command calsync syncs events from a JSON, iCalendar or CSV file into a
+google calendar.
+
+Usage:
+
+	calsync auth [flags]
+	calsync sync [flags] [file]
+	calsync doctor [flags]
+	calsync purge [flags]
+
+auth authorizes calsync to manage your calendars, and caches the token
+it is given, so that sync and doctor can run unattended.  It needs the
+client credentials of a google cloud project with the calendar api
+enabled, downloaded as json.  See
+https://developers.google.com/google-apps/calendar/quickstart/go
+Use -device where no local browser is available.
+
+sync reads events from file, or from standard input if file is missing
+or "-", and syncs them into the calendar, printing the changes it made.
+JSON files hold an array of events, with the fields title, start, end,
+where, description, src_id, all_day and attendees, an array of objects
+with a name and an email.  CSV files need a header row naming the same
+columns, apart from attendees.  iCalendar files use the UID of each event as
+its src_id.
+
+doctor checks the credentials, calendar and scope, and explains how to
+fix any problems it finds.
+
+purge deletes every event in the scope, past and upcoming, printing
+the events it deleted.
+
+Run a command with -h to see its flags.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ginabythebay/calsync"
+	"github.com/ginabythebay/calsync/auth"
+
+	"golang.org/x/net/context"
+)
+
+const usage = `usage:
+	calsync auth [flags]
+	calsync sync [flags] [file]
+	calsync doctor [flags]
+	calsync purge [flags]
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	var err error
+	switch os.Args[1] {
+	case "auth":
+		err = authCmd(os.Args[2:])
+	case "sync":
+		err = syncCmd(os.Args[2:], os.Stdin, os.Stdout)
+	case "doctor":
+		err = doctorCmd(os.Args[2:], os.Stdout)
+	case "purge":
+		err = purgeCmd(os.Args[2:], os.Stdout)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "calsync: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// common holds the flags every command takes.
+type common struct {
+	credentials  string
+	token        string
+	scope        string
+	calendar     string
+	calendarName string
+	sandbox      bool
+}
+
+func commonFlags(fs *flag.FlagSet) *common {
+	dir := filepath.Join(os.Getenv("HOME"), ".calsync")
+	c := &common{}
+	fs.StringVar(&c.credentials, "credentials", filepath.Join(dir, "credentials.json"),
+		"client credentials downloaded from the google api console")
+	fs.StringVar(&c.token, "token", filepath.Join(dir, "token.json"),
+		"where the authorized token is cached")
+	fs.StringVar(&c.scope, "scope", "", "short name identifying the events this tool manages (required)")
+	fs.StringVar(&c.calendar, "calendar", "primary", "id of the calendar to sync into")
+	fs.StringVar(&c.calendarName, "calendar-name", "", "name of the calendar to sync into, in place of -calendar")
+	fs.BoolVar(&c.sandbox, "sandbox", false, "use a scratch calendar, whatever -calendar or -calendar-name say, for testing")
+	return c
+}
+
+func (c *common) opts() []calsync.Opt {
+	if c.sandbox {
+		return []calsync.Opt{calsync.Sandbox()}
+	}
+	if c.calendarName != "" {
+		return []calsync.Opt{calsync.CalendarName(c.calendarName)}
+	}
+	return []calsync.Opt{calsync.CalendarID(c.calendar)}
+}
+
+// client returns a client using the cached token.  It doesn't prompt
+// for authorization, as sync and doctor may be running unattended.
+func (c *common) client(ctx context.Context) (*http.Client, error) {
+	f, err := auth.NewFlow(c.credentials, c.token)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = f.CachedToken(); err != nil {
+		return nil, fmt.Errorf("%v; run calsync auth first", err)
+	}
+	return f.Client(ctx)
+}
+
+func authCmd(args []string) error {
+	fs := flag.NewFlagSet("auth", flag.ExitOnError)
+	c := commonFlags(fs)
+	device := fs.Bool("device", false, "authorize by entering a code on another device, rather than in a local browser")
+	fs.Parse(args)
+
+	f, err := auth.NewFlow(c.credentials, c.token)
+	if err != nil {
+		return err
+	}
+	f.Device = *device
+	if _, err = f.Authorize(context.Background()); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Saved token to %s\n", c.token)
+	return nil
+}
+
+func syncCmd(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+	c := commonFlags(fs)
+	dryRun := fs.Bool("n", false, "dry run: print the changes without making them")
+	private := fs.Bool("private", false, "list attendees by name in descriptions rather than inviting them")
+	orphans := fs.Bool("orphans", false, "list events that are no longer in the input rather than deleting them")
+	audit := fs.String("audit", "", "append a line of JSON describing each change made to this file")
+	state := fs.String("state", "", "keep sync state in this directory, to fetch only what changed and to finish interrupted syncs")
+	maxDeletes := fs.Int("max-deletes", -1, "refuse to sync if it would delete more than this many events; -1 means no limit")
+	maxEvents := fs.Int("max-events", 0, "make at most this many changes, leaving the rest for a later sync; 0 means no limit")
+	checkBusy := fs.Bool("check-busy", false, "warn about added events that double book the calendar or an attendee")
+	horizon := fs.Duration("horizon", 0,
+		"only sync events that start within this long from now, removing any later ones synced before; 0 means no limit")
+	format := fs.String("format", "", "format of the input: json, ics or csv.  The default comes from the file name, or is json")
+	layout := fs.String("time-layout", time.RFC3339, "layout of times in csv input, as for time.Parse")
+	dateLayout := fs.String("date-layout", "2006-01-02", "layout of all day dates in csv input, as for time.Parse")
+	fs.Parse(args)
+	if c.scope == "" {
+		return fmt.Errorf("-scope is required")
+	}
+	if fs.NArg() > 1 {
+		return fmt.Errorf("too many arguments")
+	}
+
+	name := fs.Arg(0)
+	in := stdin
+	if name != "" && name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	events, err := readEvents(in, inputFormat(*format, name), *layout, *dateLayout)
+	if err != nil {
+		return err
+	}
+	if *horizon > 0 {
+		events = within(events, time.Now().Add(*horizon))
+	}
+
+	ctx := context.Background()
+	client, err := c.client(ctx)
+	if err != nil {
+		return err
+	}
+	opts := c.opts()
+	if *dryRun {
+		opts = append(opts, calsync.Nop())
+	}
+	if *private {
+		opts = append(opts, calsync.PrivateCopies())
+	}
+	if *orphans {
+		opts = append(opts, calsync.ReportOrphans())
+	}
+	if *audit != "" {
+		opts = append(opts, calsync.Audit(calsync.NewFileAudit(*audit)))
+	}
+	if *maxDeletes >= 0 {
+		opts = append(opts, calsync.MaxDeletes(*maxDeletes))
+	}
+	if *maxEvents > 0 {
+		opts = append(opts, calsync.MaxEvents(*maxEvents))
+	}
+	if *checkBusy {
+		opts = append(opts, calsync.CheckFreeBusy())
+	}
+	if *state != "" {
+		store := calsync.NewFileStore(*state)
+		opts = append(opts, calsync.Incremental(store), calsync.Journal(store))
+	}
+	changes, err := calsync.Sync(ctx, client, c.scope, events, opts...)
+	printChanges(stdout, changes)
+	return err
+}
+
+// printChanges prints changes, if any, including those left pending by
+// a failure partway, and those left for a later sync by -max-events.
+func printChanges(stdout io.Writer, changes *calsync.Changes) {
+	if changes == nil {
+		return
+	}
+	if s := changes.String(); s != "" {
+		fmt.Fprintln(stdout, s)
+	}
+	if changes.Pending != nil {
+		if s := changes.Pending.String(); s != "" {
+			fmt.Fprintf(stdout, "Not done:\n%s\n", s)
+		}
+	}
+	if changes.Overflow != nil {
+		if s := changes.Overflow.String(); s != "" {
+			fmt.Fprintf(stdout, "Left for a later sync:\n%s\n", s)
+		}
+	}
+}
+
+func doctorCmd(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	c := commonFlags(fs)
+	fs.Parse(args)
+	if c.scope == "" {
+		return fmt.Errorf("-scope is required")
+	}
+
+	ctx := context.Background()
+	client, err := c.client(ctx)
+	if err != nil {
+		return err
+	}
+	d, err := calsync.Diagnose(ctx, client, c.scope, c.opts()...)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(stdout, d)
+	if !d.OK() {
+		return fmt.Errorf("problems found")
+	}
+	return nil
+}
+
+func purgeCmd(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("purge", flag.ExitOnError)
+	c := commonFlags(fs)
+	dryRun := fs.Bool("n", false, "dry run: print the events without deleting them")
+	fs.Parse(args)
+	if c.scope == "" {
+		return fmt.Errorf("-scope is required")
+	}
+
+	ctx := context.Background()
+	client, err := c.client(ctx)
+	if err != nil {
+		return err
+	}
+	opts := c.opts()
+	if *dryRun {
+		opts = append(opts, calsync.Nop())
+	}
+	changes, err := calsync.Purge(ctx, client, c.scope, opts...)
+	printChanges(stdout, changes)
+	return err
+}
+
+// within returns the events that start before limit.
+func within(events []*calsync.Event, limit time.Time) []*calsync.Event {
+	var kept []*calsync.Event
+	for _, ev := range events {
+		if ev.Start.Before(limit) {
+			kept = append(kept, ev)
+		}
+	}
+	return kept
+}
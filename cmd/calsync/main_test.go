@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInputFormat(t *testing.T) {
+	for _, tt := range []struct{ format, name, want string }{
+		{"", "events.json", "json"},
+		{"", "events.ICS", "ics"},
+		{"", "schedule.csv", "csv"},
+		{"", "", "json"},
+		{"csv", "events.json", "csv"},
+	} {
+		if got := inputFormat(tt.format, tt.name); got != tt.want {
+			t.Errorf("inputFormat(%q, %q) = %q, want %q", tt.format, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReadEvents(t *testing.T) {
+	inputs := map[string]string{
+		"json": `[{"title": "Party", "start": "2017-05-01T19:00:00-07:00", "end": "2017-05-01T22:00:00-07:00", "where": "", "description": "", "src_id": "p1"}]`,
+		"csv":  "title,start,src_id,end\nParty,2017-05-01T19:00:00-07:00,p1,2017-05-01T22:00:00-07:00\n",
+		"ics": strings.Join([]string{
+			"BEGIN:VCALENDAR",
+			"BEGIN:VEVENT",
+			"UID:p1",
+			"SUMMARY:Party",
+			"DTSTART:20170502T020000Z",
+			"DTEND:20170502T050000Z",
+			"END:VEVENT",
+			"END:VCALENDAR",
+		}, "\r\n"),
+	}
+	start, _ := time.Parse(time.RFC3339, "2017-05-01T19:00:00-07:00")
+	for format, in := range inputs {
+		events, err := readEvents(strings.NewReader(in), format, time.RFC3339, "2006-01-02")
+		if err != nil {
+			t.Errorf("%s: %v", format, err)
+			continue
+		}
+		if len(events) != 1 {
+			t.Errorf("%s: got %d events, want 1", format, len(events))
+			continue
+		}
+		ev := events[0]
+		if ev.Title != "Party" || ev.SrcID != "p1" || !ev.Start.Equal(start) || ev.End.Sub(ev.Start) != 3*time.Hour {
+			t.Errorf("%s: got %+v", format, ev)
+		}
+	}
+
+	if _, err := readEvents(strings.NewReader(""), "xml", "", ""); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestWithin(t *testing.T) {
+	in := `[
+		{"title": "soon", "start": "2017-05-01T19:00:00Z", "end": "2017-05-01T20:00:00Z", "src_id": "a"},
+		{"title": "later", "start": "2017-06-01T19:00:00Z", "end": "2017-06-01T20:00:00Z", "src_id": "b"}
+	]`
+	events, err := readEvents(strings.NewReader(in), "json", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	limit, _ := time.Parse(time.RFC3339, "2017-05-15T00:00:00Z")
+	kept := within(events, limit)
+	if len(kept) != 1 || kept[0].Title != "soon" {
+		t.Errorf("got %v", kept)
+	}
+}
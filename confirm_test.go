@@ -0,0 +1,51 @@
+package calsync
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+func TestConfirm(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	src := []*Event{newSrcEvent("a", time.Now().Add(time.Hour).Truncate(time.Second))}
+
+	var asked []*Changes
+	veto := errors.New("vetoed")
+	answer := veto
+	confirm := Confirm(func(changes *Changes) error {
+		asked = append(asked, changes)
+		return answer
+	})
+
+	_, err := Sync(ctx, s.Client(), "scope", src, confirm, Nop())
+	ok(t, err)
+	equals(t, 0, len(asked))
+
+	_, err = Sync(ctx, s.Client(), "scope", src, confirm)
+	equals(t, veto, err)
+	equals(t, 1, len(asked))
+	equals(t, 1, len(asked[0].Adds))
+	equals(t, 0, len(s.Events("primary")))
+
+	answer = nil
+	changes, err := Sync(ctx, s.Client(), "scope", src, confirm)
+	ok(t, err)
+	equals(t, asked[1], changes)
+	equals(t, 1, len(s.Events("primary")))
+
+	// Nothing to do, so nothing to ask.
+	_, err = Sync(ctx, s.Client(), "scope", src, confirm)
+	ok(t, err)
+	equals(t, 2, len(asked))
+
+	answer = veto
+	_, err = Apply(ctx, s.Client(), "scope", &Changes{Adds: []*Event{newSrcEvent("b", src[0].Start)}}, confirm)
+	equals(t, veto, err)
+	equals(t, 1, len(s.Events("primary")))
+}
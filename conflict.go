@@ -0,0 +1,197 @@
+package calsync
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ConflictPolicy determines what Sync does with an event that was
+// edited in google calendar since it was last synced, when the source
+// version no longer matches the calendar version.
+//
+// We detect such edits by storing a hash of the content we wrote as a
+// private extended property of the form <scope>Hash=<hash>.  Events
+// written before this hash existed are never considered edited.
+type ConflictPolicy int
+
+const (
+	// PreferCalendar keeps the calendar edits and reports the event in
+	// Changes.Conflicts.  The event is still deleted if it disappears
+	// from the source.  This is the default.
+	PreferCalendar ConflictPolicy = iota
+
+	// PreferSource overwrites the calendar edits with the source
+	// version.  This was the only behavior before edits were detected.
+	// See also Force.
+	PreferSource
+
+	// SkipConflicts leaves edited events entirely alone, including
+	// not deleting them if they disappear from the source, and reports
+	// them in Changes.Conflicts.
+	SkipConflicts
+
+	// FailOnConflict makes Sync fail with a *ConflictError, without
+	// modifying anything.
+	FailOnConflict
+)
+
+// ConflictError is returned by Sync, under the FailOnConflict policy,
+// when events were edited in google calendar since they were last
+// synced.
+type ConflictError struct {
+	// Events holds the edited events, as found in google calendar.
+	Events []*Event
+}
+
+func (e *ConflictError) Error() string {
+	var names []string
+	for _, ev := range e.Events {
+		names = append(names, ev.String())
+	}
+	return fmt.Sprintf("%d event(s) edited in calendar since last sync: %s",
+		len(e.Events), strings.Join(names, ", "))
+}
+
+// Conflict describes an event that was edited in google calendar since
+// it was last synced, and that no longer matches the source.
+type Conflict struct {
+	// Source is the event as the source has it, or nil if it is no
+	// longer in the source.
+	Source *Event
+
+	// Calendar is the event as it now is in google calendar.
+	Calendar *Event
+
+	// Fields names the fields that differ between Source and Calendar,
+	// as for Edit.Fields.  It is empty if Source is nil.
+	Fields []string
+}
+
+// CalendarUpdated returns when the calendar event was last modified,
+// by anyone, or the zero time if that isn't known.
+func (c *Conflict) CalendarUpdated() time.Time {
+	if c.Calendar.raw == nil {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, c.Calendar.raw.Updated)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// ConflictResolver decides what Sync does with each Conflict, in place
+// of a ConflictPolicy.  See ResolveConflicts, and the strategies
+// package for ready made resolvers.
+type ConflictResolver interface {
+	// Resolve returns the event as google calendar should have it:
+	//
+	//   - c.Calendar, or an event with the same content, keeps the
+	//     calendar edits and reports the event in Changes.Conflicts
+	//   - nil deletes the calendar event
+	//   - any other event, such as c.Source or a merge of the two,
+	//     updates the calendar event to match it
+	//
+	// An update that doesn't match the source is reconsidered by later
+	// syncs, as the calendar still differs from the source.  An error
+	// makes Sync fail without modifying anything.
+	Resolve(c *Conflict) (*Event, error)
+}
+
+// ConflictResolverFunc adapts a function to a ConflictResolver.
+type ConflictResolverFunc func(c *Conflict) (*Event, error)
+
+// Resolve calls f(c).
+func (f ConflictResolverFunc) Resolve(c *Conflict) (*Event, error) {
+	return f(c)
+}
+
+// resolve adds the operation that p.resolver picks for the edited
+// calendar event calEv to changes.  srcEv is nil if the event is no
+// longer in the source.
+func (p planner) resolve(changes *Changes, srcEv, calEv *Event) error {
+	conflict := &Conflict{Source: srcEv, Calendar: calEv}
+	if srcEv != nil {
+		conflict.Fields = changedFields(srcEv, calEv)
+	}
+	resolved, err := p.resolver.Resolve(conflict)
+	if err != nil {
+		return err
+	}
+	if resolved == nil {
+		changes.Deletes = append(changes.Deletes, calEv)
+		return nil
+	}
+	kept := *resolved
+	kept.SrcID = calEv.SrcID
+	if resolved == calEv || p.equal(&kept, calEv) {
+		changes.Conflicts = append(changes.Conflicts, calEv)
+		return nil
+	}
+	update := calEv.newUpdate(&kept)
+	if srcEv != nil && !p.equal(srcEv, &kept) {
+		// Record the source as synced, rather than what we write, so
+		// that the calendar still looks edited next time and the
+		// resolver gets to keep its decision, or change it if the
+		// source changed.
+		update.syncedSource = srcEv
+	}
+	changes.Updates = append(changes.Updates, update)
+	return nil
+}
+
+// contentHash returns a hash of the fields we sync, so we can tell
+// later whether someone else changed them.  It covers the same fields
+// as equal, apart from SrcID and any fields we preserve.
+func (p planner) contentHash(ev *Event) string {
+	where := ev.Where
+	if p.preserves(FieldLocation) {
+		where = ""
+	}
+	start := fmt.Sprint(ev.Start.Unix())
+	end := fmt.Sprint(ev.End.Unix())
+	if ev.AllDay {
+		// All day events come back from google calendar at midnight in
+		// the calendar's timezone, which needn't match ours.
+		start = ev.Start.Format(dateLayout)
+		end = ev.End.Format(dateLayout)
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%q\n%s\n%s\n%q\n%q\n",
+		ev.Title,
+		start,
+		end,
+		where,
+		p.syncedDescription(ev))
+	if len(ev.Attendees) != 0 && !p.preserves(FieldAttendees) {
+		// Only hashed when there are some, so that the hashes of
+		// events synced before attendees were supported still match.
+		fmt.Fprintf(h, "%s\n", attendeeKey(ev.Attendees))
+	}
+	if len(ev.Metadata) != 0 {
+		// Likewise.
+		fmt.Fprintf(h, "%q\n", formatMetadata(ev.Metadata))
+	}
+	if link, title := ev.source(); link != "" {
+		// Likewise.
+		fmt.Fprintf(h, "%q\n%q\n", link, title)
+	}
+	if ev.status() != EventConfirmed {
+		// Likewise.
+		fmt.Fprintf(h, "%s\n", ev.status())
+	}
+	if g := ev.guestPermissions(); !g.isDefault() {
+		// Only hashed when they aren't the defaults, likewise.
+		fmt.Fprintf(h, "%s\n", g)
+	}
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// edited reports whether ev, read from google calendar, was changed
+// since we last wrote it.
+func (p planner) edited(ev *Event) bool {
+	return ev.syncedHash != "" && ev.syncedHash != p.contentHash(ev)
+}
@@ -0,0 +1,153 @@
+package calsync
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestConflictPolicies(t *testing.T) {
+	now := when("2017-04-29T20:00:00-07:00")
+
+	// Changed in the source, untouched in the calendar.
+	changed := newSrcEvent("changed", now.Add(time.Hour))
+	// Unchanged in the source, edited in the calendar.
+	edited := newSrcEvent("edited", now.AddDate(0, 0, 1))
+	// Removed from the source, edited in the calendar.
+	removed := newSrcEvent("removed", now.AddDate(0, 0, 2))
+
+	changedSrc := *changed
+	changedSrc.Where = "new where"
+	srcEvents := []*Event{&changedSrc, edited}
+
+	calEvents := func() []*Event {
+		return []*Event{
+			syncedCalEvent(changed),
+			editedCalEvent(edited),
+			editedCalEvent(removed),
+		}
+	}
+
+	changes, err := planner{conflictPolicy: PreferSource}.getOperations(now, calEvents(), srcEvents)
+	ok(t, err)
+	equals(t, 2, len(changes.Updates))
+	equals(t, 1, len(changes.Deletes))
+	equals(t, 0, len(changes.Conflicts))
+
+	// This is the default.
+	changes, err = planner{}.getOperations(now, calEvents(), srcEvents)
+	ok(t, err)
+	equals(t, 1, len(changes.Updates))
+	equals(t, "changed title", changes.Updates[0].Title)
+	equals(t, 1, len(changes.Deletes))
+	equals(t, "removed title", changes.Deletes[0].calEventID)
+	equals(t, 0, len(changes.Adds))
+	equals(t, 1, len(changes.Conflicts))
+	equals(t, "edited title", changes.Conflicts[0].calEventID)
+
+	changes, err = planner{conflictPolicy: SkipConflicts}.getOperations(now, calEvents(), srcEvents)
+	ok(t, err)
+	equals(t, 1, len(changes.Updates))
+	equals(t, "changed title", changes.Updates[0].Title)
+	equals(t, 0, len(changes.Deletes))
+	equals(t, 0, len(changes.Adds))
+	equals(t, 2, len(changes.Conflicts))
+
+	_, err = planner{conflictPolicy: FailOnConflict}.getOperations(now, calEvents(), srcEvents)
+	conflictErr, isConflict := err.(*ConflictError)
+	assert(t, isConflict, "expected a *ConflictError, got %v", err)
+	equals(t, 2, len(conflictErr.Events))
+}
+
+func TestForce(t *testing.T) {
+	c := &cal{}
+	Force()(c)
+	equals(t, PreferSource, c.conflictPolicy)
+}
+
+func TestEdited(t *testing.T) {
+	ev := newSrcEvent("ev", when("2017-04-29T20:00:00-07:00"))
+
+	assert(t, !planner{}.edited(testCalEvent("", "", ev)), "events without a hash are never edited")
+	assert(t, !planner{}.edited(syncedCalEvent(ev)), "expected synced event not to be edited")
+	assert(t, planner{}.edited(editedCalEvent(ev)), "expected edited event to be edited")
+
+	commented := syncedCalEvent(ev)
+	commented.Description = "a comment\n" + commented.Description
+	assert(t, !planner{}.edited(commented), "comments before the delimiter are not edits")
+}
+
+// syncedCalEvent returns a calendar event for srcEvent, as we would
+// have written it.
+func syncedCalEvent(srcEvent *Event) *Event {
+	calEvent := testCalEvent("", "", srcEvent)
+	calEvent.syncedHash = planner{}.contentHash(calEvent)
+	return calEvent
+}
+
+// editedCalEvent returns a calendar event for srcEvent, which somebody
+// edited after we wrote it.
+func editedCalEvent(srcEvent *Event) *Event {
+	calEvent := syncedCalEvent(srcEvent)
+	calEvent.Start = calEvent.Start.Add(time.Hour)
+	return calEvent
+}
+
+func TestConflictResolver(t *testing.T) {
+	now := when("2017-04-29T20:00:00-07:00")
+	kept := newSrcEvent("kept", now.Add(time.Hour))
+	removed := newSrcEvent("removed", now.Add(2*time.Hour))
+	merged := newSrcEvent("merged", now.Add(3*time.Hour))
+	calEvents := []*Event{editedCalEvent(kept), editedCalEvent(removed), editedCalEvent(merged)}
+
+	var seen []*Conflict
+	r := ConflictResolverFunc(func(c *Conflict) (*Event, error) {
+		seen = append(seen, c)
+		switch c.Calendar.SrcID {
+		case kept.SrcID:
+			return c.Calendar, nil
+		case removed.SrcID:
+			return nil, nil
+		}
+		// Take the calendar's times, and the rest from the source.
+		m := *c.Source
+		m.Start, m.End = c.Calendar.Start, c.Calendar.End
+		m.Title = "merged by hand"
+		return &m, nil
+	})
+
+	changes, err := planner{resolver: r}.getOperations(now, calEvents, []*Event{kept, merged})
+	ok(t, err)
+	equals(t, 3, len(seen))
+	equals(t, []string{"Start"}, seen[0].Fields)
+	assert(t, seen[1].Source == nil, "expected no source for a removed event")
+
+	equals(t, 1, len(changes.Conflicts))
+	equals(t, kept.SrcID, changes.Conflicts[0].SrcID)
+	equals(t, 1, len(changes.Deletes))
+	equals(t, removed.SrcID, changes.Deletes[0].SrcID)
+	equals(t, 1, len(changes.Updates))
+	update := changes.Updates[0]
+	equals(t, "merged by hand", update.Title)
+	equals(t, merged.SrcID, update.SrcID)
+	equals(t, "merged title", update.calEventID)
+	equals(t, merged, update.syncedSource)
+
+	// Once written, the merge still differs from the source, so it is
+	// reconsidered, and kept as it is.
+	written := *update
+	written.syncedHash = planner{}.contentHash(merged)
+	seen = nil
+	changes, err = planner{resolver: r}.getOperations(now, []*Event{&written}, []*Event{merged})
+	ok(t, err)
+	equals(t, 1, len(seen))
+	equals(t, 0, len(changes.Updates))
+	equals(t, 1, len(changes.Conflicts))
+
+	// Errors stop planning.
+	failing := ConflictResolverFunc(func(c *Conflict) (*Event, error) {
+		return nil, errors.New("no")
+	})
+	_, err = planner{resolver: failing}.getOperations(now, calEvents, []*Event{kept, merged})
+	assert(t, err != nil, "expected an error")
+}
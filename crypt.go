@@ -0,0 +1,119 @@
+package calsync
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// sealedPrefix marks property values written by Encrypt.
+const sealedPrefix = "calsync1:"
+
+// MinKeyLen is the minimum length of a key passed to Encrypt.
+const MinKeyLen = 16
+
+// sealer encrypts and decrypts property values.  Encryption is
+// deterministic, so that writing the same value twice gives the same
+// result and unchanged events don't look changed.  Each value is bound
+// to the name of its property, and those that describe an event, such
+// as its hash, to the event's SrcID too, so they can't be swapped
+// between properties or events, and equal values of different events
+// don't look equal.  The values that identify events can't be bound to
+// them, since events are looked up by them, so an event's ID is sealed
+// the same way wherever it is written.
+type sealer struct {
+	aead     cipher.AEAD
+	nonceKey []byte
+}
+
+func newSealer(key []byte) (*sealer, error) {
+	if len(key) < MinKeyLen {
+		return nil, fmt.Errorf("encryption key is %d bytes, less than the minimum of %d", len(key), MinKeyLen)
+	}
+	block, err := aes.NewCipher(deriveKey(key, "calsync encryption"))
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &sealer{aead, deriveKey(key, "calsync nonce")}, nil
+}
+
+func deriveKey(key []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+// seal encrypts value, bound to property name and, unless it is empty,
+// to the event with srcID.
+func (s *sealer) seal(name, srcID, value string) string {
+	ad := associatedData(name, srcID)
+	mac := hmac.New(sha256.New, s.nonceKey)
+	mac.Write(ad)
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	nonce := mac.Sum(nil)[:s.aead.NonceSize()]
+	sealed := s.aead.Seal(nonce, nonce, []byte(value), ad)
+	return sealedPrefix + base64.RawURLEncoding.EncodeToString(sealed)
+}
+
+func associatedData(name, srcID string) []byte {
+	if srcID == "" {
+		return []byte(name)
+	}
+	return []byte(name + "\x00" + srcID)
+}
+
+func (s *sealer) open(name, srcID, value string) (string, error) {
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(value, sealedPrefix))
+	if err != nil || len(b) < s.aead.NonceSize() {
+		return "", fmt.Errorf("malformed encrypted %s", name)
+	}
+	n := s.aead.NonceSize()
+	plain, err := s.aead.Open(nil, b[:n], b[n:], associatedData(name, srcID))
+	if err != nil {
+		return "", fmt.Errorf("unable to decrypt %s; was it encrypted with a different key?", name)
+	}
+	return string(plain), nil
+}
+
+// sealProp returns value as it should be stored in private extended
+// property name, which identifies events.
+func (c cal) sealProp(name, value string) string {
+	return c.sealEventProp("", name, value)
+}
+
+// sealEventProp returns value as it should be stored in private
+// extended property name of the event with srcID.
+func (c cal) sealEventProp(srcID, name, value string) string {
+	if c.sealer == nil {
+		return value
+	}
+	return c.sealer.seal(name, srcID, value)
+}
+
+// openProp returns the value that was stored in private extended
+// property name, which identifies events.  Values written before
+// encryption was turned on are returned as they are.
+func (c cal) openProp(name, value string) (string, error) {
+	return c.openEventProp("", name, value)
+}
+
+// openEventProp returns the value that was stored in private extended
+// property name of the event with srcID, as openProp does.
+func (c cal) openEventProp(srcID, name, value string) (string, error) {
+	if !strings.HasPrefix(value, sealedPrefix) {
+		return value, nil
+	}
+	if c.sealer == nil {
+		return "", fmt.Errorf("%s is encrypted; use the Encrypt option", name)
+	}
+	return c.sealer.open(name, srcID, value)
+}
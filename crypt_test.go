@@ -0,0 +1,142 @@
+package calsync
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+var testKey = []byte("0123456789abcdef0123456789abcdef")
+
+func TestEncrypt(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	src := []*Event{newSrcEvent("secret", time.Now().Add(time.Hour).Truncate(time.Second))}
+
+	changes, err := Sync(ctx, s.Client(), "scope", src, Encrypt(testKey))
+	ok(t, err)
+	equals(t, 1, len(changes.Adds))
+
+	stored := s.Events("primary")
+	equals(t, 1, len(stored))
+	props := stored[0].ExtendedProperties.Private
+	equals(t, "True", props["scope"])
+	for _, key := range []string{"scopeID", "scopeHash"} {
+		assert(t, strings.HasPrefix(props[key], sealedPrefix), "%s is not encrypted: %q", key, props[key])
+	}
+	assert(t, !strings.Contains(props["scopeID"], "secret"), "scopeID leaks the SrcID: %q", props["scopeID"])
+
+	// Encrypted values read back as they were, so nothing changes.
+	changes, err = Sync(ctx, s.Client(), "scope", src, Encrypt(testKey))
+	ok(t, err)
+	assert(t, changes.empty(), "expected no changes, got %s", changes)
+	equals(t, props["scopeID"], s.Events("primary")[0].ExtendedProperties.Private["scopeID"])
+
+	events, err := Fetch(ctx, s.Client(), "scope", Encrypt(testKey))
+	ok(t, err)
+	equals(t, src[0].SrcID, events[0].SrcID)
+
+	// Without the right key, fetching fails rather than treating the
+	// events as unknown.
+	_, err = Fetch(ctx, s.Client(), "scope")
+	assert(t, err != nil, "expected an error without a key")
+	_, err = Fetch(ctx, s.Client(), "scope", Encrypt([]byte("a different key, just as long...")))
+	assert(t, err != nil, "expected an error with the wrong key")
+}
+
+func TestEncryptExistingEvents(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	src := []*Event{newSrcEvent("plain", time.Now().Add(time.Hour).Truncate(time.Second))}
+
+	_, err := Sync(ctx, s.Client(), "scope", src)
+	ok(t, err)
+
+	changes, err := Sync(ctx, s.Client(), "scope", src, Encrypt(testKey))
+	ok(t, err)
+	assert(t, changes.empty(), "expected no changes, got %s", changes)
+
+	src[0].Title = "changed"
+	changes, err = Sync(ctx, s.Client(), "scope", src, Encrypt(testKey))
+	ok(t, err)
+	equals(t, 1, len(changes.Updates))
+	id := s.Events("primary")[0].ExtendedProperties.Private["scopeID"]
+	assert(t, strings.HasPrefix(id, sealedPrefix), "expected the update to encrypt, got %q", id)
+}
+
+func TestEncryptPrivateProps(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	src := []*Event{newSrcEvent("secret", time.Now().Add(time.Hour).Truncate(time.Second))}
+	src[0].PrivateProps = map[string]string{"ticket": "ABC-123"}
+
+	_, err := Sync(ctx, s.Client(), "scope", src, Encrypt(testKey))
+	ok(t, err)
+	ticket := s.Events("primary")[0].ExtendedProperties.Private["ticket"]
+	assert(t, strings.HasPrefix(ticket, sealedPrefix), "ticket is not encrypted: %q", ticket)
+	assert(t, !strings.Contains(ticket, "ABC-123"), "ticket leaks its value: %q", ticket)
+
+	changes, err := Sync(ctx, s.Client(), "scope", src, Encrypt(testKey))
+	ok(t, err)
+	assert(t, changes.empty(), "expected no changes, got %s", changes)
+
+	events, err := Fetch(ctx, s.Client(), "scope", Encrypt(testKey))
+	ok(t, err)
+	equals(t, src[0].PrivateProps, events[0].PrivateProps)
+}
+
+func TestEncryptedHashSwapped(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	src := []*Event{newSrcEvent("a", start), newSrcEvent("b", start.Add(time.Hour))}
+	_, err := Sync(ctx, s.Client(), "scope", src, Encrypt(testKey))
+	ok(t, err)
+
+	stored := s.Events("primary")
+	stored[1].ExtendedProperties.Private["scopeHash"] = stored[0].ExtendedProperties.Private["scopeHash"]
+	_, err = s.Put("primary", stored[1])
+	ok(t, err)
+	_, err = Fetch(ctx, s.Client(), "scope", Encrypt(testKey))
+	assert(t, err != nil, "expected an error for a hash copied from another event")
+}
+
+func TestEncryptShortKey(t *testing.T) {
+	s := calsynctest.NewServer()
+	_, err := Sync(context.Background(), s.Client(), "scope", nil, Encrypt([]byte("short")))
+	assert(t, err != nil, "expected an error for a short key")
+}
+
+func TestSealerBindsName(t *testing.T) {
+	s, err := newSealer(testKey)
+	ok(t, err)
+	sealed := s.seal("scopeID", "", "value")
+	equals(t, sealed, s.seal("scopeID", "", "value"))
+
+	opened, err := s.open("scopeID", "", sealed)
+	ok(t, err)
+	equals(t, "value", opened)
+
+	_, err = s.open("scopeHash", "", sealed)
+	assert(t, err != nil, "expected a value sealed for one property not to open for another")
+}
+
+func TestSealerBindsEvent(t *testing.T) {
+	s, err := newSealer(testKey)
+	ok(t, err)
+	sealed := s.seal("scopeHash", "a", "hash")
+	assert(t, sealed != s.seal("scopeHash", "b", "hash"), "expected equal values of different events to differ")
+
+	opened, err := s.open("scopeHash", "a", sealed)
+	ok(t, err)
+	equals(t, "hash", opened)
+
+	_, err = s.open("scopeHash", "b", sealed)
+	assert(t, err != nil, "expected a value sealed for one event not to open for another")
+	_, err = s.open("scopeHash", "", sealed)
+	assert(t, err != nil, "expected a value sealed for an event not to open unbound")
+}
@@ -0,0 +1,198 @@
+package calsync
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ColumnMap says how ReadCSV finds the fields of each event in the
+// columns of a CSV file.
+type ColumnMap struct {
+	// Title, Start, End, Where, Description and SrcID name the header
+	// of the column holding each field.  Headers are matched ignoring
+	// case and surrounding space.  Title, Start and SrcID are required.
+	// The others may be empty, in which case the field is left empty.
+	Title, Start, End, Where, Description, SrcID string
+
+	// Layouts are the time.Parse layouts tried, in order, to parse Start
+	// and End.  The default is time.RFC3339.
+	Layouts []string
+
+	// DateLayout, if set, is tried after Layouts.  Values that match it
+	// are dates, and make all day events.  End is the day after the last
+	// day, as for Event.
+	DateLayout string
+
+	// Location is used for times and dates whose layout has no zone.
+	// The default is time.Local.
+	Location *time.Location
+
+	// Duration is the length of timed events with no End column, or an
+	// empty End.  All day events without an end last a day.
+	Duration time.Duration
+}
+
+// ReadCSV reads events from a CSV file, such as a spreadsheet export,
+// so they can be passed to Sync.  The first row must hold the column
+// headers.  Empty rows are skipped.
+func ReadCSV(r io.Reader, mapping ColumnMap) ([]*Event, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("csv: no header row")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("csv: %v", err)
+	}
+	cols, err := mapping.columns(header)
+	if err != nil {
+		return nil, fmt.Errorf("csv: %v", err)
+	}
+
+	var events []*Event
+	for row := 2; ; row++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("csv: %v", err)
+		}
+		if isBlank(record) {
+			continue
+		}
+		ev, err := mapping.event(cols, record)
+		if err != nil {
+			return nil, fmt.Errorf("csv row %d: %v", row, err)
+		}
+		events = append(events, ev)
+	}
+	return events, nil
+}
+
+// csvColumns holds the index of the column for each field, or -1.
+type csvColumns struct {
+	title, start, end, where, description, srcID int
+}
+
+func (m ColumnMap) columns(header []string) (*csvColumns, error) {
+	index := map[string]int{}
+	for i, h := range header {
+		index[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	var err error
+	find := func(field, name string, required bool) int {
+		if name == "" {
+			if required && err == nil {
+				err = fmt.Errorf("no column given for %s", field)
+			}
+			return -1
+		}
+		i, ok := index[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			if err == nil {
+				err = fmt.Errorf("no column %q for %s", name, field)
+			}
+			return -1
+		}
+		return i
+	}
+	cols := &csvColumns{
+		title:       find("Title", m.Title, true),
+		start:       find("Start", m.Start, true),
+		end:         find("End", m.End, false),
+		where:       find("Where", m.Where, false),
+		description: find("Description", m.Description, false),
+		srcID:       find("SrcID", m.SrcID, true),
+	}
+	if err != nil {
+		return nil, err
+	}
+	return cols, nil
+}
+
+func (m ColumnMap) event(cols *csvColumns, record []string) (*Event, error) {
+	field := func(i int) string {
+		if i < 0 || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	srcID := field(cols.srcID)
+	if srcID == "" {
+		return nil, fmt.Errorf("empty %s", m.SrcID)
+	}
+	start, allDay, err := m.parseTime(field(cols.start))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", m.Start, err)
+	}
+
+	var end time.Time
+	if s := field(cols.end); s != "" {
+		var endAllDay bool
+		if end, endAllDay, err = m.parseTime(s); err != nil {
+			return nil, fmt.Errorf("%s: %v", m.End, err)
+		}
+		if endAllDay != allDay {
+			return nil, fmt.Errorf("%s and %s must both be dates or both be times", m.Start, m.End)
+		}
+	} else if allDay {
+		end = start.AddDate(0, 0, 1)
+	} else {
+		end = start.Add(m.Duration)
+	}
+	if end.Before(start) {
+		return nil, fmt.Errorf("%s is before %s", m.End, m.Start)
+	}
+
+	return &Event{
+		Title:       field(cols.title),
+		Start:       start,
+		End:         end,
+		Where:       field(cols.where),
+		Description: field(cols.description),
+		SrcID:       srcID,
+		AllDay:      allDay,
+	}, nil
+}
+
+// parseTime parses s using the layouts of m, reporting whether it is a
+// date.
+func (m ColumnMap) parseTime(s string) (time.Time, bool, error) {
+	if s == "" {
+		return time.Time{}, false, fmt.Errorf("empty time")
+	}
+	loc := m.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	layouts := m.Layouts
+	if len(layouts) == 0 {
+		layouts = []string{time.RFC3339}
+	}
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, false, nil
+		}
+	}
+	if m.DateLayout != "" {
+		if t, err := time.ParseInLocation(m.DateLayout, s, loc); err == nil {
+			return t, true, nil
+		}
+	}
+	return time.Time{}, false, fmt.Errorf("%q doesn't match any layout", s)
+}
+
+func isBlank(record []string) bool {
+	for _, f := range record {
+		if strings.TrimSpace(f) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,90 @@
+package calsync
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+var testColumns = ColumnMap{
+	Title:       "Event",
+	Start:       "Starts",
+	End:         "Ends",
+	Where:       "Venue",
+	Description: "Notes",
+	SrcID:       "ID",
+	Layouts:     []string{"2006-01-02 15:04"},
+	DateLayout:  "2006-01-02",
+}
+
+func TestReadCSV(t *testing.T) {
+	csv := strings.Join([]string{
+		"ID, event ,Starts,Ends,Venue,Notes,Ignored",
+		`1,Rehearsal,2017-05-01 19:00,2017-05-01 21:00,The Hall,"Bring music,
+and a stand",x`,
+		",,,,,,",
+		"2,Festival,2017-05-02,2017-05-04,,,",
+		"3,Open ended,2017-05-05 10:00,,,,",
+	}, "\n")
+
+	kathmandu := loadKathmandu(t)
+	m := testColumns
+	m.Location = kathmandu
+	m.Duration = 30 * time.Minute
+	events, err := ReadCSV(strings.NewReader(csv), m)
+	ok(t, err)
+	equals(t, 3, len(events))
+
+	rehearsal := events[0]
+	equals(t, "1", rehearsal.SrcID)
+	equals(t, "Rehearsal", rehearsal.Title)
+	equals(t, "The Hall", rehearsal.Where)
+	equals(t, "Bring music,\nand a stand", rehearsal.Description)
+	equals(t, time.Date(2017, 5, 1, 19, 0, 0, 0, kathmandu), rehearsal.Start)
+	equals(t, time.Date(2017, 5, 1, 21, 0, 0, 0, kathmandu), rehearsal.End)
+	assert(t, !rehearsal.AllDay, "expected a timed event")
+
+	festival := events[1]
+	assert(t, festival.AllDay, "expected an all day event")
+	equals(t, "2017-05-02", festival.Start.Format(dateLayout))
+	equals(t, "2017-05-04", festival.End.Format(dateLayout))
+
+	equals(t, 30*time.Minute, events[2].End.Sub(events[2].Start))
+}
+
+func TestReadCSVDefaults(t *testing.T) {
+	csv := "title,start,id\nParty,2017-05-01T19:00:00-07:00,p\nPicnic,2017-05-02,q\n"
+	events, err := ReadCSV(strings.NewReader(csv), ColumnMap{
+		Title:      "title",
+		Start:      "start",
+		SrcID:      "id",
+		DateLayout: "2006-01-02",
+	})
+	ok(t, err)
+	equals(t, 2, len(events))
+	assert(t, events[0].Start.Equal(when("2017-05-01T19:00:00-07:00")), "got start %v", events[0].Start)
+	equals(t, events[0].Start, events[0].End)
+	assert(t, events[1].AllDay, "expected an all day event")
+	equals(t, "2017-05-03", events[1].End.Format(dateLayout))
+}
+
+func TestReadCSVErrors(t *testing.T) {
+	header := "ID,Event,Starts,Ends,Venue,Notes\n"
+	for _, tt := range []struct {
+		csv  string
+		m    ColumnMap
+		want string
+	}{
+		{"", testColumns, "no header row"},
+		{"ID,Event\n", testColumns, `no column "Starts" for Start`},
+		{header, ColumnMap{Title: "Event", Start: "Starts"}, "no column given for SrcID"},
+		{header + ",Untitled,2017-05-01,,,\n", testColumns, "row 2: empty ID"},
+		{header + "1,Bad,tomorrow,,,\n", testColumns, `row 2: Starts: "tomorrow" doesn't match any layout`},
+		{header + "1,Mixed,2017-05-01,2017-05-01 10:00,,\n", testColumns, "both be dates or both be times"},
+		{header + "1,Backwards,2017-05-02,2017-05-01,,\n", testColumns, "Ends is before Starts"},
+	} {
+		_, err := ReadCSV(strings.NewReader(tt.csv), tt.m)
+		assert(t, err != nil && strings.Contains(err.Error(), tt.want),
+			"%q: got error %v, want %q", tt.csv, err, tt.want)
+	}
+}
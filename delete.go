@@ -0,0 +1,78 @@
+package calsync
+
+import (
+	"fmt"
+	"strings"
+
+	calendar "google.golang.org/api/calendar/v3"
+
+	"golang.org/x/net/context"
+)
+
+// DeletePolicy determines what Sync does with calendar events that are
+// no longer in the source.
+type DeletePolicy int
+
+const (
+	// HardDelete deletes the events.  This is the default.
+	HardDelete DeletePolicy = iota
+
+	// Cancel keeps the events, so that invitees keep the context, but
+	// marks them as cancelled: their titles get the CancelledPrefix,
+	// and they no longer block time.  They are then no longer part of
+	// the scope, so later syncs leave them alone, and an event that
+	// reappears in the source is added again.
+	//
+	// Google calendar's own cancelled status isn't used, as it deletes
+	// the event.
+	Cancel
+)
+
+// CancelledPrefix starts the title of events cancelled under the
+// Cancel policy.
+const CancelledPrefix = "[CANCELLED] "
+
+// cancelledScope is the value of the <scope> property of events
+// cancelled under the Cancel policy.  It is anything but "True", so
+// that they no longer match our queries.
+const cancelledScope = "Cancelled"
+
+func (p DeletePolicy) String() string {
+	switch p {
+	case HardDelete:
+		return "HardDelete"
+	case Cancel:
+		return "Cancel"
+	}
+	return fmt.Sprintf("DeletePolicy(%d)", int(p))
+}
+
+// cancel marks ev as cancelled, for the Cancel policy.
+func (c cal) cancel(ctx context.Context, ev *Event) error {
+	title := ev.Title
+	if !strings.HasPrefix(title, CancelledPrefix) {
+		title = CancelledPrefix + title
+	}
+	call := c.svc.Events.Patch(c.calendarOf(ev), ev.calEventID, &calendar.Event{
+		Summary:      title,
+		Transparency: "transparent",
+		ExtendedProperties: &calendar.EventExtendedProperties{
+			Private: map[string]string{c.scope: cancelledScope},
+		},
+	})
+	if send := c.notify.sendUpdates(); send != "" {
+		call = call.SendUpdates(send)
+	}
+	_, err := call.Context(ctx).Do()
+	if isNotFound(err) || isGone(err) {
+		// Already deleted, which will do.
+		return nil
+	}
+	if isRateLimited(err) {
+		return err
+	}
+	if err != nil {
+		return &opError{fmt.Sprintf("cancelling %s", ev.calEventID), err}
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package calsync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+func TestCancel(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	src := []*Event{newSrcEvent("a", start), newSrcEvent("b", start)}
+	_, err := Sync(ctx, s.Client(), "scope", src)
+	ok(t, err)
+
+	changes, err := Sync(ctx, s.Client(), "scope", src[:1], OnDelete(Cancel))
+	ok(t, err)
+	equals(t, 1, len(changes.Deletes))
+	events := s.Events("primary")
+	equals(t, 2, len(events))
+	cancelled := events[1]
+	if events[0].Summary != "a title" {
+		cancelled = events[0]
+	}
+	equals(t, "[CANCELLED] b title", cancelled.Summary)
+	equals(t, "transparent", cancelled.Transparency)
+
+	// The cancelled event is left alone from now on.
+	changes, err = Sync(ctx, s.Client(), "scope", src[:1], OnDelete(Cancel))
+	ok(t, err)
+	assert(t, changes.empty(), "expected no changes, got %s", changes)
+	scopes, err := ListScopes(ctx, s.Client(), "primary")
+	ok(t, err)
+	equals(t, []ScopeCount{{"scope", 1}}, scopes)
+}
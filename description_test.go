@@ -16,8 +16,8 @@ func TestFallback(t *testing.T) {
 
 func TestCommentPreservation(t *testing.T) {
 	d := &description{
-		"testprefix",
-		"testsuffix",
+		prefix: "testprefix",
+		suffix: "testsuffix",
 	}
 	s := d.String()
 	equals(t, "testprefix\n"+delim+"\ntestsuffix", s)
@@ -26,6 +26,17 @@ func TestCommentPreservation(t *testing.T) {
 	equals(t, "testsuffix", d.suffix)
 }
 
+func TestTrailerPreservation(t *testing.T) {
+	s := "testprefix\n" + delim + "\ntestsuffix\n" + delim + "\ntesttrailer"
+	d := parseDescription(s)
+	equals(t, &description{"testprefix", "testsuffix", "testtrailer", true}, d)
+	equals(t, s, d.String())
+
+	d = parseDescription(delim + "\ntestsuffix\n" + delim + "\n")
+	equals(t, &description{"", "testsuffix", "", true}, d)
+	equals(t, delim+"\ntestsuffix\n"+delim+"\n", d.String())
+}
+
 // equals fails the test if exp is not equal to act.
 func equals(tb testing.TB, exp, act interface{}) {
 	if !reflect.DeepEqual(exp, act) {
@@ -0,0 +1,354 @@
+package calsync
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	calendar "google.golang.org/api/calendar/v3"
+	"google.golang.org/api/googleapi"
+
+	"golang.org/x/net/context"
+)
+
+// maxClockSkew is how far the local clock may be from google's before
+// Diagnose warns about it.  Sync uses the local clock to decide which
+// events are upcoming.
+const maxClockSkew = time.Minute
+
+// probeEventID names an event that doesn't exist.  Diagnose patches it
+// to find out whether the token allows writes, without writing
+// anything.
+const probeEventID = "calsyncdiagnoseprobe"
+
+// Status is the outcome of a diagnostic Check.
+type Status int
+
+const (
+	// StatusOK means nothing is wrong.
+	StatusOK Status = iota
+
+	// StatusWarning means syncing may work, but not as intended.
+	StatusWarning
+
+	// StatusFailed means syncing won't work until the problem is fixed.
+	StatusFailed
+
+	// StatusSkipped means the check couldn't be made because an earlier
+	// one failed.
+	StatusSkipped
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusOK:
+		return "ok"
+	case StatusWarning:
+		return "warning"
+	case StatusFailed:
+		return "failed"
+	case StatusSkipped:
+		return "skipped"
+	}
+	return fmt.Sprintf("Status(%d)", int(s))
+}
+
+// Check is the result of a single diagnostic check.
+type Check struct {
+	Name   string
+	Status Status
+
+	// Detail describes what was found.
+	Detail string
+
+	// Remedy says what to do about it.  It is empty when Status is
+	// StatusOK or StatusSkipped.
+	Remedy string
+}
+
+// Diagnosis is the result of Diagnose.
+type Diagnosis struct {
+	Checks []*Check
+}
+
+// OK reports whether no check failed.  Warnings don't count.
+func (d *Diagnosis) OK() bool {
+	for _, c := range d.Checks {
+		if c.Status == StatusFailed {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *Diagnosis) String() string {
+	var lines []string
+	for _, c := range d.Checks {
+		lines = append(lines, fmt.Sprintf("[%s] %s: %s", c.Status, c.Name, c.Detail))
+		if c.Remedy != "" {
+			lines = append(lines, "    fix: "+c.Remedy)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (d *Diagnosis) add(name string, status Status, detail, remedy string) {
+	d.Checks = append(d.Checks, &Check{name, status, detail, remedy})
+}
+
+func (d *Diagnosis) skip(name string) {
+	d.add(name, StatusSkipped, "not checked", "")
+}
+
+// Diagnose checks that Sync could work for scope with client and opts,
+// and explains how to fix whatever would stop it.  It checks, in order,
+// the scope, that the credentials are accepted, that the token allows
+// writing to calendars, that the calendar exists and can be written,
+// that google calendar isn't rate limiting us, that the local clock
+// agrees with google's, and that the events in scope can be found by
+// their private extended properties.
+//
+// Diagnose doesn't modify anything.  It returns an error only if it
+// couldn't make the checks at all; the problems it finds are reported
+// in the Diagnosis.
+func Diagnose(ctx context.Context, client *http.Client, scope string, opts ...Opt) (*Diagnosis, error) {
+	c, err := configure(client, scope, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.needsGoogle("Diagnose"); err != nil {
+		return nil, err
+	}
+	if err = c.findCalendar(ctx); err != nil {
+		return nil, err
+	}
+
+	d := &Diagnosis{}
+	switch {
+	case scope == "":
+		d.add("scope", StatusFailed, "scope is empty",
+			"Pass a short name that is unique to the application syncing events.")
+	case len(scope) > MaxScopeLen:
+		d.add("scope", StatusFailed,
+			fmt.Sprintf("scope %q is %d characters long, more than the %d allowed", scope, len(scope), MaxScopeLen),
+			"Use a shorter scope.")
+	default:
+		d.add("scope", StatusOK, fmt.Sprintf("%q", scope), "")
+	}
+
+	var rateLimited []string
+	noteRateLimit := func(what string, err error) {
+		if isRateLimited(err) {
+			rateLimited = append(rateLimited, what)
+		}
+	}
+
+	sent := time.Now()
+	entry, err := c.svc.CalendarList.Get(c.calID).Context(ctx).Do()
+	received := time.Now()
+	noteRateLimit("looking up the calendar", err)
+	var header http.Header
+	if entry != nil {
+		header = entry.Header
+	} else if e, ok := err.(*googleapi.Error); ok {
+		header = e.Header
+	}
+
+	if !diagnoseCredentials(d, err) {
+		for _, name := range []string{"token scope", "calendar", "rate limits", "clock", "extended properties"} {
+			d.skip(name)
+		}
+		return d, nil
+	}
+
+	_, probeErr := c.svc.Events.Patch(c.calID, probeEventID, &calendar.Event{}).Context(ctx).Do()
+	noteRateLimit("checking write access", probeErr)
+	if isInsufficientScope(err) || isInsufficientScope(probeErr) {
+		d.add("token scope", StatusFailed, "the token doesn't allow managing calendars",
+			fmt.Sprintf("Request %s when authorizing, and discard any cached token that was granted narrower scopes.", Scope))
+	} else {
+		d.add("token scope", StatusOK, "the token allows managing calendars", "")
+	}
+
+	calendarOK := diagnoseCalendar(d, c.calID, entry, err)
+
+	if len(rateLimited) != 0 {
+		d.add("rate limits", StatusWarning,
+			fmt.Sprintf("rate limited while %s", strings.Join(rateLimited, " and ")),
+			"Wait and try again.  If it persists, sync less often or request more quota for the project in the Google API Console.")
+	} else {
+		d.add("rate limits", StatusOK, "not rate limited", "")
+	}
+
+	diagnoseClock(d, header, sent, received)
+
+	if !calendarOK {
+		d.skip("extended properties")
+		return d, nil
+	}
+	diagnoseProperties(ctx, d, c)
+	return d, nil
+}
+
+// diagnoseCredentials adds a check for the credentials, given the
+// result of the first api call, and reports whether they work.
+func diagnoseCredentials(d *Diagnosis, err error) bool {
+	const name = "credentials"
+	if err == nil {
+		d.add(name, StatusOK, "accepted by google calendar", "")
+		return true
+	}
+	e, ok := err.(*googleapi.Error)
+	if !ok {
+		d.add(name, StatusFailed, fmt.Sprintf("unable to reach google calendar: %v", err),
+			"Check the network connection and any proxy settings, and that the oauth token can be refreshed.")
+		return false
+	}
+	if e.Code == http.StatusUnauthorized {
+		d.add(name, StatusFailed, "rejected by google calendar",
+			"The token is invalid, expired or revoked.  Authorize again; see https://developers.google.com/google-apps/calendar/quickstart/go")
+		return false
+	}
+	d.add(name, StatusOK, "accepted by google calendar", "")
+	return true
+}
+
+// diagnoseCalendar adds a check for calendar calID, given the result of
+// looking it up, and reports whether events can be listed from it.
+func diagnoseCalendar(d *Diagnosis, calID string, entry *calendar.CalendarListEntry, err error) bool {
+	const name = "calendar"
+	switch {
+	case err == nil:
+	case isNotFound(err):
+		d.add(name, StatusFailed,
+			fmt.Sprintf("calendar %q is not in the calendar list of the authorized account", calID),
+			"Check the CalendarID option.  If the calendar belongs to someone else, have them share it with the authorized account, then add it to that account's calendar list.")
+		return false
+	case isInsufficientScope(err) || isRateLimited(err):
+		// Reported by other checks.
+		d.add(name, StatusSkipped, "not checked", "")
+		return false
+	default:
+		d.add(name, StatusFailed, fmt.Sprintf("unable to retrieve calendar %q: %v", calID, err),
+			"Check the CalendarID option.")
+		return false
+	}
+
+	switch entry.AccessRole {
+	case "owner", "writer":
+		d.add(name, StatusOK, fmt.Sprintf("%q (%s), access role %s", calID, entry.Summary, entry.AccessRole), "")
+	default:
+		d.add(name, StatusFailed,
+			fmt.Sprintf("%q (%s), access role %s, which can't change events", calID, entry.Summary, entry.AccessRole),
+			"Have the calendar's owner share it with the authorized account with permission to make changes to events.")
+	}
+	return true
+}
+
+// diagnoseClock adds a check comparing the local clock to the Date
+// header google calendar sent with a response, for a request made
+// between sent and received.
+func diagnoseClock(d *Diagnosis, header http.Header, sent, received time.Time) {
+	const name = "clock"
+	date, err := http.ParseTime(header.Get("Date"))
+	if err != nil {
+		d.add(name, StatusSkipped, "google calendar didn't report its time", "")
+		return
+	}
+	// The Date header only has whole seconds.
+	var skew time.Duration
+	switch {
+	case date.Add(time.Second).Before(sent):
+		skew = sent.Sub(date.Add(time.Second))
+	case date.After(received):
+		skew = -date.Sub(received)
+	}
+	if skew > maxClockSkew || -skew > maxClockSkew {
+		direction := "ahead of"
+		if skew < 0 {
+			direction, skew = "behind", -skew
+		}
+		d.add(name, StatusWarning,
+			fmt.Sprintf("the local clock is %v %s google's", skew/time.Second*time.Second, direction),
+			"Synchronize the local clock, for example with NTP.  Events ending around now may otherwise be added or removed when they shouldn't be.")
+		return
+	}
+	d.add(name, StatusOK, "the local clock agrees with google's", "")
+}
+
+// diagnoseProperties adds a check that filtering by our private
+// extended properties works, and that the events it finds are ones we
+// can match up with source events.
+func diagnoseProperties(ctx context.Context, d *Diagnosis, c *cal) {
+	const name = "extended properties"
+	var found, foreign, unmatched int
+	err := c.svc.Events.List(c.calID).
+		ShowDeleted(false).
+		SingleEvents(true).
+		TimeMin(c.now().Format(time.RFC3339)).
+		PrivateExtendedProperty(c.scope+"=True").
+		Pages(ctx, func(page *calendar.Events) error {
+			for _, item := range page.Items {
+				found++
+				var props map[string]string
+				if item.ExtendedProperties != nil {
+					props = item.ExtendedProperties.Private
+				}
+				switch {
+				case props[c.scope] != "True":
+					foreign++
+				case props[c.idKey()] == "":
+					unmatched++
+				}
+			}
+			return nil
+		})
+	switch {
+	case err != nil:
+		d.add(name, StatusFailed, fmt.Sprintf("unable to list events by private extended property: %v", err),
+			"Check that nothing between here and google calendar drops or rewrites the privateExtendedProperty query parameter.")
+	case foreign != 0:
+		d.add(name, StatusFailed,
+			fmt.Sprintf("%d of %d listed events don't have the %s=True property, so filtering by it isn't working", foreign, found, c.scope),
+			"Check that nothing between here and google calendar drops or rewrites the privateExtendedProperty query parameter.  Syncing now would delete events it doesn't own.")
+	case unmatched != 0:
+		d.add(name, StatusWarning,
+			fmt.Sprintf("%d of %d upcoming events in scope have no %s property and will be deleted by the next sync", unmatched, found, c.idKey()),
+			"If these events were copied or created by hand with the scope's properties, remove the properties from the ones you want to keep.")
+	default:
+		d.add(name, StatusOK, fmt.Sprintf("%d upcoming events in scope", found), "")
+	}
+}
+
+// isRateLimited reports whether err means google calendar is rate
+// limiting us or we ran out of quota.
+func isRateLimited(err error) bool {
+	e, ok := err.(*googleapi.Error)
+	if !ok {
+		return false
+	}
+	if e.Code == http.StatusTooManyRequests {
+		return true
+	}
+	return e.Code == http.StatusForbidden && hasReason(e,
+		"rateLimitExceeded", "userRateLimitExceeded", "quotaExceeded", "dailyLimitExceeded")
+}
+
+// isInsufficientScope reports whether err means the token wasn't
+// granted a scope the call needs.
+func isInsufficientScope(err error) bool {
+	e, ok := err.(*googleapi.Error)
+	return ok && e.Code == http.StatusForbidden && hasReason(e, "insufficientPermissions")
+}
+
+func hasReason(e *googleapi.Error, reasons ...string) bool {
+	for _, item := range e.Errors {
+		for _, r := range reasons {
+			if item.Reason == r {
+				return true
+			}
+		}
+	}
+	return false
+}
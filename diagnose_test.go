@@ -0,0 +1,164 @@
+package calsync
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+	calendar "google.golang.org/api/calendar/v3"
+
+	"golang.org/x/net/context"
+)
+
+// rejectTransport fails requests using method, or all requests if
+// method is empty, with a google api error, and sends the rest to base.
+type rejectTransport struct {
+	base   http.RoundTripper
+	method string
+	code   int
+	reason string
+}
+
+func (t rejectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.method != "" && req.Method != t.method {
+		return t.base.RoundTrip(req)
+	}
+	body := fmt.Sprintf(`{"error":{"errors":[{"domain":"global","reason":%q,"message":"rejected"}],"code":%d,"message":"rejected"}}`,
+		t.reason, t.code)
+	return &http.Response{
+		StatusCode: t.code,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       ioutil.NopCloser(bytes.NewBufferString(body)),
+		Request:    req,
+	}, nil
+}
+
+func diagnoseStatuses(t *testing.T, client *http.Client, opts ...Opt) map[string]Status {
+	d, err := Diagnose(context.Background(), client, "scope", opts...)
+	ok(t, err)
+	statuses := map[string]Status{}
+	for _, c := range d.Checks {
+		statuses[c.Name] = c.Status
+		if c.Status != StatusOK && c.Status != StatusSkipped {
+			assert(t, c.Remedy != "", "%s: no remedy for %s", c.Name, c.Detail)
+		}
+	}
+	return statuses
+}
+
+func TestDiagnoseHealthy(t *testing.T) {
+	s := calsynctest.NewServer()
+	_, err := Sync(context.Background(), s.Client(), "scope", []*Event{newSrcEvent("a", time.Now().Add(time.Hour))})
+	ok(t, err)
+
+	d, err := Diagnose(context.Background(), s.Client(), "scope")
+	ok(t, err)
+	assert(t, d.OK(), "expected ok, got\n%s", d)
+	for _, c := range d.Checks {
+		equals(t, StatusOK, c.Status)
+	}
+	equals(t, "1 upcoming events in scope", d.Checks[len(d.Checks)-1].Detail)
+
+	// Diagnose doesn't write anything.
+	equals(t, 1, len(s.Events("primary")))
+}
+
+func TestDiagnoseProblems(t *testing.T) {
+	tests := []struct {
+		name   string
+		setup  func(s *calsynctest.Server, client *http.Client) []Opt
+		check  string
+		status Status
+	}{
+		{"unknown calendar", func(s *calsynctest.Server, client *http.Client) []Opt {
+			return []Opt{CalendarID("nope")}
+		}, "calendar", StatusFailed},
+		{"read only calendar", func(s *calsynctest.Server, client *http.Client) []Opt {
+			s.SetAccessRole("primary", "reader")
+			return nil
+		}, "calendar", StatusFailed},
+		{"clock ahead", func(s *calsynctest.Server, client *http.Client) []Opt {
+			s.Now = func() time.Time { return time.Now().Add(-10 * time.Minute) }
+			return nil
+		}, "clock", StatusWarning},
+		{"clock behind", func(s *calsynctest.Server, client *http.Client) []Opt {
+			s.Now = func() time.Time { return time.Now().Add(10 * time.Minute) }
+			return nil
+		}, "clock", StatusWarning},
+		{"rejected credentials", func(s *calsynctest.Server, client *http.Client) []Opt {
+			client.Transport = rejectTransport{client.Transport, "", http.StatusUnauthorized, "authError"}
+			return nil
+		}, "credentials", StatusFailed},
+		{"read only token", func(s *calsynctest.Server, client *http.Client) []Opt {
+			client.Transport = rejectTransport{client.Transport, "PATCH", http.StatusForbidden, "insufficientPermissions"}
+			return nil
+		}, "token scope", StatusFailed},
+		{"rate limited", func(s *calsynctest.Server, client *http.Client) []Opt {
+			client.Transport = &calsynctest.FaultTransport{
+				Base:  client.Transport,
+				Rules: []calsynctest.Rule{{Fault: calsynctest.RateLimit, Match: calsynctest.Method("PATCH")}},
+			}
+			return nil
+		}, "rate limits", StatusWarning},
+		{"unmatched event", func(s *calsynctest.Server, client *http.Client) []Opt {
+			start := time.Now().Add(time.Hour)
+			_, err := s.Put("primary", &calendar.Event{
+				Summary: "copied by hand",
+				Start:   &calendar.EventDateTime{DateTime: start.Format(time.RFC3339)},
+				End:     &calendar.EventDateTime{DateTime: start.Add(time.Hour).Format(time.RFC3339)},
+				ExtendedProperties: &calendar.EventExtendedProperties{
+					Private: map[string]string{"scope": "True"},
+				},
+			})
+			ok(t, err)
+			return nil
+		}, "extended properties", StatusWarning},
+	}
+	for _, tt := range tests {
+		s := calsynctest.NewServer()
+		client := s.Client()
+		opts := tt.setup(s, client)
+		statuses := diagnoseStatuses(t, client, opts...)
+		if statuses[tt.check] != tt.status {
+			t.Errorf("%s: got %s %s, want %s", tt.name, tt.check, statuses[tt.check], tt.status)
+		}
+	}
+}
+
+func TestDiagnoseSkipsAfterFailure(t *testing.T) {
+	s := calsynctest.NewServer()
+	client := s.Client()
+	client.Transport = rejectTransport{client.Transport, "", http.StatusUnauthorized, "authError"}
+
+	statuses := diagnoseStatuses(t, client)
+	equals(t, StatusFailed, statuses["credentials"])
+	for _, name := range []string{"token scope", "calendar", "rate limits", "clock", "extended properties"} {
+		equals(t, StatusSkipped, statuses[name])
+	}
+}
+
+func TestDiagnoseScope(t *testing.T) {
+	s := calsynctest.NewServer()
+	d, err := Diagnose(context.Background(), s.Client(), "a scope that is far too long to use")
+	ok(t, err)
+	assert(t, !d.OK(), "expected a failure, got\n%s", d)
+	equals(t, "scope", d.Checks[0].Name)
+	equals(t, StatusFailed, d.Checks[0].Status)
+}
+
+func TestDiagnoseWithService(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	_, err := Diagnose(ctx, nil, "scope", WithService(nil))
+	assert(t, err != nil, "expected an error for a nil service")
+
+	svc, err := calendar.New(s.Client())
+	ok(t, err)
+	d, err := Diagnose(ctx, nil, "scope", WithService(svc))
+	ok(t, err)
+	assert(t, d.OK(), "expected no problems, got\n%s", d)
+}
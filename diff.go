@@ -0,0 +1,95 @@
+package calsync
+
+import "strings"
+
+// PlanDiff describes how one plan differs from an earlier one.  A plan
+// is the Changes reported by Sync, typically run with Nop so that
+// nothing is actually modified.
+type PlanDiff struct {
+	// Appeared holds operations in the newer plan that were not in the
+	// older plan, or that would now write different content.
+	Appeared *Changes
+
+	// Disappeared holds operations in the older plan that are no longer
+	// planned.
+	Disappeared *Changes
+}
+
+// DiffChanges compares two plans, for example yesterday's dry run
+// against today's, so that a reviewer only needs to look at the
+// operations that are new rather than re-reading every operation that
+// was already planned.  Either argument may be nil.
+func DiffChanges(older, newer *Changes) *PlanDiff {
+	if older == nil {
+		older = &Changes{}
+	}
+	if newer == nil {
+		newer = &Changes{}
+	}
+	return &PlanDiff{
+		Appeared: &Changes{
+			Deletes: missingOps(newer.Deletes, older.Deletes, false),
+			Updates: missingOps(newer.Updates, older.Updates, true),
+			Adds:    missingOps(newer.Adds, older.Adds, true),
+
+			Conflicts: missingOps(newer.Conflicts, older.Conflicts, false),
+			Orphans:   missingOps(newer.Orphans, older.Orphans, false),
+		},
+		Disappeared: &Changes{
+			Deletes: missingOps(older.Deletes, newer.Deletes, false),
+			Updates: missingOps(older.Updates, newer.Updates, true),
+			Adds:    missingOps(older.Adds, newer.Adds, true),
+
+			Conflicts: missingOps(older.Conflicts, newer.Conflicts, false),
+			Orphans:   missingOps(older.Orphans, newer.Orphans, false),
+		},
+	}
+}
+
+// missingOps returns the events in ops that have no counterpart with
+// the same SrcID in other.  If compareContent is set, the counterpart
+// must also have the same content.  Deletes, conflicts and orphans
+// don't need that, as we won't be writing their content either way.
+func missingOps(ops, other []*Event, compareContent bool) []*Event {
+	bySrcID := map[string]*Event{}
+	for _, ev := range other {
+		bySrcID[ev.SrcID] = ev
+	}
+	var missing []*Event
+	for _, ev := range ops {
+		if o, ok := bySrcID[ev.SrcID]; ok && (!compareContent || ev.equal(o)) {
+			continue
+		}
+		missing = append(missing, ev)
+	}
+	return missing
+}
+
+// Empty reports whether the two plans contained the same operations.
+func (d *PlanDiff) Empty() bool {
+	return d.Appeared.empty() && d.Disappeared.empty()
+}
+
+func (d *PlanDiff) String() string {
+	var lines []string
+	if s := d.Appeared.String(); s != "" {
+		lines = append(lines, prefixLines("+ ", s))
+	}
+	if s := d.Disappeared.String(); s != "" {
+		lines = append(lines, prefixLines("- ", s))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (c *Changes) empty() bool {
+	return len(c.Deletes) == 0 && len(c.Updates) == 0 && len(c.Adds) == 0 &&
+		len(c.Conflicts) == 0 && len(c.Adopted) == 0 && len(c.Orphans) == 0
+}
+
+func prefixLines(prefix, s string) string {
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		lines[i] = prefix + l
+	}
+	return strings.Join(lines, "\n")
+}
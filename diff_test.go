@@ -0,0 +1,55 @@
+package calsync
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDiffChanges(t *testing.T) {
+	now := when("2017-04-29T20:00:00-07:00")
+
+	sameAdd := newSrcEvent("sameAdd", now.Add(time.Hour))
+	changedAdd := newSrcEvent("changedAdd", now.AddDate(0, 0, 1))
+	changedAddLater := *changedAdd
+	changedAddLater.Where = "somewhere else"
+	newDelete := newSrcEvent("newDelete", now.AddDate(0, 0, 2))
+	goneUpdate := newSrcEvent("goneUpdate", now.AddDate(0, 0, 3))
+
+	older := &Changes{
+		Updates: []*Event{goneUpdate},
+		Adds:    []*Event{sameAdd, changedAdd},
+	}
+	newer := &Changes{
+		Deletes: []*Event{newDelete},
+		Adds:    []*Event{sameAdd, &changedAddLater},
+	}
+
+	d := DiffChanges(older, newer)
+	assert(t, !d.Empty(), "expected a non-empty diff")
+
+	equals(t, []*Event{newDelete}, d.Appeared.Deletes)
+	equals(t, 0, len(d.Appeared.Updates))
+	equals(t, []*Event{&changedAddLater}, d.Appeared.Adds)
+
+	equals(t, 0, len(d.Disappeared.Deletes))
+	equals(t, []*Event{goneUpdate}, d.Disappeared.Updates)
+	equals(t, []*Event{changedAdd}, d.Disappeared.Adds)
+}
+
+func TestDiffChangesSame(t *testing.T) {
+	now := when("2017-04-29T20:00:00-07:00")
+	ev := newSrcEvent("ev", now.Add(time.Hour))
+	plan := &Changes{Adds: []*Event{ev}}
+
+	d := DiffChanges(plan, plan)
+	assert(t, d.Empty(), "expected an empty diff, got %s", d)
+	equals(t, "", d.String())
+}
+
+func TestDiffChangesNil(t *testing.T) {
+	now := when("2017-04-29T20:00:00-07:00")
+	ev := newSrcEvent("ev", now.Add(time.Hour))
+
+	d := DiffChanges(nil, &Changes{Deletes: []*Event{ev}})
+	equals(t, "+ Delete 2017/04/29: ev title", d.String())
+}
@@ -0,0 +1,112 @@
+package calsync
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	calendar "google.golang.org/api/calendar/v3"
+
+	"golang.org/x/net/context"
+)
+
+// newCalendar describes the calendar EnsureCalendar looks for, and
+// creates if it is missing.
+type newCalendar struct {
+	summary  string
+	timeZone string
+	colorID  string
+}
+
+// calendarsNamed returns the ids of the calendars in the user's
+// calendar list that are named name, either by their owner or by the
+// user, and that the user may write to.
+func (c cal) calendarsNamed(ctx context.Context, name string) ([]string, error) {
+	var ids []string
+	err := c.svc.CalendarList.List().
+		MinAccessRole("writer").
+		Pages(ctx, func(page *calendar.CalendarList) error {
+			for _, entry := range page.Items {
+				if entry.Summary == name || entry.SummaryOverride == name {
+					ids = append(ids, entry.Id)
+				}
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list calendars: %v", err)
+	}
+	return ids, nil
+}
+
+// findCalendar points c at the calendar named c.calName, if it is set.
+// See CalendarName.
+func (c *cal) findCalendar(ctx context.Context) error {
+	if c.calName == "" {
+		return nil
+	}
+	ids, err := c.calendarsNamed(ctx, c.calName)
+	if err != nil {
+		return err
+	}
+	switch len(ids) {
+	case 0:
+		return fmt.Errorf("there is no calendar named %q that you can write to", c.calName)
+	case 1:
+		c.calID = ids[0]
+		return nil
+	}
+	return fmt.Errorf("there are %d calendars named %q (%s); rename one, or use CalendarID to pick one",
+		len(ids), c.calName, strings.Join(ids, ", "))
+}
+
+// ensureCalendar points c at the calendar named by c.ensure, creating
+// it if there isn't one.  With Nop, nothing is created, and c.missing
+// is set instead, so that c plans against an empty calendar.
+func (c *cal) ensureCalendar(ctx context.Context) error {
+	if c.ensure == nil {
+		return nil
+	}
+	ids, err := c.calendarsNamed(ctx, c.ensure.summary)
+	if err != nil {
+		return err
+	}
+	switch {
+	case len(ids) == 1:
+		c.calID = ids[0]
+		return nil
+	case len(ids) > 1:
+		return fmt.Errorf("there are %d calendars named %q; use CalendarID to pick one", len(ids), c.ensure.summary)
+	case c.nop:
+		c.missing = true
+		return nil
+	}
+
+	created, err := c.svc.Calendars.Insert(&calendar.Calendar{
+		Summary:  c.ensure.summary,
+		TimeZone: c.ensure.timeZone,
+	}).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("unable to create calendar %q: %v", c.ensure.summary, err)
+	}
+	c.calID = created.Id
+	if c.ensure.colorID != "" {
+		_, err = c.svc.CalendarList.Patch(created.Id, &calendar.CalendarListEntry{
+			ColorId: c.ensure.colorID,
+		}).Context(ctx).Do()
+		if err != nil {
+			return fmt.Errorf("unable to set the color of calendar %q: %v", c.ensure.summary, err)
+		}
+	}
+	return nil
+}
+
+// missingLocation returns the timezone a calendar that EnsureCalendar
+// would create will have.
+func (c cal) missingLocation() *time.Location {
+	loc, err := time.LoadLocation(c.ensure.timeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
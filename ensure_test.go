@@ -0,0 +1,72 @@
+package calsync
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+func TestEnsureCalendar(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	src := []*Event{newSrcEvent("class", time.Now().Add(time.Hour).Truncate(time.Second))}
+	ensure := EnsureCalendar("Classes", "America/Los_Angeles", "7")
+
+	// A dry run creates nothing, and plans as if the calendar were empty.
+	changes, err := Sync(ctx, s.Client(), "scope", src, ensure, Nop())
+	ok(t, err)
+	equals(t, 1, len(changes.Adds))
+	equals(t, 1, len(s.Calendars()))
+
+	changes, err = Sync(ctx, s.Client(), "scope", src, ensure)
+	ok(t, err)
+	equals(t, 1, len(changes.Adds))
+	calendars := s.Calendars()
+	equals(t, 2, len(calendars))
+	created := calendars[0]
+	equals(t, "Classes", created.Summary)
+	equals(t, "America/Los_Angeles", created.TimeZone)
+	equals(t, "7", created.ColorId)
+	equals(t, 1, len(s.Events(created.Id)))
+	equals(t, 0, len(s.Events("primary")))
+
+	// The second time, the calendar is found by name.
+	changes, err = Sync(ctx, s.Client(), "scope", src, ensure)
+	ok(t, err)
+	assert(t, changes.empty(), "expected no changes, got %s", changes)
+	equals(t, 2, len(s.Calendars()))
+
+	settings, err := Settings(ctx, s.Client(), EnsureCalendar("Elsewhere", "Asia/Kathmandu", ""))
+	ok(t, err)
+	equals(t, "Asia/Kathmandu", settings.Location.String())
+	equals(t, 2, len(s.Calendars()))
+
+	s.AddCalendar("other", "Classes", "UTC")
+	_, err = Sync(ctx, s.Client(), "scope", src, ensure)
+	assert(t, err != nil, "expected an error for an ambiguous name")
+}
+
+func TestCalendarName(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	s.AddCalendar("team@group.calendar.google.com", "Team", "UTC")
+	s.AddCalendar("shared@group.calendar.google.com", "Shared", "UTC")
+	src := []*Event{newSrcEvent("standup", time.Now().Add(time.Hour).Truncate(time.Second))}
+
+	_, err := Sync(ctx, s.Client(), "scope", src, CalendarName("Team"))
+	ok(t, err)
+	equals(t, 1, len(s.Events("team@group.calendar.google.com")))
+	equals(t, 0, len(s.Events("primary")))
+
+	_, err = Sync(ctx, s.Client(), "scope", src, CalendarName("Missing"))
+	assert(t, err != nil, "expected an error for a missing calendar")
+
+	s.AddCalendar("shared@group.calendar.google.com", "Team", "UTC")
+	_, err = Sync(ctx, s.Client(), "scope", src, CalendarName("Team"))
+	assert(t, err != nil && strings.Contains(err.Error(), "shared@group.calendar.google.com"),
+		"expected an error naming both calendars, got %v", err)
+}
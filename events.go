@@ -15,6 +15,11 @@ const delim = "===================="
 type description struct {
 	prefix string
 	suffix string
+
+	// text after a second, closing, delimiter, which users may add
+	// below the synced text.  See TrailingComments.
+	trailer string
+	closed  bool
 }
 
 func parseDescription(s string) *description {
@@ -34,6 +39,12 @@ func parseDescription(s string) *description {
 		if l != 0 && d.suffix[0] == '\n' {
 			d.suffix = d.suffix[1:]
 		}
+		tokens = strings.SplitN(d.suffix, delim, 2)
+		if len(tokens) == 2 {
+			d.suffix = strings.TrimSuffix(tokens[0], "\n")
+			d.trailer = strings.TrimPrefix(tokens[1], "\n")
+			d.closed = true
+		}
 		return d
 	}
 	d.suffix = s
@@ -41,10 +52,14 @@ func parseDescription(s string) *description {
 }
 
 func (d *description) String() string {
-	if d.prefix == "" {
-		return delim + "\n" + d.suffix
+	s := delim + "\n" + d.suffix
+	if d.prefix != "" {
+		s = d.prefix + "\n" + s
+	}
+	if d.closed || d.trailer != "" {
+		s += "\n" + delim + "\n" + d.trailer
 	}
-	return d.prefix + "\n" + delim + "\n" + d.suffix
+	return s
 }
 
 // Event represents a single synchronizable event.
@@ -60,38 +75,176 @@ type Event struct {
 	// sync it again later.  It should be unique across all events that you
 	// sync into a single calendar.
 	SrcID string `json:"src_id"`
+	// AllDay events are written as dates rather than times.  Start is
+	// the first day and End is the day after the last day, each taken
+	// from the date part of the time, in its own location.
+	AllDay bool `json:"all_day,omitempty"`
+	// Attendees are invited to the event by google calendar, unless
+	// PrivateCopies is used.
+	Attendees []Attendee `json:"attendees,omitempty"`
+	// DescriptionHTML is set if Description is HTML rather than plain
+	// text.  It is written to google calendar as is, and compared with
+	// calendar events as plain text.  Events read from google calendar
+	// always have plain text descriptions, converted from HTML if a
+	// user edited them in the web UI.
+	DescriptionHTML bool `json:"description_html,omitempty"`
+	// Metadata is written to the synced part of the description in
+	// google calendar, as a block of "key: value" lines, where both
+	// calendar users and Fetch can read it.  Keys must not contain ':'
+	// or newlines, and values must not contain newlines.  See Validate.
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// PrivateProps are written to the private extended properties of
+	// the google calendar event, along with the ones Sync uses to track
+	// it, which take precedence.  They can't be seen in google
+	// calendar, but other programs can read them back, for example to
+	// find events by their own identifiers.  A change to them updates
+	// the event, replacing any other properties but those of other
+	// scopes, but they aren't checked for edits made in google calendar.
+	PrivateProps map[string]string `json:"private_props,omitempty"`
+	// SourceURL and SourceTitle link the event to where it came from,
+	// such as a ticket or a booking.  Google calendar shows the link
+	// with the event, titled SourceTitle.  SourceURL must be http or
+	// https, and SourceTitle is only written along with it.
+	SourceURL   string `json:"source_url,omitempty"`
+	SourceTitle string `json:"source_title,omitempty"`
+	// Status is whether the event is confirmed, tentative or cancelled.
+	// Empty means confirmed.  A source can cancel an event by setting
+	// EventCancelled rather than leaving it out, so that attendees are
+	// sent a cancellation.  Events read from google calendar only have
+	// it set if it isn't confirmed.
+	Status EventStatus `json:"status,omitempty"`
+	// GuestsCanModify, GuestsCanInviteOthers and
+	// GuestsCanSeeOtherGuests set what invitees may do with the event in
+	// google calendar.  When nil, google calendar's defaults apply:
+	// guests can invite others and see the other guests, but can't
+	// modify the event.  Events read from google calendar only have
+	// those set that differ from the defaults.
+	GuestsCanModify         *bool `json:"guests_can_modify,omitempty"`
+	GuestsCanInviteOthers   *bool `json:"guests_can_invite_others,omitempty"`
+	GuestsCanSeeOtherGuests *bool `json:"guests_can_see_other_guests,omitempty"`
+	// UserNote is only set for events read from google calendar, such as
+	// those returned by Fetch.  It is the text calendar users added
+	// before the delimiter in the description, which is otherwise left
+	// alone by syncing.  It is ignored when syncing source events.
+	UserNote string `json:"user_note,omitempty"`
 
 	// only set for events we read from google calendar.  The id assigned by
 	// google calendar.
 	calEventID string
+
+	// only set for events we read from google calendar.  The contentHash
+	// of the event as we last wrote it.
+	syncedHash string
+
+	// only set with RouteTo.  The calendar the event is in, or is to be
+	// added to.
+	calID string
+
+	// only set for events we read from google calendar, and updates to
+	// them.  The event as google calendar returned it.
+	raw *calendar.Event
+
+	// only set for updates that resolve a Conflict with something other
+	// than the source.  The source event, whose contentHash is recorded
+	// as synced in place of this event's.
+	syncedSource *Event
+
+	// only set for updates.  The calendar event the update replaces.
+	previous *Event
+
+	// only set for deletes and updates in plans read by ReadPlan.  The
+	// etag of the calendar event when the plan was made.
+	etag string
 }
 
 func (ev *Event) String() string {
 	return fmt.Sprintf("%s: %s", ev.Start.Format("2006/01/02"), ev.Title)
 }
 
-// Has the effect of prepending our delimiter when it is missing.
-func (ev *Event) exportedDescription() string {
+// Has the effect of prepending our delimiter when it is missing, and
+// of appending a closing delimiter if closed is set.  With
+// DescriptionHTML, the prefix and trailer, which come from google
+// calendar as plain text, are converted to HTML.
+func (ev *Event) exportedDescription(closed bool) string {
 	d := parseDescription(ev.Description)
-	return d.String()
+	d.suffix = withMetadata(d.suffix, ev.Metadata, ev.DescriptionHTML)
+	d.closed = d.closed || closed
+	if !ev.DescriptionHTML {
+		return d.String()
+	}
+	s := delim + "<br>" + d.suffix
+	if d.prefix != "" {
+		s = textToHTML(d.prefix) + "<br>" + s
+	}
+	if d.closed || d.trailer != "" {
+		s += "<br>" + delim + "<br>" + textToHTML(d.trailer)
+	}
+	return s
+}
+
+// SyncedDescription returns the part of ev's description that is
+// synced, as plain text: what follows the delimiter, without any
+// metadata block, and without the notes calendar users added before
+// it or after a closing delimiter.  It is what can be shown of an
+// event that was read from google calendar to people other than the
+// calendar's users.
+func (ev *Event) SyncedDescription() string {
+	return ev.syncedDescription()
+}
+
+// syncedDescription returns the part of ev's description that is
+// synced, after the delimiter, as plain text.
+func (ev *Event) syncedDescription() string {
+	suffix := parseDescription(ev.Description).suffix
+	if ev.DescriptionHTML {
+		return htmlToText(suffix)
+	}
+	return suffix
 }
 
 func (ev *Event) equal(other *Event) bool {
 	if ev.Title != other.Title {
 		return false
 	}
-	if !ev.Start.Equal(other.Start) {
+	if ev.AllDay != other.AllDay {
 		return false
 	}
-	if !ev.End.Equal(other.End) {
-		return false
+	if ev.AllDay {
+		if !sameDate(ev.Start, other.Start) || !sameDate(ev.End, other.End) {
+			return false
+		}
+	} else {
+		if !ev.Start.Equal(other.Start) {
+			return false
+		}
+		if !ev.End.Equal(other.End) {
+			return false
+		}
 	}
 	if ev.Where != other.Where {
 		return false
 	}
-	d := parseDescription(ev.Description)
-	otherD := parseDescription(other.Description)
-	if d.suffix != otherD.suffix {
+	if !sameAttendees(ev.Attendees, other.Attendees) {
+		return false
+	}
+	if !sameMetadata(ev.Metadata, other.Metadata) {
+		return false
+	}
+	if !sameProps(ev.PrivateProps, other.PrivateProps) {
+		return false
+	}
+	if ev.guestPermissions() != other.guestPermissions() {
+		return false
+	}
+	if ev.status() != other.status() {
+		return false
+	}
+	link, title := ev.source()
+	otherLink, otherTitle := other.source()
+	if link != otherLink || title != otherTitle {
+		return false
+	}
+	if ev.syncedDescription() != other.syncedDescription() {
 		return false
 	}
 	if ev.SrcID != other.SrcID {
@@ -104,44 +257,93 @@ func (ev *Event) equal(other *Event) bool {
 func (ev *Event) newUpdate(srcEv *Event) *Event {
 	update := *srcEv
 	update.calEventID = ev.calEventID
+	update.calID = ev.calID
+	update.raw = ev.raw
+	update.previous = ev
 	calDescription := parseDescription(ev.Description)
 	updateDescription := description{
-		prefix: calDescription.prefix,
-		suffix: srcEv.Description,
+		prefix:  calDescription.prefix,
+		trailer: calDescription.trailer,
+		closed:  calDescription.closed,
+		// srcEv may itself be an update we computed earlier, in which
+		// case its description already carries a delimiter.
+		suffix: parseDescription(srcEv.Description).suffix,
 	}
 	update.Description = updateDescription.String()
 	return &update
 }
 
-func parseEvent(in *calendar.Event, idKey string) (*Event, error) {
+func (c cal) parseEvent(in *calendar.Event) (*Event, error) {
 	title := in.Summary
-	start, err := time.Parse(time.RFC3339, in.Start.DateTime)
+	start, allDay, err := c.parseEventTime(in.Start)
 	if err != nil {
-		return nil, fmt.Errorf("unable to parse start %q: %v",
-			in.Start.DateTime, err)
+		return nil, fmt.Errorf("unable to parse start: %v", err)
 	}
-	end, err := time.Parse(time.RFC3339, in.End.DateTime)
+	end, _, err := c.parseEventTime(in.End)
 	if err != nil {
-		return nil, fmt.Errorf("Unable to parse end %q: %v",
-			in.End.DateTime, err)
+		return nil, fmt.Errorf("Unable to parse end: %v", err)
 	}
 	where := in.Location
 	description := in.Description
+	if looksLikeHTML(description) {
+		description = htmlToText(description)
+	}
+	if c.layout != nil {
+		description = c.layout.canonical(description)
+	}
+	d := parseDescription(description)
+	var metadata map[string]string
+	if d.suffix, metadata = splitMetadata(d.suffix); metadata != nil {
+		description = d.String()
+	}
 
 	var props map[string]string
 	if in.ExtendedProperties != nil {
 		props = in.ExtendedProperties.Private
 	}
-	srcID := props[idKey]
+	srcID, err := c.readSrcID(props)
+	if err != nil {
+		return nil, err
+	}
+	if c.match == MatchICalUID {
+		_, srcID, _ = parseICalUID(in.ICalUID)
+	}
+	syncedHash, err := c.openEventProp(srcID, c.hashKey(), props[c.hashKey()])
+	if err != nil {
+		return nil, err
+	}
+	private, err := c.openUserProps(srcID, userProps(c.scope, props))
+	if err != nil {
+		return nil, err
+	}
+	var sourceURL, sourceTitle string
+	if in.Source != nil {
+		sourceURL, sourceTitle = in.Source.Url, in.Source.Title
+	}
 
 	return &Event{
-		title,
-		start,
-		end,
-		where,
-		description,
-		srcID,
-		in.Id,
+		Title:        title,
+		Start:        start,
+		End:          end,
+		Where:        where,
+		Description:  description,
+		Metadata:     metadata,
+		PrivateProps: private,
+		UserNote:     d.prefix,
+		SrcID:        srcID,
+		AllDay:       allDay,
+		Attendees:    parseAttendees(in.Attendees),
+		Status:       parseStatus(in.Status),
+		SourceURL:    sourceURL,
+		SourceTitle:  sourceTitle,
+
+		GuestsCanModify:         nonDefault(&in.GuestsCanModify, defaultGuestsCanModify),
+		GuestsCanInviteOthers:   nonDefault(in.GuestsCanInviteOthers, defaultGuestsCanInviteOthers),
+		GuestsCanSeeOtherGuests: nonDefault(in.GuestsCanSeeOtherGuests, defaultGuestsCanSeeOtherGuests),
+
+		calEventID: in.Id,
+		syncedHash: syncedHash,
+		raw:        in,
 	}, nil
 }
 
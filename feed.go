@@ -0,0 +1,103 @@
+package calsync
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+type feed struct {
+	client *http.Client
+	url    string
+	read   func(io.Reader) ([]*Event, error)
+
+	// The validators and events of the last response, so that a feed
+	// that hasn't changed isn't downloaded and parsed again.
+	mu           sync.Mutex
+	etag         string
+	lastModified string
+	events       []*Event
+}
+
+// ICSFeed returns a Source that subscribes to the iCalendar feed at
+// url, fetching it with client, or with http.DefaultClient if client is
+// nil, and reading it as ReadICS does.  A webcal url is fetched over
+// https.  Used with a Runner, it refreshes a subscription as often as
+// the Runner syncs, rather than as seldom as google calendar's own
+// subscriptions do, and into a scope that can be cleaned up.
+//
+// Each fetch is conditional on the ETag or Last-Modified of the
+// previous response, if the server sent them, so that a feed that
+// hasn't changed isn't downloaded again.
+func ICSFeed(client *http.Client, url string) Source {
+	if strings.HasPrefix(strings.ToLower(url), "webcal://") {
+		url = "https://" + url[len("webcal://"):]
+	}
+	return newFeed(client, url, ReadICS)
+}
+
+// JSONFeed returns a Source that fetches the JSON events at url with
+// client, or with http.DefaultClient if client is nil, and reads them
+// as ReadJSON does.  Fetches are conditional, as for ICSFeed.
+func JSONFeed(client *http.Client, url string) Source {
+	return newFeed(client, url, ReadJSON)
+}
+
+func newFeed(client *http.Client, url string, read func(io.Reader) ([]*Event, error)) *feed {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &feed{client: client, url: url, read: read}
+}
+
+func (f *feed) Events(ctx context.Context) ([]*Event, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	req, err := http.NewRequest("GET", f.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if f.events != nil {
+		if f.etag != "" {
+			req.Header.Set("If-None-Match", f.etag)
+		}
+		if f.lastModified != "" {
+			req.Header.Set("If-Modified-Since", f.lastModified)
+		}
+	}
+	resp, err := f.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch %s: %v", f.url, err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotModified && f.events != nil:
+		return copyEvents(f.events), nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("unable to fetch %s: %s", f.url, resp.Status)
+	}
+	events, err := f.read(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %v", f.url, err)
+	}
+	f.etag = resp.Header.Get("ETag")
+	f.lastModified = resp.Header.Get("Last-Modified")
+	// Kept even if empty, so that an empty feed is also revalidated.
+	f.events = append([]*Event{}, events...)
+	return copyEvents(events), nil
+}
+
+// copyEvents returns copies of events, so that callers may change
+// them without changing what the feed keeps.
+func copyEvents(events []*Event) []*Event {
+	out := make([]*Event, len(events))
+	for i, ev := range events {
+		cp := *ev
+		out[i] = &cp
+	}
+	return out
+}
@@ -0,0 +1,61 @@
+package calsync
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestICSFeed(t *testing.T) {
+	ctx := context.Background()
+	var ics bytes.Buffer
+	ok(t, WriteICS(&ics, []*Event{newSrcEvent("feed", when("2017-05-01T19:00:00-07:00"))}))
+	etag := `"v1"`
+	fetches := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/events.ics" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fetches++
+		w.Header().Set("ETag", etag)
+		w.Write(ics.Bytes())
+	}))
+	defer srv.Close()
+
+	feed := ICSFeed(nil, srv.URL+"/events.ics")
+	events, err := feed.Events(ctx)
+	ok(t, err)
+	equals(t, 1, len(events))
+	equals(t, "feed srcId", events[0].SrcID)
+	events[0].Title = "changed by the caller"
+
+	events, err = feed.Events(ctx)
+	ok(t, err)
+	equals(t, 1, fetches)
+	equals(t, 1, len(events))
+	equals(t, "feed title", events[0].Title)
+
+	etag = `"v2"`
+	_, err = feed.Events(ctx)
+	ok(t, err)
+	equals(t, 2, fetches)
+
+	_, err = ICSFeed(nil, srv.URL+"/missing.ics").Events(ctx)
+	assert(t, err != nil, "expected an error for a missing feed")
+}
+
+func TestICSFeedWebcal(t *testing.T) {
+	f := ICSFeed(nil, "webcal://example.com/events.ics").(*feed)
+	equals(t, "https://example.com/events.ics", f.url)
+	f = ICSFeed(nil, "https://example.com/events.ics").(*feed)
+	assert(t, !strings.HasPrefix(f.url, "webcal"), "expected the url to be kept, got %s", f.url)
+}
@@ -0,0 +1,27 @@
+package calsync
+
+import (
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// fetch returns the upcoming events of c's scope, trying as many times
+// as RetryFetch allows.
+func (c cal) fetch(ctx context.Context, now time.Time) ([]*Event, error) {
+	delay := c.fetchDelay
+	for attempt := 1; ; attempt++ {
+		events, err := c.fetchOnce(ctx, now)
+		if err == nil || attempt >= c.fetchAttempts || ctx.Err() != nil {
+			return events, err
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		case <-timer.C:
+		}
+		delay *= 2
+	}
+}
@@ -0,0 +1,56 @@
+package calsync
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// flakyBackend fails the first failures fetches.
+type flakyBackend struct {
+	*memBackend
+	failures, fetches int
+}
+
+func (b *flakyBackend) Fetch(ctx context.Context, scope string, now time.Time) ([]*Event, error) {
+	b.fetches++
+	if b.fetches <= b.failures {
+		return nil, errors.New("backend unavailable")
+	}
+	return b.memBackend.Fetch(ctx, scope, now)
+}
+
+func TestSyncFetchError(t *testing.T) {
+	ctx := context.Background()
+	now := when("2017-04-29T20:00:00-07:00")
+	b := &flakyBackend{memBackend: newMemBackend()}
+	opts := []Opt{WithBackend(b), WithNow(func() time.Time { return now })}
+	src := []*Event{newSrcEvent("a", now.Add(time.Hour))}
+
+	_, err := Sync(ctx, nil, "scope", src, opts...)
+	ok(t, err)
+
+	// A failed fetch fails the sync, rather than adding every event
+	// again.
+	b.failures, b.fetches = 1, 0
+	changes, err := Sync(ctx, nil, "scope", src, opts...)
+	assert(t, err != nil, "expected the fetch error")
+	assert(t, changes == nil, "expected no changes, got %s", changes)
+	equals(t, []string{"add a srcId"}, b.ops)
+
+	b.fetches = 0
+	changes, err = Sync(ctx, nil, "scope", src, append(opts, RetryFetch(3, time.Millisecond))...)
+	ok(t, err)
+	assert(t, changes.empty(), "expected no changes, got %s", changes)
+	equals(t, 2, b.fetches)
+
+	b.failures, b.fetches = 5, 0
+	_, err = Sync(ctx, nil, "scope", src, append(opts, RetryFetch(3, time.Millisecond))...)
+	assert(t, err != nil, "expected the fetch error after 3 attempts")
+	equals(t, 3, b.fetches)
+
+	_, err = Sync(ctx, nil, "scope", src, append(opts, RetryFetch(0, time.Millisecond))...)
+	assert(t, err != nil, "expected an error for no attempts")
+}
@@ -0,0 +1,64 @@
+package calsync
+
+import (
+	"strings"
+
+	calendar "google.golang.org/api/calendar/v3"
+	"google.golang.org/api/googleapi"
+)
+
+// readFields are the google calendar event fields that calsync reads,
+// which are always fetched.
+var readFields = []string{
+	"id", "etag", "status", "iCalUID", "sequence", "updated",
+	"summary", "location", "description", "start", "end", "attendees",
+	"extendedProperties", "source",
+	"guestsCanModify", "guestsCanInviteOthers", "guestsCanSeeOtherGuests",
+}
+
+// DefaultFetchFields are the google calendar event fields that are
+// fetched, beyond those calsync reads, unless FetchFields says
+// otherwise.  They are those that updates can leave in place, so that
+// Rollback can restore them.
+var DefaultFetchFields = []string{
+	"reminders", "colorId", "transparency", "visibility", "recurrence",
+	"recurringEventId", "originalStartTime", "attachments", "conferenceData",
+}
+
+// fetchFields returns the partial response field mask for listing
+// events, or "" to fetch every field.
+func (c cal) fetchFields() googleapi.Field {
+	extra := DefaultFetchFields
+	if c.customFields {
+		extra = c.extraFields
+	}
+	var fields []string
+	seen := map[string]bool{}
+	add := func(f string) {
+		if !seen[f] {
+			seen[f] = true
+			fields = append(fields, f)
+		}
+	}
+	for _, f := range readFields {
+		add(f)
+	}
+	for _, f := range extra {
+		if f == "*" {
+			return ""
+		}
+		add(f)
+	}
+	for _, f := range c.preserve {
+		add(string(f))
+	}
+	return googleapi.Field("nextPageToken,nextSyncToken,items(" + strings.Join(fields, ",") + ")")
+}
+
+// withFields limits call to the fields c fetches.
+func (c cal) withFields(call *calendar.EventsListCall) *calendar.EventsListCall {
+	if fields := c.fetchFields(); fields != "" {
+		return call.Fields(fields)
+	}
+	return call
+}
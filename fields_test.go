@@ -0,0 +1,52 @@
+package calsync
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+// listFields syncs src with opts, returning the fields parameter of the
+// event listing.
+func listFields(t *testing.T, src []*Event, opts ...Opt) string {
+	s := calsynctest.NewServer()
+	rec := &calsynctest.Recorder{Base: s.Client().Transport}
+	_, err := Sync(context.Background(), &http.Client{Transport: rec}, "scope", src, opts...)
+	ok(t, err)
+	for _, in := range rec.Fixture().Interactions {
+		u, err := url.Parse(in.URL)
+		ok(t, err)
+		if in.Method == "GET" && strings.HasSuffix(u.Path, "/events") {
+			return u.Query().Get("fields")
+		}
+	}
+	t.Fatal("no event listing")
+	return ""
+}
+
+func TestFetchFields(t *testing.T) {
+	src := []*Event{newSrcEvent("a", time.Now().Add(time.Hour).Truncate(time.Second))}
+
+	fields := listFields(t, src)
+	assert(t, strings.HasPrefix(fields, "nextPageToken,nextSyncToken,items(id,etag,"), "got fields %q", fields)
+	assert(t, strings.Contains(fields, ",extendedProperties,"), "got fields %q", fields)
+	assert(t, strings.Contains(fields, ",reminders,"), "got fields %q", fields)
+
+	fields = listFields(t, src, FetchFields())
+	assert(t, !strings.Contains(fields, "reminders"), "got fields %q", fields)
+	assert(t, !strings.Contains(fields, "colorId"), "got fields %q", fields)
+
+	fields = listFields(t, src, FetchFields("locked"), Preserve(FieldColor))
+	assert(t, strings.HasSuffix(fields, ",locked,colorId)"), "got fields %q", fields)
+
+	equals(t, "", listFields(t, src, FetchFields("*")))
+
+	_, err := Sync(context.Background(), calsynctest.NewServer().Client(), "scope", src, FetchFields(""))
+	assert(t, err != nil, "expected an error for an empty field")
+}
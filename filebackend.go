@@ -0,0 +1,141 @@
+package calsync
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+type fileBackend struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileBackend returns a Backend that keeps events in the JSON file
+// at path, which is created if needed, so that syncs can be run
+// without any network access, for demos or golden tests:
+//
+//	changes, err := calsync.Sync(ctx, nil, "myscope", events,
+//		calsync.WithBackend(calsync.NewFileBackend("events.json")))
+//
+// The file holds an object with a list of events for each scope,
+// ordered by start time, and indented so that it diffs well.  It is
+// read again for each operation, so it can be edited between syncs,
+// and replaced atomically after each change.
+//
+// It is also the reference implementation of Backend.
+func NewFileBackend(path string) Backend {
+	return &fileBackend{path: path}
+}
+
+func (b *fileBackend) Name() string { return "json file" }
+
+func (b *fileBackend) Fetch(ctx context.Context, scope string, now time.Time) ([]*Event, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	scopes, err := b.read()
+	if err != nil {
+		return nil, err
+	}
+	var events []*Event
+	for _, ev := range scopes[scope] {
+		if ev.End.After(now) {
+			events = append(events, ev)
+		}
+	}
+	return events, nil
+}
+
+func (b *fileBackend) Add(ctx context.Context, scope string, ev *Event) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	scopes, err := b.read()
+	if err != nil {
+		return "", err
+	}
+	if indexBySrcID(scopes[scope], ev.SrcID) != -1 {
+		return "", fmt.Errorf("adding %q: %s already has an event with SrcID %q", ev.Title, b.path, ev.SrcID)
+	}
+	scopes[scope] = append(scopes[scope], ev)
+	// The SrcID is unique within the scope, so it serves as the id.
+	return ev.SrcID, b.write(scopes)
+}
+
+func (b *fileBackend) Update(ctx context.Context, scope string, ev *Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	scopes, err := b.read()
+	if err != nil {
+		return err
+	}
+	i := indexBySrcID(scopes[scope], ev.SrcID)
+	if i == -1 {
+		return fmt.Errorf("updating %q: %s has no event with SrcID %q", ev.Title, b.path, ev.SrcID)
+	}
+	scopes[scope][i] = ev
+	return b.write(scopes)
+}
+
+func (b *fileBackend) Delete(ctx context.Context, scope string, ev *Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	scopes, err := b.read()
+	if err != nil {
+		return err
+	}
+	i := indexBySrcID(scopes[scope], ev.SrcID)
+	if i == -1 {
+		return nil
+	}
+	events := scopes[scope]
+	scopes[scope] = append(events[:i], events[i+1:]...)
+	return b.write(scopes)
+}
+
+// read returns the events of each scope kept in the file, or none if
+// there is no file yet.
+func (b *fileBackend) read() (map[string][]*Event, error) {
+	scopes := map[string][]*Event{}
+	data, err := ioutil.ReadFile(b.path)
+	if os.IsNotExist(err) {
+		return scopes, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, &scopes); err != nil {
+		return nil, fmt.Errorf("reading %s: %v", b.path, err)
+	}
+	return scopes, nil
+}
+
+func (b *fileBackend) write(scopes map[string][]*Event) error {
+	for scope, events := range scopes {
+		if len(events) == 0 {
+			delete(scopes, scope)
+			continue
+		}
+		sort.Sort(byStart(events))
+	}
+	data, err := json.MarshalIndent(scopes, "", "  ")
+	if err != nil {
+		return err
+	}
+	return replaceFile(b.path, append(data, '\n'))
+}
+
+// indexBySrcID returns the index of the event with srcID in events, or -1.
+func indexBySrcID(events []*Event, srcID string) int {
+	for i, ev := range events {
+		if ev.SrcID == srcID {
+			return i
+		}
+	}
+	return -1
+}
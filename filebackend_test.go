@@ -0,0 +1,83 @@
+package calsync
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestFileBackend(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "calsync")
+	ok(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "events.json")
+
+	now := when("2017-04-29T20:00:00-07:00")
+	past := newSrcEvent("past", now.Add(-2*time.Hour))
+	kept := newSrcEvent("kept", now.Add(time.Hour))
+	changed := newSrcEvent("changed", now.Add(2*time.Hour))
+	opts := []Opt{WithBackend(NewFileBackend(path)), WithNow(func() time.Time { return now })}
+
+	changes, err := Sync(ctx, nil, "scope", []*Event{changed, kept}, opts...)
+	ok(t, err)
+	equals(t, 2, len(changes.Adds))
+	earlier := func() time.Time { return now.Add(-3 * time.Hour) }
+	_, err = Sync(ctx, nil, "other", []*Event{past}, opts[0], WithNow(earlier))
+	ok(t, err)
+
+	data, err := ioutil.ReadFile(path)
+	ok(t, err)
+	s := string(data)
+	assert(t, strings.Index(s, `"kept srcId"`) < strings.Index(s, `"changed srcId"`),
+		"expected events ordered by start, got %s", s)
+	assert(t, strings.Contains(s, `"other": [`), "expected the other scope, got %s", s)
+
+	// A new backend on the same file sees the same events.
+	opts[0] = WithBackend(NewFileBackend(path))
+	changedSrc := *changed
+	changedSrc.Where = "elsewhere"
+	changes, err = Sync(ctx, nil, "scope", []*Event{&changedSrc}, opts...)
+	ok(t, err)
+	equals(t, 1, len(changes.Updates))
+	equals(t, 1, len(changes.Deletes))
+
+	events, err := Fetch(ctx, nil, "scope", opts...)
+	ok(t, err)
+	equals(t, 1, len(events))
+	equals(t, "elsewhere", events[0].Where)
+
+	// Past events are kept, but not fetched.
+	events, err = Fetch(ctx, nil, "other", opts...)
+	ok(t, err)
+	equals(t, 0, len(events))
+	data, err = ioutil.ReadFile(path)
+	ok(t, err)
+	assert(t, strings.Contains(string(data), `"past srcId"`), "expected the past event to be kept")
+}
+
+func TestFileBackendErrors(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "calsync")
+	ok(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "events.json")
+	b := NewFileBackend(path)
+	ev := newSrcEvent("a", when("2017-04-29T20:00:00-07:00"))
+
+	ok(t, b.Delete(ctx, "scope", ev))
+	assert(t, b.Update(ctx, "scope", ev) != nil, "expected an error updating a missing event")
+	_, err = b.Add(ctx, "scope", ev)
+	ok(t, err)
+	_, err = b.Add(ctx, "scope", ev)
+	assert(t, err != nil, "expected an error adding an event twice")
+
+	ok(t, ioutil.WriteFile(path, []byte("not json"), 0600))
+	_, err = b.Fetch(ctx, "scope", time.Time{})
+	assert(t, err != nil, "expected an error reading a corrupt file")
+}
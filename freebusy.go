@@ -0,0 +1,196 @@
+package calsync
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	calendar "google.golang.org/api/calendar/v3"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	// freeBusyWindow is the longest range that one free/busy query asks
+	// about, well within what google calendar allows.
+	freeBusyWindow = 30 * 24 * time.Hour
+
+	// maxFreeBusyItems is how many calendars google calendar answers
+	// for in one free/busy query.
+	maxFreeBusyItems = 50
+)
+
+// Warning is about an operation of a plan that was made all the same,
+// but that someone may want to know about.  See CheckFreeBusy.
+type Warning struct {
+	// Event is the event of the operation.
+	Event *Event
+
+	// Calendar is the id of the calendar, or the email address of the
+	// attendee, that the warning is about.
+	Calendar string
+
+	Message string
+}
+
+func (w *Warning) String() string {
+	return fmt.Sprintf("%s: %s", w.Event, w.Message)
+}
+
+// CheckFreeBusy makes Sync, SyncAll, SyncChunked and Apply ask google
+// calendar, before adding events, whether the calendar each is added
+// to, or any of its attendees, is already busy then with events that
+// the scope didn't sync, and warn about those that are in
+// Changes.Warnings, so that an import that double books someone
+// doesn't go unnoticed.  The events are added all the same, but Confirm
+// sees the warnings first, and may refuse the plan.  Attendees whose
+// calendars can't be seen aren't checked.
+func CheckFreeBusy() Opt {
+	return func(c *cal) {
+		c.freeBusy = true
+	}
+}
+
+type period struct {
+	start, end time.Time
+}
+
+func (p period) overlaps(q period) bool {
+	return p.start.Before(q.end) && q.start.Before(p.end)
+}
+
+// warnBusy adds to changes.Warnings a warning for each add, and each
+// calendar or attendee it would make busy, that is busy then with
+// events other than calEvents, the events of the scope.  See
+// CheckFreeBusy.
+func (c cal) warnBusy(ctx context.Context, calEvents []*Event, changes *Changes) error {
+	if !c.freeBusy || len(changes.Adds) == 0 {
+		return nil
+	}
+	adds := append([]*Event{}, changes.Adds...)
+	sort.Sort(byStart(adds))
+
+	// Each add is busy for its calendar and its attendees, and so is
+	// each event of the scope, whose time isn't someone else's.
+	var ids []string
+	seen := map[string]bool{}
+	for _, ev := range adds {
+		for _, id := range c.busyFor(ev) {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	own := map[string][]period{}
+	for _, ev := range calEvents {
+		for _, id := range c.busyFor(ev) {
+			own[id] = append(own[id], period{ev.Start, ev.End})
+		}
+	}
+
+	busy := map[string][]period{}
+	for len(adds) != 0 {
+		n, min, max := 1, adds[0].Start, adds[0].End
+		for ; n < len(adds) && adds[n].End.Sub(min) <= freeBusyWindow; n++ {
+			if adds[n].End.After(max) {
+				max = adds[n].End
+			}
+		}
+		for i := 0; i < len(ids); i += maxFreeBusyItems {
+			batch := ids[i:]
+			if len(batch) > maxFreeBusyItems {
+				batch = batch[:maxFreeBusyItems]
+			}
+			if err := c.queryBusy(ctx, min, max, batch, busy); err != nil {
+				return err
+			}
+		}
+		adds = adds[n:]
+	}
+
+	for _, ev := range changes.Adds {
+		p := period{ev.Start, ev.End}
+		for _, id := range c.busyFor(ev) {
+			for _, b := range subtract(busy[id], own[id]) {
+				if b.overlaps(p) {
+					changes.Warnings = append(changes.Warnings, &Warning{
+						Event:    ev,
+						Calendar: id,
+						Message: fmt.Sprintf("%s is already busy from %s to %s",
+							id, b.start.Format(time.RFC3339), b.end.Format(time.RFC3339)),
+					})
+					break
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// busyFor returns the calendar ev is in, and the email addresses of its
+// attendees, lower cased.
+func (c cal) busyFor(ev *Event) []string {
+	ids := []string{strings.ToLower(c.calendarOf(ev))}
+	for _, a := range ev.Attendees {
+		ids = append(ids, strings.ToLower(a.Email))
+	}
+	return ids
+}
+
+// queryBusy adds the busy periods of ids, between min and max, to busy.
+func (c cal) queryBusy(ctx context.Context, min, max time.Time, ids []string, busy map[string][]period) error {
+	req := &calendar.FreeBusyRequest{
+		TimeMin: min.UTC().Format(time.RFC3339),
+		TimeMax: max.UTC().Format(time.RFC3339),
+	}
+	for _, id := range ids {
+		req.Items = append(req.Items, &calendar.FreeBusyRequestItem{Id: id})
+	}
+	resp, err := c.svc.Freebusy.Query(req).Context(ctx).Do()
+	if isRateLimited(err) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("unable to query free/busy: %v", err)
+	}
+	for id, fb := range resp.Calendars {
+		// Calendars with errors, such as those that can't be seen, have
+		// no busy periods.
+		for _, tp := range fb.Busy {
+			start, err := time.Parse(time.RFC3339, tp.Start)
+			if err != nil {
+				return fmt.Errorf("free/busy of %s: %v", id, err)
+			}
+			end, err := time.Parse(time.RFC3339, tp.End)
+			if err != nil {
+				return fmt.Errorf("free/busy of %s: %v", id, err)
+			}
+			busy[strings.ToLower(id)] = append(busy[strings.ToLower(id)], period{start, end})
+		}
+	}
+	return nil
+}
+
+// subtract returns the parts of the periods of busy that none of own
+// overlap.
+func subtract(busy, own []period) []period {
+	for _, o := range own {
+		var rest []period
+		for _, b := range busy {
+			if !b.overlaps(o) {
+				rest = append(rest, b)
+				continue
+			}
+			if b.start.Before(o.start) {
+				rest = append(rest, period{b.start, o.start})
+			}
+			if o.end.Before(b.end) {
+				rest = append(rest, period{o.end, b.end})
+			}
+		}
+		busy = rest
+	}
+	return busy
+}
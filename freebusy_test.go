@@ -0,0 +1,69 @@
+package calsync
+
+import (
+	"testing"
+	"time"
+
+	calendar "google.golang.org/api/calendar/v3"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+func TestSubtract(t *testing.T) {
+	at := func(h int) time.Time { return when("2017-05-01T00:00:00Z").Add(time.Duration(h) * time.Hour) }
+	busy := []period{{at(1), at(5)}, {at(7), at(8)}}
+	equals(t, []period{{at(1), at(2)}, {at(3), at(5)}, {at(7), at(8)}}, subtract(busy, []period{{at(2), at(3)}}))
+	equals(t, []period{{at(1), at(5)}}, subtract(busy, []period{{at(6), at(9)}}))
+	equals(t, 0, len(subtract(busy, []period{{at(0), at(9)}})))
+}
+
+func TestCheckFreeBusy(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	s.AddCalendar("alice@example.com", "Alice", "UTC")
+	start := time.Now().Add(time.Hour).Truncate(time.Hour)
+	busy := func(calID string, start time.Time) {
+		_, err := s.Put(calID, &calendar.Event{
+			Summary: "already there",
+			Start:   &calendar.EventDateTime{DateTime: start.Format(time.RFC3339)},
+			End:     &calendar.EventDateTime{DateTime: start.Add(time.Hour).Format(time.RFC3339)},
+		})
+		ok(t, err)
+	}
+	busy("primary", start)
+	busy("alice@example.com", start.Add(5*time.Hour))
+	synced := newSrcEvent("synced", start.Add(3*time.Hour))
+	_, err := Sync(ctx, s.Client(), "scope", []*Event{synced})
+	ok(t, err)
+
+	overlapping := newSrcEvent("overlapping", start.Add(30*time.Minute))
+	ownTime := newSrcEvent("own time", start.Add(3*time.Hour+30*time.Minute))
+	invited := newSrcEvent("invited", start.Add(5*time.Hour))
+	invited.Attendees = []Attendee{{Name: "Alice", Email: "Alice@example.com"}}
+	unknown := newSrcEvent("unknown", start.Add(7*time.Hour))
+	unknown.Attendees = []Attendee{{Name: "Bob", Email: "bob@example.com"}}
+	src := []*Event{synced, overlapping, ownTime, invited, unknown}
+
+	var confirmed []*Warning
+	changes, err := Sync(ctx, s.Client(), "scope", src, CheckFreeBusy(), Confirm(func(changes *Changes) error {
+		confirmed = changes.Warnings
+		return nil
+	}))
+	ok(t, err)
+	equals(t, 4, len(changes.Adds))
+	equals(t, 2, len(changes.Warnings))
+	equals(t, overlapping.SrcID, changes.Warnings[0].Event.SrcID)
+	equals(t, "primary", changes.Warnings[0].Calendar)
+	equals(t, invited.SrcID, changes.Warnings[1].Event.SrcID)
+	equals(t, "alice@example.com", changes.Warnings[1].Calendar)
+	equals(t, changes.Warnings, confirmed)
+
+	changes, err = Sync(ctx, s.Client(), "other", []*Event{newSrcEvent("a", start)}, Nop())
+	ok(t, err)
+	equals(t, 0, len(changes.Warnings))
+
+	_, err = Sync(ctx, nil, "scope", src, WithBackend(newMemBackend()), CheckFreeBusy())
+	assert(t, err != nil, "expected an error for CheckFreeBusy with a Backend")
+}
@@ -0,0 +1,208 @@
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ginabythebay/calsync"
+)
+
+// eventPage is a page of events, as Microsoft Graph lists them.
+type eventPage struct {
+	Value    []*graphEvent `json:"value"`
+	NextLink string        `json:"@odata.nextLink"`
+}
+
+// graphEvent is the part of a Microsoft Graph event that calsync
+// writes.  Fields are written even when empty, so that updates clear
+// them.
+type graphEvent struct {
+	ID         string          `json:"id,omitempty"`
+	Subject    string          `json:"subject"`
+	Body       itemBody        `json:"body"`
+	Start      dateTimeZone    `json:"start"`
+	End        dateTimeZone    `json:"end"`
+	IsAllDay   bool            `json:"isAllDay"`
+	Location   location        `json:"location"`
+	ShowAs     string          `json:"showAs"`
+	Attendees  []graphAttendee `json:"attendees"`
+	Extensions []*extension    `json:"extensions,omitempty"`
+}
+
+type itemBody struct {
+	ContentType string `json:"contentType"`
+	Content     string `json:"content"`
+}
+
+type dateTimeZone struct {
+	DateTime string `json:"dateTime"`
+	TimeZone string `json:"timeZone"`
+}
+
+type location struct {
+	DisplayName string `json:"displayName"`
+}
+
+type graphAttendee struct {
+	EmailAddress emailAddress `json:"emailAddress"`
+	Type         string       `json:"type,omitempty"`
+}
+
+type emailAddress struct {
+	Address string `json:"address"`
+	Name    string `json:"name,omitempty"`
+}
+
+// extension is the open extension calsync keeps on the events it syncs.
+// Microsoft Graph only keeps simple values in open extensions, so maps
+// are kept as JSON.
+type extension struct {
+	ODataType               string `json:"@odata.type,omitempty"`
+	ExtensionName           string `json:"extensionName"`
+	Scope                   string `json:"scope"`
+	SrcID                   string `json:"srcId"`
+	PrivateProps            string `json:"privateProps"`
+	Metadata                string `json:"metadata"`
+	SourceURL               string `json:"sourceUrl"`
+	SourceTitle             string `json:"sourceTitle"`
+	GuestsCanModify         *bool  `json:"guestsCanModify"`
+	GuestsCanInviteOthers   *bool  `json:"guestsCanInviteOthers"`
+	GuestsCanSeeOtherGuests *bool  `json:"guestsCanSeeOtherGuests"`
+
+	// Microsoft Graph names extensions by id when returning them.
+	ID string `json:"id,omitempty"`
+}
+
+func newGraphEvent(ev *calsync.Event) *graphEvent {
+	out := &graphEvent{
+		Subject:   ev.Title,
+		Body:      itemBody{ContentType: "text", Content: ev.Description},
+		Start:     newDateTimeZone(ev.Start, ev.AllDay),
+		End:       newDateTimeZone(ev.End, ev.AllDay),
+		IsAllDay:  ev.AllDay,
+		Location:  location{DisplayName: ev.Where},
+		ShowAs:    "busy",
+		Attendees: []graphAttendee{},
+	}
+	if ev.DescriptionHTML {
+		out.Body.ContentType = "html"
+	}
+	if ev.Status == calsync.EventTentative {
+		out.ShowAs = "tentative"
+	}
+	for _, a := range ev.Attendees {
+		out.Attendees = append(out.Attendees, graphAttendee{
+			EmailAddress: emailAddress{Address: a.Email, Name: a.Name},
+			Type:         "required",
+		})
+	}
+	return out
+}
+
+// newDateTimeZone returns t in UTC, or, for all day events, the
+// midnight that starts its date, as Microsoft Graph requires.
+func newDateTimeZone(t time.Time, allDay bool) dateTimeZone {
+	if allDay {
+		t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	return dateTimeZone{DateTime: t.UTC().Format(dateTimeLayout), TimeZone: "UTC"}
+}
+
+func newExtension(scope string, ev *calsync.Event) *extension {
+	return &extension{
+		ODataType:               "microsoft.graph.openTypeExtension",
+		ExtensionName:           ExtensionName,
+		Scope:                   scope,
+		SrcID:                   ev.SrcID,
+		PrivateProps:            encodeMap(ev.PrivateProps),
+		Metadata:                encodeMap(ev.Metadata),
+		SourceURL:               ev.SourceURL,
+		SourceTitle:             ev.SourceTitle,
+		GuestsCanModify:         ev.GuestsCanModify,
+		GuestsCanInviteOthers:   ev.GuestsCanInviteOthers,
+		GuestsCanSeeOtherGuests: ev.GuestsCanSeeOtherGuests,
+	}
+}
+
+// extension returns the calsync extension of in, or nil if it has none.
+func (in *graphEvent) extension() *extension {
+	for _, ext := range in.Extensions {
+		if ext.ID == ExtensionName || ext.ExtensionName == ExtensionName {
+			return ext
+		}
+	}
+	return nil
+}
+
+// event returns in as a calsync event, with the fields kept in ext.
+func (in *graphEvent) event(ext *extension) (*calsync.Event, error) {
+	start, err := in.Start.time()
+	if err != nil {
+		return nil, fmt.Errorf("start: %v", err)
+	}
+	end, err := in.End.time()
+	if err != nil {
+		return nil, fmt.Errorf("end: %v", err)
+	}
+	ev := &calsync.Event{
+		Title:                   in.Subject,
+		Start:                   start,
+		End:                     end,
+		Where:                   in.Location.DisplayName,
+		Description:             in.Body.Content,
+		DescriptionHTML:         in.Body.ContentType == "html",
+		SrcID:                   ext.SrcID,
+		AllDay:                  in.IsAllDay,
+		SourceURL:               ext.SourceURL,
+		SourceTitle:             ext.SourceTitle,
+		GuestsCanModify:         ext.GuestsCanModify,
+		GuestsCanInviteOthers:   ext.GuestsCanInviteOthers,
+		GuestsCanSeeOtherGuests: ext.GuestsCanSeeOtherGuests,
+	}
+	if in.ShowAs == "tentative" {
+		ev.Status = calsync.EventTentative
+	}
+	for _, a := range in.Attendees {
+		ev.Attendees = append(ev.Attendees, calsync.Attendee{Name: a.EmailAddress.Name, Email: a.EmailAddress.Address})
+	}
+	if ev.PrivateProps, err = decodeMap(ext.PrivateProps); err != nil {
+		return nil, fmt.Errorf("private props: %v", err)
+	}
+	if ev.Metadata, err = decodeMap(ext.Metadata); err != nil {
+		return nil, fmt.Errorf("metadata: %v", err)
+	}
+	return ev, nil
+}
+
+// time parses d, which Microsoft Graph writes in UTC as it was asked
+// to.
+func (d dateTimeZone) time() (time.Time, error) {
+	loc := time.UTC
+	if d.TimeZone != "" && d.TimeZone != "UTC" {
+		var err error
+		if loc, err = time.LoadLocation(d.TimeZone); err != nil {
+			return time.Time{}, err
+		}
+	}
+	return time.ParseInLocation(dateTimeLayout, d.DateTime, loc)
+}
+
+func encodeMap(m map[string]string) string {
+	if len(m) == 0 {
+		return ""
+	}
+	b, _ := json.Marshal(m)
+	return string(b)
+}
+
+func decodeMap(s string) (map[string]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var m map[string]string
+	if err := json.Unmarshal([]byte(s), &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,268 @@
+/*
+Package graph is a calsync.Backend that syncs events into an Outlook
+calendar through Microsoft Graph, so that the same source events can be
+synced to Microsoft 365 users as to google calendar users:
+
+	b := graph.New(client, "")
+	changes, err := calsync.Sync(ctx, nil, scope, events, calsync.WithBackend(b))
+
+client must add a Microsoft Graph access token with the
+Calendars.ReadWrite permission to requests, for example one from
+golang.org/x/oauth2.  The calendar is the signed in user's default
+calendar, or the calendar with the given id.
+
+Each synced event carries an open extension, named ExtensionName, that
+records its scope and SrcID, along with its PrivateProps and Metadata,
+as google calendar private extended properties do.  Events without it
+are left alone.  Tentative events are shown as tentative, and cancelled
+ones are removed, as Outlook only lets organizers cancel meetings.
+*/
+package graph
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ginabythebay/calsync"
+
+	"golang.org/x/net/context"
+)
+
+// DefaultBaseURL is where Microsoft Graph is served.
+const DefaultBaseURL = "https://graph.microsoft.com/v1.0"
+
+// ExtensionName names the open extension calsync keeps on the events it
+// syncs.
+const ExtensionName = "com.github.ginabythebay.calsync"
+
+// dateTimeLayout is how Microsoft Graph writes the dateTime of a
+// dateTimeTimeZone, in the timeZone beside it.
+const dateTimeLayout = "2006-01-02T15:04:05.9999999"
+
+// Backend is a calsync.Backend that keeps events in an Outlook
+// calendar.
+type Backend struct {
+	// BaseURL, if set, overrides DefaultBaseURL, for example for a
+	// national cloud.
+	BaseURL string
+
+	client     *http.Client
+	calendarID string
+
+	// ids maps the SrcIDs of the events of each scope to their graph
+	// ids, as of the latest Fetch, which Sync and Apply make before
+	// modifying anything.
+	mu  sync.Mutex
+	ids map[string]map[string]string
+}
+
+// New returns a Backend for the calendar with id calendarID, or for the
+// default calendar if calendarID is empty, that makes requests with
+// client.
+func New(client *http.Client, calendarID string) *Backend {
+	return &Backend{client: client, calendarID: calendarID, ids: map[string]map[string]string{}}
+}
+
+// Name implements calsync.Backend.
+func (b *Backend) Name() string { return "microsoft graph" }
+
+// Fetch implements calsync.Backend.
+func (b *Backend) Fetch(ctx context.Context, scope string, now time.Time) ([]*calsync.Event, error) {
+	q := url.Values{}
+	q.Set("$filter", fmt.Sprintf("Extensions/any(f:f/id eq '%s') and end/dateTime ge '%s'",
+		ExtensionName, now.UTC().Format(dateTimeLayout)))
+	q.Set("$expand", fmt.Sprintf("Extensions($filter=id eq '%s')", ExtensionName))
+	q.Set("$top", "100")
+	next := b.calendarPath() + "/events?" + q.Encode()
+
+	var events []*calsync.Event
+	ids := map[string]string{}
+	for next != "" {
+		page := &eventPage{}
+		if err := b.do(ctx, "GET", next, nil, page); err != nil {
+			return nil, fmt.Errorf("unable to retrieve outlook events: %v", err)
+		}
+		for _, in := range page.Value {
+			ext := in.extension()
+			if ext == nil || ext.Scope != scope {
+				continue
+			}
+			ev, err := in.event(ext)
+			if err != nil {
+				return nil, fmt.Errorf("event %q: %v", in.Subject, err)
+			}
+			if !ev.End.After(now) {
+				continue
+			}
+			events = append(events, ev)
+			ids[ev.SrcID] = in.ID
+		}
+		next = page.NextLink
+	}
+	b.mu.Lock()
+	b.ids[scope] = ids
+	b.mu.Unlock()
+	return events, nil
+}
+
+// Add implements calsync.Backend.
+func (b *Backend) Add(ctx context.Context, scope string, ev *calsync.Event) (string, error) {
+	out := newGraphEvent(ev)
+	out.Extensions = []*extension{newExtension(scope, ev)}
+	added := &graphEvent{}
+	if err := b.do(ctx, "POST", b.calendarPath()+"/events", out, added); err != nil {
+		return "", fmt.Errorf("adding %q: %v", ev.Title, err)
+	}
+	b.mu.Lock()
+	if b.ids[scope] == nil {
+		b.ids[scope] = map[string]string{}
+	}
+	b.ids[scope][ev.SrcID] = added.ID
+	b.mu.Unlock()
+	return added.ID, nil
+}
+
+// Update implements calsync.Backend.
+func (b *Backend) Update(ctx context.Context, scope string, ev *calsync.Event) error {
+	if ev.Status == calsync.EventCancelled {
+		return b.Delete(ctx, scope, ev)
+	}
+	id, err := b.id(ctx, scope, ev.SrcID)
+	if err != nil {
+		return err
+	}
+	if id == "" {
+		return fmt.Errorf("updating %q: no outlook event with SrcID %q", ev.Title, ev.SrcID)
+	}
+	if err = b.do(ctx, "PATCH", "/me/events/"+pathEscape(id), newGraphEvent(ev), nil); err != nil {
+		return fmt.Errorf("updating %q: %v", ev.Title, err)
+	}
+	path := "/me/events/" + pathEscape(id) + "/extensions/" + pathEscape(ExtensionName)
+	if err = b.do(ctx, "PATCH", path, newExtension(scope, ev), nil); err != nil {
+		return fmt.Errorf("updating %q: %v", ev.Title, err)
+	}
+	return nil
+}
+
+// Delete implements calsync.Backend.
+func (b *Backend) Delete(ctx context.Context, scope string, ev *calsync.Event) error {
+	id, err := b.id(ctx, scope, ev.SrcID)
+	if err != nil || id == "" {
+		return err
+	}
+	err = b.do(ctx, "DELETE", "/me/events/"+pathEscape(id), nil, nil)
+	if e, ok := err.(*Error); ok && e.StatusCode == http.StatusNotFound {
+		// Already deleted, which is what we wanted.
+		err = nil
+	}
+	if err != nil {
+		return fmt.Errorf("deleting %q: %v", ev.Title, err)
+	}
+	b.mu.Lock()
+	delete(b.ids[scope], ev.SrcID)
+	b.mu.Unlock()
+	return nil
+}
+
+// id returns the graph id of the event of scope with srcID, or "" if
+// there is none, fetching the events of scope if they weren't already.
+func (b *Backend) id(ctx context.Context, scope, srcID string) (string, error) {
+	b.mu.Lock()
+	ids, ok := b.ids[scope]
+	b.mu.Unlock()
+	if !ok {
+		if _, err := b.Fetch(ctx, scope, time.Now()); err != nil {
+			return "", err
+		}
+		b.mu.Lock()
+		ids = b.ids[scope]
+		b.mu.Unlock()
+	}
+	return ids[srcID], nil
+}
+
+func (b *Backend) calendarPath() string {
+	if b.calendarID == "" {
+		return "/me/calendar"
+	}
+	return "/me/calendars/" + pathEscape(b.calendarID)
+}
+
+// pathEscape escapes s to be one segment of a url path, "/" included,
+// as url.PathEscape does, which go 1.7 lacks.
+func pathEscape(s string) string {
+	return strings.Replace((&url.URL{Path: s}).EscapedPath(), "/", "%2F", -1)
+}
+
+// Error is an error response from Microsoft Graph.
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// do sends a request for path, which may also be a full url, such as
+// an @odata.nextLink, with in as its JSON body if it isn't nil, and
+// decodes the response into out if it isn't nil.
+func (b *Backend) do(ctx context.Context, method, path string, in, out interface{}) error {
+	if !strings.HasPrefix(path, "https://") && !strings.HasPrefix(path, "http://") {
+		base := b.BaseURL
+		if base == "" {
+			base = DefaultBaseURL
+		}
+		path = strings.TrimSuffix(base, "/") + path
+	}
+	var body io.Reader
+	if in != nil {
+		buf, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(buf)
+	}
+	req, err := http.NewRequest(method, path, body)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Prefer", `outlook.timezone="UTC"`)
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		e := &Error{StatusCode: resp.StatusCode}
+		var graphErr struct {
+			Error struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if raw, _ := ioutil.ReadAll(resp.Body); json.Unmarshal(raw, &graphErr) == nil {
+			e.Code, e.Message = graphErr.Error.Code, graphErr.Error.Message
+		} else {
+			e.Message = strings.TrimSpace(string(raw))
+		}
+		return e
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
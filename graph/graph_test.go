@@ -0,0 +1,251 @@
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ginabythebay/calsync"
+
+	"golang.org/x/net/context"
+)
+
+// fakeGraph serves the part of the Microsoft Graph calendar api that
+// Backend uses, from memory.
+type fakeGraph struct {
+	mu     sync.Mutex
+	events map[string]*graphEvent
+	nextID int
+}
+
+func newFakeGraph() (*fakeGraph, *httptest.Server) {
+	f := &fakeGraph{events: map[string]*graphEvent{}}
+	return f, httptest.NewServer(f)
+}
+
+func (f *fakeGraph) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	path := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case r.Method == "GET" && r.URL.Path == "/me/calendar/events":
+		f.list(w, r)
+	case r.Method == "POST" && r.URL.Path == "/me/calendar/events":
+		in := &graphEvent{}
+		if err := json.NewDecoder(r.Body).Decode(in); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.nextID++
+		in.ID = fmt.Sprintf("id%d", f.nextID)
+		for _, ext := range in.Extensions {
+			ext.ID, ext.ODataType = ext.ExtensionName, ""
+		}
+		f.events[in.ID] = in
+		json.NewEncoder(w).Encode(in)
+	case len(path) >= 3 && path[0] == "me" && path[1] == "events":
+		ev, ok := f.events[path[2]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error": {"code": "ErrorItemNotFound", "message": "not found"}}`)
+			return
+		}
+		switch {
+		case r.Method == "DELETE" && len(path) == 3:
+			delete(f.events, ev.ID)
+		case r.Method == "PATCH" && len(path) == 3:
+			json.NewDecoder(r.Body).Decode(ev)
+		case r.Method == "PATCH" && len(path) == 5 && path[3] == "extensions":
+			ext := &extension{}
+			json.NewDecoder(r.Body).Decode(ext)
+			ext.ID, ext.ODataType = path[4], ""
+			ev.Extensions = []*extension{ext}
+		default:
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+	}
+}
+
+// list returns one event per page, to exercise paging.  It ignores the
+// filter, which Backend checks again.
+func (f *fakeGraph) list(w http.ResponseWriter, r *http.Request) {
+	var ids []string
+	for id := range f.events {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	skip, _ := strconv.Atoi(r.URL.Query().Get("$skip"))
+	page := eventPage{Value: []*graphEvent{}}
+	if skip < len(ids) {
+		page.Value = append(page.Value, f.events[ids[skip]])
+	}
+	if skip+1 < len(ids) {
+		next := *r.URL
+		q := next.Query()
+		q.Set("$skip", strconv.Itoa(skip+1))
+		next.RawQuery = q.Encode()
+		page.NextLink = "http://" + r.Host + next.String()
+	}
+	json.NewEncoder(w).Encode(page)
+}
+
+func (f *fakeGraph) bySrcID(srcID string) *graphEvent {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, ev := range f.events {
+		if ext := ev.extension(); ext != nil && ext.SrcID == srcID {
+			return ev
+		}
+	}
+	return nil
+}
+
+func testEvents() []*calsync.Event {
+	start := time.Date(2030, 1, 2, 18, 0, 0, 0, time.UTC)
+	return []*calsync.Event{
+		{
+			Title:        "class",
+			Start:        start,
+			End:          start.Add(time.Hour),
+			Where:        "room 1",
+			Description:  "bring a pencil",
+			SrcID:        "class1",
+			Attendees:    []calsync.Attendee{{Name: "Ann", Email: "ann@example.com"}},
+			Metadata:     map[string]string{"teacher": "Bob"},
+			PrivateProps: map[string]string{"row": "7"},
+			Status:       calsync.EventTentative,
+		},
+		{
+			Title:  "holiday",
+			Start:  time.Date(2030, 1, 3, 0, 0, 0, 0, time.UTC),
+			End:    time.Date(2030, 1, 4, 0, 0, 0, 0, time.UTC),
+			SrcID:  "holiday1",
+			AllDay: true,
+		},
+	}
+}
+
+func TestSync(t *testing.T) {
+	ctx := context.Background()
+	f, srv := newFakeGraph()
+	defer srv.Close()
+	// Someone else's event, which is left alone.
+	f.events["mine"] = &graphEvent{ID: "mine", Subject: "lunch"}
+
+	b := New(http.DefaultClient, "")
+	b.BaseURL = srv.URL
+	events := testEvents()
+	changes, err := calsync.Sync(ctx, nil, "scope", events, calsync.WithBackend(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes.Adds) != 2 {
+		t.Fatalf("got %d adds, want 2", len(changes.Adds))
+	}
+	class := f.bySrcID("class1")
+	if class == nil || class.ShowAs != "tentative" || class.Start.DateTime != "2030-01-02T18:00:00" {
+		t.Errorf("got %+v, want a tentative class at 18:00", class)
+	}
+	if ext := class.extension(); ext.Scope != "scope" || ext.Metadata != `{"teacher":"Bob"}` {
+		t.Errorf("got extension %+v", ext)
+	}
+
+	changes, err = calsync.Sync(ctx, nil, "scope", events, calsync.WithBackend(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(changes.Adds) + len(changes.Updates) + len(changes.Deletes); n != 0 {
+		t.Errorf("got %d changes syncing again, want none: %s", n, changes)
+	}
+
+	// A fresh Backend finds the events it didn't add itself.
+	b = New(http.DefaultClient, "")
+	b.BaseURL = srv.URL
+	events[0].Where = "room 2"
+	events[0].PrivateProps = nil
+	changes, err = calsync.Sync(ctx, nil, "scope", events[:1], calsync.WithBackend(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes.Updates) != 1 || len(changes.Deletes) != 1 {
+		t.Errorf("got %s, want one update and one delete", changes)
+	}
+	if class = f.bySrcID("class1"); class.Location.DisplayName != "room 2" || class.extension().PrivateProps != "" {
+		t.Errorf("got %+v, want an updated class", class)
+	}
+	if f.bySrcID("holiday1") != nil {
+		t.Error("expected the holiday to be deleted")
+	}
+	if _, ok := f.events["mine"]; !ok {
+		t.Error("expected the event without an extension to be left alone")
+	}
+
+	// Other scopes don't see the events.
+	fetched, err := calsync.Fetch(ctx, nil, "other", calsync.WithBackend(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fetched) != 0 {
+		t.Errorf("got %d events in another scope, want none", len(fetched))
+	}
+}
+
+func TestDelete(t *testing.T) {
+	ctx := context.Background()
+	f, srv := newFakeGraph()
+	defer srv.Close()
+	b := New(http.DefaultClient, "")
+	b.BaseURL = srv.URL
+	ev := testEvents()[0]
+	if _, err := b.Add(ctx, "scope", ev); err != nil {
+		t.Fatal(err)
+	}
+	// Deleted behind the Backend's back.
+	f.mu.Lock()
+	f.events = map[string]*graphEvent{}
+	f.mu.Unlock()
+	if err := b.Delete(ctx, "scope", ev); err != nil {
+		t.Errorf("got %v, want no error deleting a missing event", err)
+	}
+	if err := b.Update(ctx, "other", ev); err == nil {
+		t.Error("expected an error updating a missing event")
+	}
+}
+
+func TestEventRoundTrip(t *testing.T) {
+	for _, ev := range testEvents() {
+		in := newGraphEvent(ev)
+		ext := newExtension("scope", ev)
+		got, err := in.event(ext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Title != ev.Title || !got.Start.Equal(ev.Start) || !got.End.Equal(ev.End) ||
+			got.AllDay != ev.AllDay || got.Status != ev.Status || len(got.Attendees) != len(ev.Attendees) ||
+			got.Metadata["teacher"] != ev.Metadata["teacher"] || got.PrivateProps["row"] != ev.PrivateProps["row"] {
+			t.Errorf("got %+v, want %+v", got, ev)
+		}
+	}
+}
+
+func TestPathEscape(t *testing.T) {
+	for in, want := range map[string]string{
+		"AAMkAD=":     "AAMkAD=",
+		"a/b c?d#e%f": "a%2Fb%20c%3Fd%23e%25f",
+	} {
+		if got := pathEscape(in); got != want {
+			t.Errorf("pathEscape(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
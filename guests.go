@@ -0,0 +1,58 @@
+package calsync
+
+import "fmt"
+
+// Google calendar's defaults for the guest permissions of an event,
+// which an Event leaving them nil gets.
+const (
+	defaultGuestsCanModify         = false
+	defaultGuestsCanInviteOthers   = true
+	defaultGuestsCanSeeOtherGuests = true
+)
+
+// guestPermissions holds the effective guest permissions of an event.
+type guestPermissions struct {
+	canModify, canInviteOthers, canSeeOtherGuests bool
+}
+
+// guestPermissions returns the guest permissions of ev, with google
+// calendar's defaults for those that aren't set.
+func (ev *Event) guestPermissions() guestPermissions {
+	return guestPermissions{
+		canModify:         boolOr(ev.GuestsCanModify, defaultGuestsCanModify),
+		canInviteOthers:   boolOr(ev.GuestsCanInviteOthers, defaultGuestsCanInviteOthers),
+		canSeeOtherGuests: boolOr(ev.GuestsCanSeeOtherGuests, defaultGuestsCanSeeOtherGuests),
+	}
+}
+
+// isDefault reports whether g are google calendar's defaults.
+func (g guestPermissions) isDefault() bool {
+	return g == guestPermissions{
+		canModify:         defaultGuestsCanModify,
+		canInviteOthers:   defaultGuestsCanInviteOthers,
+		canSeeOtherGuests: defaultGuestsCanSeeOtherGuests,
+	}
+}
+
+func (g guestPermissions) String() string {
+	return fmt.Sprintf("modify=%t invite=%t see=%t", g.canModify, g.canInviteOthers, g.canSeeOtherGuests)
+}
+
+// boolOr returns *b, or def if b is nil.
+func boolOr(b *bool, def bool) bool {
+	if b == nil {
+		return def
+	}
+	return *b
+}
+
+// nonDefault returns a pointer to b, or nil if b is def, so that
+// events read from google calendar only have the guest permissions set
+// that differ from the defaults.
+func nonDefault(b *bool, def bool) *bool {
+	v := boolOr(b, def)
+	if v == def {
+		return nil
+	}
+	return &v
+}
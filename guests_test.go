@@ -0,0 +1,75 @@
+package calsync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+func TestGuestPermissions(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	yes, no := true, false
+	ev := newSrcEvent("a", time.Now().Add(time.Hour).Truncate(time.Second))
+	ev.GuestsCanInviteOthers = &no
+
+	_, err := Sync(ctx, s.Client(), "scope", []*Event{ev})
+	ok(t, err)
+	events := s.Events("primary")
+	equals(t, 1, len(events))
+	equals(t, false, events[0].GuestsCanModify)
+	equals(t, &no, events[0].GuestsCanInviteOthers)
+	equals(t, &yes, events[0].GuestsCanSeeOtherGuests)
+
+	fetched, err := Fetch(ctx, s.Client(), "scope")
+	ok(t, err)
+	equals(t, (*bool)(nil), fetched[0].GuestsCanModify)
+	equals(t, &no, fetched[0].GuestsCanInviteOthers)
+	equals(t, (*bool)(nil), fetched[0].GuestsCanSeeOtherGuests)
+
+	// Setting the defaults explicitly changes nothing.
+	ev.GuestsCanModify = &no
+	ev.GuestsCanSeeOtherGuests = &yes
+	changes, err := Sync(ctx, s.Client(), "scope", []*Event{ev})
+	ok(t, err)
+	assert(t, changes.empty(), "expected no changes, got %s", changes)
+
+	ev.GuestsCanModify = &yes
+	changes, err = Sync(ctx, s.Client(), "scope", []*Event{ev})
+	ok(t, err)
+	equals(t, 1, len(changes.Updates))
+	equals(t, []string{"GuestsCanModify"}, changes.UpdateDetails()[0].Fields)
+	equals(t, true, s.Events("primary")[0].GuestsCanModify)
+
+	ev.GuestsCanModify, ev.GuestsCanInviteOthers = nil, nil
+	changes, err = Sync(ctx, s.Client(), "scope", []*Event{ev})
+	ok(t, err)
+	equals(t, []string{"GuestsCanModify", "GuestsCanInviteOthers"}, changes.UpdateDetails()[0].Fields)
+	events = s.Events("primary")
+	equals(t, false, events[0].GuestsCanModify)
+	equals(t, &yes, events[0].GuestsCanInviteOthers)
+
+	changes, err = Sync(ctx, s.Client(), "scope", []*Event{ev})
+	ok(t, err)
+	assert(t, changes.empty(), "expected no changes, got %s", changes)
+}
+
+func TestGuestPermissionsEdited(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	ev := newSrcEvent("a", time.Now().Add(time.Hour).Truncate(time.Second))
+	_, err := Sync(ctx, s.Client(), "scope", []*Event{ev})
+	ok(t, err)
+
+	// Letting guests modify the event in google calendar is an edit.
+	edited := s.Events("primary")[0]
+	edited.GuestsCanModify = true
+	_, err = s.Put("primary", edited)
+	ok(t, err)
+	changes, err := Sync(ctx, s.Client(), "scope", []*Event{ev}, OnConflict(SkipConflicts))
+	ok(t, err)
+	equals(t, 1, len(changes.Conflicts))
+}
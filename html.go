@@ -0,0 +1,37 @@
+package calsync
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	// htmlTag matches the tags that show a description is HTML, as
+	// written by google calendar's web UI when a user edits it.
+	htmlTag = regexp.MustCompile(`(?i)</?(br|p|div|span|b|i|u|a|ul|ol|li)\b[^>]*>`)
+
+	// lineBreakTags matches the tags that end a line of HTML.
+	lineBreakTags = regexp.MustCompile(`(?i)<br\s*/?>|</(p|div|li)>`)
+
+	// tags matches any HTML tag.
+	tags = regexp.MustCompile(`<[^>]*>`)
+)
+
+// looksLikeHTML reports whether the description s is HTML.
+func looksLikeHTML(s string) bool {
+	return htmlTag.MatchString(s)
+}
+
+// htmlToText converts the HTML description s to plain text, with a
+// newline for each line break or paragraph, and no other markup.
+func htmlToText(s string) string {
+	s = lineBreakTags.ReplaceAllString(s, "\n")
+	s = tags.ReplaceAllString(s, "")
+	return html.UnescapeString(s)
+}
+
+// textToHTML converts the plain text description s to HTML.
+func textToHTML(s string) string {
+	return strings.Replace(html.EscapeString(s), "\n", "<br>", -1)
+}
@@ -0,0 +1,47 @@
+package calsync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+func TestHTMLDescription(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	ev := newSrcEvent("a", time.Now().Add(time.Hour).Truncate(time.Second))
+	ev.Description = "<p>Bring <b>snacks</b> &amp; drinks</p>"
+	ev.DescriptionHTML = true
+
+	_, err := Sync(ctx, s.Client(), "scope", []*Event{ev})
+	ok(t, err)
+	events := s.Events("primary")
+	equals(t, 1, len(events))
+	equals(t, delim+"<br><p>Bring <b>snacks</b> &amp; drinks</p>", events[0].Description)
+
+	// A user adds a comment in the web UI, which stores the whole
+	// description as HTML.
+	events[0].Description = "<div>See you <i>there</i></div>" + events[0].Description
+	_, err = s.Put("primary", events[0])
+	ok(t, err)
+
+	changes, err := Sync(ctx, s.Client(), "scope", []*Event{ev})
+	ok(t, err)
+	assert(t, changes.empty(), "expected no changes, got %s", changes)
+
+	ev.Description = "<p>Bring snacks</p>"
+	changes, err = Sync(ctx, s.Client(), "scope", []*Event{ev})
+	ok(t, err)
+	equals(t, 1, len(changes.Updates))
+	equals(t, "See you there<br>"+delim+"<br><p>Bring snacks</p>", s.Events("primary")[0].Description)
+}
+
+func TestHTMLToText(t *testing.T) {
+	equals(t, "a\nb & c\n", htmlToText("a<br/>b &amp; <span>c</span></p>"))
+	equals(t, "a &lt; b<br>c", textToHTML("a < b\nc"))
+	assert(t, looksLikeHTML("<DIV>a</DIV>"), "expected HTML")
+	assert(t, !looksLikeHTML("a < b > c"), "expected plain text")
+}
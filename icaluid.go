@@ -0,0 +1,92 @@
+package calsync
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+// MatchStrategy determines how Sync recognizes the calendar events it
+// synced.
+type MatchStrategy int
+
+const (
+	// MatchProperties recognizes events by the private extended
+	// properties Sync writes to them.  This is the default.
+	MatchProperties MatchStrategy = iota
+
+	// MatchICalUID recognizes events by their iCalUID, which is derived
+	// from the scope and SrcID, and adds events with Events.Import
+	// rather than Events.Insert.  Importing an event that already exists
+	// updates it, so adds are never duplicated, and other iCalendar
+	// tools see the same, stable, identifiers as Sync.  No private
+	// extended properties are written, other than PrivateProps.
+	//
+	// As nothing records what was synced, calendar edits aren't
+	// detected, and a whole calendar is listed to find the events in
+	// scope.  Incremental, Adopt, OnDelete(Cancel) and MigrateScope don't
+	// support it, and ListScopes doesn't see such events.
+	MatchICalUID
+)
+
+func (s MatchStrategy) String() string {
+	switch s {
+	case MatchProperties:
+		return "Properties"
+	case MatchICalUID:
+		return "ICalUID"
+	}
+	return fmt.Sprintf("MatchStrategy(%d)", int(s))
+}
+
+// icalUIDSuffix ends the iCalUIDs that MatchICalUID writes.
+const icalUIDSuffix = "@calsync"
+
+// icalUID returns the iCalUID of the event in scope with srcID.  Both
+// are encoded, so that any SrcID makes a valid iCalUID, and the SrcID
+// can be read back.
+func icalUID(scope, srcID string) string {
+	return hex.EncodeToString([]byte(srcID)) + "." + hex.EncodeToString([]byte(scope)) + icalUIDSuffix
+}
+
+// parseICalUID returns the scope and SrcID that uid was made from by
+// icalUID.  ok is false if uid wasn't.
+func parseICalUID(uid string) (scope, srcID string, ok bool) {
+	if !strings.HasSuffix(uid, icalUIDSuffix) {
+		return "", "", false
+	}
+	parts := strings.Split(strings.TrimSuffix(uid, icalUIDSuffix), ".")
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	id, err := hex.DecodeString(parts[0])
+	if err != nil {
+		return "", "", false
+	}
+	s, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return "", "", false
+	}
+	return string(s), string(id), true
+}
+
+// listScope limits call to events in c's scope, as far as the api can.
+// With MatchICalUID, it can't, and owns must check each event.
+func (c cal) listScope(call *calendar.EventsListCall) *calendar.EventsListCall {
+	if c.match == MatchICalUID {
+		return call
+	}
+	return call.PrivateExtendedProperty(c.scope + "=True")
+}
+
+// owns reports whether ev, listed by a call limited by listScope, is in
+// c's scope.
+func (c cal) owns(ev *calendar.Event) bool {
+	if c.match != MatchICalUID {
+		return true
+	}
+	scope, _, ok := parseICalUID(ev.ICalUID)
+	return ok && scope == c.scope
+}
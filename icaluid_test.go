@@ -0,0 +1,74 @@
+package calsync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+func TestMatchICalUID(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	src := []*Event{newSrcEvent("a", start), newSrcEvent("b", start)}
+	byUID := MatchBy(MatchICalUID)
+
+	// Events synced by another scope, or entered by hand, are left alone.
+	_, err := Sync(ctx, s.Client(), "other", []*Event{newSrcEvent("c", start)}, byUID)
+	ok(t, err)
+	_, err = Sync(ctx, s.Client(), "props", []*Event{newSrcEvent("d", start)})
+	ok(t, err)
+
+	changes, err := Sync(ctx, s.Client(), "scope", src, byUID)
+	ok(t, err)
+	equals(t, 2, len(changes.Adds))
+	var uids []string
+	for _, ev := range s.Events("primary") {
+		if ev.Summary == "a title" {
+			equals(t, icalUID("scope", "a srcId"), ev.ICalUID)
+			assert(t, ev.ExtendedProperties == nil || len(ev.ExtendedProperties.Private) == 0,
+				"expected no private properties, got %v", ev.ExtendedProperties)
+		}
+		uids = append(uids, ev.ICalUID)
+	}
+	equals(t, 4, len(uids))
+
+	fetched, err := Fetch(ctx, s.Client(), "scope", byUID)
+	ok(t, err)
+	equals(t, 2, len(fetched))
+
+	changes, err = Sync(ctx, s.Client(), "scope", src, byUID)
+	ok(t, err)
+	assert(t, changes.empty(), "expected no changes, got %s", changes)
+
+	// Adding again imports over the existing event, rather than adding
+	// a duplicate.
+	c, err := setup(ctx, s.Client(), "scope", []Opt{byUID})
+	ok(t, err)
+	renamed := *src[0]
+	renamed.Title = "renamed"
+	_, err = c.add(ctx, &renamed)
+	ok(t, err)
+	equals(t, 4, len(s.Events("primary")))
+
+	changes, err = Purge(ctx, s.Client(), "scope", byUID)
+	ok(t, err)
+	equals(t, 2, len(changes.Deletes))
+	equals(t, 2, len(s.Events("primary")))
+}
+
+func TestICalUID(t *testing.T) {
+	uid := icalUID("scope", "a.b@c")
+	scope, srcID, ok := parseICalUID(uid)
+	assert(t, ok, "can't parse %q", uid)
+	equals(t, "scope", scope)
+	equals(t, "a.b@c", srcID)
+
+	for _, uid := range []string{"", "abc@calsynctest", "zz.00@calsync", "00@calsync"} {
+		_, _, ok := parseICalUID(uid)
+		assert(t, !ok, "parsed %q", uid)
+	}
+}
@@ -0,0 +1,425 @@
+package calsync
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	icsDateLayout     = "20060102"
+	icsDateTimeLayout = "20060102T150405Z"
+
+	// lines longer than this many octets must be folded.
+	icsMaxLine = 75
+)
+
+// icsNow returns the time stamped on exported events.
+var icsNow = time.Now
+
+// WriteICS writes events to w as an RFC 5545 iCalendar file, so they
+// can be previewed in, or imported into, calendar clients other than
+// google calendar.  SrcID is used as the UID of each event.
+func WriteICS(w io.Writer, events []*Event) error {
+	iw := newICSWriter(w)
+	iw.begin()
+	for _, ev := range events {
+		iw.event(ev, "")
+	}
+	return iw.end()
+}
+
+// WriteICS writes c to w as an RFC 5545 iCalendar file.  Adds and
+// Updates are written as they will appear once applied, and Deletes as
+// cancelled events.  Conflicts are not written, as they won't change.
+func (c *Changes) WriteICS(w io.Writer) error {
+	iw := newICSWriter(w)
+	iw.begin()
+	for _, ev := range c.Deletes {
+		iw.event(ev, "CANCELLED")
+	}
+	for _, ev := range c.Updates {
+		iw.event(ev, "")
+	}
+	for _, ev := range c.Adds {
+		iw.event(ev, "")
+	}
+	return iw.end()
+}
+
+type icsWriter struct {
+	w     *bufio.Writer
+	stamp string
+}
+
+func newICSWriter(w io.Writer) *icsWriter {
+	return &icsWriter{
+		w:     bufio.NewWriter(w),
+		stamp: icsNow().UTC().Format(icsDateTimeLayout),
+	}
+}
+
+func (iw *icsWriter) begin() {
+	iw.line("BEGIN:VCALENDAR")
+	iw.line("VERSION:2.0")
+	iw.line("PRODID:-//ginabythebay//calsync//EN")
+	iw.line("CALSCALE:GREGORIAN")
+}
+
+func (iw *icsWriter) end() error {
+	iw.line("END:VCALENDAR")
+	return iw.w.Flush()
+}
+
+func (iw *icsWriter) event(ev *Event, status string) {
+	iw.line("BEGIN:VEVENT")
+	iw.line("UID:" + icsEscape(ev.SrcID))
+	iw.line("DTSTAMP:" + iw.stamp)
+	if ev.AllDay {
+		iw.line("DTSTART;VALUE=DATE:" + ev.Start.Format(icsDateLayout))
+		iw.line("DTEND;VALUE=DATE:" + ev.End.Format(icsDateLayout))
+	} else {
+		iw.line("DTSTART:" + ev.Start.UTC().Format(icsDateTimeLayout))
+		iw.line("DTEND:" + ev.End.UTC().Format(icsDateTimeLayout))
+	}
+	iw.line("SUMMARY:" + icsEscape(ev.Title))
+	if ev.Where != "" {
+		iw.line("LOCATION:" + icsEscape(ev.Where))
+	}
+	if ev.Description != "" {
+		iw.line("DESCRIPTION:" + icsEscape(ev.Description))
+	}
+	if status != "" {
+		iw.line("STATUS:" + status)
+	}
+	iw.line("END:VEVENT")
+}
+
+// line writes s, folded as needed, followed by a CRLF.  Errors are
+// sticky in the bufio.Writer and reported by end.
+func (iw *icsWriter) line(s string) {
+	limit := icsMaxLine
+	for len(s) > limit {
+		// Don't split a utf-8 sequence.
+		n := limit
+		for n > 0 && !utf8.RuneStart(s[n]) {
+			n--
+		}
+		iw.w.WriteString(s[:n])
+		iw.w.WriteString("\r\n ")
+		s = s[n:]
+		// The leading space of a continuation line counts towards its
+		// length.
+		limit = icsMaxLine - 1
+	}
+	iw.w.WriteString(s)
+	iw.w.WriteString("\r\n")
+}
+
+var icsEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	";", `\;`,
+	",", `\,`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+)
+
+func icsEscape(s string) string {
+	return icsEscaper.Replace(s)
+}
+
+// ReadICS parses the events in an RFC 5545 iCalendar file, such as a
+// webcal feed, so they can be passed to Sync.  UID is used as SrcID,
+// SUMMARY as Title, LOCATION as Where and DESCRIPTION as Description.
+// An event whose DTSTART is a date is an all day event.
+//
+// Cancelled events are skipped, so that Sync removes them.  Recurrence
+// rules are not expanded: a recurring event is read as its first
+// instance, and changed instances of it are skipped.
+func ReadICS(r io.Reader) ([]*Event, error) {
+	lines, err := icsUnfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []*Event
+	var stack []string
+	var ev *icsEvent
+	for _, l := range lines {
+		prop, err := parseICSLine(l.text)
+		if err != nil {
+			return nil, fmt.Errorf("ics line %d: %v", l.num, err)
+		}
+		switch prop.name {
+		case "BEGIN":
+			stack = append(stack, strings.ToUpper(prop.value))
+			if len(stack) == 2 && stack[1] == "VEVENT" {
+				ev = &icsEvent{line: l.num}
+			}
+			continue
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1] != strings.ToUpper(prop.value) {
+				return nil, fmt.Errorf("ics line %d: unexpected END:%s", l.num, prop.value)
+			}
+			stack = stack[:len(stack)-1]
+			if len(stack) == 1 && ev != nil {
+				done, err := ev.event()
+				if err != nil {
+					return nil, fmt.Errorf("ics event at line %d: %v", ev.line, err)
+				}
+				if done != nil {
+					events = append(events, done)
+				}
+				ev = nil
+			}
+			continue
+		}
+		// Properties of components nested in the event, such as alarms,
+		// are not the event's.
+		if ev == nil || len(stack) != 2 {
+			continue
+		}
+		ev.set(prop)
+	}
+	if len(stack) != 0 {
+		return nil, fmt.Errorf("ics: missing END:%s", stack[len(stack)-1])
+	}
+	return events, nil
+}
+
+type icsLine struct {
+	num  int
+	text string
+}
+
+// icsUnfold reads the content lines from r, joining folded lines back
+// together.  num is the line number of the first line of each.
+func icsUnfold(r io.Reader) ([]icsLine, error) {
+	var lines []icsLine
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	num := 0
+	for scanner.Scan() {
+		num++
+		s := strings.TrimSuffix(scanner.Text(), "\r")
+		if s == "" {
+			continue
+		}
+		if (s[0] == ' ' || s[0] == '\t') && len(lines) != 0 {
+			lines[len(lines)-1].text += s[1:]
+			continue
+		}
+		lines = append(lines, icsLine{num, s})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
+
+type icsProp struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// parseICSLine splits a content line into its name, parameters and
+// value.  Names and parameter names are upper cased.  Parameter values
+// may be quoted, and then may contain ':', ';' and ','.
+func parseICSLine(s string) (*icsProp, error) {
+	prop := &icsProp{params: map[string]string{}}
+	var fields []string
+	start, quoted := 0, false
+	end := -1
+	for i := 0; i < len(s) && end < 0; i++ {
+		switch s[i] {
+		case '"':
+			quoted = !quoted
+		case ';':
+			if !quoted {
+				fields = append(fields, s[start:i])
+				start = i + 1
+			}
+		case ':':
+			if !quoted {
+				fields = append(fields, s[start:i])
+				end = i
+			}
+		}
+	}
+	if end < 0 {
+		return nil, fmt.Errorf("no ':' in %q", s)
+	}
+	prop.name = strings.ToUpper(fields[0])
+	prop.value = s[end+1:]
+	for _, f := range fields[1:] {
+		kv := strings.SplitN(f, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("malformed parameter %q", f)
+		}
+		prop.params[strings.ToUpper(kv[0])] = strings.Trim(kv[1], `"`)
+	}
+	return prop, nil
+}
+
+// icsEvent collects the properties of a VEVENT as they are read.
+type icsEvent struct {
+	line int
+
+	uid, summary, location, description string
+	start, end                          *icsProp
+	duration                            string
+	cancelled, instance                 bool
+}
+
+func (ie *icsEvent) set(prop *icsProp) {
+	switch prop.name {
+	case "UID":
+		ie.uid = icsUnescape(prop.value)
+	case "SUMMARY":
+		ie.summary = icsUnescape(prop.value)
+	case "LOCATION":
+		ie.location = icsUnescape(prop.value)
+	case "DESCRIPTION":
+		ie.description = icsUnescape(prop.value)
+	case "DTSTART":
+		ie.start = prop
+	case "DTEND":
+		ie.end = prop
+	case "DURATION":
+		ie.duration = prop.value
+	case "STATUS":
+		ie.cancelled = strings.EqualFold(prop.value, "CANCELLED")
+	case "RECURRENCE-ID":
+		ie.instance = true
+	}
+}
+
+// event returns the Event ie describes, or nil if it should be
+// skipped.
+func (ie *icsEvent) event() (*Event, error) {
+	if ie.cancelled || ie.instance {
+		return nil, nil
+	}
+	if ie.uid == "" {
+		return nil, fmt.Errorf("no UID")
+	}
+	if ie.start == nil {
+		return nil, fmt.Errorf("no DTSTART")
+	}
+	start, allDay, err := parseICSTime(ie.start)
+	if err != nil {
+		return nil, fmt.Errorf("DTSTART: %v", err)
+	}
+
+	var end time.Time
+	switch {
+	case ie.end != nil:
+		if end, _, err = parseICSTime(ie.end); err != nil {
+			return nil, fmt.Errorf("DTEND: %v", err)
+		}
+	case ie.duration != "":
+		d, err := parseICSDuration(ie.duration)
+		if err != nil {
+			return nil, fmt.Errorf("DURATION: %v", err)
+		}
+		end = start.Add(d)
+	case allDay:
+		// A date start with no end lasts the day.
+		end = start.AddDate(0, 0, 1)
+	default:
+		end = start
+	}
+
+	return &Event{
+		Title:       ie.summary,
+		Start:       start,
+		End:         end,
+		Where:       ie.location,
+		Description: ie.description,
+		SrcID:       ie.uid,
+		AllDay:      allDay,
+	}, nil
+}
+
+// parseICSTime parses a DTSTART or DTEND property, reporting whether it
+// is a date.  Dates are midnight UTC.  Times without a TZID or a
+// trailing Z are floating, and are taken to be local times.
+func parseICSTime(prop *icsProp) (time.Time, bool, error) {
+	if prop.params["VALUE"] == "DATE" || len(prop.value) == len(icsDateLayout) {
+		t, err := time.Parse(icsDateLayout, prop.value)
+		return t, true, err
+	}
+	if strings.HasSuffix(prop.value, "Z") {
+		t, err := time.Parse(icsDateTimeLayout, prop.value)
+		return t, false, err
+	}
+	loc := time.Local
+	if tzid := prop.params["TZID"]; tzid != "" {
+		var err error
+		if loc, err = time.LoadLocation(tzid); err != nil {
+			return time.Time{}, false, fmt.Errorf("unknown TZID %q", tzid)
+		}
+	}
+	t, err := time.ParseInLocation(strings.TrimSuffix(icsDateTimeLayout, "Z"), prop.value, loc)
+	return t, false, err
+}
+
+// parseICSDuration parses an RFC 5545 duration, such as PT1H30M or P1D.
+// Days are taken to be 24 hours.
+func parseICSDuration(s string) (time.Duration, error) {
+	orig := s
+	sign := time.Duration(1)
+	switch {
+	case strings.HasPrefix(s, "-"):
+		sign, s = -1, s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+	if !strings.HasPrefix(s, "P") || len(s) == 1 {
+		return 0, fmt.Errorf("malformed duration %q", orig)
+	}
+	s = s[1:]
+
+	units := map[byte]time.Duration{'W': 7 * 24 * time.Hour, 'D': 24 * time.Hour}
+	var d time.Duration
+	n := -1
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= '0' && c <= '9':
+			if n < 0 {
+				n = 0
+			}
+			n = n*10 + int(c-'0')
+		case c == 'T' && n < 0:
+			units = map[byte]time.Duration{'H': time.Hour, 'M': time.Minute, 'S': time.Second}
+		default:
+			unit, ok := units[c]
+			if !ok || n < 0 {
+				return 0, fmt.Errorf("malformed duration %q", orig)
+			}
+			d += time.Duration(n) * unit
+			n = -1
+		}
+	}
+	if n >= 0 {
+		return 0, fmt.Errorf("malformed duration %q", orig)
+	}
+	return sign * d, nil
+}
+
+var icsUnescaper = strings.NewReplacer(
+	`\\`, `\`,
+	`\;`, ";",
+	`\,`, ",",
+	`\n`, "\n",
+	`\N`, "\n",
+)
+
+func icsUnescape(s string) string {
+	return icsUnescaper.Replace(s)
+}
@@ -0,0 +1,196 @@
+package calsync
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteICS(t *testing.T) {
+	defer func(f func() time.Time) { icsNow = f }(icsNow)
+	icsNow = func() time.Time { return when("2017-04-29T20:00:00-07:00") }
+
+	timed := newSrcEvent("timed", when("2017-05-01T19:00:00-07:00"))
+	timed.Description = "line one\nline two; with, punctuation"
+	allDay := &Event{
+		Title:  "all day",
+		Start:  when("2017-05-02T00:00:00-07:00"),
+		End:    when("2017-05-03T00:00:00-07:00"),
+		SrcID:  "allDay",
+		AllDay: true,
+	}
+
+	var buf bytes.Buffer
+	ok(t, WriteICS(&buf, []*Event{timed, allDay}))
+	equals(t, strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//ginabythebay//calsync//EN",
+		"CALSCALE:GREGORIAN",
+		"BEGIN:VEVENT",
+		"UID:timed srcId",
+		"DTSTAMP:20170430T030000Z",
+		"DTSTART:20170502T020000Z",
+		"DTEND:20170502T030000Z",
+		"SUMMARY:timed title",
+		"LOCATION:timed where",
+		`DESCRIPTION:line one\nline two\; with\, punctuation`,
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:allDay",
+		"DTSTAMP:20170430T030000Z",
+		"DTSTART;VALUE=DATE:20170502",
+		"DTEND;VALUE=DATE:20170503",
+		"SUMMARY:all day",
+		"END:VEVENT",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n"), buf.String())
+}
+
+func TestWriteChangesICS(t *testing.T) {
+	now := when("2017-04-29T20:00:00-07:00")
+	changes := &Changes{
+		Deletes: []*Event{newSrcEvent("deleted", now)},
+		Adds:    []*Event{newSrcEvent("added", now)},
+	}
+
+	var buf bytes.Buffer
+	ok(t, changes.WriteICS(&buf))
+	s := buf.String()
+	equals(t, 2, strings.Count(s, "BEGIN:VEVENT"))
+	equals(t, 1, strings.Count(s, "STATUS:CANCELLED"))
+	assert(t, strings.Index(s, "UID:deleted") < strings.Index(s, "STATUS:CANCELLED"), "expected the delete to be cancelled")
+}
+
+func TestICSFolding(t *testing.T) {
+	iw := newICSWriter(nil)
+	var buf bytes.Buffer
+	iw.w.Reset(&buf)
+
+	long := "DESCRIPTION:" + strings.Repeat("é", 100)
+	iw.line(long)
+	ok(t, iw.w.Flush())
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n")
+	assert(t, len(lines) > 1, "expected folding, got %q", buf.String())
+	var unfolded string
+	for i, l := range lines {
+		assert(t, len(l) <= icsMaxLine, "line %d too long: %d", i, len(l))
+		if i > 0 {
+			assert(t, strings.HasPrefix(l, " "), "continuation %d lacks a space", i)
+			l = l[1:]
+		}
+		unfolded += l
+	}
+	equals(t, long, unfolded)
+}
+
+func TestReadICS(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("no timezone data: %v", err)
+	}
+	ics := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"BEGIN:VEVENT",
+		"UID:zoned",
+		"DTSTART;TZID=America/New_York:20170501T190000",
+		"DURATION:PT1H30M",
+		"SUMMARY:zoned",
+		"LOCATION:the park\\, north end",
+		"DESCRIPTION:a long description that goes on and on and was folded by",
+		"  the writer",
+		"BEGIN:VALARM",
+		"ACTION:DISPLAY",
+		"DESCRIPTION:not the event description",
+		"END:VALARM",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:day",
+		"DTSTART;VALUE=DATE:20170502",
+		"SUMMARY:all day",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:gone",
+		"DTSTART:20170503T020000Z",
+		"STATUS:CANCELLED",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:zoned",
+		"RECURRENCE-ID;TZID=America/New_York:20170508T190000",
+		"DTSTART;TZID=America/New_York:20170508T200000",
+		"END:VEVENT",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n")
+
+	events, err := ReadICS(strings.NewReader(ics))
+	ok(t, err)
+	equals(t, 2, len(events))
+
+	zoned := events[0]
+	equals(t, "zoned", zoned.SrcID)
+	equals(t, "zoned", zoned.Title)
+	equals(t, "the park, north end", zoned.Where)
+	equals(t, "a long description that goes on and on and was folded by the writer", zoned.Description)
+	equals(t, time.Date(2017, 5, 1, 19, 0, 0, 0, ny), zoned.Start)
+	equals(t, 90*time.Minute, zoned.End.Sub(zoned.Start))
+	assert(t, !zoned.AllDay, "expected a timed event")
+
+	day := events[1]
+	assert(t, day.AllDay, "expected an all day event")
+	equals(t, "2017-05-02", day.Start.Format(dateLayout))
+	equals(t, "2017-05-03", day.End.Format(dateLayout))
+}
+
+func TestICSRoundTrip(t *testing.T) {
+	timed := newSrcEvent("timed", when("2017-05-01T19:00:00-07:00"))
+	timed.Description = "line one\nline two; with, punctuation \\ and a backslash"
+	allDay := &Event{
+		Title:  "all day",
+		Start:  when("2017-05-02T00:00:00-07:00"),
+		End:    when("2017-05-03T00:00:00-07:00"),
+		SrcID:  "allDay",
+		AllDay: true,
+	}
+
+	var buf bytes.Buffer
+	ok(t, WriteICS(&buf, []*Event{timed, allDay}))
+	events, err := ReadICS(&buf)
+	ok(t, err)
+	equals(t, 2, len(events))
+	assert(t, timed.equal(events[0]), "got %+v, want %+v", events[0], timed)
+	equals(t, timed.Description, events[0].Description)
+	assert(t, allDay.equal(events[1]), "got %+v, want %+v", events[1], allDay)
+}
+
+func TestReadICSErrors(t *testing.T) {
+	for _, ics := range []string{
+		"BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART:20170503T020000Z\nEND:VEVENT\nEND:VCALENDAR\n",
+		"BEGIN:VCALENDAR\nBEGIN:VEVENT\nUID:x\nEND:VEVENT\nEND:VCALENDAR\n",
+		"BEGIN:VCALENDAR\nBEGIN:VEVENT\nUID:x\nDTSTART;TZID=Nowhere/Special:20170503T020000\nEND:VEVENT\nEND:VCALENDAR\n",
+		"BEGIN:VCALENDAR\nBEGIN:VEVENT\nUID:x\nDTSTART:20170503T020000Z\nDURATION:P1X\nEND:VEVENT\nEND:VCALENDAR\n",
+		"BEGIN:VCALENDAR\nBEGIN:VEVENT\nUID:x\nEND:VCALENDAR\n",
+		"BEGIN:VCALENDAR\nnot a property\nEND:VCALENDAR\n",
+	} {
+		_, err := ReadICS(strings.NewReader(ics))
+		assert(t, err != nil, "expected an error for %q", ics)
+	}
+}
+
+func TestParseICSDuration(t *testing.T) {
+	for s, want := range map[string]time.Duration{
+		"PT1H30M": 90 * time.Minute,
+		"P1D":     24 * time.Hour,
+		"P1W":     7 * 24 * time.Hour,
+		"P1DT2S":  24*time.Hour + 2*time.Second,
+		"-PT15M":  -15 * time.Minute,
+	} {
+		got, err := parseICSDuration(s)
+		ok(t, err)
+		equals(t, want, got)
+	}
+}
@@ -0,0 +1,147 @@
+package calsync
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	calendar "google.golang.org/api/calendar/v3"
+	"google.golang.org/api/googleapi"
+
+	"golang.org/x/net/context"
+)
+
+// syncState is what Incremental persists between syncs: the token to
+// pass on the next list call, and every scoped event we know about,
+// keyed by calendar event id.
+//
+// The calendar api doesn't allow a sync token to be combined with the
+// private extended property and time filters that a full fetch uses,
+// so the state covers the whole calendar and we filter client side.
+type syncState struct {
+	Token  string                     `json:"token"`
+	Events map[string]*calendar.Event `json:"events"`
+}
+
+func (c cal) syncStateKey() string {
+	return fmt.Sprintf("calsync/%s/%s/sync", c.calID, c.scope)
+}
+
+func (c cal) fetchIncremental(ctx context.Context, now time.Time) ([]*Event, error) {
+	st, err := c.loadSyncState()
+	if err != nil {
+		return nil, err
+	}
+
+	if st.Token != "" {
+		err = c.listInto(ctx, st)
+		if isGone(err) {
+			// The token expired.  Start over with a full listing.
+			st = &syncState{}
+			err = nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to retrieve google calendar changes: %v", err)
+		}
+	}
+	if st.Token == "" {
+		st.Events = map[string]*calendar.Event{}
+		if err = c.listInto(ctx, st); err != nil {
+			return nil, fmt.Errorf("unable to retrieve google calendar events: %v", err)
+		}
+	}
+
+	if err = c.saveSyncState(st); err != nil {
+		return nil, err
+	}
+	return st.upcoming(now, c)
+}
+
+// listInto lists events changed since st.Token, or every event if
+// there is no token, and folds them into st.
+func (c cal) listInto(ctx context.Context, st *syncState) error {
+	call := c.withFields(c.svc.Events.List(c.calID)).SingleEvents(true)
+	if st.Token != "" {
+		call = call.SyncToken(st.Token)
+	}
+	var token string
+	err := call.Pages(ctx, func(page *calendar.Events) error {
+		st.apply(page.Items, c.scope)
+		if page.NextSyncToken != "" {
+			token = page.NextSyncToken
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	st.Token = token
+	return nil
+}
+
+// apply folds listed events into the state.  Events that were
+// cancelled, or that don't belong to scope (any more), are dropped.
+func (st *syncState) apply(items []*calendar.Event, scope string) {
+	for _, item := range items {
+		var props map[string]string
+		if item.ExtendedProperties != nil {
+			props = item.ExtendedProperties.Private
+		}
+		if item.Status == "cancelled" || props[scope] != "True" {
+			delete(st.Events, item.Id)
+			continue
+		}
+		st.Events[item.Id] = item
+	}
+}
+
+// upcoming returns the events in st which end after now, mirroring the
+// TimeMin filter of a full fetch.
+func (st *syncState) upcoming(now time.Time, c cal) ([]*Event, error) {
+	var events []*Event
+	for _, each := range st.Events {
+		ev, err := c.parseEvent(each)
+		if err != nil {
+			return nil, fmt.Errorf("parseEvent %q, %v", each.Summary, err)
+		}
+		if !ev.End.After(now) {
+			continue
+		}
+		events = append(events, ev)
+	}
+	return events, nil
+}
+
+func (c cal) loadSyncState() (*syncState, error) {
+	st := &syncState{}
+	b, err := c.state.Get(c.syncStateKey())
+	if err != nil {
+		return nil, fmt.Errorf("loading sync state: %v", err)
+	}
+	if b == nil {
+		return st, nil
+	}
+	if err = json.Unmarshal(b, st); err != nil {
+		return nil, fmt.Errorf("decoding sync state: %v", err)
+	}
+	return st, nil
+}
+
+func (c cal) saveSyncState(st *syncState) error {
+	b, err := json.Marshal(st)
+	if err != nil {
+		return fmt.Errorf("encoding sync state: %v", err)
+	}
+	if err = c.state.Put(c.syncStateKey(), b); err != nil {
+		return fmt.Errorf("saving sync state: %v", err)
+	}
+	return nil
+}
+
+// isGone reports whether err is a 410 Gone, which google calendar
+// returns for expired sync tokens and for events already deleted.
+func isGone(err error) bool {
+	e, ok := err.(*googleapi.Error)
+	return ok && e.Code == http.StatusGone
+}
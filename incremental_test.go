@@ -0,0 +1,60 @@
+package calsync
+
+import (
+	"testing"
+	"time"
+
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+func TestSyncStateApply(t *testing.T) {
+	now := when("2017-04-29T20:00:00-07:00")
+	c := cal{scope: "test"}
+
+	kept := newSrcEvent("kept", now.Add(time.Hour))
+	cancelled := newSrcEvent("cancelled", now.Add(time.Hour))
+	past := newSrcEvent("past", now.Add(-2*time.Hour))
+
+	st := &syncState{Events: map[string]*calendar.Event{}}
+	st.apply([]*calendar.Event{
+		testItem(c, "kept", kept),
+		testItem(c, "cancelled", cancelled),
+		testItem(c, "past", past),
+		{Id: "unscoped", Summary: "not ours"},
+	}, c.scope)
+	equals(t, 3, len(st.Events))
+
+	gone := testItem(c, "cancelled", cancelled)
+	gone.Status = "cancelled"
+	st.apply([]*calendar.Event{gone}, c.scope)
+	equals(t, 2, len(st.Events))
+
+	events, err := st.upcoming(now, c)
+	ok(t, err)
+	equals(t, 1, len(events))
+	equals(t, "kept", events[0].calEventID)
+	equals(t, kept.SrcID, events[0].SrcID)
+}
+
+func TestSyncStateRoundTrip(t *testing.T) {
+	c := cal{scope: "test", calID: "primary", state: NewMemoryStore()}
+
+	st, err := c.loadSyncState()
+	ok(t, err)
+	equals(t, "", st.Token)
+
+	st.Token = "token"
+	st.Events = map[string]*calendar.Event{"id": {Id: "id"}}
+	ok(t, c.saveSyncState(st))
+
+	st, err = c.loadSyncState()
+	ok(t, err)
+	equals(t, "token", st.Token)
+	equals(t, "id", st.Events["id"].Id)
+}
+
+func testItem(c cal, id string, ev *Event) *calendar.Event {
+	item := c.makeCalEvent(ev)
+	item.Id = id
+	return item
+}
@@ -0,0 +1,232 @@
+package calsync
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// journal is the plan that Journal keeps in its store while it is
+// being applied.
+type journal struct {
+	Started time.Time  `json:"started"`
+	Deletes []resumeOp `json:"deletes,omitempty"`
+	Updates []resumeOp `json:"updates,omitempty"`
+	Adds    []resumeOp `json:"adds,omitempty"`
+}
+
+// journalProgress counts the operations of each kind in a journal that
+// were applied.  It is kept apart from the journal, so that recording
+// each operation doesn't rewrite the whole plan.
+type journalProgress struct {
+	Deletes int `json:"deletes"`
+	Updates int `json:"updates"`
+	Adds    int `json:"adds"`
+}
+
+// Journal makes Sync, SyncAll, Apply and Purge record each plan in
+// store before applying it, along with how much of it was applied, and
+// clear it once it has been.  If a call is interrupted partway, for
+// example by a crash, a deploy or a cancelled context, the calendar is
+// left half updated, but the next Sync, SyncAll or Apply of the same
+// scope and calendar with the same store first finishes the operations
+// that remain, then carries on as usual.  The changes it returns
+// include those it finished.
+//
+// Remaining operations are reconciled against the calendar, as with
+// Apply, so an operation that was made just before the interruption,
+// but not yet recorded, is not made twice.  Those that google calendar
+// refuses for good, such as with a 400 response, are skipped, with
+// Failed results, and if they no longer reconcile, for example because
+// an event to update was deleted, none of them are finished.  Either
+// way, the call goes ahead.  With Nop, nothing is recorded or finished.
+func Journal(store StateStore) Opt {
+	return func(c *cal) {
+		c.journal = store
+	}
+}
+
+func (c cal) journalKey() string {
+	return fmt.Sprintf("calsync/%s/%s/journal", c.calID, c.scope)
+}
+
+func (c cal) journalProgressKey() string {
+	return c.journalKey() + "/progress"
+}
+
+// beginJournal records changes, which are about to be applied.
+func (c cal) beginJournal(changes *Changes) error {
+	if c.journal == nil || c.nop {
+		return nil
+	}
+	b, err := json.Marshal(&journal{
+		Started: c.now(),
+		Deletes: resumeOps(changes.Deletes),
+		Updates: resumeOps(changes.Updates),
+		Adds:    resumeOps(changes.Adds),
+	})
+	if err != nil {
+		return fmt.Errorf("encoding journal: %v", err)
+	}
+	if err = c.journal.Put(c.journalProgressKey(), nil); err != nil {
+		return fmt.Errorf("saving journal: %v", err)
+	}
+	if err = c.journal.Put(c.journalKey(), b); err != nil {
+		return fmt.Errorf("saving journal: %v", err)
+	}
+	return nil
+}
+
+// logProgress records that deletes, updates and adds operations of the
+// journaled plan were applied.
+func (c cal) logProgress(deletes, updates, adds int) error {
+	if c.journal == nil || c.nop {
+		return nil
+	}
+	b, err := json.Marshal(&journalProgress{Deletes: deletes, Updates: updates, Adds: adds})
+	if err != nil {
+		return fmt.Errorf("encoding journal progress: %v", err)
+	}
+	if err = c.journal.Put(c.journalProgressKey(), b); err != nil {
+		return fmt.Errorf("saving journal progress: %v", err)
+	}
+	return nil
+}
+
+// endJournal clears the journal, once its plan has been applied.
+func (c cal) endJournal() error {
+	if c.journal == nil || c.nop {
+		return nil
+	}
+	if err := c.journal.Put(c.journalKey(), nil); err != nil {
+		return fmt.Errorf("clearing journal: %v", err)
+	}
+	if err := c.journal.Put(c.journalProgressKey(), nil); err != nil {
+		return fmt.Errorf("clearing journal: %v", err)
+	}
+	return nil
+}
+
+// unfinished returns the operations of the journaled plan that were
+// not applied, or nil if there is no journaled plan.
+func (c cal) unfinished() (*Changes, error) {
+	b, err := c.journal.Get(c.journalKey())
+	if err != nil {
+		return nil, fmt.Errorf("loading journal: %v", err)
+	}
+	if len(b) == 0 {
+		return nil, nil
+	}
+	j := &journal{}
+	if err = json.Unmarshal(b, j); err != nil {
+		return nil, fmt.Errorf("decoding journal: %v", err)
+	}
+	p := &journalProgress{}
+	if b, err = c.journal.Get(c.journalProgressKey()); err != nil {
+		return nil, fmt.Errorf("loading journal progress: %v", err)
+	}
+	if len(b) != 0 {
+		if err = json.Unmarshal(b, p); err != nil {
+			return nil, fmt.Errorf("decoding journal progress: %v", err)
+		}
+	}
+	if p.Deletes > len(j.Deletes) || p.Updates > len(j.Updates) || p.Adds > len(j.Adds) {
+		return nil, fmt.Errorf("journal progress %+v is past the end of its plan", *p)
+	}
+	return &Changes{
+		Deletes: resumeEvents(j.Deletes[p.Deletes:]),
+		Updates: resumeEvents(j.Updates[p.Updates:]),
+		Adds:    resumeEvents(j.Adds[p.Adds:]),
+	}, nil
+}
+
+// resumeJournal finishes the journaled plan that an earlier call was
+// interrupted applying, if any, returning the changes it made, which
+// are empty if there was nothing to finish.  If applying fails, it
+// returns those it made along with the error.
+//
+// Operations that google calendar refuses for good are skipped, with
+// Failed results, and a plan that no longer reconciles with the
+// calendar is discarded, so that neither is tried again before every
+// later call.  What they were for is planned afresh by the call.
+func (c cal) resumeJournal(ctx context.Context, now time.Time) (*Changes, error) {
+	if c.journal == nil || c.nop {
+		return &Changes{}, nil
+	}
+	plan, err := c.unfinished()
+	if err != nil {
+		return nil, err
+	}
+	if plan == nil {
+		return &Changes{}, nil
+	}
+	calEvents, err := c.fetch(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	if plan, err = c.reconcilePlan(plan, calEvents); err != nil {
+		// Such as an update of an event that was since deleted.
+		return &Changes{}, c.endJournal()
+	}
+	resumed := &Changes{}
+	for {
+		err = c.apply(ctx, plan)
+		resumed.merge(plan)
+		if err == nil || !permanent(err) {
+			return resumed, err
+		}
+		// apply leaves the operation that failed first in Pending.
+		plan = plan.Pending
+		switch {
+		case len(plan.Deletes) != 0:
+			plan.Deletes = plan.Deletes[1:]
+		case len(plan.Updates) != 0:
+			plan.Updates = plan.Updates[1:]
+		default:
+			plan.Adds = plan.Adds[1:]
+		}
+	}
+}
+
+// orNil returns c, or nil if it made no operations, so that calls that
+// fail after resuming a journal return the changes it made, and nil
+// changes if nothing was modified.
+func (c *Changes) orNil() *Changes {
+	if c == nil || len(c.Deletes)+len(c.Updates)+len(c.Adds) == 0 {
+		return nil
+	}
+	return c
+}
+
+// resumedOnly is orNil for the changes SyncAll resumed, by scope.
+func resumedOnly(resumed map[string]*Changes) map[string]*Changes {
+	var made map[string]*Changes
+	for scope, changes := range resumed {
+		if changes.orNil() != nil {
+			if made == nil {
+				made = map[string]*Changes{}
+			}
+			made[scope] = changes
+		}
+	}
+	return made
+}
+
+// include adds the operations in resumed, which were made first, to
+// c.
+func (c *Changes) include(resumed *Changes) {
+	c.Deletes = append(resumed.Deletes, c.Deletes...)
+	c.Updates = append(resumed.Updates, c.Updates...)
+	c.Adds = append(resumed.Adds, c.Adds...)
+	c.Undo = append(resumed.Undo, c.Undo...)
+	if c.Results == nil && len(resumed.Results) != 0 {
+		c.Results = SyncResult{}
+	}
+	for id, result := range resumed.Results {
+		if _, ok := c.Results[id]; !ok {
+			c.Results[id] = result
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package calsync
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+// crashingAudit fails once it has recorded after operations, standing
+// in for a process that dies partway through a sync.
+type crashingAudit struct {
+	after int
+}
+
+func (a *crashingAudit) WriteAudit(r *AuditRecord) error {
+	if a.after == 0 {
+		return errors.New("crashed")
+	}
+	a.after--
+	return nil
+}
+
+func TestJournalResume(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	store := NewMemoryStore()
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	a, b := newSrcEvent("a", start), newSrcEvent("b", start.Add(time.Hour))
+	_, err := Sync(ctx, s.Client(), "scope", []*Event{a, b})
+	ok(t, err)
+
+	// Delete a, update b and add c and d, crashing after adding c but
+	// before recording it.
+	changed := *b
+	changed.Title = "new title"
+	src := []*Event{&changed, newSrcEvent("c", start), newSrcEvent("d", start.Add(2*time.Hour))}
+	_, err = Sync(ctx, s.Client(), "scope", src, Journal(store), Audit(&crashingAudit{after: 2}))
+	assert(t, err != nil, "expected the sync to crash")
+	equals(t, 2, len(s.Events("primary")))
+	journaled, err := store.Get("calsync/primary/scope/journal")
+	ok(t, err)
+	assert(t, len(journaled) != 0, "expected a journal")
+
+	changes, err := Sync(ctx, s.Client(), "scope", src, Journal(store))
+	ok(t, err)
+	equals(t, 0, len(changes.Deletes))
+	equals(t, 0, len(changes.Updates))
+	equals(t, 1, len(changes.Adds))
+	equals(t, 1, len(changes.Undo))
+	equals(t, 3, len(s.Events("primary")))
+	journaled, err = store.Get("calsync/primary/scope/journal")
+	ok(t, err)
+	equals(t, 0, len(journaled))
+
+	changes, err = Sync(ctx, s.Client(), "scope", src, Journal(store))
+	ok(t, err)
+	assert(t, changes.empty(), "expected no changes, got %s", changes)
+}
+
+func TestJournalResumeFirst(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	store := NewMemoryStore()
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	a, b := newSrcEvent("a", start), newSrcEvent("b", start.Add(time.Hour))
+
+	_, err := Sync(ctx, s.Client(), "scope", []*Event{a, b}, Journal(store), Audit(&crashingAudit{after: 0}))
+	assert(t, err != nil, "expected the sync to crash")
+	// The add was made, but its record wasn't, so it is reconciled
+	// rather than added again.
+	equals(t, 1, len(s.Events("primary")))
+
+	// The interrupted plan is finished before the new source is synced.
+	changes, err := Sync(ctx, s.Client(), "scope", []*Event{b}, Journal(store))
+	ok(t, err)
+	equals(t, 1, len(changes.Deletes))
+	equals(t, 1, len(changes.Adds))
+	equals(t, 1, len(s.Events("primary")))
+	equals(t, b.Title, s.Events("primary")[0].Summary)
+}
+
+func TestJournalNop(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	store := NewMemoryStore()
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	_, err := Sync(ctx, s.Client(), "scope", []*Event{newSrcEvent("a", start)}, Journal(store), Nop())
+	ok(t, err)
+	journaled, err := store.Get("calsync/primary/scope/journal")
+	ok(t, err)
+	equals(t, 0, len(journaled))
+}
+
+// rejecting answers inserts of events whose bodies contain text with a
+// 400, as google calendar does for events it finds invalid.
+type rejecting struct {
+	base http.RoundTripper
+	text string
+}
+
+func (r *rejecting) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method == "POST" && req.Body != nil {
+		b, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(b))
+		if bytes.Contains(b, []byte(r.text)) {
+			return &http.Response{
+				StatusCode: http.StatusBadRequest,
+				Header:     http.Header{"Content-Type": {"application/json"}},
+				Body: ioutil.NopCloser(strings.NewReader(
+					`{"error": {"code": 400, "message": "Invalid", "errors": [{"reason": "invalid"}]}}`)),
+				Request: req,
+			}, nil
+		}
+	}
+	return r.base.RoundTrip(req)
+}
+
+func TestJournalSkipsRefused(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	store := NewMemoryStore()
+	client := s.Client()
+	client.Transport = &rejecting{base: client.Transport, text: "bad title"}
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	bad, good := newSrcEvent("bad", start), newSrcEvent("good", start.Add(time.Hour))
+
+	_, err := Sync(ctx, client, "scope", []*Event{bad, good}, Journal(store))
+	assert(t, err != nil, "expected the add to be refused")
+	journaled, err := store.Get("calsync/primary/scope/journal")
+	ok(t, err)
+	assert(t, len(journaled) != 0, "expected a journal")
+
+	// The refused add is skipped, rather than failing every sync.
+	other := newSrcEvent("other", start.Add(2*time.Hour))
+	changes, err := Sync(ctx, client, "scope", []*Event{good, other}, Journal(store))
+	ok(t, err)
+	equals(t, []string{bad.SrcID}, changes.Results.Failed())
+	equals(t, 2, len(s.Events("primary")))
+	journaled, err = store.Get("calsync/primary/scope/journal")
+	ok(t, err)
+	equals(t, 0, len(journaled))
+}
+
+func TestJournalDiscardsStale(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	store := NewMemoryStore()
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	a := newSrcEvent("a", start)
+	_, err := Sync(ctx, s.Client(), "scope", []*Event{a})
+	ok(t, err)
+
+	changed := *a
+	changed.Title = "new title"
+	_, err = Sync(ctx, s.Client(), "scope", []*Event{&changed}, Journal(store), Audit(&crashingAudit{after: 0}))
+	assert(t, err != nil, "expected the sync to crash")
+
+	// The event the journaled update is for is deleted, so the update
+	// can't be finished, but the sync goes ahead and adds it again.
+	ev := s.Events("primary")[0]
+	ev.Status = "cancelled"
+	_, err = s.Put("primary", ev)
+	ok(t, err)
+	changes, err := Sync(ctx, s.Client(), "scope", []*Event{&changed}, Journal(store))
+	ok(t, err)
+	equals(t, 1, len(changes.Adds))
+	equals(t, 0, len(changes.Updates))
+	equals(t, "new title", s.Events("primary")[0].Summary)
+	journaled, err := store.Get("calsync/primary/scope/journal")
+	ok(t, err)
+	equals(t, 0, len(journaled))
+}
+
+func TestJournalResumedThenFailed(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	store := NewMemoryStore()
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	a, b := newSrcEvent("a", start), newSrcEvent("b", start.Add(time.Hour))
+	_, err := Sync(ctx, s.Client(), "scope", []*Event{a, b}, Journal(store), Audit(&crashingAudit{after: 0}))
+	assert(t, err != nil, "expected the sync to crash")
+
+	// The journal is finished before the new plan is refused, and what
+	// it did is returned with the error.
+	refuse := Confirm(func(*Changes) error { return errors.New("refused") })
+	src := []*Event{a, b, newSrcEvent("c", start)}
+	changes, err := Sync(ctx, s.Client(), "scope", src, Journal(store), refuse)
+	assert(t, err != nil, "expected the plan to be refused")
+	assert(t, changes != nil, "expected the resumed changes")
+	equals(t, 1, len(changes.Adds))
+	equals(t, b.SrcID, changes.Adds[0].SrcID)
+	equals(t, 2, len(s.Events("primary")))
+
+	changes, err = Sync(ctx, s.Client(), "scope", src, Journal(store), refuse)
+	assert(t, err != nil, "expected the plan to be refused")
+	assert(t, changes == nil, "expected nil changes, got %s", changes)
+}
@@ -0,0 +1,101 @@
+package calsync
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ReadJSON reads a JSON array of events, each an object with the
+// fields of Event as it marshals to JSON, such as a dump from another
+// system, so they can be passed to Sync:
+//
+//	[{"src_id": "a1", "title": "Standup", "start": "2017-05-01T09:00:00-07:00",
+//	  "end": "2017-05-01T09:15:00-07:00"}]
+//
+// A record that isn't an object, has a field that Event doesn't, or
+// has a value of the wrong type is reported by its position in the
+// array, as are the events that Validate rejects, in which case the
+// error is a *ValidationError.
+func ReadJSON(r io.Reader) ([]*Event, error) {
+	var records []json.RawMessage
+	if err := json.NewDecoder(r).Decode(&records); err != nil {
+		if _, ok := err.(*json.UnmarshalTypeError); ok {
+			return nil, fmt.Errorf("expected an array of events: %v", err)
+		}
+		return nil, err
+	}
+	events := make([]*Event, len(records))
+	for i, record := range records {
+		ev, err := readJSONEvent(record)
+		if err != nil {
+			return nil, fmt.Errorf("event %d%s: %v", i, srcIDOf(record), err)
+		}
+		events[i] = ev
+	}
+	if err := Validate(events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func readJSONEvent(record json.RawMessage) (*Event, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(record, &fields); err != nil || fields == nil {
+		return nil, fmt.Errorf("expected an object, got %s", abbreviate(string(record), 40))
+	}
+	var unknown []string
+	for name := range fields {
+		if !eventJSONFields[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) != 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unknown field(s) %q", unknown)
+	}
+	ev := &Event{}
+	if err := json.Unmarshal(record, ev); err != nil {
+		return nil, err
+	}
+	return ev, nil
+}
+
+// srcIDOf returns the SrcID of record, if it can be found, to help
+// find a bad record in a large array.
+func srcIDOf(record json.RawMessage) string {
+	var ev struct {
+		SrcID interface{} `json:"src_id"`
+	}
+	if json.Unmarshal(record, &ev) != nil {
+		return ""
+	}
+	if s, ok := ev.SrcID.(string); ok && s != "" {
+		return fmt.Sprintf(" (src_id %q)", s)
+	}
+	return ""
+}
+
+// eventJSONFields holds the names of the fields of Event in JSON.
+var eventJSONFields = func() map[string]bool {
+	names := map[string]bool{}
+	t := reflect.TypeOf(Event{})
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get("json")
+		if name := strings.Split(tag, ",")[0]; name != "" && name != "-" {
+			names[name] = true
+		}
+	}
+	return names
+}()
+
+// abbreviate returns s, cut to n runes if it is longer.
+func abbreviate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n]) + "..."
+	}
+	return s
+}
@@ -0,0 +1,76 @@
+package calsync
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+const testJSONEvents = `[
+  {"src_id": "a1", "title": "Standup", "start": "2017-05-01T09:00:00-07:00", "end": "2017-05-01T09:15:00-07:00"},
+  {"src_id": "a2", "title": "Offsite", "start": "2017-05-02T00:00:00-07:00", "end": "2017-05-03T00:00:00-07:00",
+   "all_day": true, "metadata": {"room": "7"}}
+]`
+
+func TestReadJSON(t *testing.T) {
+	events, err := ReadJSON(strings.NewReader(testJSONEvents))
+	ok(t, err)
+	equals(t, 2, len(events))
+	equals(t, "Standup", events[0].Title)
+	equals(t, when("2017-05-01T09:15:00-07:00"), events[0].End)
+	assert(t, events[1].AllDay, "expected an all day event")
+	equals(t, "7", events[1].Metadata["room"])
+
+	events, err = ReadJSON(strings.NewReader("[]"))
+	ok(t, err)
+	equals(t, 0, len(events))
+}
+
+func TestReadJSONErrors(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+	}{
+		{`{"src_id": "a1"}`, "expected an array of events"},
+		{`[{"src_id": "a1"`, "unexpected EOF"},
+		{`[{"src_id": "a1", "title": "x", "start": "2017-05-01T09:00:00Z", "end": "2017-05-01T10:00:00Z"}, 7]`,
+			"event 1: expected an object, got 7"},
+		{`[{"src_id": "a1", "titel": "x"}]`, `event 0 (src_id "a1"): unknown field(s) ["titel"]`},
+		{`[{"src_id": "a1", "start": "tomorrow"}]`, `event 0 (src_id "a1"): parsing time`},
+		{`[{"src_id": "a1", "title": 7}]`, `event 0 (src_id "a1"): json: cannot unmarshal number`},
+		{`[{"src_id": "a1", "title": "x", "start": "2017-05-01T10:00:00Z", "end": "2017-05-01T09:00:00Z"}]`,
+			`event 0 ("x"): `},
+	} {
+		_, err := ReadJSON(strings.NewReader(tc.in))
+		assert(t, err != nil && strings.Contains(err.Error(), tc.want),
+			"reading %s: got %v, want %q", tc.in, err, tc.want)
+	}
+
+	_, err := ReadJSON(strings.NewReader(`[{"title": "x", "start": "2017-05-01T09:00:00Z", "end": "2017-05-01T10:00:00Z"}]`))
+	_, isValidation := err.(*ValidationError)
+	assert(t, isValidation, "expected a *ValidationError, got %v", err)
+}
+
+func TestJSONSources(t *testing.T) {
+	ctx := context.Background()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testJSONEvents))
+	}))
+	defer srv.Close()
+	events, err := JSONFeed(nil, srv.URL).Events(ctx)
+	ok(t, err)
+	equals(t, 2, len(events))
+
+	dir, err := ioutil.TempDir("", "calsync")
+	ok(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "events.json")
+	ok(t, ioutil.WriteFile(path, []byte(`[{"src_id": "a1", "titel": "x"}]`), 0600))
+	_, err = JSONFile(path).Events(ctx)
+	assert(t, err != nil && strings.Contains(err.Error(), path), "expected an error naming the file, got %v", err)
+}
@@ -0,0 +1,204 @@
+package calsync
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Layout describes how synced descriptions are laid out in google
+// calendar.  See DescriptionLayout.
+//
+// Delimiter and Footer are text/template templates, executed with a
+// LayoutData.  They are recognized again when events are read back by
+// their text outside of actions, so those parts must not change once
+// events are synced.
+type Layout struct {
+	// Delimiter is the line separating text that calendar users add,
+	// before it, from the synced description, after it.  It must have
+	// some text outside of actions.  The default is
+	// "====================".
+	Delimiter string
+
+	// Footer, if set, is added after the synced description.
+	Footer string
+
+	// Source names the source, for the templates.
+	Source string
+}
+
+// LastSyncedFooter is the footer that LastSynced adds to descriptions.
+const LastSyncedFooter = `Last synced from {{.Source}} at {{.Synced.Format "2006-01-02 15:04 MST"}}`
+
+// LayoutData is what the templates of a Layout are executed with.
+type LayoutData struct {
+	// Source is Layout.Source.
+	Source string
+
+	// Synced is when the event was written to google calendar.
+	Synced time.Time
+}
+
+// layout is a compiled Layout.
+type layout struct {
+	source string
+
+	delimiter   *template.Template
+	delimiterRe *regexp.Regexp
+
+	footer   *template.Template
+	footerRe *regexp.Regexp
+
+	// set if the layout was made for LastSynced, rather than given with
+	// DescriptionLayout.
+	implicit bool
+}
+
+// templateAction matches the actions in a template.
+var templateAction = regexp.MustCompile(`\{\{.*?\}\}`)
+
+func compileLayout(l Layout) (*layout, error) {
+	if l.Delimiter == "" {
+		l.Delimiter = delim
+	}
+	if strings.TrimSpace(templateAction.ReplaceAllString(l.Delimiter, "")) == "" {
+		return nil, fmt.Errorf("delimiter %q has no text outside of actions", l.Delimiter)
+	}
+	if strings.Contains(l.Delimiter, "\n") {
+		return nil, fmt.Errorf("delimiter %q is more than one line", l.Delimiter)
+	}
+	c := &layout{source: l.Source}
+	var err error
+	if c.delimiter, err = template.New("delimiter").Parse(l.Delimiter); err != nil {
+		return nil, err
+	}
+	c.delimiterRe = regexp.MustCompile(`(?m)^[ \t]*` + templatePattern(l.Delimiter) + `[ \t]*$`)
+	// Catch templates that refer to missing fields now, rather than
+	// when writing events.
+	if err = c.delimiter.Execute(ioutil.Discard, &LayoutData{}); err != nil {
+		return nil, err
+	}
+	if l.Footer != "" {
+		if err = c.setFooter(l.Footer); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// setFooter makes l add the footer template text after synced
+// descriptions.
+func (l *layout) setFooter(text string) error {
+	footer, err := template.New("footer").Parse(text)
+	if err != nil {
+		return err
+	}
+	if err = footer.Execute(ioutil.Discard, &LayoutData{}); err != nil {
+		return err
+	}
+	l.footer = footer
+	l.footerRe = regexp.MustCompile(`\n[ \t]*` + templatePattern(text) + `\s*$`)
+	return nil
+}
+
+// addLastSynced adds the LastSyncedFooter to the layout c lays out
+// descriptions with, naming c.lastSynced as the source.  See
+// LastSynced.
+func (c *cal) addLastSynced() error {
+	if c.layout == nil {
+		l, err := compileLayout(Layout{})
+		if err != nil {
+			return err
+		}
+		l.implicit = true
+		c.layout = l
+	}
+	if c.layout.footer != nil {
+		return fmt.Errorf("LastSynced can't be combined with a DescriptionLayout that has a Footer")
+	}
+	c.layout.source = c.lastSynced
+	return c.layout.setFooter(LastSyncedFooter)
+}
+
+// templatePattern returns a regular expression matching what the
+// template text may produce.
+func templatePattern(text string) string {
+	var pattern string
+	for i, literal := range templateAction.Split(text, -1) {
+		if i != 0 {
+			pattern += ".*?"
+		}
+		pattern += regexp.QuoteMeta(literal)
+	}
+	return pattern
+}
+
+// canonical converts the description s, as read from google calendar,
+// into the layout the rest of the package uses, with the default
+// delimiter and without the footer.
+func (l *layout) canonical(s string) string {
+	loc := l.delimiterRe.FindStringIndex(s)
+	if loc == nil {
+		return s
+	}
+	d := &description{
+		prefix: strings.TrimSuffix(s[:loc[0]], "\n"),
+		suffix: strings.TrimPrefix(s[loc[1]:], "\n"),
+	}
+	if loc := l.delimiterRe.FindStringIndex(d.suffix); loc != nil {
+		d.trailer = strings.TrimPrefix(d.suffix[loc[1]:], "\n")
+		d.suffix = strings.TrimSuffix(d.suffix[:loc[0]], "\n")
+		d.closed = true
+	}
+	if l.footerRe != nil {
+		body := "\n" + d.suffix
+		if loc := l.footerRe.FindStringIndex(body); loc != nil {
+			d.suffix = strings.TrimPrefix(body[:loc[0]], "\n")
+		}
+	}
+	return d.String()
+}
+
+// export lays out the description of ev for writing to google
+// calendar, as of now.  If closed is set, a closing delimiter follows
+// the footer even if there is no trailer.
+func (l *layout) export(ev *Event, now time.Time, closed bool) string {
+	data := &LayoutData{Source: l.source, Synced: now}
+	line := execute(l.delimiter, data)
+	var footer string
+	if l.footer != nil {
+		footer = execute(l.footer, data)
+	}
+
+	d := parseDescription(ev.Description)
+	d.suffix = withMetadata(d.suffix, ev.Metadata, ev.DescriptionHTML)
+	prefix, trailer, sep := d.prefix, d.trailer, "\n"
+	if ev.DescriptionHTML {
+		prefix, trailer, sep = textToHTML(prefix), textToHTML(trailer), "<br>"
+		line, footer = html.EscapeString(line), textToHTML(footer)
+	}
+	s := line + sep + d.suffix
+	if prefix != "" {
+		s = prefix + sep + s
+	}
+	if footer != "" {
+		s += sep + footer
+	}
+	if closed || d.closed || d.trailer != "" {
+		s += sep + line + sep + trailer
+	}
+	return s
+}
+
+// execute executes t with data.  compileLayout checked that t executes
+// without error.
+func execute(t *template.Template, data *LayoutData) string {
+	var b bytes.Buffer
+	t.Execute(&b, data)
+	return b.String()
+}
@@ -0,0 +1,99 @@
+package calsync
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+func TestDescriptionLayout(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	ev := newSrcEvent("a", time.Now().Add(time.Hour).Truncate(time.Second))
+	layout := DescriptionLayout(Layout{
+		Delimiter: "--- {{.Source}} ---",
+		Footer:    "Synced {{.Synced.Format \"2006-01-02 15:04:05.000000000\"}}",
+		Source:    "Acme",
+	})
+
+	_, err := Sync(ctx, s.Client(), "scope", []*Event{ev}, layout)
+	ok(t, err)
+	events := s.Events("primary")
+	equals(t, 1, len(events))
+	lines := strings.Split(events[0].Description, "\n")
+	equals(t, 3, len(lines))
+	equals(t, "--- Acme ---", lines[0])
+	equals(t, "a description", lines[1])
+	assert(t, strings.HasPrefix(lines[2], "Synced "), "expected a footer, got %q", lines[2])
+
+	// Comments are kept, and the footer isn't a change.
+	events[0].Description = "my notes\n" + events[0].Description
+	_, err = s.Put("primary", events[0])
+	ok(t, err)
+	changes, err := Sync(ctx, s.Client(), "scope", []*Event{ev}, layout)
+	ok(t, err)
+	assert(t, changes.empty(), "expected no changes, got %s", changes)
+
+	ev.Description = "new description"
+	changes, err = Sync(ctx, s.Client(), "scope", []*Event{ev}, layout)
+	ok(t, err)
+	equals(t, 1, len(changes.Updates))
+	lines = strings.Split(s.Events("primary")[0].Description, "\n")
+	equals(t, 4, len(lines))
+	equals(t, []string{"my notes", "--- Acme ---", "new description"}, lines[:3])
+	assert(t, lines[3] != strings.Split(events[0].Description, "\n")[3], "expected a new footer, got %q", lines[3])
+}
+
+func TestDescriptionLayoutErrors(t *testing.T) {
+	for _, l := range []Layout{
+		{Delimiter: "{{.Source}}"},
+		{Delimiter: "a\nb"},
+		{Delimiter: "{{.Nope}} ==="},
+		{Footer: "{{"},
+	} {
+		_, err := compileLayout(l)
+		assert(t, err != nil, "expected an error for %+v", l)
+	}
+	_, err := compileLayout(Layout{})
+	ok(t, err)
+}
+
+func TestLastSynced(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	ev := newSrcEvent("a", time.Now().Add(time.Hour).Truncate(time.Second))
+
+	changes, err := Sync(ctx, s.Client(), "scope", []*Event{ev}, LastSynced("Acme"))
+	ok(t, err)
+	equals(t, []string{`LastSynced("Acme")`}, changes.Manifest.Options)
+	lines := strings.Split(s.Events("primary")[0].Description, "\n")
+	equals(t, 3, len(lines))
+	equals(t, []string{delim, "a description"}, lines[:2])
+	assert(t, strings.HasPrefix(lines[2], "Last synced from Acme at "), "expected a footer, got %q", lines[2])
+
+	changes, err = Sync(ctx, s.Client(), "scope", []*Event{ev}, LastSynced("Acme"))
+	ok(t, err)
+	assert(t, changes.empty(), "expected no changes, got %s", changes)
+	fetched, err := Fetch(ctx, s.Client(), "scope", LastSynced("Acme"))
+	ok(t, err)
+	equals(t, delim+"\na description", fetched[0].Description)
+
+	// It combines with a layout without a footer.
+	layout := DescriptionLayout(Layout{Delimiter: "--- {{.Source}} ---"})
+	_, err = Sync(ctx, s.Client(), "other", []*Event{ev}, layout, LastSynced("Acme"))
+	ok(t, err)
+	fetched, err = Fetch(ctx, s.Client(), "other")
+	ok(t, err)
+	lines = strings.Split(fetched[0].raw.Description, "\n")
+	equals(t, []string{"--- Acme ---", "a description"}, lines[:2])
+	assert(t, strings.HasPrefix(lines[2], "Last synced from Acme at "), "expected a footer, got %q", lines[2])
+
+	_, err = Sync(ctx, s.Client(), "scope", []*Event{ev}, DescriptionLayout(Layout{Footer: "x"}), LastSynced("Acme"))
+	assert(t, err != nil, "expected an error for two footers")
+	_, err = Sync(ctx, s.Client(), "scope", []*Event{ev}, LastSynced(""))
+	assert(t, err != nil, "expected an error for an empty source")
+}
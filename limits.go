@@ -0,0 +1,36 @@
+package calsync
+
+import "fmt"
+
+// DeleteLimitError is returned by Sync, without modifying anything,
+// when the plan would delete more events than MaxDeletes or
+// MaxDeleteFraction allow.  This usually means the source failed and
+// returned too few events.
+type DeleteLimitError struct {
+	// Deletes is how many events the plan would delete.
+	Deletes int
+
+	// Synced is how many upcoming events the scope owns.
+	Synced int
+
+	// Limit describes the limit that was exceeded.
+	Limit string
+}
+
+func (e *DeleteLimitError) Error() string {
+	return fmt.Sprintf("refusing to delete %d of %d synced events, more than %s; check the source, or raise the limit if the deletes are intended",
+		e.Deletes, e.Synced, e.Limit)
+}
+
+// checkDeletes returns a *DeleteLimitError if changes deletes more of
+// the synced events than p allows.
+func (p planner) checkDeletes(changes *Changes, synced int) error {
+	deletes := len(changes.Deletes)
+	if p.limitDeletes && deletes > p.maxDeletes {
+		return &DeleteLimitError{deletes, synced, fmt.Sprintf("the limit of %d", p.maxDeletes)}
+	}
+	if p.limitDeleteFraction && synced > 0 && float64(deletes) > p.maxDeleteFraction*float64(synced) {
+		return &DeleteLimitError{deletes, synced, fmt.Sprintf("the limit of %g%%", p.maxDeleteFraction*100)}
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package calsync
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestDeleteLimits(t *testing.T) {
+	now := when("2017-04-29T20:00:00-07:00")
+	var calEvents, srcEvents []*Event
+	for i := 0; i < 10; i++ {
+		ev := newSrcEvent(fmt.Sprint(i), now.Add(time.Hour))
+		calEvents = append(calEvents, syncedCalEvent(ev))
+		srcEvents = append(srcEvents, ev)
+	}
+
+	for _, tc := range []struct {
+		p       planner
+		src     []*Event
+		wantErr bool
+	}{
+		{planner{}, nil, false},
+		{planner{limitDeletes: true, maxDeletes: 3}, srcEvents[3:], false},
+		{planner{limitDeletes: true, maxDeletes: 3}, srcEvents[4:], true},
+		{planner{limitDeletes: true}, srcEvents[1:], true},
+		{planner{limitDeleteFraction: true, maxDeleteFraction: 0.5}, srcEvents[5:], false},
+		{planner{limitDeleteFraction: true, maxDeleteFraction: 0.5}, srcEvents[6:], true},
+		{planner{limitDeleteFraction: true, maxDeleteFraction: 0.5}, nil, true},
+	} {
+		_, err := tc.p.getOperations(now, calEvents, tc.src)
+		_, isLimit := err.(*DeleteLimitError)
+		assert(t, tc.wantErr == isLimit, "%+v with %d source events: got %v", tc.p, len(tc.src), err)
+	}
+
+	// Nothing synced yet, so nothing to protect.
+	_, err := planner{limitDeleteFraction: true}.getOperations(now, nil, srcEvents)
+	ok(t, err)
+}
@@ -0,0 +1,231 @@
+package calsync
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	calendar "google.golang.org/api/calendar/v3"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	// LockProp is the private extended property that marks the lock
+	// events of CalendarLock.  It is set to the scope they lock.
+	LockProp = "calsyncLock"
+
+	// the private extended properties that say who holds a lock event,
+	// and when it expires, in RFC 3339.
+	lockHolderProp  = "calsyncLockHolder"
+	lockExpiresProp = "calsyncLockExpires"
+
+	// how long unlocking may take, since it is done after the context
+	// of the sync may have been cancelled.
+	unlockTimeout = 30 * time.Second
+)
+
+// Locker keeps syncs of the same scope from running at once, such as
+// overlapping runs of a cron job, each of which would otherwise add the
+// events that the other hasn't added yet.  See WithLock.
+type Locker interface {
+	// Lock acquires the lock for scope, failing with a *LockedError if
+	// another sync holds it, and returns a function that releases it.
+	// Locks should expire on their own, so that a sync that dies
+	// without releasing its lock doesn't block its scope forever.
+	Lock(ctx context.Context, scope string) (unlock func(), err error)
+}
+
+// LockedError is returned by syncs that didn't run because another one
+// holds the lock for their scope.
+type LockedError struct {
+	Scope string
+
+	// Holder identifies the sync holding the lock, if known, by its
+	// host name and process id.
+	Holder string
+
+	// Expires is when the lock expires, if known.
+	Expires time.Time
+}
+
+func (e *LockedError) Error() string {
+	msg := fmt.Sprintf("scope %q is locked by another sync", e.Scope)
+	if e.Holder != "" {
+		msg += fmt.Sprintf(" (%s)", e.Holder)
+	}
+	if !e.Expires.IsZero() {
+		msg += fmt.Sprintf(" until %s", e.Expires.Format(time.RFC3339))
+	}
+	return msg
+}
+
+// lock acquires the lock for c's scope, with the Locker given to
+// WithLock, or else with a lock event if CalendarLock was used.  It
+// does nothing for a dry run, which modifies nothing.
+func (c cal) lock(ctx context.Context) (unlock func(), err error) {
+	switch {
+	case c.nop:
+		return func() {}, nil
+	case c.locker != nil:
+		return c.locker.Lock(ctx, c.scope)
+	case c.lockTTL > 0:
+		return c.lockCalendar(ctx)
+	}
+	return func() {}, nil
+}
+
+// lockAll acquires the locks for each of scopes, in order, releasing
+// those it acquired if it can't acquire them all.
+func (c cal) lockAll(ctx context.Context, scopes []string) (unlock func(), err error) {
+	var unlocks []func()
+	unlockAll := func() {
+		for i := len(unlocks) - 1; i >= 0; i-- {
+			unlocks[i]()
+		}
+	}
+	for _, scope := range scopes {
+		sc := c
+		sc.scope = scope
+		unlock, err := sc.lock(ctx)
+		if err != nil {
+			unlockAll()
+			return nil, err
+		}
+		unlocks = append(unlocks, unlock)
+	}
+	return unlockAll, nil
+}
+
+// lockCalendar acquires the lock for c's scope by adding a lock event,
+// far in the past, to the calendar.  Two syncs that both add one agree
+// that the one google calendar created first holds the lock, and the
+// other deletes its own and fails.  Lock events that expired, because
+// their sync died, are deleted.
+func (c cal) lockCalendar(ctx context.Context) (unlock func(), err error) {
+	now := c.now()
+	locks, err := c.listLocks(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	if len(locks) != 0 {
+		return nil, locks[0].lockedError(c.scope)
+	}
+
+	holder, err := lockHolder()
+	if err != nil {
+		return nil, err
+	}
+	expires := now.Add(c.lockTTL)
+	added, err := c.svc.Events.Insert(c.calID, &calendar.Event{
+		Summary:      fmt.Sprintf("calsync lock for %s", c.scope),
+		Description:  fmt.Sprintf("Keeps two syncs of %s from running at once.  It is deleted when the sync finishes, and may be deleted by hand if none is running.", c.scope),
+		Start:        &calendar.EventDateTime{Date: "1970-01-01"},
+		End:          &calendar.EventDateTime{Date: "1970-01-02"},
+		Transparency: "transparent",
+		Visibility:   "private",
+		ExtendedProperties: &calendar.EventExtendedProperties{
+			Private: map[string]string{
+				LockProp:        c.scope,
+				lockHolderProp:  holder,
+				lockExpiresProp: expires.UTC().Format(time.RFC3339),
+			},
+		},
+	}).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("locking scope %q: %v", c.scope, err)
+	}
+	unlock = func() {
+		ctx, cancel := context.WithTimeout(context.Background(), unlockTimeout)
+		defer cancel()
+		// If this fails, the lock event expires instead.
+		_ = c.svc.Events.Delete(c.calID, added.Id).Context(ctx).Do()
+	}
+
+	if locks, err = c.listLocks(ctx, now); err != nil {
+		unlock()
+		return nil, err
+	}
+	if len(locks) != 0 && locks[0].Id != added.Id {
+		unlock()
+		return nil, locks[0].lockedError(c.scope)
+	}
+	return unlock, nil
+}
+
+// lockEvent is a lock event in the calendar.
+type lockEvent struct {
+	*calendar.Event
+	created time.Time
+	expires time.Time
+}
+
+func (l lockEvent) lockedError(scope string) *LockedError {
+	return &LockedError{
+		Scope:   scope,
+		Holder:  l.ExtendedProperties.Private[lockHolderProp],
+		Expires: l.expires,
+	}
+}
+
+type byCreated []lockEvent
+
+func (s byCreated) Len() int { return len(s) }
+func (s byCreated) Less(i, j int) bool {
+	if !s[i].created.Equal(s[j].created) {
+		return s[i].created.Before(s[j].created)
+	}
+	return s[i].Id < s[j].Id
+}
+func (s byCreated) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+
+// listLocks returns the lock events for c's scope that haven't expired
+// by now, in the order they were created, deleting those that have.
+func (c cal) listLocks(ctx context.Context, now time.Time) ([]lockEvent, error) {
+	var locks []lockEvent
+	var expired []string
+	err := c.svc.Events.List(c.calID).
+		ShowDeleted(false).
+		PrivateExtendedProperty(LockProp+"="+c.scope).
+		Pages(ctx, func(page *calendar.Events) error {
+			for _, each := range page.Items {
+				l := lockEvent{Event: each}
+				// A lock event that can't be parsed is treated as
+				// expired, so it can't block the scope forever.
+				l.expires, _ = time.Parse(time.RFC3339, each.ExtendedProperties.Private[lockExpiresProp])
+				if !l.expires.After(now) {
+					expired = append(expired, each.Id)
+					continue
+				}
+				l.created, _ = time.Parse(time.RFC3339, each.Created)
+				locks = append(locks, l)
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve locks for scope %q: %v", c.scope, err)
+	}
+	for _, id := range expired {
+		err = c.svc.Events.Delete(c.calID, id).Context(ctx).Do()
+		if err != nil && !isNotFound(err) && !isGone(err) {
+			return nil, fmt.Errorf("deleting expired lock %s: %v", id, err)
+		}
+	}
+	sort.Sort(byCreated(locks))
+	return locks, nil
+}
+
+// lockHolder returns a name for this process, unique even among
+// processes on the same host.
+func lockHolder() (string, error) {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown host"
+	}
+	id, err := randomHex(4)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s pid %d (%s)", host, os.Getpid(), id), nil
+}
@@ -0,0 +1,152 @@
+package calsync
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	calendar "google.golang.org/api/calendar/v3"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+func putLock(t *testing.T, s *calsynctest.Server, scope string, expires time.Time) string {
+	ev, err := s.Put("primary", &calendar.Event{
+		Summary: "another lock",
+		Start:   &calendar.EventDateTime{Date: "1970-01-01"},
+		End:     &calendar.EventDateTime{Date: "1970-01-02"},
+		ExtendedProperties: &calendar.EventExtendedProperties{
+			Private: map[string]string{
+				LockProp:        scope,
+				lockHolderProp:  "elsewhere",
+				lockExpiresProp: expires.UTC().Format(time.RFC3339),
+			},
+		},
+	})
+	ok(t, err)
+	return ev.Id
+}
+
+func countLocks(s *calsynctest.Server) int {
+	n := 0
+	for _, ev := range s.Events("primary") {
+		if ev.ExtendedProperties != nil && ev.ExtendedProperties.Private[LockProp] != "" {
+			n++
+		}
+	}
+	return n
+}
+
+func TestCalendarLock(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	src := []*Event{newSrcEvent("a", start)}
+
+	changes, err := Sync(ctx, s.Client(), "scope", src, CalendarLock(time.Hour))
+	ok(t, err)
+	equals(t, 1, len(changes.Adds))
+	equals(t, 0, countLocks(s))
+
+	putLock(t, s, "scope", time.Now().Add(time.Hour))
+	src = append(src, newSrcEvent("b", start))
+	_, err = Sync(ctx, s.Client(), "scope", src, CalendarLock(time.Hour))
+	locked, isLocked := err.(*LockedError)
+	assert(t, isLocked, "expected a *LockedError, got %v", err)
+	equals(t, "scope", locked.Scope)
+	equals(t, "elsewhere", locked.Holder)
+	equals(t, 2, len(s.Events("primary")))
+
+	_, err = Sync(ctx, s.Client(), "other", src, CalendarLock(time.Hour))
+	ok(t, err)
+	changes, err = Sync(ctx, s.Client(), "scope", src, CalendarLock(time.Hour), Nop())
+	ok(t, err)
+	equals(t, 1, len(changes.Adds))
+
+	s = calsynctest.NewServer()
+	putLock(t, s, "scope", time.Now().Add(-time.Minute))
+	changes, err = Sync(ctx, s.Client(), "scope", src, CalendarLock(time.Hour))
+	ok(t, err)
+	equals(t, 2, len(changes.Adds))
+	equals(t, 0, countLocks(s))
+
+	_, err = Sync(ctx, s.Client(), "scope", src, CalendarLock(0))
+	assert(t, err != nil, "expected an error for a zero ttl")
+	_, err = Sync(ctx, nil, "scope", src, WithBackend(newMemBackend()), CalendarLock(time.Hour))
+	assert(t, err != nil, "expected an error for CalendarLock with a Backend")
+}
+
+// raceTransport adds a lock event, as another sync would, just before
+// the first lock event is inserted through it.
+type raceTransport struct {
+	http.RoundTripper
+	race func()
+}
+
+func (rt *raceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method == "POST" && rt.race != nil {
+		rt.race()
+		rt.race = nil
+	}
+	return rt.RoundTripper.RoundTrip(req)
+}
+
+func TestCalendarLockRace(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	client := s.Client()
+	client.Transport = &raceTransport{RoundTripper: client.Transport, race: func() {
+		putLock(t, s, "scope", time.Now().Add(time.Hour))
+	}}
+
+	_, err := Sync(ctx, client, "scope", []*Event{newSrcEvent("a", start)}, CalendarLock(time.Hour))
+	_, isLocked := err.(*LockedError)
+	assert(t, isLocked, "expected a *LockedError, got %v", err)
+	equals(t, 1, countLocks(s))
+	equals(t, 1, len(s.Events("primary")))
+}
+
+// fakeLocker holds the lock for the scopes in held.
+type fakeLocker struct {
+	held  map[string]bool
+	locks []string
+}
+
+func (l *fakeLocker) Lock(ctx context.Context, scope string) (func(), error) {
+	if l.held[scope] {
+		return nil, &LockedError{Scope: scope}
+	}
+	l.held[scope] = true
+	l.locks = append(l.locks, scope)
+	return func() { delete(l.held, scope) }, nil
+}
+
+func TestWithLock(t *testing.T) {
+	ctx := context.Background()
+	now := when("2017-04-29T20:00:00-07:00")
+	b := newMemBackend()
+	l := &fakeLocker{held: map[string]bool{}}
+	src := []*Event{newSrcEvent("a", now.Add(time.Hour))}
+	opts := []Opt{WithBackend(b), WithNow(func() time.Time { return now }), WithLock(l)}
+
+	_, err := Sync(ctx, nil, "scope", src, opts...)
+	ok(t, err)
+	_, err = SyncAll(ctx, nil, map[string][]*Event{"b": src, "a": src}, opts...)
+	ok(t, err)
+	_, err = Sync(ctx, nil, "scope", src, append(opts, Nop())...)
+	ok(t, err)
+	equals(t, []string{"scope", "a", "b"}, l.locks)
+	equals(t, 0, len(l.held))
+
+	l.held["b"] = true
+	_, err = SyncAll(ctx, nil, map[string][]*Event{"b": src, "a": src}, opts...)
+	_, isLocked := err.(*LockedError)
+	assert(t, isLocked, "expected a *LockedError, got %v", err)
+	equals(t, map[string]bool{"b": true}, l.held)
+
+	_, err = Sync(ctx, nil, "scope", src, WithBackend(b), WithLock(nil))
+	assert(t, err != nil, "expected an error for a nil locker")
+}
@@ -0,0 +1,104 @@
+package calsync
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// maxPropValueLen is the longest value google calendar allows for
+	// an extended property.
+	maxPropValueLen = 1024
+
+	// idPartLen is the most bytes of a long SrcID stored in each part.
+	// Even encrypted, a part fits in maxPropValueLen.
+	idPartLen = 512
+
+	// MaxSrcIDLen is the longest SrcID, in bytes, that Validate accepts.
+	MaxSrcIDLen = 8192
+
+	// hashedIDPrefix starts the hash that is stored in <scope>ID in
+	// place of a SrcID too long to fit there.  The SrcID itself is then
+	// stored in parts, in <scope>ID0, <scope>ID1 and so on.
+	hashedIDPrefix = "sha256:"
+)
+
+// hashID returns the hash stored in place of srcID when it is too long.
+func hashID(srcID string) string {
+	sum := sha256.Sum256([]byte(srcID))
+	return hashedIDPrefix + hex.EncodeToString(sum[:])
+}
+
+// idPartKey returns the key of part i of a long SrcID.
+func (c cal) idPartKey(i int) string {
+	return c.idKey() + strconv.Itoa(i)
+}
+
+// idProps returns the private extended properties that store srcID.
+func (c cal) idProps(srcID string) map[string]string {
+	sealed := c.sealProp(c.idKey(), srcID)
+	if len(sealed) <= maxPropValueLen {
+		return map[string]string{c.idKey(): sealed}
+	}
+	props := map[string]string{c.idKey(): c.sealProp(c.idKey(), hashID(srcID))}
+	for i, part := range splitID(srcID) {
+		props[c.idPartKey(i)] = c.sealProp(c.idPartKey(i), part)
+	}
+	return props
+}
+
+// readSrcID returns the SrcID stored in props by idProps.
+func (c cal) readSrcID(props map[string]string) (string, error) {
+	id, err := c.openProp(c.idKey(), props[c.idKey()])
+	if err != nil {
+		return "", err
+	}
+	if _, ok := props[c.idPartKey(0)]; !ok || !strings.HasPrefix(id, hashedIDPrefix) {
+		return id, nil
+	}
+	var parts []string
+	for i := 0; ; i++ {
+		value, ok := props[c.idPartKey(i)]
+		if !ok {
+			break
+		}
+		part, err := c.openProp(c.idPartKey(i), value)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, part)
+	}
+	srcID := strings.Join(parts, "")
+	if hashID(srcID) != id {
+		return "", fmt.Errorf("the parts of %s don't match its hash", c.idKey())
+	}
+	return srcID, nil
+}
+
+// splitID splits srcID into parts of at most idPartLen bytes, without
+// splitting any character, as property values must be valid UTF-8.
+func splitID(srcID string) []string {
+	var parts []string
+	for len(srcID) > idPartLen {
+		n := idPartLen
+		for n > 0 && !utf8.RuneStart(srcID[n]) {
+			n--
+		}
+		parts = append(parts, srcID[:n])
+		srcID = srcID[n:]
+	}
+	return append(parts, srcID)
+}
+
+// sharedID returns srcID as it is stored in shared extended properties,
+// which aren't split into parts.
+func sharedID(srcID string) string {
+	if len(srcID) > maxPropValueLen {
+		return hashID(srcID)
+	}
+	return srcID
+}
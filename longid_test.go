@@ -0,0 +1,57 @@
+package calsync
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+func TestLongSrcID(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	ev := newSrcEvent("a", time.Now().Add(time.Hour).Truncate(time.Second))
+	ev.SrcID = "https://example.com/events?id=" + strings.Repeat("é", 1000)
+	short := newSrcEvent("b", ev.Start)
+
+	for _, opts := range [][]Opt{nil, {Encrypt(testKey)}} {
+		_, err := Sync(ctx, s.Client(), "scope", []*Event{ev, short}, opts...)
+		ok(t, err)
+		events := s.Events("primary")
+		equals(t, 2, len(events))
+		for _, each := range events {
+			for k, v := range each.ExtendedProperties.Private {
+				assert(t, len(v) <= maxPropValueLen, "%s is %d bytes long", k, len(v))
+				assert(t, utf8.ValidString(v), "%s is not valid UTF-8", k)
+			}
+		}
+
+		fetched, err := Fetch(ctx, s.Client(), "scope", opts...)
+		ok(t, err)
+		var ids []string
+		for _, each := range fetched {
+			ids = append(ids, each.SrcID)
+		}
+		assert(t, len(ids) == 2 && (ids[0] == ev.SrcID || ids[1] == ev.SrcID), "got SrcIDs %q", ids)
+
+		changes, err := Sync(ctx, s.Client(), "scope", []*Event{ev, short}, opts...)
+		ok(t, err)
+		assert(t, changes.empty(), "expected no changes, got %s", changes)
+
+		_, err = Purge(ctx, s.Client(), "scope", opts...)
+		ok(t, err)
+	}
+}
+
+func TestSplitID(t *testing.T) {
+	id := strings.Repeat("a", 511) + "é" + strings.Repeat("b", 600)
+	parts := splitID(id)
+	equals(t, 3, len(parts))
+	equals(t, 511, len(parts[0]))
+	equals(t, id, strings.Join(parts, ""))
+	equals(t, []string{"short"}, splitID("short"))
+}
@@ -0,0 +1,140 @@
+package calsync
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// Version is the version of this package, as recorded in manifests.
+	Version = "0.1.0"
+
+	// ManifestSchemaVersion is the version of the Manifest format.  It
+	// changes whenever fields are renamed or change meaning, so that
+	// old manifests can still be read correctly.
+	ManifestSchemaVersion = 1
+)
+
+// Manifest records the effective configuration of the Sync, Apply,
+// Purge or MigrateScope that produced a set of Changes, so that saved
+// results can be interpreted later, even after the configuration has
+// changed.
+type Manifest struct {
+	SchemaVersion int    `json:"schema_version"`
+	Version       string `json:"version"`
+
+	// Operation is "sync", "apply", "purge" or "migrate".
+	Operation string    `json:"operation"`
+	Started   time.Time `json:"started"`
+
+	// Backend is where events were synced to: "google calendar", or
+	// the Name of a Backend.
+	Backend    string `json:"backend"`
+	Scope      string `json:"scope"`
+	CalendarID string `json:"calendar_id"`
+
+	// DryRun is set if nothing was modified, because of Nop.
+	DryRun bool `json:"dry_run"`
+
+	// ConflictPolicy is the name of the ConflictPolicy in effect, or
+	// "resolver" if ResolveConflicts replaced it.
+	ConflictPolicy string `json:"conflict_policy"`
+
+	// Options lists the options that changed behavior from the
+	// defaults, such as "WallClock" or "Preserve(reminders)", in a fixed
+	// order.  Secrets, such as encryption keys, are left out.
+	Options []string `json:"options,omitempty"`
+}
+
+func (m *Manifest) String() string {
+	s := fmt.Sprintf("calsync %s %s of %q into %s %q, conflicts: %s",
+		m.Version, m.Operation, m.Scope, m.Backend, m.CalendarID, m.ConflictPolicy)
+	if m.DryRun {
+		s += ", dry run"
+	}
+	if len(m.Options) != 0 {
+		s += ", options: " + strings.Join(m.Options, ", ")
+	}
+	return s
+}
+
+func (p ConflictPolicy) String() string {
+	switch p {
+	case PreferCalendar:
+		return "PreferCalendar"
+	case PreferSource:
+		return "PreferSource"
+	case SkipConflicts:
+		return "SkipConflicts"
+	case FailOnConflict:
+		return "FailOnConflict"
+	}
+	return fmt.Sprintf("ConflictPolicy(%d)", int(p))
+}
+
+// manifest returns the Manifest for operation op, started at started.
+func (c cal) manifest(op string, started time.Time) *Manifest {
+	m := &Manifest{
+		SchemaVersion:  ManifestSchemaVersion,
+		Version:        Version,
+		Operation:      op,
+		Started:        started,
+		Backend:        "google calendar",
+		Scope:          c.scope,
+		CalendarID:     c.calID,
+		DryRun:         c.nop,
+		ConflictPolicy: c.conflictPolicy.String(),
+	}
+	if c.resolver != nil {
+		m.ConflictPolicy = "resolver"
+	}
+	if c.backend != nil {
+		m.Backend = c.backend.Name()
+	}
+	add := func(set bool, format string, args ...interface{}) {
+		if set {
+			m.Options = append(m.Options, fmt.Sprintf(format, args...))
+		}
+	}
+	add(c.calName != "", "CalendarName(%q)", c.calName)
+	add(c.sandbox, "Sandbox")
+	if c.ensure != nil && !c.sandbox {
+		add(true, "EnsureCalendar(%q, %q, %q)", c.ensure.summary, c.ensure.timeZone, c.ensure.colorID)
+	}
+	add(c.validation, "ValidateSource")
+	add(c.reportOrphans, "ReportOrphans")
+	add(c.match != MatchProperties, "MatchBy(%s)", c.match)
+	add(c.deletePolicy != HardDelete, "OnDelete(%s)", c.deletePolicy)
+	add(c.notify != NotifyDefault, "SendUpdates(%s)", c.notify)
+	add(c.route != nil, "RouteTo")
+	add(c.adoption, "Adopt")
+	add(c.resurrection, "Resurrect")
+	add(c.confirm != nil, "Confirm")
+	add(c.auditor != nil, "Audit")
+	add(c.journal != nil, "Journal")
+	add(c.reporter != nil, "Report(%T)", c.reporter)
+	add(c.mirrorer != nil, "MirrorTo(%T)", c.mirrorer)
+	add(c.state != nil, "Incremental")
+	add(c.clock != nil, "WithNow")
+	add(c.customFields, "FetchFields(%s)", strings.Join(c.extraFields, ", "))
+	add(c.resolver != nil, "ResolveConflicts(%T)", c.resolver)
+	add(c.layout != nil && !c.layout.implicit, "DescriptionLayout")
+	add(c.lastSynced != "", "LastSynced(%q)", c.lastSynced)
+	add(c.trailingComments, "TrailingComments")
+	add(c.share, "Share(%s)", strings.Join(c.shareKeys, ", "))
+	add(c.normalizeDescriptions, "NormalizeDescriptions")
+	add(c.equalFunc != nil, "EqualFunc")
+	add(c.timeTolerance > 0, "TimeTolerance(%s)", c.timeTolerance)
+	add(c.wallClock, "WallClock")
+	for _, f := range c.preserve {
+		add(true, "Preserve(%s)", f)
+	}
+	add(c.privateCopies, "PrivateCopies")
+	add(c.limitDeletes, "MaxDeletes(%d)", c.maxDeletes)
+	add(c.limitDeleteFraction, "MaxDeleteFraction(%g)", c.maxDeleteFraction)
+	add(c.maxEvents > 0, "MaxEvents(%d)", c.maxEvents)
+	add(c.freeBusy, "CheckFreeBusy")
+	add(c.sealer != nil, "Encrypt")
+	return m
+}
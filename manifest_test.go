@@ -0,0 +1,43 @@
+package calsync
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+func TestManifest(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	src := []*Event{newSrcEvent("ev", time.Now().Add(time.Hour).Truncate(time.Second))}
+
+	changes, err := Sync(ctx, s.Client(), "scope", src,
+		Nop(), Force(), WallClock(), Preserve(FieldReminders), Encrypt(testKey))
+	ok(t, err)
+	m := changes.Manifest
+	assert(t, m != nil, "expected a manifest")
+	equals(t, ManifestSchemaVersion, m.SchemaVersion)
+	equals(t, "sync", m.Operation)
+	equals(t, "primary", m.CalendarID)
+	equals(t, true, m.DryRun)
+	equals(t, "PreferSource", m.ConflictPolicy)
+	equals(t, []string{"WallClock", "Preserve(reminders)", "Encrypt"}, m.Options)
+
+	b, err := json.Marshal(m)
+	ok(t, err)
+	var read Manifest
+	ok(t, json.Unmarshal(b, &read))
+	equals(t, m.Options, read.Options)
+	assert(t, read.Started.Equal(m.Started), "got started %v, want %v", read.Started, m.Started)
+
+	applied, err := Apply(ctx, s.Client(), "scope", changes)
+	ok(t, err)
+	equals(t, "apply", applied.Manifest.Operation)
+	equals(t, false, applied.Manifest.DryRun)
+	equals(t, "PreferCalendar", applied.Manifest.ConflictPolicy)
+	equals(t, 0, len(applied.Manifest.Options))
+}
@@ -0,0 +1,66 @@
+package calsync
+
+import (
+	"fmt"
+	"sort"
+)
+
+// MaxEvents makes Sync, SyncAll, SyncChunked and Apply make at most n
+// operations, so that giant backfills can be spread across several
+// days, each within the daily quota of google calendar.  Deletes are
+// made first, then updates, then adds, each in order of start, and
+// those that don't fit are left for a later sync, and reported in
+// Changes.Overflow.  For SyncAll, n counts the operations of every
+// scope, in order of scope.
+func MaxEvents(n int) Opt {
+	return func(c *cal) {
+		if n < 1 && c.optErr == nil {
+			c.optErr = fmt.Errorf("MaxEvents: n must be at least 1, not %d", n)
+		}
+		c.maxEvents = n
+	}
+}
+
+// budget returns how many operations c may make, or -1 if there is no
+// limit.
+func (c cal) budget() int {
+	if c.maxEvents == 0 {
+		return -1
+	}
+	return c.maxEvents
+}
+
+// deferOps moves the operations of changes beyond the first n to
+// changes.Overflow, keeping deletes first, then updates, then adds,
+// each in order of start, and returns how many of n are left.  A
+// negative n means no limit.
+func deferOps(changes *Changes, n int) int {
+	if n < 0 {
+		return n
+	}
+	overflow := &Changes{}
+	for _, kind := range []struct {
+		events, over *[]*Event
+	}{
+		{&changes.Deletes, &overflow.Deletes},
+		{&changes.Updates, &overflow.Updates},
+		{&changes.Adds, &overflow.Adds},
+	} {
+		events := *kind.events
+		sort.Stable(byStart(events))
+		if len(events) > n {
+			*kind.over = events[n:]
+			*kind.events = events[:n:n]
+		}
+		n -= len(*kind.events)
+	}
+	if opCount(overflow) != 0 {
+		changes.Overflow = overflow
+	}
+	return n
+}
+
+// opCount returns how many operations changes holds.
+func opCount(changes *Changes) int {
+	return len(changes.Deletes) + len(changes.Updates) + len(changes.Adds)
+}
@@ -0,0 +1,86 @@
+package calsync
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestDeferOps(t *testing.T) {
+	start := when("2017-05-01T09:00:00-07:00")
+	a, b, c := newSrcEvent("a", start), newSrcEvent("b", start.Add(time.Hour)), newSrcEvent("c", start.Add(2*time.Hour))
+	changes := &Changes{Deletes: []*Event{b, a}, Updates: []*Event{c}, Adds: []*Event{c, a}}
+
+	equals(t, 0, deferOps(changes, 4))
+	equals(t, []*Event{a, b}, changes.Deletes)
+	equals(t, []*Event{c}, changes.Updates)
+	equals(t, []*Event{a}, changes.Adds)
+	equals(t, []*Event{c}, changes.Overflow.Adds)
+
+	changes = &Changes{Adds: []*Event{a}}
+	equals(t, 2, deferOps(changes, 3))
+	assert(t, changes.Overflow == nil, "expected no overflow, got %s", changes.Overflow)
+	equals(t, -1, deferOps(changes, -1))
+}
+
+func TestMaxEvents(t *testing.T) {
+	ctx := context.Background()
+	now := when("2017-04-29T20:00:00-07:00")
+	b := newMemBackend()
+	opts := []Opt{WithBackend(b), WithNow(func() time.Time { return now })}
+	src := hourly(4, now.Add(time.Hour))
+	_, err := Sync(ctx, nil, "scope", src, opts...)
+	ok(t, err)
+
+	src[2].Title = "new title"
+	src = append(src[1:], newSrcEvent("x", now.Add(10*time.Hour)), newSrcEvent("y", now.Add(11*time.Hour)),
+		newSrcEvent("z", now.Add(12*time.Hour)))
+	changes, err := Sync(ctx, nil, "scope", src, append(opts, MaxEvents(3))...)
+	ok(t, err)
+	equals(t, 1, len(changes.Deletes))
+	equals(t, 1, len(changes.Updates))
+	equals(t, 1, len(changes.Adds))
+	equals(t, src[3].SrcID, changes.Adds[0].SrcID)
+	equals(t, 2, len(changes.Overflow.Adds))
+	equals(t, []string{"WithNow", "MaxEvents(3)"}, changes.Manifest.Options)
+
+	changes, err = Sync(ctx, nil, "scope", src, append(opts, MaxEvents(3))...)
+	ok(t, err)
+	equals(t, 2, len(changes.Adds))
+	assert(t, changes.Overflow == nil, "expected no overflow, got %s", changes.Overflow)
+
+	all, err := SyncAll(ctx, nil, map[string][]*Event{"a": hourly(2, now.Add(time.Hour)), "b": hourly(2, now.Add(time.Hour))},
+		append(opts, MaxEvents(3))...)
+	ok(t, err)
+	equals(t, 2, len(all["a"].Adds))
+	equals(t, 1, len(all["b"].Adds))
+	equals(t, 1, len(all["b"].Overflow.Adds))
+
+	_, err = Sync(ctx, nil, "scope", src, append(opts, MaxEvents(0))...)
+	assert(t, err != nil, "expected an error for MaxEvents(0)")
+}
+
+func TestMaxEventsChunked(t *testing.T) {
+	ctx := context.Background()
+	now := when("2017-04-29T20:00:00-07:00")
+	store := NewMemoryStore()
+	opts := []Opt{WithBackend(newMemBackend()), WithNow(func() time.Time { return now }), Checkpoint(store), MaxEvents(4)}
+	src := hourly(9, now.Add(time.Hour))
+
+	changes, err := SyncChunked(ctx, nil, "scope", src, 3, opts...)
+	ok(t, err)
+	equals(t, 4, len(changes.Adds))
+	equals(t, 2, len(changes.Overflow.Adds))
+
+	changes, err = SyncChunked(ctx, nil, "scope", src, 3, opts...)
+	ok(t, err)
+	equals(t, 4, len(changes.Adds))
+	equals(t, src[4].SrcID, changes.Adds[0].SrcID)
+	equals(t, 1, len(changes.Overflow.Adds))
+
+	changes, err = SyncChunked(ctx, nil, "scope", src, 3, opts...)
+	ok(t, err)
+	equals(t, 1, len(changes.Adds))
+	assert(t, changes.Overflow == nil, "expected no overflow, got %s", changes.Overflow)
+}
@@ -0,0 +1,82 @@
+package calsync
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// metadataFence opens the block that Event.Metadata is written in, at
+// the end of the synced part of a description.  The block is closed by
+// "```".
+const metadataFence = "```metadata"
+
+// metadataBlock matches the metadata block at the end of the synced part
+// of a description.
+var metadataBlock = regexp.MustCompile("(?s)(?:^|\n\n)```metadata\n(.*?)\n?```\\s*$")
+
+// formatMetadata returns the metadata block for md, or "" if it is
+// empty.  Keys are sorted, so the block is the same every time.
+func formatMetadata(md map[string]string) string {
+	if len(md) == 0 {
+		return ""
+	}
+	lines := []string{metadataFence}
+	for _, k := range metadataKeys(md) {
+		lines = append(lines, k+": "+md[k])
+	}
+	lines = append(lines, "```")
+	return strings.Join(lines, "\n")
+}
+
+// metadataKeys returns the keys of md, sorted.
+func metadataKeys(md map[string]string) []string {
+	var keys []string
+	for k := range md {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// withMetadata returns suffix, the synced part of a description, with
+// the metadata block for md appended.  If html is set, the block is
+// converted to HTML.
+func withMetadata(suffix string, md map[string]string, html bool) string {
+	block := formatMetadata(md)
+	if block == "" {
+		return suffix
+	}
+	sep := "\n\n"
+	if html {
+		block, sep = textToHTML(block), "<br><br>"
+	}
+	if suffix == "" {
+		return block
+	}
+	return suffix + sep + block
+}
+
+// splitMetadata returns suffix, the synced part of a description read
+// from google calendar, without its metadata block, and the metadata
+// the block holds, which is nil if there is no block.
+func splitMetadata(suffix string) (string, map[string]string) {
+	m := metadataBlock.FindStringSubmatchIndex(suffix)
+	if m == nil {
+		return suffix, nil
+	}
+	md := map[string]string{}
+	for _, line := range strings.Split(suffix[m[2]:m[3]], "\n") {
+		kv := strings.SplitN(line, ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		md[kv[0]] = strings.TrimPrefix(kv[1], " ")
+	}
+	return suffix[:m[0]], md
+}
+
+// sameMetadata reports whether a and b hold the same metadata.
+func sameMetadata(a, b map[string]string) bool {
+	return formatMetadata(a) == formatMetadata(b)
+}
@@ -0,0 +1,60 @@
+package calsync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+func TestMetadata(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	ev := newSrcEvent("a", time.Now().Add(time.Hour).Truncate(time.Second))
+	ev.Metadata = map[string]string{"ticket": "ABC-123", "room": "4B"}
+
+	_, err := Sync(ctx, s.Client(), "scope", []*Event{ev})
+	ok(t, err)
+	events := s.Events("primary")
+	equals(t, 1, len(events))
+	equals(t, delim+"\na description\n\n```metadata\nroom: 4B\nticket: ABC-123\n```", events[0].Description)
+
+	fetched, err := Fetch(ctx, s.Client(), "scope")
+	ok(t, err)
+	equals(t, ev.Metadata, fetched[0].Metadata)
+	equals(t, delim+"\na description", fetched[0].Description)
+
+	changes, err := Sync(ctx, s.Client(), "scope", []*Event{ev})
+	ok(t, err)
+	assert(t, changes.empty(), "expected no changes, got %s", changes)
+
+	ev.Metadata = map[string]string{"ticket": "ABC-124"}
+	changes, err = Sync(ctx, s.Client(), "scope", []*Event{ev})
+	ok(t, err)
+	equals(t, 1, len(changes.Updates))
+	equals(t, []string{"Metadata"}, changes.UpdateDetails()[0].Fields)
+	fetched, err = Fetch(ctx, s.Client(), "scope")
+	ok(t, err)
+	equals(t, ev.Metadata, fetched[0].Metadata)
+}
+
+func TestSplitMetadata(t *testing.T) {
+	for _, tc := range []struct {
+		in, suffix string
+		md         map[string]string
+	}{
+		{"plain", "plain", nil},
+		{"```metadata\nk: v\n```", "", map[string]string{"k": "v"}},
+		{"body\n\n```metadata\nk: v: w\nnot a pair\n```\n", "body", map[string]string{"k": "v: w"}},
+		{"body ```metadata\nk: v\n```", "body ```metadata\nk: v\n```", nil},
+	} {
+		suffix, md := splitMetadata(tc.in)
+		equals(t, tc.suffix, suffix)
+		equals(t, tc.md, md)
+	}
+
+	err := Validate([]*Event{{SrcID: "a", Start: time.Now(), End: time.Now(), Metadata: map[string]string{"a:b": "c"}}})
+	assert(t, err != nil, "expected an invalid key")
+}
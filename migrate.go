@@ -0,0 +1,131 @@
+package calsync
+
+import (
+	"fmt"
+	"net/http"
+
+	calendar "google.golang.org/api/calendar/v3"
+
+	"golang.org/x/net/context"
+)
+
+// MigrateScope renames oldScope to newScope, rewriting the private
+// extended properties of every event in oldScope, past and upcoming,
+// so that later syncs with newScope take them over.  Unlike syncing
+// into newScope and purging oldScope, nothing is deleted and recreated,
+// so attendees aren't sent cancellations and invitations, and calendar
+// edits are kept.  With Nop, nothing is modified, and the returned
+// Changes report what would have been.
+//
+// Values encrypted with Encrypt need the key, and are encrypted again
+// for newScope.  A sync token kept by Incremental is not migrated; the
+// first incremental sync with newScope lists the whole calendar.
+//
+// It is an error for newScope to already own an event with the same
+// SrcID as one in oldScope.  If MigrateScope fails partway through,
+// running it again finishes the job.
+func MigrateScope(ctx context.Context, client *http.Client, oldScope, newScope string, opts ...Opt) (*Changes, error) {
+	if err := checkScope(newScope); err != nil {
+		return nil, err
+	}
+	if oldScope == newScope {
+		return nil, fmt.Errorf("scope %q can't be migrated to itself", oldScope)
+	}
+	from, err := setup(ctx, client, oldScope, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err = from.needsGoogle("MigrateScope"); err != nil {
+		return nil, err
+	}
+	started := from.now()
+	if from.match == MatchICalUID {
+		return nil, fmt.Errorf("MigrateScope doesn't support MatchBy(%s)", from.match)
+	}
+	to := *from
+	to.scope = newScope
+
+	existing, err := to.listAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	taken := map[string]bool{}
+	for _, ev := range existing {
+		srcID, err := to.readSrcID(ev.raw.ExtendedProperties.Private)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", ev, err)
+		}
+		taken[srcID] = true
+	}
+	events, err := from.listAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check every event before modifying any.
+	migrated := make([]*calendar.Event, len(events))
+	for i, ev := range events {
+		if migrated[i], err = from.migrateEvent(to, ev.raw); err != nil {
+			return nil, fmt.Errorf("%s: %v", ev, err)
+		}
+		srcID, _ := to.readSrcID(migrated[i].ExtendedProperties.Private)
+		if taken[srcID] {
+			return nil, fmt.Errorf("%s: scope %q already has an event with SrcID %q", ev, newScope, srcID)
+		}
+	}
+
+	changes := &Changes{}
+	for i, ev := range events {
+		if !from.nop {
+			_, err = from.svc.Events.Update(from.calID, ev.calEventID, migrated[i]).Context(ctx).Do()
+			if err != nil {
+				return nil, fmt.Errorf("migrating %s: %v", ev, err)
+			}
+		}
+		changes.Updates = append(changes.Updates, ev)
+	}
+	changes.Manifest = to.manifest("migrate", started)
+	return changes, nil
+}
+
+// migrateEvent returns a copy of in, with the private extended
+// properties of c's scope replaced by those of to's.
+func (c cal) migrateEvent(to cal, in *calendar.Event) (*calendar.Event, error) {
+	props := map[string]string{}
+	for k, v := range in.ExtendedProperties.Private {
+		props[k] = v
+	}
+	delete(props, c.scope)
+	props[to.scope] = "True"
+	// The hash is sealed bound to the event, the ids aren't.
+	srcID, err := c.readSrcID(props)
+	if err != nil {
+		return nil, err
+	}
+	type key struct{ from, to, srcID string }
+	keys := []key{
+		{c.idKey(), to.idKey(), ""},
+		{c.hashKey(), to.hashKey(), srcID},
+	}
+	for i := 0; props[c.idPartKey(i)] != ""; i++ {
+		keys = append(keys, key{c.idPartKey(i), to.idPartKey(i), ""})
+	}
+	for _, key := range keys {
+		value, ok := props[key.from]
+		if !ok {
+			continue
+		}
+		plain, err := c.openEventProp(key.srcID, key.from, value)
+		if err != nil {
+			return nil, err
+		}
+		delete(props, key.from)
+		props[key.to] = to.sealEventProp(key.srcID, key.to, plain)
+	}
+
+	out := *in
+	ext := *in.ExtendedProperties
+	ext.Private = props
+	out.ExtendedProperties = &ext
+	return &out, nil
+}
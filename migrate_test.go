@@ -0,0 +1,58 @@
+package calsync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+func TestMigrateScope(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	src := []*Event{newSrcEvent("a", start), newSrcEvent("b", start)}
+	_, err := Sync(ctx, s.Client(), "old", src, Encrypt(testKey))
+	ok(t, err)
+	ids := map[string]bool{}
+	for _, ev := range s.Events("primary") {
+		ids[ev.Id] = true
+	}
+
+	changes, err := MigrateScope(ctx, s.Client(), "old", "new", Encrypt(testKey), Nop())
+	ok(t, err)
+	equals(t, 2, len(changes.Updates))
+	_, ok2 := s.Events("primary")[0].ExtendedProperties.Private["old"]
+	assert(t, ok2, "expected a dry run to leave the old scope")
+
+	_, err = MigrateScope(ctx, s.Client(), "old", "new")
+	assert(t, err != nil, "expected an error without the key")
+
+	changes, err = MigrateScope(ctx, s.Client(), "old", "new", Encrypt(testKey))
+	ok(t, err)
+	equals(t, 2, len(changes.Updates))
+	for _, ev := range s.Events("primary") {
+		assert(t, ids[ev.Id], "event %s was recreated", ev.Id)
+		props := ev.ExtendedProperties.Private
+		equals(t, "True", props["new"])
+		_, hasOld := props["oldID"]
+		assert(t, !hasOld, "old properties remain: %v", props)
+	}
+
+	// The new scope takes the events over as they are.
+	changes, err = Sync(ctx, s.Client(), "new", src, Encrypt(testKey))
+	ok(t, err)
+	assert(t, changes.empty(), "expected no changes, got %s", changes)
+	changes, err = Sync(ctx, s.Client(), "old", nil, Encrypt(testKey))
+	ok(t, err)
+	assert(t, changes.empty(), "expected no changes, got %s", changes)
+
+	// Migrating back into a scope that has the same events would make
+	// duplicates.
+	_, err = Sync(ctx, s.Client(), "old", src[:1])
+	ok(t, err)
+	_, err = MigrateScope(ctx, s.Client(), "old", "new", Encrypt(testKey))
+	assert(t, err != nil, "expected an error for a duplicate SrcID")
+}
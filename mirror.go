@@ -0,0 +1,54 @@
+package calsync
+
+import "sort"
+
+// SyncedEvent is an event as it is in google calendar after a sync.
+type SyncedEvent struct {
+	CalendarID string
+	EventID    string
+	Event      *Event
+}
+
+// Mirror keeps a local copy of the upcoming events each scope owns in
+// google calendar, so that questions such as what was synced can be
+// answered without listing the calendar.  See MirrorTo, and package
+// mirror for one kept in a SQLite database.
+type Mirror interface {
+	// Mirror replaces the copy for scope with events, ordered by start
+	// time, then by SrcID.  If it returns an error, the call that
+	// synced them returns it, along with the changes it made.
+	Mirror(scope string, events []*SyncedEvent) error
+}
+
+// mirror tells c.mirrorer what the upcoming events in scope are, given
+// calEvents, as they were before changes were applied.
+func (c cal) mirror(calEvents []*Event, changes *Changes) error {
+	if c.mirrorer == nil || c.nop {
+		return nil
+	}
+	bySrcID := map[string]*SyncedEvent{}
+	for _, ev := range calEvents {
+		bySrcID[ev.SrcID] = &SyncedEvent{CalendarID: c.calendarOf(ev), EventID: ev.calEventID, Event: ev}
+	}
+	for _, ev := range changes.Deletes {
+		delete(bySrcID, ev.SrcID)
+	}
+	for _, ev := range changes.Updates {
+		bySrcID[ev.SrcID] = &SyncedEvent{CalendarID: c.calendarOf(ev), EventID: ev.calEventID, Event: ev}
+	}
+	for _, ev := range changes.Adds {
+		bySrcID[ev.SrcID] = &SyncedEvent{CalendarID: c.calendarOf(ev), EventID: changes.addedIDs[ev.SrcID], Event: ev}
+	}
+	var events []*Event
+	synced := map[*Event]*SyncedEvent{}
+	for _, s := range bySrcID {
+		events = append(events, s.Event)
+		synced[s.Event] = s
+	}
+	sort.Sort(byStart(events))
+	var mirrored []*SyncedEvent
+	for _, ev := range events {
+		mirrored = append(mirrored, synced[ev])
+	}
+	return c.mirrorer.Mirror(c.scope, mirrored)
+}
@@ -0,0 +1,136 @@
+/*
+Package mirror keeps a copy of the events calsync has synced in a
+local SQLite database, for use with calsync.MirrorTo, so that what was
+synced can be looked up, diffed and reported on without listing the
+calendar, and so that a record survives if the calendar is damaged.
+
+The database is opened by the caller, with whichever SQLite driver it
+prefers, such as github.com/mattn/go-sqlite3:
+
+	db, err := sql.Open("sqlite3", "calsync.db")
+	...
+	m, err := mirror.New(db)
+	...
+	changes, err := calsync.Sync(ctx, client, scope, events, calsync.MirrorTo(m))
+
+Events are kept in the calsync_events table, one row per scope and
+SrcID, with the event as calsync marshals it to JSON in the event
+column, and its start and end in UTC, as RFC 3339 text that sorts in
+time order:
+
+	CREATE TABLE calsync_events (
+		scope       TEXT NOT NULL,
+		src_id      TEXT NOT NULL,
+		calendar_id TEXT NOT NULL,
+		event_id    TEXT NOT NULL,
+		title       TEXT NOT NULL,
+		start       TEXT NOT NULL,
+		end         TEXT NOT NULL,
+		event       TEXT NOT NULL,
+		mirrored    TEXT NOT NULL,
+		PRIMARY KEY (scope, src_id)
+	)
+*/
+package mirror
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ginabythebay/calsync"
+)
+
+const createTable = `CREATE TABLE IF NOT EXISTS calsync_events (
+	scope       TEXT NOT NULL,
+	src_id      TEXT NOT NULL,
+	calendar_id TEXT NOT NULL,
+	event_id    TEXT NOT NULL,
+	title       TEXT NOT NULL,
+	start       TEXT NOT NULL,
+	end         TEXT NOT NULL,
+	event       TEXT NOT NULL,
+	mirrored    TEXT NOT NULL,
+	PRIMARY KEY (scope, src_id)
+)`
+
+// timeLayout is how times are stored: in UTC, to the second, so that
+// they sort in time order as text.
+const timeLayout = "2006-01-02T15:04:05Z"
+
+// SQLite is a calsync.Mirror that keeps events in a SQLite database.
+type SQLite struct {
+	db *sql.DB
+}
+
+// New returns a SQLite mirror keeping events in db, creating its table
+// if needed.
+func New(db *sql.DB) (*SQLite, error) {
+	if _, err := db.Exec(createTable); err != nil {
+		return nil, fmt.Errorf("creating calsync_events: %v", err)
+	}
+	return &SQLite{db: db}, nil
+}
+
+// Mirror implements calsync.Mirror, replacing the rows for scope in one
+// transaction.
+func (m *SQLite) Mirror(scope string, events []*calsync.SyncedEvent) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err = mirror(tx, scope, events); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("mirroring %q: %v", scope, err)
+	}
+	return tx.Commit()
+}
+
+func mirror(tx *sql.Tx, scope string, events []*calsync.SyncedEvent) error {
+	if _, err := tx.Exec(`DELETE FROM calsync_events WHERE scope = ?`, scope); err != nil {
+		return err
+	}
+	mirrored := time.Now().UTC().Format(timeLayout)
+	for _, s := range events {
+		b, err := json.Marshal(s.Event)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`INSERT INTO calsync_events
+			(scope, src_id, calendar_id, event_id, title, start, end, event, mirrored)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			scope, s.Event.SrcID, s.CalendarID, s.EventID, s.Event.Title,
+			s.Event.Start.UTC().Format(timeLayout), s.Event.End.UTC().Format(timeLayout),
+			string(b), mirrored)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Events returns the events mirrored for scope, ordered by start time,
+// then by SrcID, as they were after the last sync, for example to
+// compare against the source without listing the calendar.
+func (m *SQLite) Events(scope string) ([]*calsync.SyncedEvent, error) {
+	rows, err := m.db.Query(`SELECT calendar_id, event_id, event FROM calsync_events
+		WHERE scope = ? ORDER BY start, src_id`, scope)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var events []*calsync.SyncedEvent
+	for rows.Next() {
+		s := &calsync.SyncedEvent{Event: &calsync.Event{}}
+		var b string
+		if err = rows.Scan(&s.CalendarID, &s.EventID, &b); err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal([]byte(b), s.Event); err != nil {
+			return nil, fmt.Errorf("malformed event %s: %v", s.EventID, err)
+		}
+		events = append(events, s)
+	}
+	return events, rows.Err()
+}
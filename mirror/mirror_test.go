@@ -0,0 +1,131 @@
+package mirror
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ginabythebay/calsync"
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+// fakeDB is a database/sql driver that understands just the statements
+// SQLite runs, keeping rows in memory in the order they were inserted.
+type fakeDB struct {
+	mu   sync.Mutex
+	rows map[string][][]driver.Value // by scope
+}
+
+var fake = &fakeDB{rows: map[string][][]driver.Value{}}
+
+func init() {
+	sql.Register("mirrorfake", fake)
+}
+
+func (d *fakeDB) Open(name string) (driver.Conn, error) { return d, nil }
+func (d *fakeDB) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{d, strings.Fields(query)[0]}, nil
+}
+func (d *fakeDB) Close() error              { return nil }
+func (d *fakeDB) Begin() (driver.Tx, error) { return d, nil }
+func (d *fakeDB) Commit() error             { return nil }
+func (d *fakeDB) Rollback() error           { return nil }
+
+type fakeStmt struct {
+	d    *fakeDB
+	verb string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	switch s.verb {
+	case "CREATE":
+	case "DELETE":
+		delete(s.d.rows, args[0].(string))
+	case "INSERT":
+		scope := args[0].(string)
+		s.d.rows[scope] = append(s.d.rows[scope], args)
+	default:
+		return nil, errors.New("unsupported " + s.verb)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	return &fakeRows{rows: s.d.rows[args[0].(string)]}, nil
+}
+
+type fakeRows struct {
+	rows [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return []string{"calendar_id", "event_id", "event"} }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	row := r.rows[0]
+	r.rows = r.rows[1:]
+	dest[0], dest[1], dest[2] = row[2], row[3], row[7]
+	return nil
+}
+
+func TestMirror(t *testing.T) {
+	db, err := sql.Open("mirrorfake", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	var src []*calsync.Event
+	for _, id := range []string{"b", "a"} {
+		src = append(src, &calsync.Event{Title: id, Start: start, End: start.Add(time.Hour), SrcID: id})
+	}
+	if _, err = calsync.Sync(ctx, s.Client(), "scope", src, calsync.MirrorTo(m)); err != nil {
+		t.Fatal(err)
+	}
+	got, err := m.Events("scope")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d events, want 2", len(got))
+	}
+	for i, want := range s.Events("primary") {
+		if got[i].EventID != want.Id || got[i].CalendarID != "primary" {
+			t.Errorf("got event %d in %s/%s, want primary/%s", i, got[i].CalendarID, got[i].EventID, want.Id)
+		}
+		if got[i].Event.Title != want.Summary || !got[i].Event.Start.Equal(start) {
+			t.Errorf("got %v, want %q at %v", got[i].Event, want.Summary, start)
+		}
+	}
+
+	if _, err = calsync.Sync(ctx, s.Client(), "scope", src[:1], calsync.MirrorTo(m)); err != nil {
+		t.Fatal(err)
+	}
+	if got, err = m.Events("scope"); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Event.SrcID != "b" {
+		t.Errorf("got %v, want just b", got)
+	}
+}
@@ -0,0 +1,54 @@
+package calsync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+// memoryMirror is a Mirror that keeps the last events it was told.
+type memoryMirror map[string][]*SyncedEvent
+
+func (m memoryMirror) Mirror(scope string, events []*SyncedEvent) error {
+	m[scope] = events
+	return nil
+}
+
+func TestMirrorTo(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	m := memoryMirror{}
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	a, b := newSrcEvent("a", start), newSrcEvent("b", start.Add(time.Hour))
+	_, err := Sync(ctx, s.Client(), "scope", []*Event{a, b}, MirrorTo(m))
+	ok(t, err)
+	checkMirror := func() {
+		events := s.Events("primary")
+		equals(t, len(events), len(m["scope"]))
+		for i, ev := range events {
+			equals(t, ev.Id, m["scope"][i].EventID)
+			equals(t, "primary", m["scope"][i].CalendarID)
+			equals(t, ev.Summary, m["scope"][i].Event.Title)
+		}
+	}
+	checkMirror()
+
+	// Delete a, update b and add c.
+	changed := *b
+	changed.Title = "changed"
+	c := newSrcEvent("c", start.Add(2*time.Hour))
+	_, err = Sync(ctx, s.Client(), "scope", []*Event{&changed, c}, MirrorTo(m))
+	ok(t, err)
+	checkMirror()
+
+	_, err = Sync(ctx, s.Client(), "scope", nil, MirrorTo(m), Nop())
+	ok(t, err)
+	equals(t, 2, len(m["scope"]))
+
+	_, err = Purge(ctx, s.Client(), "scope", MirrorTo(m))
+	ok(t, err)
+	equals(t, 0, len(m["scope"]))
+}
@@ -0,0 +1,35 @@
+package calsync
+
+import "strings"
+
+// normalizeDescription returns s without HTML markup, with each line
+// trimmed of surrounding whitespace, runs of blank lines collapsed into
+// one, and no blank lines at the start or end.
+func normalizeDescription(s string) string {
+	s = htmlToText(strings.Replace(s, "\r\n", "\n", -1))
+
+	var lines []string
+	blank := true
+	for _, l := range strings.Split(s, "\n") {
+		l = strings.TrimSpace(l)
+		if l == "" && blank {
+			continue
+		}
+		blank = l == ""
+		lines = append(lines, l)
+	}
+	if blank && len(lines) != 0 {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// syncedDescription returns the synced part of ev's description, as
+// the planner compares and hashes it.
+func (p planner) syncedDescription(ev *Event) string {
+	suffix := ev.syncedDescription()
+	if p.normalizeDescriptions {
+		return normalizeDescription(suffix)
+	}
+	return suffix
+}
@@ -0,0 +1,48 @@
+package calsync
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNormalizeDescription(t *testing.T) {
+	for _, tc := range []struct{ in, want string }{
+		{"", ""},
+		{"plain", "plain"},
+		{"  line one  \r\n\n\n\nline two\n\n", "line one\n\nline two"},
+		{"line one<br>line two<br/><br />", "line one\nline two"},
+		{"<p>Bring <b>snacks</b> &amp; drinks</p><p>Room 4</p>", "Bring snacks & drinks\nRoom 4"},
+		{`<a href="https://example.com">link</a>`, "link"},
+	} {
+		equals(t, tc.want, normalizeDescription(tc.in))
+	}
+}
+
+func TestNormalizeDescriptions(t *testing.T) {
+	now := when("2017-04-29T20:00:00-07:00")
+	p := planner{normalizeDescriptions: true}
+	srcEv := newSrcEvent("ev", now.Add(time.Hour))
+	srcEv.Description = "Bring snacks & drinks\n\nRoom 4 \n"
+	calEv := testCalEvent("", "", srcEv)
+	calEv.syncedHash = p.contentHash(calEv)
+	calEv.Description = "====================<br>Bring snacks &amp; drinks<br><br>Room 4"
+
+	changes, err := p.getOperations(now, []*Event{calEv}, []*Event{srcEv})
+	ok(t, err)
+	assert(t, changes.empty(), "expected no changes, got %s", changes)
+
+	// Synced without normalizing, so the rewrite looks like an edit, and
+	// is recorded as synced.
+	calEv.syncedHash = planner{}.contentHash(testCalEvent("", "", srcEv))
+	changes, err = p.getOperations(now, []*Event{calEv}, []*Event{srcEv})
+	ok(t, err)
+	equals(t, 1, len(changes.Updates))
+	equals(t, 0, len(changes.Conflicts))
+
+	// A real change is still a change.
+	srcEv.Description = "Room 5"
+	changes, err = p.getOperations(now, []*Event{calEv}, []*Event{srcEv})
+	ok(t, err)
+	equals(t, 0, len(changes.Updates))
+	equals(t, 1, len(changes.Conflicts))
+}
@@ -0,0 +1,51 @@
+package calsync
+
+import "fmt"
+
+// Notify determines which attendees google calendar emails when Sync
+// adds, updates or deletes an event.
+type Notify int
+
+const (
+	// NotifyDefault leaves the choice to google calendar.  This is the
+	// default.
+	NotifyDefault Notify = iota
+
+	// NotifyAll emails every attendee.
+	NotifyAll
+
+	// NotifyExternalOnly emails only attendees who don't use google
+	// calendar.
+	NotifyExternalOnly
+
+	// NotifyNone emails nobody.
+	NotifyNone
+)
+
+func (n Notify) String() string {
+	switch n {
+	case NotifyDefault:
+		return "NotifyDefault"
+	case NotifyAll:
+		return "NotifyAll"
+	case NotifyExternalOnly:
+		return "NotifyExternalOnly"
+	case NotifyNone:
+		return "NotifyNone"
+	}
+	return fmt.Sprintf("Notify(%d)", int(n))
+}
+
+// sendUpdates returns the value of the sendUpdates parameter for n, or
+// "" if the parameter should be left out.
+func (n Notify) sendUpdates() string {
+	switch n {
+	case NotifyAll:
+		return "all"
+	case NotifyExternalOnly:
+		return "externalOnly"
+	case NotifyNone:
+		return "none"
+	}
+	return ""
+}
@@ -0,0 +1,69 @@
+package calsync
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+// sendUpdatesTransport records the sendUpdates parameter of each
+// request that modifies an event, by method, and sends it on to base.
+type sendUpdatesTransport struct {
+	base http.RoundTripper
+
+	mu   sync.Mutex
+	sent map[string][]string
+}
+
+func (t *sendUpdatesTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != "GET" {
+		t.mu.Lock()
+		t.sent[req.Method] = append(t.sent[req.Method], req.URL.Query().Get("sendUpdates"))
+		t.mu.Unlock()
+	}
+	return t.base.RoundTrip(req)
+}
+
+func TestSendUpdates(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	a, b := newSrcEvent("a", start), newSrcEvent("b", start)
+	_, err := Sync(ctx, s.Client(), "scope", []*Event{a, b})
+	ok(t, err)
+
+	client := s.Client()
+	transport := &sendUpdatesTransport{base: client.Transport, sent: map[string][]string{}}
+	client.Transport = transport
+	changed := *a
+	changed.Title = "changed title"
+	c := newSrcEvent("c", start)
+	changes, err := Sync(ctx, client, "scope", []*Event{&changed, c}, SendUpdates(NotifyExternalOnly))
+	ok(t, err)
+	equals(t, []string{"SendUpdates(NotifyExternalOnly)"}, changes.Manifest.Options)
+	equals(t, map[string][]string{
+		"DELETE": {"externalOnly"},
+		"PATCH":  {"externalOnly"},
+		"POST":   {"externalOnly"},
+	}, transport.sent)
+
+	// By default, the parameter is left out.
+	transport.sent = map[string][]string{}
+	_, err = Sync(ctx, client, "scope", []*Event{a}, OnDelete(Cancel))
+	ok(t, err)
+	equals(t, map[string][]string{
+		"PATCH": {"", ""},
+	}, transport.sent)
+}
+
+func TestSendUpdatesUnknown(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	_, err := Sync(ctx, s.Client(), "scope", nil, SendUpdates(Notify(7)))
+	assert(t, err != nil, "expected an error for an unknown Notify")
+}
@@ -2,11 +2,16 @@ package calsync
 
 import (
 	"fmt"
+	"net/http"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
 )
 
 func TestGetOperations(t *testing.T) {
@@ -66,6 +71,64 @@ func TestGetOperations(t *testing.T) {
 	equals(t, "newEvent title", changes.Adds[0].Title)
 }
 
+func TestReconcilePlan(t *testing.T) {
+	now := when("2017-04-29T20:00:00-07:00")
+
+	updated := newSrcEvent("updated", now.Add(time.Hour))
+	alreadyUpdated := newSrcEvent("alreadyUpdated", now.Add(time.Hour))
+	deleted := newSrcEvent("deleted", now.AddDate(0, 0, 1))
+	alreadyDeleted := newSrcEvent("alreadyDeleted", now.AddDate(0, 0, 1))
+	added := newSrcEvent("added", now.AddDate(0, 0, 2))
+	alreadyAdded := newSrcEvent("alreadyAdded", now.AddDate(0, 0, 2))
+	addedElsewhere := newSrcEvent("addedElsewhere", now.AddDate(0, 0, 2))
+	calEvents := []*Event{
+		testCalEvent("This is a comment", "", updated),
+		testCalEvent("", "", alreadyUpdated),
+		testCalEvent("", "", deleted),
+		testCalEvent("", "", alreadyAdded),
+		testCalEvent("", "This is a change", addedElsewhere),
+	}
+
+	edited := *updated
+	edited.Where = "somewhere else"
+	plan := &Changes{
+		Deletes: []*Event{{SrcID: deleted.SrcID}, {SrcID: alreadyDeleted.SrcID}},
+		Updates: []*Event{&edited, alreadyUpdated},
+		Adds:    []*Event{added, alreadyAdded, addedElsewhere},
+	}
+
+	reconciled, err := planner{}.reconcilePlan(plan, calEvents)
+	ok(t, err)
+
+	equals(t, 1, len(reconciled.Deletes))
+	equals(t, "deleted title", reconciled.Deletes[0].calEventID)
+
+	equals(t, 2, len(reconciled.Updates))
+	ev := findEvent(t, "updated title", reconciled.Updates)
+	equals(t, "updated title", ev.calEventID)
+	equals(t, "somewhere else", ev.Where)
+	assert(t, strings.HasPrefix(ev.Description, "This is a comment\n"+delim), "unexpected description %s", ev.Description)
+	ev = findEvent(t, "addedElsewhere title", reconciled.Updates)
+	equals(t, "addedElsewhere title", ev.calEventID)
+
+	equals(t, []*Event{added}, reconciled.Adds)
+
+	// Applying the reconciled plan, then reconciling again, leaves
+	// nothing to do.
+	again, err := planner{}.reconcilePlan(plan, []*Event{
+		reconciled.Updates[0],
+		reconciled.Updates[1],
+		testCalEvent("", "", alreadyUpdated),
+		testCalEvent("", "", alreadyAdded),
+		testCalEvent("", "", added),
+	})
+	ok(t, err)
+	assert(t, again.empty(), "expected nothing left to do, got %s", again)
+
+	_, err = planner{}.reconcilePlan(&Changes{Updates: []*Event{{SrcID: "missing"}}}, calEvents)
+	assert(t, err != nil, "expected an error updating a missing event")
+}
+
 func findEvent(tb testing.TB, title string, events []*Event) *Event {
 	for _, ev := range events {
 		if ev.Title == title {
@@ -131,3 +194,190 @@ func newSrcEvent(name string, start time.Time) *Event {
 		SrcID:       cat(name, "srcId"),
 	}
 }
+
+func TestChangesString(t *testing.T) {
+	now := when("2017-04-29T20:00:00-07:00")
+	var yoga []*Event
+	for i := 0; i < 3; i++ {
+		ev := newSrcEvent(fmt.Sprintf("yoga%d", i), now.AddDate(0, 0, 7*i))
+		ev.Title = "Yoga Class"
+		yoga = append(yoga, ev)
+	}
+	dinner := newSrcEvent("dinner", now.AddDate(0, 0, 1))
+	lunch := newSrcEvent("lunch", now.AddDate(0, 0, 2))
+	lunch.Title = "Yoga Class"
+
+	changes := &Changes{
+		Updates: []*Event{dinner, yoga[0], yoga[1], yoga[2]},
+		Adds:    []*Event{lunch, yoga[0]},
+	}
+	equals(t, strings.Join([]string{
+		"Update 2017/04/30: dinner title",
+		"Update 3 occurrences of 'Yoga Class'",
+		"    2017/04/29",
+		"    2017/05/06",
+		"    2017/05/13",
+		"Add 2017/05/01: Yoga Class",
+		"Add 2017/04/29: Yoga Class",
+	}, "\n"), changes.String())
+}
+
+func TestUpdateDetails(t *testing.T) {
+	now := when("2017-04-29T20:00:00-07:00")
+	srcEv := newSrcEvent("moved", now.Add(time.Hour))
+	calEv := syncedCalEvent(srcEv)
+	srcEv.Start = srcEv.Start.Add(time.Hour)
+	srcEv.Where = "elsewhere"
+
+	changes := getOperations(now, []*Event{calEv}, []*Event{srcEv})
+	equals(t, 1, len(changes.Updates))
+	details := changes.UpdateDetails()
+	equals(t, 1, len(details))
+	equals(t, calEv, details[0].Previous)
+	equals(t, changes.Updates[0], details[0].Next)
+	equals(t, []string{"Start", "Where"}, details[0].Fields)
+
+	resumed := &Changes{Updates: []*Event{srcEv}}
+	equals(t, []*Update{{Next: srcEv}}, resumed.UpdateDetails())
+}
+
+func TestGetOperationsOrder(t *testing.T) {
+	now := when("2017-04-29T20:00:00-07:00")
+	var srcEvents, want []*Event
+	for i := 9; i >= 0; i-- {
+		// Pairs of events share a start, so SrcID breaks the tie.
+		ev := newSrcEvent(fmt.Sprint(i), now.Add(time.Duration(i/2)*time.Hour))
+		srcEvents = append(srcEvents, ev)
+		want = append([]*Event{ev}, want...)
+	}
+	for i := 0; i < 5; i++ {
+		changes := getOperations(now, nil, srcEvents)
+		equals(t, want, changes.Adds)
+	}
+}
+
+func TestEqualFunc(t *testing.T) {
+	now := when("2017-04-29T20:00:00-07:00")
+	srcEv := newSrcEvent("ev", now.Add(time.Hour))
+	calEv := testCalEvent("my notes", "", srcEv)
+	calEv.syncedHash = planner{}.contentHash(calEv)
+	srcEv.Description += "  \n"
+
+	changes := getOperations(now, []*Event{calEv}, []*Event{srcEv})
+	equals(t, 1, len(changes.Updates))
+
+	var got []*Event
+	p := planner{equalFunc: func(a, b *Event) bool {
+		got = append(got, a, b)
+		a.Description = strings.TrimSpace(a.Description)
+		b.Description = strings.TrimSpace(b.Description)
+		return DefaultEqual(a, b)
+	}}
+	changes, err := p.getOperations(now, []*Event{calEv}, []*Event{srcEv})
+	ok(t, err)
+	assert(t, changes.empty(), "expected no changes, got %s", changes)
+	equals(t, 2, len(got))
+	equals(t, "ev description", got[1].Description)
+}
+
+func TestTimeTolerance(t *testing.T) {
+	now := when("2017-04-29T20:00:00-07:00")
+	p := planner{timeTolerance: 5 * time.Minute}
+	for _, tc := range []struct {
+		shift   time.Duration
+		updates int
+	}{
+		{0, 0},
+		{3 * time.Minute, 0},
+		{-5 * time.Minute, 0},
+		{6 * time.Minute, 1},
+	} {
+		srcEv := newSrcEvent("ev", now.Add(time.Hour))
+		calEv := syncedCalEvent(srcEv)
+		srcEv.Start = srcEv.Start.Add(tc.shift)
+		srcEv.End = srcEv.End.Add(tc.shift)
+		changes, err := p.getOperations(now, []*Event{calEv}, []*Event{srcEv})
+		ok(t, err)
+		equals(t, tc.updates, len(changes.Updates))
+	}
+
+	// Other changes still bring the times along.
+	srcEv := newSrcEvent("ev", now.Add(time.Hour))
+	calEv := syncedCalEvent(srcEv)
+	srcEv.Start = srcEv.Start.Add(time.Minute)
+	srcEv.Title = "renamed"
+	changes, err := p.getOperations(now, []*Event{calEv}, []*Event{srcEv})
+	ok(t, err)
+	equals(t, 1, len(changes.Updates))
+	equals(t, srcEv.Start, changes.Updates[0].Start)
+}
+
+func TestApplyTimeTolerance(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	a, b := newSrcEvent("a", start), newSrcEvent("b", start.Add(time.Hour))
+	_, err := Sync(ctx, s.Client(), "scope", []*Event{a, b})
+	ok(t, err)
+
+	// A plan made against other times, which Sync would leave alone.
+	shifted := func(ev *Event) *Event {
+		moved := *ev
+		moved.Start = ev.Start.Add(time.Minute)
+		moved.End = ev.End.Add(time.Minute)
+		return &moved
+	}
+	plan := &Changes{Updates: []*Event{shifted(a)}, Adds: []*Event{shifted(b)}}
+	changes, err := Apply(ctx, s.Client(), "scope", plan, TimeTolerance(5*time.Minute))
+	ok(t, err)
+	assert(t, changes.empty(), "expected no changes, got %s", changes)
+	assert(t, start.Equal(when(s.Events("primary")[0].Start.DateTime)), "expected the times to be left alone")
+
+	changes, err = Apply(ctx, s.Client(), "scope", plan)
+	ok(t, err)
+	equals(t, 2, len(changes.Updates))
+}
+
+func TestWithNow(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	then := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	clock := func() time.Time { return then }
+
+	// Past by the system clock, but upcoming as of then.
+	past := newSrcEvent("past", then.Add(time.Hour))
+	changes, err := Sync(ctx, s.Client(), "scope", []*Event{past}, WithNow(clock))
+	ok(t, err)
+	equals(t, 1, len(changes.Adds))
+	assert(t, changes.Manifest.Started.Equal(then), "got started %v, want %v", changes.Manifest.Started, then)
+	equals(t, []string{"WithNow"}, changes.Manifest.Options)
+
+	changes, err = Sync(ctx, s.Client(), "scope", []*Event{past}, WithNow(clock))
+	ok(t, err)
+	assert(t, changes.empty(), "expected no changes, got %s", changes)
+
+	// By the system clock, it is past, so neither synced nor fetched.
+	changes, err = Sync(ctx, s.Client(), "scope", []*Event{past})
+	ok(t, err)
+	assert(t, changes.empty(), "expected no changes, got %s", changes)
+
+	_, err = Sync(ctx, s.Client(), "scope", nil, WithNow(nil))
+	assert(t, err != nil, "expected an error for a nil clock")
+}
+
+func TestReplaySync(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	then := time.Now().Truncate(time.Second)
+	clock := WithNow(func() time.Time { return then })
+	src := []*Event{newSrcEvent("a", then.Add(time.Hour)), newSrcEvent("b", then.Add(2*time.Hour))}
+	rec := &calsynctest.Recorder{Base: s.Client().Transport}
+	recorded, err := Sync(ctx, &http.Client{Transport: rec}, "scope", src, clock)
+	ok(t, err)
+
+	r := calsynctest.NewReplayer(rec.Fixture())
+	replayed, err := Sync(ctx, r.Client(), "scope", src, clock)
+	ok(t, err)
+	equals(t, recorded.String(), replayed.String())
+	equals(t, 0, r.Remaining())
+}
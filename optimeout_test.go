@@ -0,0 +1,35 @@
+package calsync
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// hangingBackend hangs adding events until the context is done.
+type hangingBackend struct {
+	*memBackend
+}
+
+func (b hangingBackend) Add(ctx context.Context, scope string, ev *Event) (string, error) {
+	<-ctx.Done()
+	return "", ctx.Err()
+}
+
+func TestOperationTimeout(t *testing.T) {
+	ctx := context.Background()
+	now := when("2017-04-29T20:00:00-07:00")
+	b := hangingBackend{newMemBackend()}
+	src := []*Event{newSrcEvent("a", now.Add(time.Hour)), newSrcEvent("b", now.Add(2*time.Hour))}
+
+	started := time.Now()
+	changes, err := Sync(ctx, nil, "scope", src, WithBackend(b), WithNow(func() time.Time { return now }),
+		OperationTimeout(10*time.Millisecond))
+	equals(t, context.DeadlineExceeded, err)
+	assert(t, time.Since(started) < time.Second, "expected the sync to fail fast")
+	equals(t, 2, len(changes.Pending.Adds))
+
+	_, err = Sync(ctx, nil, "scope", src, WithBackend(b), OperationTimeout(0))
+	assert(t, err != nil, "expected an error for a zero timeout")
+}
@@ -0,0 +1,38 @@
+package calsync
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReportOrphans(t *testing.T) {
+	now := when("2017-04-29T20:00:00-07:00")
+	kept := newSrcEvent("kept", now.Add(time.Hour))
+	orphan := newSrcEvent("orphan", now.Add(time.Hour))
+	added := newSrcEvent("added", now.Add(2*time.Hour))
+	calEvents := []*Event{syncedCalEvent(kept), syncedCalEvent(orphan)}
+
+	changes, err := planner{reportOrphans: true, limitDeletes: true}.getOperations(now, calEvents, []*Event{kept, added})
+	ok(t, err)
+	equals(t, 0, len(changes.Deletes))
+	equals(t, []*Event{calEvents[1]}, changes.Orphans)
+	equals(t, []*Event{added}, changes.Adds)
+	equals(t, "Add 2017/04/29: added title\nOrphan 2017/04/29: orphan title", changes.String())
+}
+
+func TestReportOrphansRouted(t *testing.T) {
+	now := when("2017-04-29T20:00:00-07:00")
+	moved := newSrcEvent("moved", now.Add(time.Hour))
+	calEv := syncedCalEvent(moved)
+	calEv.calID = "old"
+	p := planner{
+		reportOrphans: true,
+		route:         func(*Event) string { return "new" },
+	}
+
+	changes, err := p.getOperations(now, []*Event{calEv}, []*Event{moved})
+	ok(t, err)
+	equals(t, 0, len(changes.Orphans))
+	equals(t, []*Event{calEv}, changes.Deletes)
+	equals(t, 1, len(changes.Adds))
+}
@@ -0,0 +1,40 @@
+package calsync
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+// Calendar events are listed a page at a time.  Events past the first
+// page used to be missed, so every sync added them again.
+func TestFetchAllPages(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	s.PageSize = 2
+	start := time.Now().Add(time.Hour).Truncate(time.Hour)
+	var src []*Event
+	for i := 0; i < 5; i++ {
+		src = append(src, newSrcEvent(fmt.Sprint(i), start.Add(time.Duration(i)*time.Hour)))
+	}
+	changes, err := Sync(ctx, s.Client(), "scope", src)
+	ok(t, err)
+	equals(t, 5, len(changes.Adds))
+
+	fetched, err := Fetch(ctx, s.Client(), "scope")
+	ok(t, err)
+	equals(t, 5, len(fetched))
+
+	changes, err = Sync(ctx, s.Client(), "scope", src)
+	ok(t, err)
+	equals(t, 0, len(changes.Adds)+len(changes.Updates)+len(changes.Deletes))
+	equals(t, 5, len(s.Events("primary")))
+
+	changes, err = Sync(ctx, s.Client(), "scope", src[:1])
+	ok(t, err)
+	equals(t, 4, len(changes.Deletes))
+}
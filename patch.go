@@ -0,0 +1,76 @@
+package calsync
+
+import calendar "google.golang.org/api/calendar/v3"
+
+// patchFor returns a patch that updates the calendar event that ev
+// updates, sending only the fields that changed, so that fields we
+// don't model, such as reminders or conferencing, are left alone.
+// full is the event as an update would write it.
+//
+// It returns nil if the update has to replace the whole event: if the
+// calendar event ev updates isn't known, as in plans from ResumePlan,
+// or if a change can't be made by a patch, which merges the fields it
+// sends into the event, and leaves alone fields it leaves empty.
+func (c cal) patchFor(ev *Event, full *calendar.Event) *calendar.Event {
+	if ev.previous == nil {
+		return nil
+	}
+	patch := &calendar.Event{ExtendedProperties: full.ExtendedProperties}
+	for _, f := range changedFields(ev.previous, ev) {
+		switch f {
+		case "Title":
+			if full.Summary == "" {
+				return nil
+			}
+			patch.Summary = full.Summary
+		case "Start", "End":
+			patch.Start, patch.End = full.Start, full.End
+		case "Where":
+			if c.preserves(FieldLocation) {
+				continue
+			}
+			if full.Location == "" {
+				return nil
+			}
+			patch.Location = full.Location
+		case "Description", "Metadata":
+			patch.Description = full.Description
+		case "Attendees":
+			if c.preserves(FieldAttendees) {
+				continue
+			}
+			if len(full.Attendees) == 0 {
+				return nil
+			}
+			patch.Attendees = full.Attendees
+		case "SourceURL", "SourceTitle":
+			if full.Source == nil {
+				return nil
+			}
+			patch.Source = full.Source
+		case "Status":
+			patch.Status = full.Status
+		case "GuestsCanModify":
+			if !full.GuestsCanModify {
+				// A patch can't clear it, as false isn't sent.
+				return nil
+			}
+			patch.GuestsCanModify = true
+		case "GuestsCanInviteOthers":
+			patch.GuestsCanInviteOthers = full.GuestsCanInviteOthers
+		case "GuestsCanSeeOtherGuests":
+			patch.GuestsCanSeeOtherGuests = full.GuestsCanSeeOtherGuests
+		case "PrivateProps":
+			for k := range ev.previous.PrivateProps {
+				if _, ok := ev.PrivateProps[k]; !ok {
+					return nil
+				}
+			}
+		default:
+			// Such as AllDay, which changes the kind of Start and
+			// End, rather than their values.
+			return nil
+		}
+	}
+	return patch
+}
@@ -0,0 +1,71 @@
+package calsync
+
+import (
+	"testing"
+	"time"
+
+	calendar "google.golang.org/api/calendar/v3"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+func TestUpdatePatches(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	ev := newSrcEvent("a", time.Now().Add(time.Hour).Truncate(time.Second))
+	_, err := Sync(ctx, s.Client(), "scope", []*Event{ev})
+	ok(t, err)
+
+	// Fields we don't model, set in google calendar.
+	calEv := s.Events("primary")[0]
+	calEv.Reminders = &calendar.EventReminders{UseDefault: false}
+	calEv.Visibility = "private"
+	_, err = s.Put("primary", calEv)
+	ok(t, err)
+
+	ev.Title = "renamed"
+	changes, err := Sync(ctx, s.Client(), "scope", []*Event{ev})
+	ok(t, err)
+	equals(t, 1, len(changes.Updates))
+	calEv = s.Events("primary")[0]
+	equals(t, "renamed", calEv.Summary)
+	equals(t, "private", calEv.Visibility)
+	assert(t, calEv.Reminders != nil, "expected reminders to be kept")
+
+	// Clearing a field takes a full update.
+	ev.Where = ""
+	_, err = Sync(ctx, s.Client(), "scope", []*Event{ev})
+	ok(t, err)
+	calEv = s.Events("primary")[0]
+	equals(t, "", calEv.Location)
+	equals(t, "", calEv.Visibility)
+	changes, err = Sync(ctx, s.Client(), "scope", []*Event{ev})
+	ok(t, err)
+	assert(t, changes.empty(), "expected no changes, got %s", changes)
+}
+
+func TestPatchFor(t *testing.T) {
+	now := when("2017-04-29T20:00:00-07:00")
+	c := cal{scope: "scope"}
+	srcEv := newSrcEvent("a", now)
+	calEv := syncedCalEvent(srcEv)
+
+	changed := *srcEv
+	changed.Start = changed.Start.Add(time.Hour)
+	update := calEv.newUpdate(&changed)
+	patch := c.patchFor(update, c.makeCalEvent(update))
+	assert(t, patch != nil, "expected a patch")
+	equals(t, "", patch.Summary)
+	assert(t, patch.Start != nil && patch.End != nil, "expected times in %+v", patch)
+	assert(t, patch.ExtendedProperties != nil, "expected properties in %+v", patch)
+
+	changed.AllDay = true
+	update = calEv.newUpdate(&changed)
+	assert(t, c.patchFor(update, c.makeCalEvent(update)) == nil, "expected no patch")
+
+	resumed := *update
+	resumed.previous = nil
+	assert(t, c.patchFor(&resumed, c.makeCalEvent(&resumed)) == nil, "expected no patch")
+}
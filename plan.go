@@ -0,0 +1,127 @@
+package calsync
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/context"
+)
+
+// PlanFileVersion is the version of the format WritePlan writes.
+const PlanFileVersion = 1
+
+// planFile is what WritePlan writes.
+type planFile struct {
+	Version  int       `json:"version"`
+	Deletes  []planOp  `json:"deletes,omitempty"`
+	Updates  []planOp  `json:"updates,omitempty"`
+	Adds     []planOp  `json:"adds,omitempty"`
+	Manifest *Manifest `json:"manifest,omitempty"`
+}
+
+// planOp is an operation in a planFile.  Etag is that of the calendar
+// event the operation was planned against, if any.
+type planOp struct {
+	resumeOp
+	Etag string `json:"etag,omitempty"`
+}
+
+func planOps(events []*Event) []planOp {
+	var ops []planOp
+	for _, op := range resumeOps(events) {
+		p := planOp{resumeOp: op}
+		if op.Event.raw != nil {
+			p.Etag = op.Event.raw.Etag
+		}
+		ops = append(ops, p)
+	}
+	return ops
+}
+
+func planEvents(ops []planOp) []*Event {
+	var events []*Event
+	for _, op := range ops {
+		ev := resumeEvents([]resumeOp{op.resumeOp})[0]
+		ev.etag = op.Etag
+		events = append(events, ev)
+	}
+	return events
+}
+
+// Plan computes the changes that Sync would make, without making them,
+// so that they can be reviewed, for example after saving them with
+// WritePlan, and then made with Apply.  It is Sync with Nop.
+func Plan(
+	ctx context.Context,
+	client *http.Client,
+	scope string,
+	srcEvents []*Event,
+	opts ...Opt) (*Changes, error) {
+	return Sync(ctx, client, scope, srcEvents, append(opts, Nop())...)
+}
+
+// WritePlan writes plan to w as indented JSON, so that it can be
+// reviewed, edited, and applied later, perhaps by another process on
+// another machine, with ReadPlan and Apply.  Along with each delete and
+// update, it records the etag of the calendar event as planned, so that
+// Apply can tell if the event changed since.
+func WritePlan(w io.Writer, plan *Changes) error {
+	b, err := json.MarshalIndent(&planFile{
+		Version:  PlanFileVersion,
+		Deletes:  planOps(plan.Deletes),
+		Updates:  planOps(plan.Updates),
+		Adds:     planOps(plan.Adds),
+		Manifest: plan.Manifest,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// ReadPlan reads a plan written by WritePlan.  When the plan is passed
+// to Apply, a delete or update of a calendar event that changed since
+// the plan was made, as told by its etag, fails with a *StalePlanError.
+func ReadPlan(r io.Reader) (*Changes, error) {
+	f := &planFile{}
+	if err := json.NewDecoder(r).Decode(f); err != nil {
+		return nil, fmt.Errorf("malformed plan: %v", err)
+	}
+	if f.Version != PlanFileVersion {
+		return nil, fmt.Errorf("plan has version %d, not %d", f.Version, PlanFileVersion)
+	}
+	return &Changes{
+		Deletes:  planEvents(f.Deletes),
+		Updates:  planEvents(f.Updates),
+		Adds:     planEvents(f.Adds),
+		Manifest: f.Manifest,
+	}, nil
+}
+
+// StalePlanError is returned by Apply when a plan read with ReadPlan
+// would delete or update calendar events that changed since the plan
+// was made.  Nothing is modified.  Planning again takes the changes
+// into account.
+type StalePlanError struct {
+	// Events holds the calendar events as they are now.
+	Events []*Event
+}
+
+func (e *StalePlanError) Error() string {
+	var names []string
+	for _, ev := range e.Events {
+		names = append(names, ev.String())
+	}
+	return fmt.Sprintf("plan is stale: %d event(s) changed since it was made: %s",
+		len(e.Events), strings.Join(names, "; "))
+}
+
+// stale reports whether calEv changed since ev, from a plan read with
+// ReadPlan, was planned against it.
+func stale(ev, calEv *Event) bool {
+	return ev.etag != "" && calEv.raw != nil && calEv.raw.Etag != ev.etag
+}
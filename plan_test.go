@@ -0,0 +1,92 @@
+package calsync
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+func TestPlanFile(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	a, b := newSrcEvent("a", start), newSrcEvent("b", start.Add(time.Hour))
+	_, err := Sync(ctx, s.Client(), "scope", []*Event{a, b})
+	ok(t, err)
+
+	changed := *a
+	changed.Title = "new title"
+	plan, err := Plan(ctx, s.Client(), "scope", []*Event{&changed, newSrcEvent("c", start)})
+	ok(t, err)
+	equals(t, 1, len(plan.Deletes))
+	equals(t, 1, len(plan.Updates))
+	equals(t, 1, len(plan.Adds))
+	equals(t, 2, len(s.Events("primary")))
+
+	var buf bytes.Buffer
+	ok(t, WritePlan(&buf, plan))
+	read, err := ReadPlan(&buf)
+	ok(t, err)
+	equals(t, "scope", read.Manifest.Scope)
+
+	_, err = Apply(ctx, s.Client(), "other", read)
+	assert(t, err != nil, "expected an error applying a plan for another scope")
+
+	_, err = Apply(ctx, s.Client(), "scope", read)
+	ok(t, err)
+	changes, err := Sync(ctx, s.Client(), "scope", []*Event{&changed, newSrcEvent("c", start)})
+	ok(t, err)
+	assert(t, changes.empty(), "expected no changes, got %s", changes)
+}
+
+func TestStalePlan(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	a := newSrcEvent("a", start)
+	_, err := Sync(ctx, s.Client(), "scope", []*Event{a})
+	ok(t, err)
+
+	changed := *a
+	changed.Title = "new title"
+	plan, err := Plan(ctx, s.Client(), "scope", []*Event{&changed, newSrcEvent("b", start)})
+	ok(t, err)
+	var buf bytes.Buffer
+	ok(t, WritePlan(&buf, plan))
+	read, err := ReadPlan(&buf)
+	ok(t, err)
+
+	// Someone edits the event after the plan was made.
+	ev := s.Events("primary")[0]
+	ev.Location = "elsewhere"
+	_, err = s.Put("primary", ev)
+	ok(t, err)
+	requests := s.Requests()
+
+	_, err = Apply(ctx, s.Client(), "scope", read)
+	stale, isStale := err.(*StalePlanError)
+	assert(t, isStale, "expected a *StalePlanError, got %v", err)
+	equals(t, 1, len(stale.Events))
+	equals(t, "elsewhere", stale.Events[0].Where)
+	equals(t, 1, len(s.Events("primary")))
+	equals(t, a.Title, s.Events("primary")[0].Summary)
+	assert(t, s.Requests() > requests, "expected Apply to list events")
+
+	// A plan that wasn't read from a file isn't checked.
+	_, err = Apply(ctx, s.Client(), "scope", plan)
+	ok(t, err)
+	equals(t, 2, len(s.Events("primary")))
+}
+
+func TestReadPlanVersion(t *testing.T) {
+	_, err := ReadPlan(strings.NewReader(`{"version": 2}`))
+	assert(t, err != nil && strings.Contains(err.Error(), "version 2"), "got %v, want a version error", err)
+
+	_, err = ReadPlan(strings.NewReader(`not json`))
+	assert(t, err != nil, "expected an error for a malformed plan")
+}
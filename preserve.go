@@ -0,0 +1,53 @@
+package calsync
+
+import calendar "google.golang.org/api/calendar/v3"
+
+// Field names a google calendar event field that Preserve can keep.
+type Field string
+
+const (
+	// FieldReminders is the event's reminder settings.
+	FieldReminders Field = "reminders"
+
+	// FieldAttendees is the event's guest list, which is written from
+	// Event.Attendees.
+	FieldAttendees Field = "attendees"
+
+	// FieldColor is the event's color.
+	FieldColor Field = "colorId"
+
+	// FieldLocation is the event's location, which is written from
+	// Event.Where.
+	FieldLocation Field = "location"
+)
+
+func (p planner) preserves(f Field) bool {
+	for _, each := range p.preserve {
+		if each == f {
+			return true
+		}
+	}
+	return false
+}
+
+// preserveFields copies the preserved fields from current, the event
+// as it is in google calendar, to out, the event we are about to write
+// over it.  current may be nil if we never read the event, in which
+// case nothing can be preserved.
+func (c cal) preserveFields(out, current *calendar.Event) {
+	if current == nil {
+		return
+	}
+	if c.preserves(FieldReminders) {
+		out.Reminders = current.Reminders
+	}
+	if c.preserves(FieldAttendees) {
+		out.Attendees = current.Attendees
+	}
+	if c.preserves(FieldColor) {
+		out.ColorId = current.ColorId
+	}
+	if c.preserves(FieldLocation) {
+		out.Location = current.Location
+	}
+}
@@ -0,0 +1,44 @@
+package calsync
+
+import (
+	"testing"
+	"time"
+
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+func TestPreserveFields(t *testing.T) {
+	now := when("2017-04-29T20:00:00-07:00")
+	c := cal{scope: "test"}
+	Preserve(FieldReminders, FieldLocation)(&c)
+
+	src := newSrcEvent("ev", now.Add(time.Hour))
+	item := c.makeCalEvent(src)
+	item.Id = "ev"
+	item.ColorId = "5"
+	item.Location = "the usual room"
+	item.Reminders = &calendar.EventReminders{
+		Overrides: []*calendar.EventReminder{{Method: "popup", Minutes: 10}},
+	}
+	calEv, err := c.parseEvent(item)
+	ok(t, err)
+
+	// The location tweak is neither a change nor an edit.
+	changes, err := c.getOperations(now, []*Event{calEv}, []*Event{src})
+	ok(t, err)
+	assert(t, changes.empty(), "expected no changes, got %s", changes)
+
+	changed := *src
+	changed.Title = "new title"
+	changes, err = c.getOperations(now, []*Event{calEv}, []*Event{&changed})
+	ok(t, err)
+	equals(t, 1, len(changes.Updates))
+
+	update := changes.Updates[0]
+	out := c.makeCalEvent(update)
+	c.preserveFields(out, update.raw)
+	equals(t, "new title", out.Summary)
+	equals(t, "the usual room", out.Location)
+	equals(t, item.Reminders, out.Reminders)
+	equals(t, "", out.ColorId)
+}
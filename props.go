@@ -0,0 +1,77 @@
+package calsync
+
+// reservedProps returns the keys of props that scopes use to track
+// events: for each scope that owns the event, and for scope, the
+// "<scope>", "<scope>ID" and "<scope>Hash" keys, and the keys of the
+// parts of a long SrcID, as well as the SandboxProp.
+func reservedProps(scope string, props map[string]string) map[string]bool {
+	reserved := map[string]bool{SandboxProp: true}
+	for _, s := range append(eventScopes(props), scope) {
+		reserved[s] = true
+		reserved[s+"ID"] = true
+		reserved[s+"Hash"] = true
+		c := cal{scope: s}
+		for i := 0; props[c.idPartKey(i)] != ""; i++ {
+			reserved[c.idPartKey(i)] = true
+		}
+	}
+	return reserved
+}
+
+// userProps returns the private extended properties in props that
+// aren't reserved, or nil if there are none.
+func userProps(scope string, props map[string]string) map[string]string {
+	reserved := reservedProps(scope, props)
+	var user map[string]string
+	for k, v := range props {
+		if reserved[k] {
+			continue
+		}
+		if user == nil {
+			user = map[string]string{}
+		}
+		user[k] = v
+	}
+	return user
+}
+
+// openUserProps returns props, the user's private extended properties
+// of the event with srcID, decrypted if Encrypt encrypted them.
+func (c cal) openUserProps(srcID string, props map[string]string) (map[string]string, error) {
+	for k, v := range props {
+		opened, err := c.openEventProp(srcID, k, v)
+		if err != nil {
+			return nil, err
+		}
+		props[k] = opened
+	}
+	return props, nil
+}
+
+// sharedProps returns the shared extended properties to write for ev,
+// or nil if there are none.
+func (c cal) sharedProps(ev *Event) map[string]string {
+	if !c.share {
+		return nil
+	}
+	shared := map[string]string{c.idKey(): sharedID(ev.SrcID)}
+	for _, k := range c.shareKeys {
+		if v, ok := ev.PrivateProps[k]; ok {
+			shared[k] = v
+		}
+	}
+	return shared
+}
+
+// sameProps reports whether a and b hold the same properties.
+func sameProps(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || v != w {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,66 @@
+package calsync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+func TestPrivateProps(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	ev := newSrcEvent("a", time.Now().Add(time.Hour).Truncate(time.Second))
+	ev.PrivateProps = map[string]string{"ticket": "ABC-123", "scope": "spoofed"}
+
+	_, err := Sync(ctx, s.Client(), "scope", []*Event{ev})
+	ok(t, err)
+	events := s.Events("primary")
+	equals(t, 1, len(events))
+	props := events[0].ExtendedProperties.Private
+	equals(t, "ABC-123", props["ticket"])
+	equals(t, "True", props["scope"])
+
+	fetched, err := Fetch(ctx, s.Client(), "scope")
+	ok(t, err)
+	equals(t, map[string]string{"ticket": "ABC-123"}, fetched[0].PrivateProps)
+
+	ev.PrivateProps = map[string]string{"ticket": "ABC-123"}
+	changes, err := Sync(ctx, s.Client(), "scope", []*Event{ev})
+	ok(t, err)
+	assert(t, changes.empty(), "expected no changes, got %s", changes)
+
+	ev.PrivateProps = nil
+	changes, err = Sync(ctx, s.Client(), "scope", []*Event{ev})
+	ok(t, err)
+	equals(t, 1, len(changes.Updates))
+	equals(t, []string{"PrivateProps"}, changes.UpdateDetails()[0].Fields)
+	_, found := s.Events("primary")[0].ExtendedProperties.Private["ticket"]
+	assert(t, !found, "expected ticket to be removed")
+}
+
+func TestUserProps(t *testing.T) {
+	props := map[string]string{
+		"mine": "True", "mineID": "1", "mineHash": "h",
+		"other": "True", "otherID": "2", "otherHash": "h",
+		"ticket": "ABC-123",
+	}
+	equals(t, map[string]string{"ticket": "ABC-123"}, userProps("mine", props))
+	equals(t, map[string]string(nil), userProps("mine", map[string]string{"mine": "True"}))
+}
+
+func TestShare(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	ev := newSrcEvent("a", time.Now().Add(time.Hour).Truncate(time.Second))
+	ev.PrivateProps = map[string]string{"ticket": "ABC-123", "secret": "x"}
+
+	_, err := Sync(ctx, s.Client(), "scope", []*Event{ev}, Share("ticket", "room"), Encrypt(testKey))
+	ok(t, err)
+	events := s.Events("primary")
+	equals(t, 1, len(events))
+	equals(t, map[string]string{"scopeID": "a srcId", "ticket": "ABC-123"}, events[0].ExtendedProperties.Shared)
+	assert(t, events[0].ExtendedProperties.Private["scopeID"] != "a srcId", "expected the private SrcID to be encrypted")
+}
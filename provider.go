@@ -0,0 +1,90 @@
+package calsync
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/net/context"
+)
+
+// Source provides the current events of something to sync, such as a
+// feed, a file or a database, so that a Runner can pull them each time
+// it syncs rather than being handed them:
+//
+//	r := calsync.NewRunner(client, "myscope", 15*time.Minute,
+//		calsync.ICSFeed(nil, "https://example.com/events.ics"))
+type Source interface {
+	// Events returns all the events currently in the source.
+	Events(ctx context.Context) ([]*Event, error)
+}
+
+// SourceFunc is a function that is a Source, for example one reading
+// a database.
+type SourceFunc func(ctx context.Context) ([]*Event, error)
+
+// Events calls f.
+func (f SourceFunc) Events(ctx context.Context) ([]*Event, error) {
+	return f(ctx)
+}
+
+// StaticSource returns a Source that always provides events.
+func StaticSource(events []*Event) Source {
+	return SourceFunc(func(context.Context) ([]*Event, error) {
+		return events, nil
+	})
+}
+
+// ICSFile returns a Source that reads the iCalendar file at path, as
+// ReadICS does.
+func ICSFile(path string) Source {
+	return fileSource(path, ReadICS)
+}
+
+// JSONFile returns a Source that reads the JSON events in the file at
+// path, as ReadJSON does.
+func JSONFile(path string) Source {
+	return fileSource(path, ReadJSON)
+}
+
+// CSVFile returns a Source that reads the CSV file at path, as ReadCSV
+// does with mapping.
+func CSVFile(path string, mapping ColumnMap) Source {
+	return fileSource(path, func(r io.Reader) ([]*Event, error) {
+		return ReadCSV(r, mapping)
+	})
+}
+
+// fileSource returns a Source that reads the file at path with read
+// each time.
+func fileSource(path string, read func(io.Reader) ([]*Event, error)) Source {
+	return SourceFunc(func(context.Context) ([]*Event, error) {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		events, err := read(f)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %v", path, err)
+		}
+		return events, nil
+	})
+}
+
+// MergeSources returns a Source that provides the events of all of
+// sources, which fails if any of them does.  Their SrcIDs must not
+// collide.
+func MergeSources(sources ...Source) Source {
+	return SourceFunc(func(ctx context.Context) ([]*Event, error) {
+		var all []*Event
+		for _, s := range sources {
+			events, err := s.Events(ctx)
+			if err != nil {
+				return nil, err
+			}
+			all = append(all, events...)
+		}
+		return all, nil
+	})
+}
@@ -0,0 +1,53 @@
+package calsync
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestSources(t *testing.T) {
+	ctx := context.Background()
+	start := when("2017-05-01T19:00:00-07:00")
+	feedEvents := []*Event{newSrcEvent("feed", start)}
+	var ics bytes.Buffer
+	ok(t, WriteICS(&ics, feedEvents))
+
+	dir, err := ioutil.TempDir("", "calsync")
+	ok(t, err)
+	defer os.RemoveAll(dir)
+	icsPath := filepath.Join(dir, "events.ics")
+	ok(t, ioutil.WriteFile(icsPath, ics.Bytes(), 0600))
+	csvPath := filepath.Join(dir, "events.csv")
+	ok(t, ioutil.WriteFile(csvPath, []byte("id,title,start\ncsv1,csv title,2017-05-02T19:00:00-07:00\n"), 0600))
+
+	static := StaticSource([]*Event{newSrcEvent("static", start)})
+	merged := MergeSources(static, ICSFile(icsPath), CSVFile(csvPath, ColumnMap{
+		Title:    "title",
+		Start:    "start",
+		SrcID:    "id",
+		Duration: time.Hour,
+	}))
+	events, err := merged.Events(ctx)
+	ok(t, err)
+	var ids []string
+	for _, ev := range events {
+		ids = append(ids, ev.SrcID)
+	}
+	equals(t, []string{"static srcId", "feed srcId", "csv1"}, ids)
+
+	fail := errors.New("unavailable")
+	_, err = MergeSources(static, SourceFunc(func(context.Context) ([]*Event, error) {
+		return nil, fail
+	})).Events(ctx)
+	equals(t, fail, err)
+
+	_, err = ICSFile(filepath.Join(dir, "missing.ics")).Events(ctx)
+	assert(t, err != nil, "expected an error for a missing file")
+}
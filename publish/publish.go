@@ -0,0 +1,262 @@
+/*
+Package publish serves the upcoming events of a calsync scope over
+http, as JSON or iCalendar, so that a public web site can show a synced
+schedule without needing credentials, and without every page view
+calling the google calendar api.
+
+Events are fetched when first requested and again once they are older
+than the handler's TTL.  Run can be used to refresh them in the
+background instead, so that no request has to wait for a fetch:
+
+	h, err := publish.NewHandler(client, "myscope", 10*time.Minute)
+	if err != nil {
+		log.Fatal(err)
+	}
+	go h.Run(ctx)
+	http.Handle("/events", h)
+	http.Handle("/events.ics", h)
+*/
+package publish
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ginabythebay/calsync"
+
+	"golang.org/x/net/context"
+)
+
+// Handler is an http.Handler that serves the upcoming events of a
+// scope.  Requests for a path ending in ".ics", or that accept
+// text/calendar, get an iCalendar file; other requests get a JSON array
+// of events.
+//
+// Only what may be shown to anyone is served: the synced part of each
+// description, without the notes calendar users added around it or its
+// metadata, and attendees by name, without their email addresses.
+// Private properties are left out.
+//
+// Responses carry an ETag, and a Cache-Control header that lets
+// browsers and caches keep them until the events are next due to be
+// fetched.  If a fetch fails, the events from the last successful one
+// are served until a later one succeeds.
+type Handler struct {
+	fetch func(ctx context.Context) ([]*calsync.Event, error)
+	ttl   time.Duration
+	now   func() time.Time
+
+	// refreshing serializes fetches.
+	refreshing sync.Mutex
+
+	mu   sync.Mutex
+	snap *snapshot
+}
+
+// snapshot holds the result of one fetch, rendered in every format.
+type snapshot struct {
+	fetched  time.Time
+	json     []byte
+	jsonETag string
+	ics      []byte
+	icsETag  string
+}
+
+// NewHandler returns a Handler that fetches the events of scope with
+// client and opts, as calsync.Fetch does, and fetches them again once
+// they are ttl old.  It returns an error if ttl isn't positive.
+func NewHandler(client *http.Client, scope string, ttl time.Duration, opts ...calsync.Opt) (*Handler, error) {
+	if ttl <= 0 {
+		return nil, fmt.Errorf("publish: ttl must be positive, not %v", ttl)
+	}
+	return &Handler{
+		fetch: func(ctx context.Context) ([]*calsync.Event, error) {
+			return calsync.Fetch(ctx, client, scope, opts...)
+		},
+		ttl: ttl,
+		now: time.Now,
+	}, nil
+}
+
+// Run refreshes the events every TTL, until ctx is done.  Failures are
+// logged and retried at the next refresh.
+func (h *Handler) Run(ctx context.Context) error {
+	ticker := time.NewTicker(h.ttl)
+	defer ticker.Stop()
+	for {
+		if err := h.Refresh(ctx); err != nil {
+			log.Printf("publish: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Refresh fetches the events now, whether or not they are due.
+func (h *Handler) Refresh(ctx context.Context) error {
+	h.refreshing.Lock()
+	defer h.refreshing.Unlock()
+	return h.refresh(ctx)
+}
+
+// refresh fetches the events.  h.refreshing must be held.
+func (h *Handler) refresh(ctx context.Context) error {
+	fetched := h.now()
+	events, err := h.fetch(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to fetch events: %v", err)
+	}
+	events = public(events)
+	snap := &snapshot{fetched: fetched}
+	if snap.json, err = json.Marshal(nonNil(events)); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err = calsync.WriteICS(&buf, events); err != nil {
+		return err
+	}
+	snap.ics = buf.Bytes()
+	snap.jsonETag = etag(snap.json)
+	snap.icsETag = etag(snap.ics)
+
+	h.mu.Lock()
+	h.snap = snap
+	h.mu.Unlock()
+	return nil
+}
+
+// current returns the latest snapshot, fetching a new one first if it
+// is missing or stale.  If that fetch fails, the stale snapshot is
+// returned, if there is one.
+func (h *Handler) current(ctx context.Context) (*snapshot, error) {
+	if snap := h.fresh(); snap != nil {
+		return snap, nil
+	}
+	h.refreshing.Lock()
+	defer h.refreshing.Unlock()
+	// Another request may have refreshed while we waited.
+	if snap := h.fresh(); snap != nil {
+		return snap, nil
+	}
+	err := h.refresh(ctx)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.snap == nil {
+		return nil, err
+	}
+	if err != nil {
+		log.Printf("publish: serving stale events: %v", err)
+	}
+	return h.snap, nil
+}
+
+// fresh returns the latest snapshot if it is younger than the TTL.
+func (h *Handler) fresh() *snapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.snap == nil || h.now().Sub(h.snap.fetched) >= h.ttl {
+		return nil
+	}
+	return h.snap
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	snap, err := h.current(r.Context())
+	if err != nil {
+		http.Error(w, "events are not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	body, tag, contentType := snap.json, snap.jsonETag, "application/json; charset=utf-8"
+	if strings.HasSuffix(r.URL.Path, ".ics") || strings.Contains(r.Header.Get("Accept"), "text/calendar") {
+		body, tag, contentType = snap.ics, snap.icsETag, "text/calendar; charset=utf-8"
+	}
+
+	maxAge := h.ttl - h.now().Sub(snap.fetched)
+	if maxAge < 0 {
+		maxAge = 0
+	}
+	header := w.Header()
+	header.Set("ETag", tag)
+	header.Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge/time.Second)))
+	header.Set("Access-Control-Allow-Origin", "*")
+	header.Set("Vary", "Accept")
+	if matchETag(r.Header.Get("If-None-Match"), tag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Length", fmt.Sprint(len(body)))
+	if r.Method == "HEAD" {
+		return
+	}
+	w.Write(body)
+}
+
+func etag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// matchETag reports whether an If-None-Match header matches tag.
+func matchETag(ifNoneMatch, tag string) bool {
+	for _, each := range strings.Split(ifNoneMatch, ",") {
+		each = strings.TrimPrefix(strings.TrimSpace(each), "W/")
+		if each == tag || each == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// public returns copies of events with only the parts that Handler
+// serves.
+func public(events []*calsync.Event) []*calsync.Event {
+	var out []*calsync.Event
+	for _, ev := range events {
+		p := &calsync.Event{
+			Title:       ev.Title,
+			Start:       ev.Start,
+			End:         ev.End,
+			Where:       ev.Where,
+			Description: ev.SyncedDescription(),
+			SrcID:       ev.SrcID,
+			AllDay:      ev.AllDay,
+			SourceURL:   ev.SourceURL,
+			SourceTitle: ev.SourceTitle,
+			Status:      ev.Status,
+		}
+		for _, a := range ev.Attendees {
+			if a.Name != "" {
+				p.Attendees = append(p.Attendees, calsync.Attendee{Name: a.Name})
+			}
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+// nonNil makes an empty list of events marshal as [] rather than null.
+func nonNil(events []*calsync.Event) []*calsync.Event {
+	if events == nil {
+		return []*calsync.Event{}
+	}
+	return events
+}
@@ -0,0 +1,203 @@
+package publish
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ginabythebay/calsync"
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+func newTestHandler(t *testing.T) (*Handler, *calsynctest.Server) {
+	s := calsynctest.NewServer()
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	events := []*calsync.Event{{
+		Title: "Rehearsal",
+		Start: start,
+		End:   start.Add(time.Hour),
+		SrcID: "r1",
+	}}
+	if _, err := calsync.Sync(context.Background(), s.Client(), "scope", events); err != nil {
+		t.Fatal(err)
+	}
+	h, err := NewHandler(s.Client(), "scope", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h, s
+}
+
+func get(h http.Handler, path string, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("GET", path, nil)
+	for k, v := range header {
+		r.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestHandlerFormats(t *testing.T) {
+	h, _ := newTestHandler(t)
+
+	w := get(h, "/events", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("got content type %q", ct)
+	}
+	var events []*calsync.Event
+	if err := json.Unmarshal(w.Body.Bytes(), &events); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Title != "Rehearsal" {
+		t.Errorf("got events %v", events)
+	}
+
+	for _, w := range []*httptest.ResponseRecorder{
+		get(h, "/events.ics", nil),
+		get(h, "/events", http.Header{"Accept": {"text/calendar"}}),
+	} {
+		if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/calendar") {
+			t.Errorf("got content type %q", ct)
+		}
+		if !strings.Contains(w.Body.String(), "SUMMARY:Rehearsal") {
+			t.Errorf("got body %q", w.Body.String())
+		}
+	}
+}
+
+func TestHandlerCaching(t *testing.T) {
+	h, s := newTestHandler(t)
+	now := time.Now()
+	h.now = func() time.Time { return now }
+
+	first := get(h, "/events", nil)
+	tag := first.Header().Get("ETag")
+	if tag == "" {
+		t.Fatal("no ETag")
+	}
+	if cc := first.Header().Get("Cache-Control"); cc != "public, max-age=60" {
+		t.Errorf("got Cache-Control %q", cc)
+	}
+	requests := s.Requests()
+
+	now = now.Add(30 * time.Second)
+	w := get(h, "/events", http.Header{"If-None-Match": {tag}})
+	if w.Code != http.StatusNotModified {
+		t.Errorf("got status %d, want %d", w.Code, http.StatusNotModified)
+	}
+	if cc := w.Header().Get("Cache-Control"); cc != "public, max-age=30" {
+		t.Errorf("got Cache-Control %q", cc)
+	}
+	if got := s.Requests(); got != requests {
+		t.Errorf("fetched again within the ttl: %d requests, want %d", got, requests)
+	}
+
+	now = now.Add(time.Minute)
+	w = get(h, "/events", http.Header{"If-None-Match": {tag}})
+	if got := s.Requests(); got == requests {
+		t.Error("didn't fetch again after the ttl")
+	}
+	if w.Code != http.StatusNotModified {
+		t.Errorf("got status %d for unchanged events, want %d", w.Code, http.StatusNotModified)
+	}
+}
+
+func TestHandlerServesStale(t *testing.T) {
+	h, _ := newTestHandler(t)
+	now := time.Now()
+	h.now = func() time.Time { return now }
+	want := get(h, "/events", nil).Body.String()
+
+	h.fetch = func(context.Context) ([]*calsync.Event, error) {
+		return nil, errors.New("api is down")
+	}
+	now = now.Add(time.Hour)
+	w := get(h, "/events", nil)
+	if w.Code != http.StatusOK || w.Body.String() != want {
+		t.Errorf("got %d %q, want the stale events", w.Code, w.Body.String())
+	}
+	if cc := w.Header().Get("Cache-Control"); cc != "public, max-age=0" {
+		t.Errorf("got Cache-Control %q", cc)
+	}
+}
+
+func TestHandlerUnavailable(t *testing.T) {
+	h := &Handler{
+		fetch: func(context.Context) ([]*calsync.Event, error) {
+			return nil, errors.New("api is down")
+		},
+		ttl: time.Minute,
+		now: time.Now,
+	}
+	if w := get(h, "/events", nil); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("got status %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestHandlerServesPublicPartsOnly(t *testing.T) {
+	s := calsynctest.NewServer()
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	events := []*calsync.Event{{
+		Title:        "Rehearsal",
+		Start:        start,
+		End:          start.Add(time.Hour),
+		Description:  "Bring music",
+		SrcID:        "r1",
+		Attendees:    []calsync.Attendee{{Name: "Alice", Email: "alice@example.com"}, {Email: "bob@example.com"}},
+		Metadata:     map[string]string{"Room": "secret-room"},
+		PrivateProps: map[string]string{"booking": "secret-booking"},
+	}}
+	if _, err := calsync.Sync(context.Background(), s.Client(), "scope", events); err != nil {
+		t.Fatal(err)
+	}
+	ev := s.Events("primary")[0]
+	ev.Description = "secret-note\n" + ev.Description + "\n====================\nsecret-trailer"
+	if _, err := s.Put("primary", ev); err != nil {
+		t.Fatal(err)
+	}
+	h, err := NewHandler(s.Client(), "scope", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, w := range []*httptest.ResponseRecorder{get(h, "/events", nil), get(h, "/events.ics", nil)} {
+		body := w.Body.String()
+		if !strings.Contains(body, "Bring music") || !strings.Contains(body, "Rehearsal") {
+			t.Errorf("missing the synced event: %q", body)
+		}
+		for _, private := range []string{
+			"secret-note", "secret-trailer", "====================", "secret-room",
+			"secret-booking", "example.com", "user_note", "private_props", "metadata",
+		} {
+			if strings.Contains(body, private) {
+				t.Errorf("%q leaked in %q", private, body)
+			}
+		}
+	}
+
+	var served []*calsync.Event
+	if err := json.Unmarshal(get(h, "/events", nil).Body.Bytes(), &served); err != nil {
+		t.Fatal(err)
+	}
+	if len(served) != 1 || len(served[0].Attendees) != 1 || served[0].Attendees[0].Name != "Alice" {
+		t.Errorf("got events %+v", served)
+	}
+}
+
+func TestNewHandlerTTL(t *testing.T) {
+	for _, ttl := range []time.Duration{0, -time.Minute} {
+		if _, err := NewHandler(http.DefaultClient, "scope", ttl); err == nil {
+			t.Errorf("no error for ttl %v", ttl)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package calsync
+
+import (
+	"net/http"
+	"sort"
+
+	"golang.org/x/net/context"
+)
+
+// Edit describes an event that was edited in google calendar since it
+// was last synced, so that the edit can be pushed back into the source.
+type Edit struct {
+	// Source is the event as the source has it.
+	Source *Event
+
+	// Calendar is the event as it now is in google calendar.  Its
+	// Description holds only the synced part of the description, as it
+	// would be in the source, without any comment before the
+	// delimiter.
+	Calendar *Event
+
+	// Fields names the fields of Calendar that differ from Source, such
+	// as "Start" or "Description".
+	Fields []string
+}
+
+// PullChanges reports the upcoming events in scope that were edited in
+// google calendar since they were last synced, and that now differ
+// from srcEvents.  Nothing is modified.
+//
+// Once the source has taken on the edits, the next Sync will see that
+// the source and the calendar agree, and will record the edited
+// content as synced, so the events are no longer reported as edited.
+//
+// Events synced before edits could be detected, and events that are no
+// longer in srcEvents, are not reported.
+func PullChanges(
+	ctx context.Context,
+	client *http.Client,
+	scope string,
+	srcEvents []*Event,
+	opts ...Opt) ([]*Edit, error) {
+	c, err := setup(ctx, client, scope, opts)
+	if err != nil {
+		return nil, err
+	}
+	calEvents, err := c.fetch(ctx, c.now())
+	if err != nil {
+		return nil, err
+	}
+	return c.pullChanges(calEvents, srcEvents), nil
+}
+
+func (p planner) pullChanges(calEvents, srcEvents []*Event) []*Edit {
+	// Compare against each source event as Sync writes it.
+	copies := srcEvents
+	if p.privateCopies {
+		copies = privateCopies(srcEvents)
+	}
+	srcMap := map[string]*Event{}
+	writtenMap := map[string]*Event{}
+	for i, ev := range srcEvents {
+		srcMap[ev.SrcID] = ev
+		writtenMap[ev.SrcID] = copies[i]
+	}
+
+	var edits []*Edit
+	for _, calEv := range calEvents {
+		srcEv, ok := srcMap[calEv.SrcID]
+		written := writtenMap[calEv.SrcID]
+		if !ok || !p.edited(calEv) || p.equal(written, calEv) {
+			continue
+		}
+		pulled := *calEv
+		pulled.Description = parseDescription(calEv.Description).suffix
+		edits = append(edits, &Edit{
+			Source:   srcEv,
+			Calendar: &pulled,
+			Fields:   changedFields(written, &pulled),
+		})
+	}
+	sort.Sort(editsByStart(edits))
+	return edits
+}
+
+// changedFields names the synced fields that differ between a and b.
+func changedFields(a, b *Event) []string {
+	var fields []string
+	if a.Title != b.Title {
+		fields = append(fields, "Title")
+	}
+	if a.AllDay != b.AllDay {
+		fields = append(fields, "AllDay")
+	}
+	if a.AllDay && b.AllDay {
+		if !sameDate(a.Start, b.Start) {
+			fields = append(fields, "Start")
+		}
+		if !sameDate(a.End, b.End) {
+			fields = append(fields, "End")
+		}
+	} else {
+		if !a.Start.Equal(b.Start) {
+			fields = append(fields, "Start")
+		}
+		if !a.End.Equal(b.End) {
+			fields = append(fields, "End")
+		}
+	}
+	if a.Where != b.Where {
+		fields = append(fields, "Where")
+	}
+	if a.syncedDescription() != b.syncedDescription() {
+		fields = append(fields, "Description")
+	}
+	if !sameAttendees(a.Attendees, b.Attendees) {
+		fields = append(fields, "Attendees")
+	}
+	if !sameMetadata(a.Metadata, b.Metadata) {
+		fields = append(fields, "Metadata")
+	}
+	if !sameProps(a.PrivateProps, b.PrivateProps) {
+		fields = append(fields, "PrivateProps")
+	}
+	if a.status() != b.status() {
+		fields = append(fields, "Status")
+	}
+	aURL, aTitle := a.source()
+	bURL, bTitle := b.source()
+	if aURL != bURL {
+		fields = append(fields, "SourceURL")
+	}
+	if aTitle != bTitle {
+		fields = append(fields, "SourceTitle")
+	}
+	ag, bg := a.guestPermissions(), b.guestPermissions()
+	if ag.canModify != bg.canModify {
+		fields = append(fields, "GuestsCanModify")
+	}
+	if ag.canInviteOthers != bg.canInviteOthers {
+		fields = append(fields, "GuestsCanInviteOthers")
+	}
+	if ag.canSeeOtherGuests != bg.canSeeOtherGuests {
+		fields = append(fields, "GuestsCanSeeOtherGuests")
+	}
+	return fields
+}
+
+type editsByStart []*Edit
+
+func (s editsByStart) Len() int { return len(s) }
+func (s editsByStart) Less(i, j int) bool {
+	return s[i].Calendar.Start.Before(s[j].Calendar.Start)
+}
+func (s editsByStart) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
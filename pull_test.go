@@ -0,0 +1,53 @@
+package calsync
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPullChanges(t *testing.T) {
+	now := when("2017-04-29T20:00:00-07:00")
+
+	unchanged := newSrcEvent("unchanged", now.Add(time.Hour))
+	moved := newSrcEvent("moved", now.AddDate(0, 0, 1))
+	described := newSrcEvent("described", now.AddDate(0, 0, 2))
+	// Changed in the source rather than in the calendar.
+	srcChanged := newSrcEvent("srcChanged", now.AddDate(0, 0, 3))
+
+	movedCal := syncedCalEvent(moved)
+	movedCal.Start = movedCal.Start.Add(30 * time.Minute)
+	movedCal.End = movedCal.End.Add(30 * time.Minute)
+
+	describedCal := syncedCalEvent(described)
+	describedCal.Description = (&description{
+		prefix: "a comment",
+		suffix: "new description",
+	}).String()
+
+	srcChangedSrc := *srcChanged
+	srcChangedSrc.Title = "new title"
+
+	p := planner{}
+	edits := p.pullChanges(
+		[]*Event{syncedCalEvent(unchanged), describedCal, movedCal, syncedCalEvent(srcChanged)},
+		[]*Event{unchanged, moved, described, &srcChangedSrc})
+
+	equals(t, 2, len(edits))
+	equals(t, moved, edits[0].Source)
+	equals(t, []string{"Start", "End"}, edits[0].Fields)
+	equals(t, movedCal.Start, edits[0].Calendar.Start)
+
+	equals(t, described, edits[1].Source)
+	equals(t, []string{"Description"}, edits[1].Fields)
+	equals(t, "new description", edits[1].Calendar.Description)
+
+	// Once the source takes on the edit, the next sync records it.
+	movedSrc := *moved
+	movedSrc.Start = movedCal.Start
+	movedSrc.End = movedCal.End
+	changes, err := p.getOperations(now, []*Event{movedCal}, []*Event{&movedSrc})
+	ok(t, err)
+	equals(t, 1, len(changes.Updates))
+	equals(t, 0, len(changes.Conflicts))
+	equals(t, 0, len(p.pullChanges([]*Event{changes.Updates[0]}, []*Event{&movedSrc})))
+}
@@ -0,0 +1,93 @@
+package calsync
+
+import (
+	"fmt"
+	"net/http"
+
+	calendar "google.golang.org/api/calendar/v3"
+
+	"golang.org/x/net/context"
+)
+
+// Purge deletes every event in scope, past as well as upcoming, for
+// example when a source is decommissioned.  With Nop, nothing is
+// deleted, and the returned Changes report what would have been.  With
+// RouteTo, events are deleted from every calendar the user can write
+// to.
+//
+// Events are deleted even if they were edited in google calendar, and
+// even if their private extended properties can't be decrypted.  If
+// Purge fails partway, it returns the deletes it made along with the
+// error, as Sync does.
+func Purge(ctx context.Context, client *http.Client, scope string, opts ...Opt) (*Changes, error) {
+	c, err := setup(ctx, client, scope, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.needsGoogle("Purge"); err != nil {
+		return nil, err
+	}
+	started := c.now()
+	ids := []string{c.calID}
+	if c.missing {
+		ids = nil
+	} else if c.route != nil {
+		if ids, err = c.routedCalendars(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	changes := &Changes{}
+	for _, id := range ids {
+		one := *c
+		one.calID = id
+		events, err := one.listAll(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("calendar %q: %v", id, err)
+		}
+		changes.Deletes = append(changes.Deletes, events...)
+	}
+	err = c.apply(ctx, changes)
+	if err == nil {
+		err = c.mirror(nil, changes)
+	}
+	changes.Manifest = c.manifest("purge", started)
+	if err != nil {
+		return changes, err
+	}
+	if err = c.report(ctx, changes); err != nil {
+		return changes, err
+	}
+	return changes, nil
+}
+
+// listAll lists every event in scope in c.calID, past and upcoming.
+// Only what Purge needs is parsed, so that events we can't fully parse
+// can still be deleted.
+func (c cal) listAll(ctx context.Context) ([]*Event, error) {
+	var events []*Event
+	err := c.listScope(c.svc.Events.List(c.calID)).
+		ShowDeleted(false).
+		SingleEvents(true).
+		Pages(ctx, func(page *calendar.Events) error {
+			for _, each := range page.Items {
+				if !c.owns(each) {
+					continue
+				}
+				start, allDay, _ := c.parseEventTime(each.Start)
+				events = append(events, &Event{
+					Title:      each.Summary,
+					Start:      start,
+					AllDay:     allDay,
+					calID:      c.calID,
+					calEventID: each.Id,
+					raw:        each,
+				})
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve google calendar events: %v", err)
+	}
+	return events, nil
+}
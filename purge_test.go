@@ -0,0 +1,44 @@
+package calsync
+
+import (
+	"testing"
+	"time"
+
+	calendar "google.golang.org/api/calendar/v3"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+func TestPurge(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	_, err := Sync(ctx, s.Client(), "scope", []*Event{newSrcEvent("a", start), newSrcEvent("b", start)})
+	ok(t, err)
+	_, err = Sync(ctx, s.Client(), "other", []*Event{newSrcEvent("c", start)})
+	ok(t, err)
+	past := time.Now().AddDate(0, 0, -7)
+	_, err = s.Put("primary", &calendar.Event{
+		Summary: "last week",
+		Start:   &calendar.EventDateTime{DateTime: past.Format(time.RFC3339)},
+		End:     &calendar.EventDateTime{DateTime: past.Add(time.Hour).Format(time.RFC3339)},
+		ExtendedProperties: &calendar.EventExtendedProperties{
+			Private: map[string]string{"scope": "True", "scopeID": "old"},
+		},
+	})
+	ok(t, err)
+
+	changes, err := Purge(ctx, s.Client(), "scope", Nop())
+	ok(t, err)
+	equals(t, 3, len(changes.Deletes))
+	equals(t, 4, len(s.Events("primary")))
+
+	changes, err = Purge(ctx, s.Client(), "scope")
+	ok(t, err)
+	equals(t, 3, len(changes.Deletes))
+	remaining := s.Events("primary")
+	equals(t, 1, len(remaining))
+	equals(t, "c title", remaining[0].Summary)
+}
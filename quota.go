@@ -0,0 +1,120 @@
+package calsync
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// QuotaError is returned by Sync and Apply when google calendar
+// refuses an operation because the quota or rate limit for the project
+// or user ran out.  Operations before it were executed; the rest were
+// not attempted.
+//
+// To finish later, for example the next night, once the quota has been
+// reset, pass the token to ResumePlan and the plan it returns to Apply.
+// Apply reconciles the plan against the calendar as it is then, so
+// nothing is duplicated even if some operations did make it through.
+type QuotaError struct {
+	// Err is the error google calendar returned.
+	Err error
+
+	// Done holds the operations that were executed.
+	Done *Changes
+
+	// Remaining holds the operations that were not, starting with the
+	// one that failed.
+	Remaining *Changes
+
+	// Token is a resume token holding Remaining, for ResumePlan.  It is
+	// a printable string, so it can be saved anywhere.
+	Token string
+}
+
+func (e *QuotaError) Error() string {
+	n := len(e.Remaining.Deletes) + len(e.Remaining.Updates) + len(e.Remaining.Adds)
+	return fmt.Sprintf("stopped by google calendar quota with %d operations remaining: %v", n, e.Err)
+}
+
+// resumeToken is what a QuotaError.Token holds.
+type resumeToken struct {
+	Scope      string     `json:"scope"`
+	CalendarID string     `json:"calendar_id"`
+	Stopped    time.Time  `json:"stopped"`
+	Deletes    []resumeOp `json:"deletes,omitempty"`
+	Updates    []resumeOp `json:"updates,omitempty"`
+	Adds       []resumeOp `json:"adds,omitempty"`
+}
+
+// resumeOp is an operation in a resumeToken.  ID and CalendarID are
+// the google calendar ids of the event and its calendar, which the
+// Event itself doesn't marshal.
+type resumeOp struct {
+	Event      *Event `json:"event"`
+	ID         string `json:"id,omitempty"`
+	CalendarID string `json:"calendar_id,omitempty"`
+}
+
+func resumeOps(events []*Event) []resumeOp {
+	var ops []resumeOp
+	for _, ev := range events {
+		ops = append(ops, resumeOp{Event: ev, ID: ev.calEventID, CalendarID: ev.calID})
+	}
+	return ops
+}
+
+func resumeEvents(ops []resumeOp) []*Event {
+	var events []*Event
+	for _, op := range ops {
+		ev := *op.Event
+		ev.calEventID = op.ID
+		ev.calID = op.CalendarID
+		events = append(events, &ev)
+	}
+	return events
+}
+
+// quotaError returns the QuotaError for err, which stopped c.apply
+// after it did the operations in done, leaving those in remaining.
+func (c cal) quotaError(err error, done, remaining *Changes) *QuotaError {
+	e := &QuotaError{
+		Err:       err,
+		Done:      done,
+		Remaining: remaining,
+	}
+	b, jsonErr := json.Marshal(&resumeToken{
+		Scope:      c.scope,
+		CalendarID: c.calID,
+		Stopped:    c.now(),
+		Deletes:    resumeOps(e.Remaining.Deletes),
+		Updates:    resumeOps(e.Remaining.Updates),
+		Adds:       resumeOps(e.Remaining.Adds),
+	})
+	if jsonErr == nil {
+		e.Token = base64.RawURLEncoding.EncodeToString(b)
+	}
+	return e
+}
+
+// ResumePlan returns the plan held in token, a QuotaError.Token, so it
+// can be passed to Apply.  scope must be the scope of the Sync or Apply
+// that returned the QuotaError.
+func ResumePlan(token, scope string) (*Changes, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("malformed resume token: %v", err)
+	}
+	t := &resumeToken{}
+	if err = json.Unmarshal(b, t); err != nil {
+		return nil, fmt.Errorf("malformed resume token: %v", err)
+	}
+	if t.Scope != scope {
+		return nil, fmt.Errorf("resume token is for scope %q, not %q", t.Scope, scope)
+	}
+	return &Changes{
+		Deletes: resumeEvents(t.Deletes),
+		Updates: resumeEvents(t.Updates),
+		Adds:    resumeEvents(t.Adds),
+	}, nil
+}
@@ -0,0 +1,122 @@
+package calsync
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+func TestQuotaResume(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	var src []*Event
+	for i := 0; i < 5; i++ {
+		src = append(src, newSrcEvent(fmt.Sprint(i), start.Add(time.Duration(i)*time.Hour)))
+	}
+	_, err := Sync(ctx, s.Client(), "scope", src[:2])
+	ok(t, err)
+
+	// Delete one event, update the other, and add three, running out of
+	// quota after the update and the first add.
+	src[1].Title = "changed"
+	client := s.Client()
+	client.Transport = &calsynctest.FaultTransport{
+		Base:  client.Transport,
+		Rules: []calsynctest.Rule{{Fault: calsynctest.RateLimit, Match: calsynctest.Method("POST"), After: 1}},
+	}
+	partial, err := Sync(ctx, client, "scope", src[1:])
+	qe, isQuota := err.(*QuotaError)
+	assert(t, isQuota, "expected a QuotaError, got %v", err)
+	equals(t, 1, len(qe.Done.Deletes))
+	equals(t, 1, len(qe.Done.Updates))
+	equals(t, 1, len(qe.Done.Adds))
+	equals(t, 0, len(qe.Remaining.Deletes)+len(qe.Remaining.Updates))
+	equals(t, 2, len(qe.Remaining.Adds))
+	equals(t, 2, len(s.Events("primary")))
+	equals(t, 1, len(partial.Adds))
+	equals(t, 2, len(partial.Pending.Adds))
+	equals(t, 3, len(partial.Undo))
+
+	_, err = ResumePlan(qe.Token, "other")
+	assert(t, err != nil, "expected an error for the wrong scope")
+	plan, err := ResumePlan(qe.Token, "scope")
+	ok(t, err)
+	equals(t, qe.Remaining.Adds[0].SrcID, plan.Adds[0].SrcID)
+
+	// Resuming twice, as if the first resume's response was lost, adds
+	// nothing more.
+	for i := 0; i < 2; i++ {
+		_, err = Apply(ctx, s.Client(), "scope", plan)
+		ok(t, err)
+	}
+	equals(t, 4, len(s.Events("primary")))
+	changes, err := Sync(ctx, s.Client(), "scope", src[1:])
+	ok(t, err)
+	assert(t, changes.empty(), "expected no changes, got %s", changes)
+}
+
+func TestPartialChanges(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	var src []*Event
+	for i := 0; i < 3; i++ {
+		src = append(src, newSrcEvent(fmt.Sprint(i), start.Add(time.Duration(i)*time.Hour)))
+	}
+	client := s.Client()
+	client.Transport = &calsynctest.FaultTransport{
+		Base:  client.Transport,
+		Rules: []calsynctest.Rule{{Fault: calsynctest.ServerError, Match: calsynctest.Method("POST"), After: 1}},
+	}
+	changes, err := Sync(ctx, client, "scope", src)
+	assert(t, err != nil, "expected an error")
+	assert(t, changes != nil, "expected the changes made along with %v", err)
+	equals(t, 1, len(changes.Adds))
+	equals(t, src[0].SrcID, changes.Adds[0].SrcID)
+	equals(t, 2, len(changes.Pending.Adds))
+	equals(t, src[1].SrcID, changes.Pending.Adds[0].SrcID)
+	equals(t, "sync", changes.Manifest.Operation)
+	equals(t, 1, len(s.Events("primary")))
+
+	// Planning errors modify nothing, and return no changes.
+	changes, err = Sync(ctx, s.Client(), "scope", nil, MaxDeletes(0))
+	assert(t, err != nil, "expected too many deletes")
+	assert(t, changes == nil, "expected no changes, got %s", changes)
+}
+
+// cancellingAudit cancels a context once it has recorded after
+// operations.
+type cancellingAudit struct {
+	after  int
+	cancel func()
+}
+
+func (a *cancellingAudit) WriteAudit(r *AuditRecord) error {
+	a.after--
+	if a.after == 0 {
+		a.cancel()
+	}
+	return nil
+}
+
+func TestCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := calsynctest.NewServer()
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	var src []*Event
+	for i := 0; i < 3; i++ {
+		src = append(src, newSrcEvent(fmt.Sprint(i), start.Add(time.Duration(i)*time.Hour)))
+	}
+	changes, err := Sync(ctx, s.Client(), "scope", src, Audit(&cancellingAudit{after: 2, cancel: cancel}))
+	equals(t, ErrCancelled, err)
+	equals(t, 2, len(changes.Adds))
+	equals(t, 1, len(changes.Pending.Adds))
+	equals(t, src[2].SrcID, changes.Pending.Adds[0].SrcID)
+	equals(t, 2, len(s.Events("primary")))
+}
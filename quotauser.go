@@ -0,0 +1,53 @@
+package calsync
+
+import (
+	"fmt"
+	"net/http"
+
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+// maxQuotaUserLen is the longest quotaUser google accepts.
+const maxQuotaUserLen = 40
+
+// useQuotaUser makes c.svc call google calendar through client on
+// behalf of c.quotaUser, if it is set.  The generated calls only take
+// a quotaUser one call at a time, and not for listing pages, so it is
+// added to every request instead.
+func (c *cal) useQuotaUser(client *http.Client) error {
+	if c.quotaUser == "" || c.backend != nil {
+		return nil
+	}
+	if client == nil {
+		return fmt.Errorf("QuotaUser needs a client")
+	}
+	withUser := *client
+	withUser.Transport = quotaUserTransport{base: client.Transport, user: c.quotaUser}
+	svc, err := calendar.New(&withUser)
+	if err != nil {
+		return fmt.Errorf("failed creating service: %v", err)
+	}
+	c.svc = svc
+	return nil
+}
+
+// quotaUserTransport sets the quotaUser parameter of each request.
+type quotaUserTransport struct {
+	base http.RoundTripper
+	user string
+}
+
+func (t quotaUserTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the request it is given.
+	r := *req
+	u := *req.URL
+	q := u.Query()
+	q.Set("quotaUser", t.user)
+	u.RawQuery = q.Encode()
+	r.URL = &u
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(&r)
+}
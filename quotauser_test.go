@@ -0,0 +1,63 @@
+package calsync
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+// recordingTransport records the quotaUser of each request.
+type recordingTransport struct {
+	base http.RoundTripper
+
+	mu    sync.Mutex
+	users []string
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	t.users = append(t.users, req.URL.Query().Get("quotaUser"))
+	t.mu.Unlock()
+	return t.base.RoundTrip(req)
+}
+
+func TestQuotaUser(t *testing.T) {
+	srv := calsynctest.NewServer()
+	rt := &recordingTransport{base: srv.Client().Transport}
+	client := &http.Client{Transport: rt}
+	ctx := context.Background()
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	src := []*Event{newSrcEvent("a", start)}
+
+	_, err := Sync(ctx, client, "scope", src, QuotaUser("user1"))
+	ok(t, err)
+	assert(t, len(rt.users) > 0, "expected requests")
+	for _, u := range rt.users {
+		equals(t, "user1", u)
+	}
+
+	// A Syncer sets it per call.
+	s, err := NewSyncer(client)
+	ok(t, err)
+	rt.users = nil
+	_, err = s.Fetch(ctx, "scope", QuotaUser("user2"))
+	ok(t, err)
+	_, err = s.Fetch(ctx, "scope")
+	ok(t, err)
+	assert(t, len(rt.users) >= 2, "expected requests")
+	equals(t, "user2", rt.users[0])
+	equals(t, "", rt.users[len(rt.users)-1])
+
+	_, err = Sync(ctx, client, "scope", src, QuotaUser(strings.Repeat("x", 41)))
+	assert(t, err != nil, "expected an error for a long id")
+	svc, err := NewSyncer(client)
+	ok(t, err)
+	_, err = Fetch(ctx, nil, "scope", WithService(svc.svc), QuotaUser("user1"))
+	assert(t, err != nil, "expected an error without a client")
+}
@@ -0,0 +1,146 @@
+package calsync
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"sort"
+	"strings"
+)
+
+// htmlRow is a row of the table RenderHTML renders.
+type htmlRow struct {
+	Op     string
+	Class  string
+	When   string
+	Title  string
+	Before []string
+	After  []string
+}
+
+var changesHTML = template.Must(template.New("changes").Parse(`<table style="border-collapse:collapse;font-family:sans-serif;font-size:14px">
+<thead><tr style="background:#f1f3f4;text-align:left">
+<th style="padding:6px 10px">Change</th><th style="padding:6px 10px">When</th><th style="padding:6px 10px">Event</th><th style="padding:6px 10px">Before</th><th style="padding:6px 10px">After</th>
+</tr></thead>
+<tbody>
+{{- range .}}
+<tr class="{{.Class}}" style="border-top:1px solid #dadce0;vertical-align:top">
+<td style="padding:6px 10px;font-weight:bold;color:{{if eq .Class "add"}}#188038{{else if eq .Class "delete"}}#d93025{{else}}#1a73e8{{end}}">{{.Op}}</td>
+<td style="padding:6px 10px;white-space:nowrap">{{.When}}</td>
+<td style="padding:6px 10px">{{.Title}}</td>
+<td style="padding:6px 10px;color:#5f6368">{{range .Before}}<div>{{.}}</div>{{end}}</td>
+<td style="padding:6px 10px">{{range .After}}<div>{{.}}</div>{{end}}</td>
+</tr>
+{{- else}}
+<tr><td colspan="5" style="padding:6px 10px">No changes</td></tr>
+{{- end}}
+</tbody>
+</table>
+`))
+
+// RenderHTML renders the deletes, updates and adds in c as an HTML
+// table, suitable for emailing to the owners of the calendar.  Updates
+// show the fields that changed, before and after; adds and deletes
+// show the event.
+func (c *Changes) RenderHTML() string {
+	var rows []*htmlRow
+	for _, ev := range c.Deletes {
+		rows = append(rows, &htmlRow{Op: "Delete", Class: "delete", When: formatWhen(ev), Title: ev.Title,
+			Before: eventLines(ev)})
+	}
+	for _, u := range c.UpdateDetails() {
+		row := &htmlRow{Op: "Update", Class: "update", When: formatWhen(u.Next), Title: u.Next.Title}
+		for _, f := range u.Fields {
+			row.Before = append(row.Before, f+": "+fieldText(u.Previous, f))
+			row.After = append(row.After, f+": "+fieldText(u.Next, f))
+		}
+		if u.Previous == nil {
+			row.After = eventLines(u.Next)
+		}
+		rows = append(rows, row)
+	}
+	for _, ev := range c.Adds {
+		rows = append(rows, &htmlRow{Op: "Add", Class: "add", When: formatWhen(ev), Title: ev.Title,
+			After: eventLines(ev)})
+	}
+	var b bytes.Buffer
+	changesHTML.Execute(&b, rows)
+	return b.String()
+}
+
+// formatWhen formats when ev starts.
+func formatWhen(ev *Event) string {
+	if ev.AllDay {
+		return ev.Start.Format("Mon Jan 2, 2006")
+	}
+	return ev.Start.Format("Mon Jan 2, 2006 15:04")
+}
+
+// eventLines describes ev, for adds and deletes.
+func eventLines(ev *Event) []string {
+	var lines []string
+	for _, f := range []string{"Start", "End", "Where", "Attendees", "Description"} {
+		if s := fieldText(ev, f); s != "" {
+			lines = append(lines, f+": "+s)
+		}
+	}
+	return lines
+}
+
+// fieldText returns the value of the field of ev that changedFields
+// calls name, as text.
+func fieldText(ev *Event, name string) string {
+	switch name {
+	case "Title":
+		return ev.Title
+	case "AllDay":
+		return fmt.Sprint(ev.AllDay)
+	case "Start", "End":
+		t := ev.Start
+		if name == "End" {
+			t = ev.End
+		}
+		if ev.AllDay {
+			return t.Format("2006-01-02")
+		}
+		return t.Format("2006-01-02 15:04 MST")
+	case "Where":
+		return ev.Where
+	case "Description":
+		return ev.syncedDescription()
+	case "Attendees":
+		var emails []string
+		for _, a := range ev.Attendees {
+			emails = append(emails, a.Email)
+		}
+		return strings.Join(emails, ", ")
+	case "Metadata":
+		var lines []string
+		for _, k := range metadataKeys(ev.Metadata) {
+			lines = append(lines, k+": "+ev.Metadata[k])
+		}
+		return strings.Join(lines, "; ")
+	case "PrivateProps":
+		var props []string
+		for k, v := range ev.PrivateProps {
+			props = append(props, k+"="+v)
+		}
+		sort.Strings(props)
+		return strings.Join(props, ", ")
+	case "Status":
+		return string(ev.status())
+	case "SourceURL", "SourceTitle":
+		link, title := ev.source()
+		if name == "SourceURL" {
+			return link
+		}
+		return title
+	case "GuestsCanModify":
+		return fmt.Sprint(ev.guestPermissions().canModify)
+	case "GuestsCanInviteOthers":
+		return fmt.Sprint(ev.guestPermissions().canInviteOthers)
+	case "GuestsCanSeeOtherGuests":
+		return fmt.Sprint(ev.guestPermissions().canSeeOtherGuests)
+	}
+	return ""
+}
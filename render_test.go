@@ -0,0 +1,37 @@
+package calsync
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderHTML(t *testing.T) {
+	start := time.Date(2017, 4, 29, 10, 0, 0, 0, time.UTC)
+	calEv := newSrcEvent("a", start)
+	calEv.calEventID = "id1"
+	srcEv := newSrcEvent("a", start)
+	srcEv.Title = "new <title>"
+	changes := &Changes{
+		Deletes: []*Event{newSrcEvent("b", start)},
+		Updates: []*Event{calEv.newUpdate(srcEv)},
+		Adds:    []*Event{newSrcEvent("c", start)},
+	}
+
+	html := changes.RenderHTML()
+	for _, want := range []string{
+		">Delete</td>", ">b title</td>",
+		">Update</td>", "<div>Title: a title</div>", "<div>Title: new &lt;title&gt;</div>",
+		">Add</td>", ">c title</td>", "<div>Where: c where</div>",
+		"Sat Apr 29, 2017 10:00",
+	} {
+		assert(t, strings.Contains(html, want), "expected %q in %s", want, html)
+	}
+	assert(t, !strings.Contains(html, "<title>"), "expected titles to be escaped in %s", html)
+	assert(t, strings.Index(html, "Delete") < strings.Index(html, "Update") &&
+		strings.Index(html, "Update") < strings.Index(html, "Add"),
+		"expected deletes, then updates, then adds in %s", html)
+
+	html = (&Changes{}).RenderHTML()
+	assert(t, strings.Contains(html, "No changes"), "expected no changes in %s", html)
+}
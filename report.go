@@ -0,0 +1,34 @@
+package calsync
+
+import (
+	"fmt"
+
+	"golang.org/x/net/context"
+)
+
+// Reporter is told about the changes each Sync, SyncAll, Apply or
+// Purge made, once they are made, for example to post a summary to a
+// chat channel.  The report package has ready made Reporters.  See
+// Report.
+type Reporter interface {
+	Report(ctx context.Context, changes *Changes) error
+}
+
+// ReporterFunc adapts a function to a Reporter.
+type ReporterFunc func(ctx context.Context, changes *Changes) error
+
+// Report calls f(ctx, changes).
+func (f ReporterFunc) Report(ctx context.Context, changes *Changes) error {
+	return f(ctx, changes)
+}
+
+// report tells c.reporter about changes, if there is one.
+func (c cal) report(ctx context.Context, changes *Changes) error {
+	if c.reporter == nil {
+		return nil
+	}
+	if err := c.reporter.Report(ctx, changes); err != nil {
+		return fmt.Errorf("reporting changes: %v", err)
+	}
+	return nil
+}
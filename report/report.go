@@ -0,0 +1,154 @@
+/*
+Package report holds ready made calsync.Reporters, for use with
+calsync.Report, that post a summary of each sync over http.
+
+For example, to post to a Slack channel after each nightly sync:
+
+	r := report.NewSlack(webhookURL, nil)
+	changes, err := calsync.Sync(ctx, client, scope, events, calsync.Report(r))
+*/
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/ginabythebay/calsync"
+
+	"golang.org/x/net/context"
+)
+
+// MaxSlackLines is how many lines of changes a Slack message lists
+// before the rest are elided.
+const MaxSlackLines = 20
+
+// Summary is what the Reporter from NewWebhook posts, as JSON.
+type Summary struct {
+	// Text is a one line summary, such as
+	// `calsync sync of "classes": 2 added, 1 updated`.
+	Text string `json:"text"`
+
+	Deletes   int `json:"deletes"`
+	Updates   int `json:"updates"`
+	Adds      int `json:"adds"`
+	Conflicts int `json:"conflicts"`
+	Adopted   int `json:"adopted"`
+	Orphans   int `json:"orphans"`
+
+	// Changes lists the changes, one per line, as Changes.String does.
+	Changes string `json:"changes,omitempty"`
+
+	Manifest *calsync.Manifest `json:"manifest,omitempty"`
+}
+
+// Summarize returns the Summary of changes.
+func Summarize(changes *calsync.Changes) *Summary {
+	s := &Summary{
+		Deletes:   len(changes.Deletes),
+		Updates:   len(changes.Updates),
+		Adds:      len(changes.Adds),
+		Conflicts: len(changes.Conflicts),
+		Adopted:   len(changes.Adopted),
+		Orphans:   len(changes.Orphans),
+		Changes:   changes.String(),
+		Manifest:  changes.Manifest,
+	}
+	var counts []string
+	count := func(n int, what string) {
+		if n != 0 {
+			counts = append(counts, fmt.Sprintf("%d %s", n, what))
+		}
+	}
+	count(s.Adds, "added")
+	count(s.Updates, "updated")
+	count(s.Deletes, "deleted")
+	count(s.Conflicts, "in conflict")
+	count(s.Adopted, "adopted")
+	count(s.Orphans, "orphaned")
+	if len(counts) == 0 {
+		counts = []string{"no changes"}
+	}
+	s.Text = "calsync"
+	if m := changes.Manifest; m != nil {
+		s.Text += fmt.Sprintf(" %s of %q", m.Operation, m.Scope)
+		if m.DryRun {
+			s.Text += " (dry run)"
+		}
+	}
+	s.Text += ": " + strings.Join(counts, ", ")
+	return s
+}
+
+type webhook struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhook returns a Reporter that posts the Summary of each Changes
+// to url, as JSON, with client, or http.DefaultClient if client is
+// nil.  Any response other than a 2xx is an error.
+func NewWebhook(url string, client *http.Client) calsync.Reporter {
+	return &webhook{url, client}
+}
+
+func (w *webhook) Report(ctx context.Context, changes *calsync.Changes) error {
+	return post(ctx, w.client, w.url, Summarize(changes))
+}
+
+type slack struct {
+	url    string
+	client *http.Client
+}
+
+// NewSlack returns a Reporter that posts a message summarizing each
+// Changes to url, a Slack incoming webhook, with client, or
+// http.DefaultClient if client is nil.  The message lists up to
+// MaxSlackLines lines of changes.
+func NewSlack(url string, client *http.Client) calsync.Reporter {
+	return &slack{url, client}
+}
+
+func (s *slack) Report(ctx context.Context, changes *calsync.Changes) error {
+	summary := Summarize(changes)
+	text := summary.Text
+	if summary.Changes != "" {
+		lines := strings.Split(summary.Changes, "\n")
+		if len(lines) > MaxSlackLines {
+			more := len(lines) - MaxSlackLines
+			lines = append(lines[:MaxSlackLines], fmt.Sprintf("... and %d more", more))
+		}
+		text += "\n```\n" + strings.Join(lines, "\n") + "\n```"
+	}
+	return post(ctx, s.client, s.url, map[string]string{"text": text})
+}
+
+// post posts v to url as JSON.
+func post(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("posting to %s: %s: %s", url, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
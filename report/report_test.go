@@ -0,0 +1,123 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ginabythebay/calsync"
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+// receiver is a webhook that records the bodies posted to it.
+type receiver struct {
+	status int
+	bodies []string
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	b, _ := ioutil.ReadAll(req.Body)
+	r.bodies = append(r.bodies, string(b))
+	if r.status != 0 {
+		http.Error(w, "no thanks", r.status)
+	}
+}
+
+func newEvents(n int) []*calsync.Event {
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	var events []*calsync.Event
+	for i := 0; i < n; i++ {
+		events = append(events, &calsync.Event{
+			Title: fmt.Sprintf("event %d", i),
+			Start: start.Add(time.Duration(i) * time.Hour),
+			End:   start.Add(time.Duration(i+1) * time.Hour),
+			SrcID: fmt.Sprint(i),
+		})
+	}
+	return events
+}
+
+func TestWebhook(t *testing.T) {
+	r := &receiver{}
+	hook := httptest.NewServer(r)
+	defer hook.Close()
+	s := calsynctest.NewServer()
+
+	_, err := calsync.Sync(context.Background(), s.Client(), "scope", newEvents(2),
+		calsync.Report(NewWebhook(hook.URL, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.bodies) != 1 {
+		t.Fatalf("got %d posts, want 1", len(r.bodies))
+	}
+	var got Summary
+	if err = json.Unmarshal([]byte(r.bodies[0]), &got); err != nil {
+		t.Fatal(err)
+	}
+	if want := `calsync sync of "scope": 2 added`; got.Text != want {
+		t.Errorf("got text %q, want %q", got.Text, want)
+	}
+	if got.Adds != 2 || got.Deletes != 0 {
+		t.Errorf("got %d adds and %d deletes, want 2 and 0", got.Adds, got.Deletes)
+	}
+	if got.Manifest == nil || got.Manifest.Scope != "scope" {
+		t.Errorf("got manifest %+v, want one for scope", got.Manifest)
+	}
+}
+
+func TestWebhookFailure(t *testing.T) {
+	r := &receiver{status: http.StatusForbidden}
+	hook := httptest.NewServer(r)
+	defer hook.Close()
+	s := calsynctest.NewServer()
+
+	changes, err := calsync.Sync(context.Background(), s.Client(), "scope", newEvents(1),
+		calsync.Report(NewWebhook(hook.URL, nil)))
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("got error %v, want a 403", err)
+	}
+	if changes == nil || len(changes.Adds) != 1 {
+		t.Errorf("got changes %v, want the add that was made", changes)
+	}
+}
+
+func TestSlack(t *testing.T) {
+	r := &receiver{}
+	hook := httptest.NewServer(r)
+	defer hook.Close()
+	s := calsynctest.NewServer()
+
+	_, err := calsync.Sync(context.Background(), s.Client(), "scope", newEvents(MaxSlackLines+5),
+		calsync.Report(NewSlack(hook.URL, nil)), calsync.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]string
+	if err = json.Unmarshal([]byte(r.bodies[0]), &got); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(got["text"], "\n")
+	if want := fmt.Sprintf(`calsync sync of "scope" (dry run): %d added`, MaxSlackLines+5); lines[0] != want {
+		t.Errorf("got first line %q, want %q", lines[0], want)
+	}
+	if want := MaxSlackLines + 4; len(lines) != want {
+		t.Errorf("got %d lines, want %d", len(lines), want)
+	}
+	if want := "... and 5 more"; lines[len(lines)-2] != want {
+		t.Errorf("got %q, want %q", lines[len(lines)-2], want)
+	}
+}
+
+func TestSummarizeEmpty(t *testing.T) {
+	if got, want := Summarize(&calsync.Changes{}).Text, "calsync: no changes"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
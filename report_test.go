@@ -0,0 +1,30 @@
+package calsync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+func TestReport(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	var reported []string
+	r := Report(ReporterFunc(func(ctx context.Context, changes *Changes) error {
+		reported = append(reported, changes.Manifest.Operation+" "+changes.Manifest.Scope)
+		return nil
+	}))
+
+	_, err := SyncAll(ctx, s.Client(), map[string][]*Event{
+		"one": {newSrcEvent("a", start)},
+		"two": {newSrcEvent("b", start)},
+	}, r)
+	ok(t, err)
+	_, err = Purge(ctx, s.Client(), "one", r)
+	ok(t, err)
+	equals(t, []string{"sync one", "sync two", "purge one"}, reported)
+}
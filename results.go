@@ -0,0 +1,85 @@
+package calsync
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Outcome is what became of the operation planned for a source event.
+type Outcome int
+
+const (
+	// Applied means the operation was made in google calendar.
+	Applied Outcome = iota
+
+	// Skipped means the operation was not attempted, because an
+	// earlier one failed, or because the event was a conflict or an
+	// orphan.
+	Skipped
+
+	// Failed means the operation was attempted, and failed.
+	Failed
+)
+
+func (o Outcome) String() string {
+	switch o {
+	case Applied:
+		return "Applied"
+	case Skipped:
+		return "Skipped"
+	case Failed:
+		return "Failed"
+	}
+	return fmt.Sprintf("Outcome(%d)", int(o))
+}
+
+// EventResult is the outcome of the operation planned for one source
+// event.
+type EventResult struct {
+	// Operation is "delete", "cancel", "update" or "add", as in
+	// AuditRecord, or "conflict" or "orphan" for events that were left
+	// alone.
+	Operation string
+	Outcome   Outcome
+
+	// Err is why the operation failed.  It is nil unless Outcome is
+	// Failed.
+	Err error
+}
+
+// SyncResult maps the SrcID of each source event that Sync, SyncAll,
+// Apply or Purge planned an operation for to what became of it, so
+// that automation can retry just the events that failed.  Events that
+// needed no change are not listed.
+type SyncResult map[string]*EventResult
+
+// Failed returns the SrcIDs of the events whose operations failed,
+// sorted.
+func (r SyncResult) Failed() []string {
+	return r.srcIDs(Failed)
+}
+
+// Skipped returns the SrcIDs of the events whose operations were
+// skipped, sorted.
+func (r SyncResult) Skipped() []string {
+	return r.srcIDs(Skipped)
+}
+
+func (r SyncResult) srcIDs(o Outcome) []string {
+	var ids []string
+	for id, result := range r {
+		if result.Outcome == o {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// record records the outcome of op on ev in c.Results.
+func (c *Changes) record(ev *Event, op string, o Outcome, err error) {
+	if c.Results == nil {
+		c.Results = SyncResult{}
+	}
+	c.Results[ev.SrcID] = &EventResult{Operation: op, Outcome: o, Err: err}
+}
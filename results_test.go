@@ -0,0 +1,51 @@
+package calsync
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+func TestSyncResult(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	var src []*Event
+	for i := 0; i < 4; i++ {
+		src = append(src, newSrcEvent(fmt.Sprint(i), start.Add(time.Duration(i)*time.Hour)))
+	}
+	_, err := Sync(ctx, s.Client(), "scope", src[:1])
+	ok(t, err)
+
+	// Update 0, then fail to add 1, leaving 2 and 3.
+	src[0].Title = "changed"
+	client := s.Client()
+	client.Transport = &calsynctest.FaultTransport{
+		Base:  client.Transport,
+		Rules: []calsynctest.Rule{{Fault: calsynctest.ServerError, Match: calsynctest.Method("POST")}},
+	}
+	changes, err := Sync(ctx, client, "scope", src)
+	assert(t, err != nil, "expected an error")
+	r := changes.Results
+	equals(t, 4, len(r))
+	equals(t, &EventResult{Operation: "update", Outcome: Applied}, r[src[0].SrcID])
+	equals(t, "add", r[src[1].SrcID].Operation)
+	equals(t, Failed, r[src[1].SrcID].Outcome)
+	assert(t, r[src[1].SrcID].Err != nil, "expected the failure's error")
+	equals(t, []string{src[1].SrcID}, r.Failed())
+	equals(t, []string{src[2].SrcID, src[3].SrcID}, r.Skipped())
+
+	changes, err = Sync(ctx, s.Client(), "scope", src, Nop())
+	ok(t, err)
+	equals(t, 3, len(changes.Adds))
+	equals(t, SyncResult(nil), changes.Results)
+}
+
+func TestOutcomeString(t *testing.T) {
+	equals(t, "Failed", Failed.String())
+	equals(t, "Outcome(7)", Outcome(7).String())
+}
@@ -0,0 +1,73 @@
+package calsync
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	calendar "google.golang.org/api/calendar/v3"
+
+	"golang.org/x/net/context"
+)
+
+// resurrect replaces each add in changes that has a deleted upcoming
+// calendar event in scope with an update of that event, which restores
+// it.  See Resurrect.
+func (c cal) resurrect(ctx context.Context, now time.Time, changes *Changes) error {
+	if len(changes.Adds) == 0 || c.missing {
+		return nil
+	}
+	deleted := map[string]*Event{}
+	listed := map[string]bool{}
+	var adds []*Event
+	for _, ev := range changes.Adds {
+		id := c.calendarOf(ev)
+		if !listed[id] {
+			one := c
+			one.calID = id
+			if err := one.fetchDeleted(ctx, now, deleted); err != nil {
+				return fmt.Errorf("calendar %q: %v", id, err)
+			}
+			listed[id] = true
+		}
+		if calEv, ok := deleted[c.eventKey(ev)]; ok {
+			delete(deleted, c.eventKey(ev))
+			changes.Updates = append(changes.Updates, calEv.newUpdate(ev))
+			continue
+		}
+		adds = append(adds, ev)
+	}
+	changes.Adds = adds
+	sort.Sort(byStart(changes.Updates))
+	return nil
+}
+
+// fetchDeleted adds the deleted upcoming events in scope in c.calID to
+// deleted, by eventKey.  Where an event was deleted more than once, the
+// first listed is kept.
+func (c cal) fetchDeleted(ctx context.Context, now time.Time, deleted map[string]*Event) error {
+	err := c.listScope(c.svc.Events.List(c.calID)).
+		ShowDeleted(true).
+		SingleEvents(true).
+		TimeMin(now.Format(time.RFC3339)).
+		Pages(ctx, func(page *calendar.Events) error {
+			for _, each := range page.Items {
+				if each.Status != string(EventCancelled) || !c.owns(each) {
+					continue
+				}
+				ev, err := c.parseEvent(each)
+				if err != nil {
+					return fmt.Errorf("parseEvent %q, %v", each.Summary, err)
+				}
+				ev.calID = c.calID
+				if _, ok := deleted[c.eventKey(ev)]; !ok {
+					deleted[c.eventKey(ev)] = ev
+				}
+			}
+			return nil
+		})
+	if err != nil {
+		return fmt.Errorf("unable to retrieve deleted google calendar events: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package calsync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+func TestResurrect(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	a, b := newSrcEvent("a", start), newSrcEvent("b", start.Add(time.Hour))
+	_, err := Sync(ctx, s.Client(), "scope", []*Event{a, b})
+	ok(t, err)
+	id := s.Events("primary")[0].Id
+
+	_, err = Sync(ctx, s.Client(), "scope", []*Event{b})
+	ok(t, err)
+	equals(t, 1, len(s.Events("primary")))
+
+	a.Title = "new title"
+	changes, err := Sync(ctx, s.Client(), "scope", []*Event{a, b}, Resurrect())
+	ok(t, err)
+	equals(t, 0, len(changes.Adds))
+	equals(t, 1, len(changes.Updates))
+	equals(t, []string{"Resurrect"}, changes.Manifest.Options)
+	events := s.Events("primary")
+	equals(t, 2, len(events))
+	equals(t, id, events[0].Id)
+	equals(t, "new title", events[0].Summary)
+	equals(t, "confirmed", events[0].Status)
+
+	changes, err = Sync(ctx, s.Client(), "scope", []*Event{a, b}, Resurrect())
+	ok(t, err)
+	assert(t, changes.empty(), "expected no changes, got %s", changes)
+}
+
+func TestResurrectCancelled(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	ev := newSrcEvent("a", time.Now().Add(time.Hour).Truncate(time.Second))
+	_, err := Sync(ctx, s.Client(), "scope", []*Event{ev})
+	ok(t, err)
+	id := s.Events("primary")[0].Id
+
+	ev.Status = EventCancelled
+	_, err = Sync(ctx, s.Client(), "scope", []*Event{ev}, Resurrect())
+	ok(t, err)
+	equals(t, 0, len(s.Events("primary")))
+
+	ev.Status = ""
+	changes, err := Sync(ctx, s.Client(), "scope", []*Event{ev}, Resurrect())
+	ok(t, err)
+	equals(t, 1, len(changes.Updates))
+	equals(t, []string{"Status"}, changes.UpdateDetails()[0].Fields)
+	events := s.Events("primary")
+	equals(t, 1, len(events))
+	equals(t, id, events[0].Id)
+}
+
+func TestResurrectOff(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	ev := newSrcEvent("a", time.Now().Add(time.Hour).Truncate(time.Second))
+	_, err := Sync(ctx, s.Client(), "scope", []*Event{ev})
+	ok(t, err)
+	id := s.Events("primary")[0].Id
+	_, err = Sync(ctx, s.Client(), "scope", nil)
+	ok(t, err)
+
+	changes, err := Sync(ctx, s.Client(), "scope", []*Event{ev})
+	ok(t, err)
+	equals(t, 1, len(changes.Adds))
+	assert(t, s.Events("primary")[0].Id != id, "expected a new calendar event")
+}
@@ -0,0 +1,115 @@
+package calsync
+
+import (
+	"fmt"
+	"net/http"
+
+	calendar "google.golang.org/api/calendar/v3"
+
+	"golang.org/x/net/context"
+)
+
+// UndoOp records an operation that was made in google calendar, with
+// what Rollback needs to revert it.
+type UndoOp struct {
+	// Operation is "delete", "cancel", "update" or "add", as in
+	// AuditRecord.
+	Operation  string `json:"operation"`
+	CalendarID string `json:"calendar_id"`
+	EventID    string `json:"event_id"`
+
+	// Before is the google calendar event as it was before the
+	// operation.  It is nil for adds, and for deletes and updates in
+	// plans that weren't planned against the calendar, such as those
+	// from ResumePlan, which can't be reverted.
+	Before *calendar.Event `json:"before,omitempty"`
+}
+
+// applied records the operation op, just made on the event with
+// google calendar id eventID, with Audit, and in changes.Undo for
+// Rollback.  before or after may be nil.
+func (c cal) applied(changes *Changes, op, eventID string, before, after *Event) error {
+	if c.nop {
+		return nil
+	}
+	ev := after
+	if ev == nil {
+		ev = before
+	}
+	undo := &UndoOp{Operation: op, CalendarID: c.calendarOf(ev), EventID: eventID}
+	if before != nil {
+		undo.Before = before.raw
+	}
+	changes.Undo = append(changes.Undo, undo)
+	return c.audit(op, eventID, before, after)
+}
+
+// Rollback reverts the operations recorded in changes.Undo, as returned
+// by Sync, SyncAll, Apply or Purge, most recent first, for example
+// after syncing the wrong source by mistake.  Added events are
+// deleted, and updated, deleted and cancelled events are restored as
+// they were before, overwriting any edits made to them since.
+// Restored events keep their google calendar ids, and get back the
+// fields that were fetched; see FetchFields.
+//
+// If any operation can't be reverted, because it has no Before,
+// nothing is modified.  With Nop, nothing is modified either.  Of the
+// other options, only SendUpdates applies.
+func Rollback(ctx context.Context, client *http.Client, changes *Changes, opts ...Opt) error {
+	var scope string
+	if changes.Manifest != nil {
+		scope = changes.Manifest.Scope
+	}
+	for _, op := range changes.Undo {
+		if op.Operation != "add" && op.Before == nil {
+			return fmt.Errorf("%s of %s can't be reverted, as what it replaced is unknown", op.Operation, op.EventID)
+		}
+	}
+	c, err := setup(ctx, client, scope, opts)
+	if err != nil {
+		return err
+	}
+	if err = c.needsGoogle("Rollback"); err != nil {
+		return err
+	}
+	if c.nop {
+		return nil
+	}
+	for i := len(changes.Undo) - 1; i >= 0; i-- {
+		op := changes.Undo[i]
+		if err = c.revert(ctx, op); err != nil {
+			return fmt.Errorf("reverting %s of %s: %v", op.Operation, op.EventID, err)
+		}
+	}
+	return nil
+}
+
+// revert reverts op.
+func (c cal) revert(ctx context.Context, op *UndoOp) error {
+	send := c.notify.sendUpdates()
+	if op.Operation == "add" {
+		call := c.svc.Events.Delete(op.CalendarID, op.EventID)
+		if send != "" {
+			call = call.SendUpdates(send)
+		}
+		err := call.Context(ctx).Do()
+		if isNotFound(err) || isGone(err) {
+			// Already deleted, which is what we wanted.
+			return nil
+		}
+		return err
+	}
+	restored := *op.Before
+	// Google calendar rejects sequence numbers older than the event's.
+	restored.Sequence = 0
+	restored.Etag = ""
+	if restored.Status == "" {
+		restored.Status = string(EventConfirmed)
+	}
+	call := c.svc.Events.Update(op.CalendarID, op.EventID, &restored)
+	if send != "" {
+		call = call.SendUpdates(send)
+	}
+	_, err := call.Context(ctx).Do()
+	return err
+}
@@ -0,0 +1,84 @@
+package calsync
+
+import (
+	"testing"
+	"time"
+
+	calendar "google.golang.org/api/calendar/v3"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+func TestRollback(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	a, b := newSrcEvent("a", start), newSrcEvent("b", start.Add(time.Hour))
+	_, err := Sync(ctx, s.Client(), "scope", []*Event{a, b})
+	ok(t, err)
+	before := s.Events("primary")
+
+	// The wrong source: a changed, b gone and c new.
+	wrong := *a
+	wrong.Title = "wrong title"
+	changes, err := Sync(ctx, s.Client(), "scope", []*Event{&wrong, newSrcEvent("c", start)})
+	ok(t, err)
+	equals(t, 3, len(changes.Undo))
+	equals(t, "delete", changes.Undo[0].Operation)
+	equals(t, "update", changes.Undo[1].Operation)
+	equals(t, "add", changes.Undo[2].Operation)
+
+	ok(t, Rollback(ctx, s.Client(), changes, Nop()))
+	equals(t, 2, len(s.Events("primary")))
+	equals(t, "wrong title", s.Events("primary")[0].Summary)
+
+	ok(t, Rollback(ctx, s.Client(), changes))
+	after := s.Events("primary")
+	equals(t, 2, len(after))
+	for i := range before {
+		equals(t, before[i].Id, after[i].Id)
+		equals(t, before[i].Summary, after[i].Summary)
+	}
+	changes, err = Sync(ctx, s.Client(), "scope", []*Event{a, b})
+	ok(t, err)
+	assert(t, changes.empty(), "expected no changes, got %s", changes)
+}
+
+func TestRollbackUnknown(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	changes := &Changes{Undo: []*UndoOp{
+		{Operation: "add", CalendarID: "primary", EventID: "1"},
+		{Operation: "update", CalendarID: "primary", EventID: "2"},
+	}}
+	err := Rollback(ctx, s.Client(), changes)
+	assert(t, err != nil, "expected an error for an update without Before")
+	equals(t, 0, s.Requests())
+}
+
+func TestRollbackWithService(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	changes, err := Sync(ctx, s.Client(), "scope", []*Event{newSrcEvent("a", time.Now().Add(time.Hour))})
+	ok(t, err)
+	equals(t, 1, len(s.Events("primary")))
+
+	err = Rollback(ctx, nil, changes, WithService(nil))
+	assert(t, err != nil, "expected an error for a nil service")
+	equals(t, 1, len(s.Events("primary")))
+
+	svc, err := calendar.New(s.Client())
+	ok(t, err)
+	ok(t, Rollback(ctx, nil, changes, WithService(svc)))
+	equals(t, 0, len(s.Events("primary")))
+}
+
+func TestNopUndo(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	changes, err := Sync(ctx, s.Client(), "scope", []*Event{newSrcEvent("a", time.Now().Add(time.Hour))}, Nop())
+	ok(t, err)
+	equals(t, 0, len(changes.Undo))
+}
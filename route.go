@@ -0,0 +1,102 @@
+package calsync
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	calendar "google.golang.org/api/calendar/v3"
+
+	"golang.org/x/net/context"
+)
+
+// routeDefault makes c.route send events it has no calendar for to
+// c.calID.  setup calls it once c.calID is final.
+func (c *cal) routeDefault() {
+	if c.route == nil {
+		return
+	}
+	route, calID := c.route, c.calID
+	c.route = func(ev *Event) string {
+		if id := route(ev); id != "" {
+			return id
+		}
+		return calID
+	}
+}
+
+// routed returns srcEvents, each with calID set to the calendar p.route
+// sends it to.
+func (p planner) routed(srcEvents []*Event) []*Event {
+	copies := make([]*Event, len(srcEvents))
+	for i, ev := range srcEvents {
+		cp := *ev
+		cp.calID = p.route(ev)
+		copies[i] = &cp
+	}
+	return copies
+}
+
+// eventKey returns what getOperations matches source and calendar
+// events by.  With RouteTo, an event in the wrong calendar doesn't
+// match, so it is removed from there and added where it belongs.
+func (p planner) eventKey(ev *Event) string {
+	if p.route == nil {
+		return ev.SrcID
+	}
+	return ev.calID + "\x00" + ev.SrcID
+}
+
+// calendarOf returns the calendar ev is in, or is to be added to.
+func (c cal) calendarOf(ev *Event) string {
+	if ev.calID != "" {
+		return ev.calID
+	}
+	return c.calID
+}
+
+// fetchRouted fetches the upcoming events in scope from every calendar
+// that RouteTo may have put them in.
+func (c cal) fetchRouted(ctx context.Context, now time.Time) ([]*Event, error) {
+	ids, err := c.routedCalendars(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var events []*Event
+	for _, id := range ids {
+		one := c
+		one.calID = id
+		got, err := one.fetchCalendar(ctx, now)
+		if err != nil {
+			return nil, fmt.Errorf("calendar %q: %v", id, err)
+		}
+		for _, ev := range got {
+			ev.calID = id
+		}
+		events = append(events, got...)
+	}
+	return events, nil
+}
+
+// routedCalendars returns the ids of the calendars RouteTo may have put
+// events in: c.calID, and any calendar the user can write to.
+func (c cal) routedCalendars(ctx context.Context) ([]string, error) {
+	ids := map[string]bool{c.calID: true}
+	err := c.svc.CalendarList.List().
+		MinAccessRole("writer").
+		Pages(ctx, func(page *calendar.CalendarList) error {
+			for _, entry := range page.Items {
+				ids[entry.Id] = true
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list calendars: %v", err)
+	}
+	var sorted []string
+	for id := range ids {
+		sorted = append(sorted, id)
+	}
+	sort.Strings(sorted)
+	return sorted, nil
+}
@@ -0,0 +1,52 @@
+package calsync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+func TestRouteTo(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	s.AddCalendar("red", "Red team", "UTC")
+	s.AddCalendar("blue", "Blue team", "UTC")
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	redEv := newSrcEvent("red", start)
+	blueEv := newSrcEvent("blue", start)
+	allHands := newSrcEvent("allHands", start)
+	src := []*Event{redEv, blueEv, allHands}
+
+	teams := map[string]string{redEv.SrcID: "red", blueEv.SrcID: "blue"}
+	route := RouteTo(func(ev *Event) string { return teams[ev.SrcID] })
+	changes, err := Sync(ctx, s.Client(), "scope", src, route)
+	ok(t, err)
+	equals(t, 3, len(changes.Adds))
+	for _, id := range []string{"red", "blue", "primary"} {
+		equals(t, 1, len(s.Events(id)))
+	}
+
+	changes, err = Sync(ctx, s.Client(), "scope", src, route)
+	ok(t, err)
+	assert(t, changes.empty(), "expected no changes, got %s", changes)
+
+	// Moving an event to another calendar.
+	teams[redEv.SrcID] = "blue"
+	changes, err = Sync(ctx, s.Client(), "scope", src, route)
+	ok(t, err)
+	equals(t, 1, len(changes.Deletes))
+	equals(t, 1, len(changes.Adds))
+	equals(t, 0, len(s.Events("red")))
+	equals(t, 2, len(s.Events("blue")))
+
+	// Events are found in every calendar, even ones nothing is routed
+	// to any more.
+	changes, err = Sync(ctx, s.Client(), "scope", src[2:], route)
+	ok(t, err)
+	equals(t, 2, len(changes.Deletes))
+	equals(t, 0, len(s.Events("blue")))
+	equals(t, 1, len(s.Events("primary")))
+}
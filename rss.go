@@ -0,0 +1,226 @@
+package calsync
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// FeedMapping says how ReadRSS finds the event that each entry of a
+// feed announces.  Feeds only date their entries by when they were
+// published, so the date of the event is taken from the entry's text,
+// with Date or DateTemplate.
+type FeedMapping struct {
+	// Date finds the start of the event in the title of each entry, or
+	// else in its content.  The text matched by its first group, or by
+	// all of it if it has none, is parsed as the start.
+	Date *regexp.Regexp
+
+	// DateTemplate, if set, is used instead of Date.  It is executed
+	// with the FeedEntry, and its output is parsed as the start.
+	DateTemplate *template.Template
+
+	// Layouts, DateLayout, Location and Duration say how the start is
+	// parsed, and how long events last, as for ColumnMap.
+	Layouts    []string
+	DateLayout string
+	Location   *time.Location
+	Duration   time.Duration
+}
+
+// FeedEntry is an item of an RSS feed, or an entry of an Atom feed, as
+// DateTemplate sees it.
+type FeedEntry struct {
+	// ID is the guid of an RSS item, or the id of an Atom entry.
+	ID    string
+	Title string
+	Link  string
+
+	// Content is the content or description of the entry, which is
+	// usually HTML.
+	Content string
+
+	// Published and Updated are as the feed writes them.
+	Published string
+	Updated   string
+}
+
+// ReadRSS reads the entries of an RSS or Atom feed, such as a
+// community's event listing, as events, so they can be passed to Sync.
+// The guid or id of each entry is used as SrcID, or else its link, its
+// title as Title, its content as Description, and its link as
+// SourceURL.  Entries in which mapping finds no date are skipped, so
+// that a feed can mix events with other news.
+func ReadRSS(r io.Reader, mapping FeedMapping) ([]*Event, error) {
+	if mapping.Date == nil && mapping.DateTemplate == nil {
+		return nil, fmt.Errorf("rss: no Date or DateTemplate given")
+	}
+	entries, err := readFeedEntries(r)
+	if err != nil {
+		return nil, fmt.Errorf("rss: %v", err)
+	}
+	var events []*Event
+	for i, entry := range entries {
+		ev, err := mapping.event(entry)
+		if err != nil {
+			return nil, fmt.Errorf("rss entry %d (%q): %v", i, entry.Title, err)
+		}
+		if ev != nil {
+			events = append(events, ev)
+		}
+	}
+	return events, nil
+}
+
+// RSSFeed returns a Source that fetches the RSS or Atom feed at url
+// with client, or with http.DefaultClient if client is nil, and reads
+// it as ReadRSS does with mapping.  Fetches are conditional, as for
+// ICSFeed.
+func RSSFeed(client *http.Client, url string, mapping FeedMapping) Source {
+	return newFeed(client, url, func(r io.Reader) ([]*Event, error) {
+		return ReadRSS(r, mapping)
+	})
+}
+
+// event returns the event entry announces, or nil if it has no date.
+func (m FeedMapping) event(entry *FeedEntry) (*Event, error) {
+	when, err := m.date(entry)
+	if err != nil || when == "" {
+		return nil, err
+	}
+	start, allDay, err := ColumnMap{Layouts: m.Layouts, DateLayout: m.DateLayout, Location: m.Location}.parseTime(when)
+	if err != nil {
+		return nil, err
+	}
+	end := start.Add(m.Duration)
+	if allDay {
+		end = start.AddDate(0, 0, 1)
+	}
+	srcID := entry.ID
+	if srcID == "" {
+		srcID = entry.Link
+	}
+	if srcID == "" {
+		return nil, fmt.Errorf("no guid, id or link")
+	}
+	ev := &Event{
+		Title:           entry.Title,
+		Start:           start,
+		End:             end,
+		Description:     entry.Content,
+		DescriptionHTML: strings.Contains(entry.Content, "<"),
+		SrcID:           srcID,
+		AllDay:          allDay,
+	}
+	if validSourceURL(entry.Link) {
+		ev.SourceURL, ev.SourceTitle = entry.Link, entry.Title
+	}
+	return ev, nil
+}
+
+// date returns the text of the start of the event entry announces, or
+// "" if there is none.
+func (m FeedMapping) date(entry *FeedEntry) (string, error) {
+	if m.DateTemplate != nil {
+		var buf bytes.Buffer
+		if err := m.DateTemplate.Execute(&buf, entry); err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(buf.String()), nil
+	}
+	for _, s := range []string{entry.Title, entry.Content} {
+		if match := m.Date.FindStringSubmatch(s); match != nil {
+			if len(match) > 1 {
+				return strings.TrimSpace(match[1]), nil
+			}
+			return strings.TrimSpace(match[0]), nil
+		}
+	}
+	return "", nil
+}
+
+type rssItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	Description string `xml:"description"`
+	Encoded     string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	GUID        string `xml:"guid"`
+	PubDate     string `xml:"pubDate"`
+}
+
+type atomEntry struct {
+	ID    string `xml:"id"`
+	Title string `xml:"title"`
+	Links []struct {
+		Href string `xml:"href,attr"`
+		Rel  string `xml:"rel,attr"`
+	} `xml:"link"`
+	Content   string `xml:"content"`
+	Summary   string `xml:"summary"`
+	Published string `xml:"published"`
+	Updated   string `xml:"updated"`
+}
+
+// feedDoc holds any of RSS 2.0, whose items are in its channel, RSS
+// 1.0, whose items are beside it, and Atom.
+type feedDoc struct {
+	XMLName xml.Name
+	Channel struct {
+		Items []rssItem `xml:"item"`
+	} `xml:"channel"`
+	Items   []rssItem   `xml:"item"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+func readFeedEntries(r io.Reader) ([]*FeedEntry, error) {
+	doc := &feedDoc{}
+	if err := xml.NewDecoder(r).Decode(doc); err != nil {
+		return nil, err
+	}
+	var entries []*FeedEntry
+	switch doc.XMLName.Local {
+	case "rss", "RDF":
+		for _, item := range append(doc.Channel.Items, doc.Items...) {
+			content := item.Encoded
+			if content == "" {
+				content = item.Description
+			}
+			entries = append(entries, &FeedEntry{
+				ID:        strings.TrimSpace(item.GUID),
+				Title:     strings.TrimSpace(item.Title),
+				Link:      strings.TrimSpace(item.Link),
+				Content:   strings.TrimSpace(content),
+				Published: strings.TrimSpace(item.PubDate),
+			})
+		}
+	case "feed":
+		for _, e := range doc.Entries {
+			entry := &FeedEntry{
+				ID:        strings.TrimSpace(e.ID),
+				Title:     strings.TrimSpace(e.Title),
+				Content:   strings.TrimSpace(e.Content),
+				Published: strings.TrimSpace(e.Published),
+				Updated:   strings.TrimSpace(e.Updated),
+			}
+			if entry.Content == "" {
+				entry.Content = strings.TrimSpace(e.Summary)
+			}
+			for _, l := range e.Links {
+				if l.Rel == "" || l.Rel == "alternate" {
+					entry.Link = l.Href
+					break
+				}
+			}
+			entries = append(entries, entry)
+		}
+	default:
+		return nil, fmt.Errorf("expected an rss or atom feed, got <%s>", doc.XMLName.Local)
+	}
+	return entries, nil
+}
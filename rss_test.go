@@ -0,0 +1,117 @@
+package calsync
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+const testRSS = `<?xml version="1.0"?>
+<rss version="2.0" xmlns:content="http://purl.org/rss/1.0/modules/content/">
+<channel>
+  <title>Community events</title>
+  <item>
+    <title>Garden cleanup on May 6, 2017 10:00</title>
+    <link>https://example.com/events/cleanup</link>
+    <guid>cleanup-2017</guid>
+    <description>Bring gloves</description>
+    <content:encoded><![CDATA[<p>Bring <b>gloves</b></p>]]></content:encoded>
+  </item>
+  <item>
+    <title>Newsletter</title>
+    <link>https://example.com/news/1</link>
+    <description>No event here</description>
+  </item>
+  <item>
+    <title>Potluck</title>
+    <link>https://example.com/events/potluck</link>
+    <description>Join us on May 7, 2017 18:30 in the park</description>
+  </item>
+</channel>
+</rss>`
+
+const testAtom = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>Meetups</title>
+  <entry>
+    <id>urn:meetup:42</id>
+    <title>Go meetup</title>
+    <link rel="alternate" href="https://example.com/meetups/42"/>
+    <summary>Talks and pizza</summary>
+    <published>2017-05-08</published>
+  </entry>
+</feed>`
+
+var testFeedMapping = FeedMapping{
+	Date:     regexp.MustCompile(`on (\w+ \d+, \d{4} \d\d:\d\d)`),
+	Layouts:  []string{"January 2, 2006 15:04"},
+	Location: time.UTC,
+	Duration: 2 * time.Hour,
+}
+
+func TestReadRSS(t *testing.T) {
+	events, err := ReadRSS(strings.NewReader(testRSS), testFeedMapping)
+	ok(t, err)
+	equals(t, 2, len(events))
+
+	cleanup := events[0]
+	equals(t, "cleanup-2017", cleanup.SrcID)
+	equals(t, "Garden cleanup on May 6, 2017 10:00", cleanup.Title)
+	equals(t, time.Date(2017, 5, 6, 10, 0, 0, 0, time.UTC), cleanup.Start)
+	equals(t, time.Date(2017, 5, 6, 12, 0, 0, 0, time.UTC), cleanup.End)
+	equals(t, "<p>Bring <b>gloves</b></p>", cleanup.Description)
+	assert(t, cleanup.DescriptionHTML, "expected an HTML description")
+	equals(t, "https://example.com/events/cleanup", cleanup.SourceURL)
+
+	// Without a guid, the link identifies the entry, whose date is in
+	// its content.
+	potluck := events[1]
+	equals(t, "https://example.com/events/potluck", potluck.SrcID)
+	equals(t, time.Date(2017, 5, 7, 18, 30, 0, 0, time.UTC), potluck.Start)
+	assert(t, !potluck.DescriptionHTML, "expected a plain text description")
+}
+
+func TestReadAtom(t *testing.T) {
+	events, err := ReadRSS(strings.NewReader(testAtom), FeedMapping{
+		DateTemplate: template.Must(template.New("date").Parse("{{.Published}}")),
+		DateLayout:   "2006-01-02",
+		Location:     time.UTC,
+	})
+	ok(t, err)
+	equals(t, 1, len(events))
+	equals(t, "urn:meetup:42", events[0].SrcID)
+	equals(t, "Talks and pizza", events[0].Description)
+	equals(t, "https://example.com/meetups/42", events[0].SourceURL)
+	assert(t, events[0].AllDay, "expected an all day event")
+	equals(t, time.Date(2017, 5, 9, 0, 0, 0, 0, time.UTC), events[0].End)
+}
+
+func TestReadRSSErrors(t *testing.T) {
+	_, err := ReadRSS(strings.NewReader(testRSS), FeedMapping{})
+	assert(t, err != nil, "expected an error without a date mapping")
+
+	_, err = ReadRSS(strings.NewReader(`<html></html>`), testFeedMapping)
+	assert(t, err != nil && strings.Contains(err.Error(), "expected an rss or atom feed"), "got %v", err)
+
+	m := testFeedMapping
+	m.Date = regexp.MustCompile(`Garden cleanup on (.*)`)
+	m.Layouts = []string{time.RFC3339}
+	_, err = ReadRSS(strings.NewReader(testRSS), m)
+	assert(t, err != nil && strings.Contains(err.Error(), `rss entry 0 ("Garden cleanup`), "got %v", err)
+}
+
+func TestRSSFeed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testRSS))
+	}))
+	defer srv.Close()
+	events, err := RSSFeed(nil, srv.URL, testFeedMapping).Events(context.Background())
+	ok(t, err)
+	equals(t, 2, len(events))
+}
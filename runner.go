@@ -0,0 +1,204 @@
+package calsync
+
+import (
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// RunStatus describes what a Runner has done so far.
+type RunStatus struct {
+	// Runs counts the syncs attempted, successful or not.
+	Runs int
+
+	// LastRun is when the latest sync started, and LastSuccess when
+	// the latest successful one did.  Both are zero until there is
+	// one.
+	LastRun     time.Time
+	LastSuccess time.Time
+
+	// LastChanges holds the changes made by the latest sync, which are
+	// partial if it failed partway, or nil if it failed before changing
+	// anything.
+	LastChanges *Changes
+
+	// LastErr is why the latest sync failed, or nil if it succeeded.
+	LastErr error
+
+	// Failures counts the syncs that failed since the last success.
+	Failures int
+
+	// Next is when the next sync is due.  It is zero when Run is not
+	// running.
+	Next time.Time
+
+	// Running is set while a sync is in progress.  Done counts the
+	// operations of the plan of the latest sync that were made, out of
+	// Total.  Both are zero until it has planned.
+	Running     bool
+	Done, Total int
+}
+
+// Runner syncs a source into a scope periodically, for services that
+// keep a calendar up to date rather than syncing it once:
+//
+//	r := calsync.NewRunner(client, "myscope", 15*time.Minute, source, calsync.Journal(store))
+//	err := r.Run(ctx)
+//
+// A failed sync is retried sooner than the interval, after RetryDelay,
+// doubling the delay after each consecutive failure, up to the
+// interval.
+type Runner struct {
+	// Jitter is the most that is added, at random, to each wait between
+	// syncs, so that many runners started together don't all call the
+	// google calendar api at once.  NewRunner sets it to a tenth of the
+	// interval.
+	Jitter time.Duration
+
+	// RetryDelay is how long to wait before retrying a failed sync.
+	// NewRunner sets it to a minute, or to the interval if that is
+	// shorter.
+	RetryDelay time.Duration
+
+	client   *http.Client
+	scope    string
+	interval time.Duration
+	source   Source
+	opts     []Opt
+
+	// running serializes syncs.
+	running sync.Mutex
+
+	mu     sync.Mutex
+	status RunStatus
+}
+
+// NewRunner returns a Runner that syncs the events source provides
+// into scope every interval, with client and opts, as Sync does.  Like
+// time.NewTicker, it panics if interval isn't positive.
+func NewRunner(client *http.Client, scope string, interval time.Duration, source Source, opts ...Opt) *Runner {
+	if interval <= 0 {
+		panic("calsync: non-positive interval for NewRunner")
+	}
+	retry := time.Minute
+	if interval < retry {
+		retry = interval
+	}
+	return &Runner{
+		Jitter:     interval / 10,
+		RetryDelay: retry,
+		client:     client,
+		scope:      scope,
+		interval:   interval,
+		source:     source,
+		opts:       opts,
+	}
+}
+
+// Run syncs right away, then again every interval, until ctx is done,
+// and returns ctx.Err().  Failures are recorded in Status and retried.
+// Cancelling ctx while a sync is applying its changes stops it between
+// operations, as with Sync; use Journal to have the next run finish
+// them.
+func (r *Runner) Run(ctx context.Context) error {
+	defer r.setNext(time.Time{})
+	for {
+		r.RunOnce(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		wait := r.wait()
+		r.setNext(time.Now().Add(wait))
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// RunOnce fetches the source and syncs it now, whether or not a sync is
+// due, recording the outcome in Status.
+func (r *Runner) RunOnce(ctx context.Context) (*Changes, error) {
+	r.running.Lock()
+	defer r.running.Unlock()
+
+	started := time.Now()
+	r.mu.Lock()
+	r.status.Running = true
+	r.status.Done, r.status.Total = 0, 0
+	r.mu.Unlock()
+	changes, err := r.sync(ctx)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status.Running = false
+	r.status.Runs++
+	r.status.LastRun = started
+	r.status.LastChanges = changes
+	r.status.LastErr = err
+	if err != nil {
+		r.status.Failures++
+	} else {
+		r.status.Failures = 0
+		r.status.LastSuccess = started
+	}
+	return changes, err
+}
+
+func (r *Runner) sync(ctx context.Context) (*Changes, error) {
+	events, err := r.source.Events(ctx)
+	if err != nil {
+		return nil, err
+	}
+	opts := append(append([]Opt(nil), r.opts...), func(c *cal) {
+		c.progress = r.setProgress
+	})
+	return Sync(ctx, r.client, r.scope, events, opts...)
+}
+
+// Status returns what r has done so far.
+func (r *Runner) Status() RunStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.status
+}
+
+func (r *Runner) setNext(next time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status.Next = next
+}
+
+func (r *Runner) setProgress(done, total int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status.Done, r.status.Total = done, total
+}
+
+// wait returns how long to wait before the next sync.
+func (r *Runner) wait() time.Duration {
+	r.mu.Lock()
+	failures := r.status.Failures
+	r.mu.Unlock()
+
+	wait := r.interval
+	if failures > 0 && r.RetryDelay > 0 {
+		wait = r.RetryDelay
+		for i := 1; i < failures && wait < r.interval; i++ {
+			wait *= 2
+		}
+		if wait > r.interval {
+			wait = r.interval
+		}
+	}
+	if r.Jitter > 0 {
+		wait += time.Duration(rand.Int63n(int64(r.Jitter)))
+	}
+	return wait
+}
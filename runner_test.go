@@ -0,0 +1,101 @@
+package calsync
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+func TestRunner(t *testing.T) {
+	s := calsynctest.NewServer()
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	src := []*Event{newSrcEvent("a", start)}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	calls := 0
+	r := NewRunner(s.Client(), "scope", time.Millisecond, SourceFunc(func(context.Context) ([]*Event, error) {
+		calls++
+		switch calls {
+		case 1:
+			return nil, errors.New("source unavailable")
+		case 3:
+			cancel()
+			return nil, ctx.Err()
+		}
+		return src, nil
+	}))
+
+	equals(t, context.Canceled, r.Run(ctx))
+	status := r.Status()
+	equals(t, 3, status.Runs)
+	equals(t, 1, status.Failures)
+	assert(t, !status.LastSuccess.IsZero(), "expected the second run to succeed")
+	assert(t, status.Next.IsZero(), "expected no next run once stopped, got %s", status.Next)
+	equals(t, 1, len(s.Events("primary")))
+}
+
+func TestRunnerStatus(t *testing.T) {
+	s := calsynctest.NewServer()
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	fail := errors.New("source unavailable")
+	var srcErr error
+	r := NewRunner(s.Client(), "scope", time.Hour, SourceFunc(func(context.Context) ([]*Event, error) {
+		return []*Event{newSrcEvent("a", start)}, srcErr
+	}))
+	equals(t, RunStatus{}, r.Status())
+
+	changes, err := r.RunOnce(context.Background())
+	ok(t, err)
+	status := r.Status()
+	equals(t, 1, status.Runs)
+	equals(t, changes, status.LastChanges)
+	equals(t, 1, len(changes.Adds))
+	equals(t, status.LastRun, status.LastSuccess)
+
+	srcErr = fail
+	_, err = r.RunOnce(context.Background())
+	equals(t, fail, err)
+	status = r.Status()
+	equals(t, 2, status.Runs)
+	equals(t, 1, status.Failures)
+	equals(t, fail, status.LastErr)
+	assert(t, status.LastRun.After(status.LastSuccess), "expected the success to be older than the failure")
+}
+
+func TestRunnerBackoff(t *testing.T) {
+	r := NewRunner(nil, "scope", time.Hour, nil)
+	equals(t, 6*time.Minute, r.Jitter)
+	equals(t, time.Minute, r.RetryDelay)
+	r.Jitter = 0
+	equals(t, time.Hour, r.wait())
+	for failures, want := range map[int]time.Duration{
+		1: time.Minute,
+		2: 2 * time.Minute,
+		4: 8 * time.Minute,
+		7: time.Hour,
+	} {
+		r.status.Failures = failures
+		equals(t, want, r.wait())
+	}
+
+	r.Jitter = time.Minute
+	r.status.Failures = 0
+	wait := r.wait()
+	assert(t, wait >= time.Hour && wait < time.Hour+time.Minute, "got %s, want within a minute of jitter", wait)
+}
+
+func TestRunnerInterval(t *testing.T) {
+	for _, interval := range []time.Duration{0, -time.Minute} {
+		func() {
+			defer func() {
+				assert(t, recover() != nil, "expected a panic for interval %s", interval)
+			}()
+			NewRunner(nil, "scope", interval, nil)
+		}()
+	}
+}
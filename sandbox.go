@@ -0,0 +1,68 @@
+package calsync
+
+import (
+	"fmt"
+	"net/http"
+
+	calendar "google.golang.org/api/calendar/v3"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	// SandboxCalendar is the name of the calendar Sandbox syncs into.
+	SandboxCalendar = "calsync sandbox (test events)"
+
+	// SandboxProp is the private extended property, set to "True",
+	// that Sandbox tags events with.
+	SandboxProp = "calsyncSandbox"
+)
+
+// useSandbox points c at the sandbox calendar, in place of any other
+// calendar it was configured with.  See Sandbox.
+func (c *cal) useSandbox() {
+	c.calID = "primary"
+	c.calName = ""
+	c.route = nil
+	c.ensure = &newCalendar{summary: SandboxCalendar}
+}
+
+// PurgeSandbox deletes every event that Sandbox tagged in the
+// SandboxCalendar, whatever its scope, returning how many it deleted.
+// The calendar itself is kept.  It does nothing if there is no
+// SandboxCalendar.
+func PurgeSandbox(ctx context.Context, client *http.Client) (int, error) {
+	c, err := configure(client, "", nil)
+	if err != nil {
+		return 0, err
+	}
+	ids, err := c.calendarsNamed(ctx, SandboxCalendar)
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+	if len(ids) > 1 {
+		return 0, fmt.Errorf("there are %d calendars named %q", len(ids), SandboxCalendar)
+	}
+	var eventIDs []string
+	err = c.svc.Events.List(ids[0]).
+		ShowDeleted(false).
+		PrivateExtendedProperty(SandboxProp+"=True").
+		Pages(ctx, func(page *calendar.Events) error {
+			for _, each := range page.Items {
+				eventIDs = append(eventIDs, each.Id)
+			}
+			return nil
+		})
+	if err != nil {
+		return 0, fmt.Errorf("unable to retrieve google calendar events: %v", err)
+	}
+	deleted := 0
+	for _, id := range eventIDs {
+		err = c.svc.Events.Delete(ids[0], id).Context(ctx).Do()
+		if err != nil && !isNotFound(err) && !isGone(err) {
+			return deleted, fmt.Errorf("deleting %s: %v", id, err)
+		}
+		deleted++
+	}
+	return deleted, nil
+}
@@ -0,0 +1,53 @@
+package calsync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+func TestSandbox(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	s.AddCalendar("work", "Work", "UTC")
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	src := []*Event{newSrcEvent("a", start)}
+
+	n, err := PurgeSandbox(ctx, s.Client())
+	ok(t, err)
+	equals(t, 0, n)
+
+	changes, err := Sync(ctx, s.Client(), "scope", src, CalendarID("work"), Sandbox())
+	ok(t, err)
+	equals(t, 1, len(changes.Adds))
+	equals(t, 0, len(s.Events("work")))
+	equals(t, 0, len(s.Events("primary")))
+	var sandbox string
+	for _, entry := range s.Calendars() {
+		if entry.Summary == SandboxCalendar {
+			sandbox = entry.Id
+		}
+	}
+	assert(t, sandbox != "", "expected the sandbox calendar to be created")
+	equals(t, sandbox, changes.Manifest.CalendarID)
+	equals(t, []string{"Sandbox"}, changes.Manifest.Options)
+	events := s.Events(sandbox)
+	equals(t, 1, len(events))
+	equals(t, "True", events[0].ExtendedProperties.Private[SandboxProp])
+
+	// The calendar is reused, and the tag doesn't count as a change.
+	changes, err = Sync(ctx, s.Client(), "scope", src, Sandbox())
+	ok(t, err)
+	assert(t, changes.empty(), "expected no changes, got %s", changes)
+	_, err = Sync(ctx, s.Client(), "other", src, RouteTo(func(*Event) string { return "work" }), Sandbox())
+	ok(t, err)
+	equals(t, 2, len(s.Events(sandbox)))
+
+	n, err = PurgeSandbox(ctx, s.Client())
+	ok(t, err)
+	equals(t, 2, n)
+	equals(t, 0, len(s.Events(sandbox)))
+}
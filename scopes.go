@@ -0,0 +1,79 @@
+package calsync
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	calendar "google.golang.org/api/calendar/v3"
+
+	"golang.org/x/net/context"
+)
+
+// ScopeCount is a scope found by ListScopes.
+type ScopeCount struct {
+	Scope string
+
+	// Events is how many upcoming events the scope owns.
+	Events int
+}
+
+// ListScopes scans the upcoming events in calendar calID and reports
+// the scopes that own any of them, ordered by scope, for example to
+// audit a calendar that several importers sync into.  An event is
+// owned by a scope if it has the <scope>=True and <scope>ID private
+// extended properties that Sync writes.
+func ListScopes(ctx context.Context, client *http.Client, calID string) ([]ScopeCount, error) {
+	c, err := configure(client, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	counts := map[string]int{}
+	err = c.svc.Events.List(calID).
+		ShowDeleted(false).
+		SingleEvents(true).
+		TimeMin(time.Now().Format(time.RFC3339)).
+		Pages(ctx, func(page *calendar.Events) error {
+			for _, each := range page.Items {
+				if each.ExtendedProperties == nil {
+					continue
+				}
+				for _, scope := range eventScopes(each.ExtendedProperties.Private) {
+					counts[scope]++
+				}
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve google calendar events: %v", err)
+	}
+
+	var scopes []ScopeCount
+	for scope, n := range counts {
+		scopes = append(scopes, ScopeCount{Scope: scope, Events: n})
+	}
+	sort.Sort(byScope(scopes))
+	return scopes, nil
+}
+
+// eventScopes returns the scopes that own an event with the given
+// private extended properties.
+func eventScopes(props map[string]string) []string {
+	var scopes []string
+	for key, value := range props {
+		if value != "True" {
+			continue
+		}
+		if _, ok := props[key+"ID"]; ok {
+			scopes = append(scopes, key)
+		}
+	}
+	return scopes
+}
+
+type byScope []ScopeCount
+
+func (s byScope) Len() int           { return len(s) }
+func (s byScope) Less(i, j int) bool { return s[i].Scope < s[j].Scope }
+func (s byScope) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
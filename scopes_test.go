@@ -0,0 +1,35 @@
+package calsync
+
+import (
+	"testing"
+	"time"
+
+	calendar "google.golang.org/api/calendar/v3"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+func TestListScopes(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	_, err := Sync(ctx, s.Client(), "meetup", []*Event{newSrcEvent("a", start), newSrcEvent("b", start)})
+	ok(t, err)
+	_, err = Sync(ctx, s.Client(), "school", []*Event{newSrcEvent("c", start)}, Encrypt(testKey))
+	ok(t, err)
+	_, err = s.Put("primary", &calendar.Event{
+		Summary: "not synced",
+		Start:   &calendar.EventDateTime{DateTime: start.Format(time.RFC3339)},
+		End:     &calendar.EventDateTime{DateTime: start.Add(time.Hour).Format(time.RFC3339)},
+		ExtendedProperties: &calendar.EventExtendedProperties{
+			Private: map[string]string{"flag": "True"},
+		},
+	})
+	ok(t, err)
+
+	scopes, err := ListScopes(ctx, s.Client(), "primary")
+	ok(t, err)
+	equals(t, []ScopeCount{{"meetup", 2}, {"school", 1}}, scopes)
+}
@@ -0,0 +1,188 @@
+/*
+Package server exposes calsync's Sync, Plan and Fetch as a small http
+service, so that programs not written in Go can submit batches of
+events for syncing rather than shelling out to the calsync command:
+
+	s := server.New(client, calsync.Journal(store))
+	http.Handle("/v1/", s)
+
+Requests and responses are JSON, as described by the messages in
+calsync.proto, using the proto field names.  Events and changes are as
+in package calsyncpb:
+
+	POST /v1/sync   SyncRequest  -> SyncResponse
+	POST /v1/plan   SyncRequest  -> SyncResponse
+	POST /v1/fetch  FetchRequest -> FetchResponse
+
+Errors are reported with an http status and an ErrorResponse.  A
+conflict is a 409, a validation error a 400, running out of quota a
+429, and anything else a 500.  If a sync fails partway, the changes it
+made are in the ErrorResponse.
+
+The service syncs with the client and options it was created with, so
+callers can't choose the calendar or its credentials, only the scope.
+Put it behind whatever authentication the deployment needs.
+*/
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/ginabythebay/calsync"
+	"github.com/ginabythebay/calsync/calsyncpb"
+)
+
+// maxRequestBytes limits the size of request bodies.
+const maxRequestBytes = 32 << 20
+
+// SyncRequest asks for the events of a scope to be synced, or planned.
+type SyncRequest struct {
+	Scope  string           `json:"scope"`
+	Events []*calsync.Event `json:"events"`
+}
+
+// SyncResponse holds the changes made, or planned.
+type SyncResponse struct {
+	Changes *calsyncpb.Changes `json:"changes"`
+}
+
+// FetchRequest asks for the upcoming events of a scope.
+type FetchRequest struct {
+	Scope string `json:"scope"`
+}
+
+// FetchResponse holds the upcoming events of a scope.
+type FetchResponse struct {
+	Events []*calsync.Event `json:"events"`
+}
+
+// ErrorResponse describes why a request failed.
+type ErrorResponse struct {
+	Error string `json:"error"`
+
+	// Changes holds the changes a sync made before it failed, if any.
+	Changes *calsyncpb.Changes `json:"changes,omitempty"`
+}
+
+// Server is an http.Handler that serves the calsync service.
+type Server struct {
+	client *http.Client
+	opts   []calsync.Opt
+	mux    *http.ServeMux
+}
+
+// New returns a Server that syncs and fetches with client and opts.
+func New(client *http.Client, opts ...calsync.Opt) *Server {
+	s := &Server{client: client, opts: opts, mux: http.NewServeMux()}
+	s.mux.HandleFunc("/v1/sync", s.sync)
+	s.mux.HandleFunc("/v1/plan", s.plan)
+	s.mux.HandleFunc("/v1/fetch", s.fetch)
+	return s
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) sync(w http.ResponseWriter, r *http.Request) {
+	in := &SyncRequest{}
+	if !decode(w, r, in) {
+		return
+	}
+	changes, err := calsync.Sync(r.Context(), s.client, in.Scope, in.Events, s.opts...)
+	if err != nil {
+		writeError(w, err, changes)
+		return
+	}
+	write(w, http.StatusOK, &SyncResponse{Changes: calsyncpb.FromChanges(changes)})
+}
+
+func (s *Server) plan(w http.ResponseWriter, r *http.Request) {
+	in := &SyncRequest{}
+	if !decode(w, r, in) {
+		return
+	}
+	changes, err := calsync.Plan(r.Context(), s.client, in.Scope, in.Events, s.opts...)
+	if err != nil {
+		writeError(w, err, nil)
+		return
+	}
+	write(w, http.StatusOK, &SyncResponse{Changes: calsyncpb.FromChanges(changes)})
+}
+
+func (s *Server) fetch(w http.ResponseWriter, r *http.Request) {
+	in := &FetchRequest{}
+	if !decode(w, r, in) {
+		return
+	}
+	events, err := calsync.Fetch(r.Context(), s.client, in.Scope, s.opts...)
+	if err != nil {
+		writeError(w, err, nil)
+		return
+	}
+	if events == nil {
+		events = []*calsync.Event{}
+	}
+	write(w, http.StatusOK, &FetchResponse{Events: events})
+}
+
+// decode reads the request body into v and checks it, reporting any
+// error to the client.  Source events are checked with
+// calsync.Validate.  It returns whether it succeeded.
+func decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		write(w, http.StatusMethodNotAllowed, &ErrorResponse{Error: "only POST is supported"})
+		return false
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes)).Decode(v); err != nil {
+		write(w, http.StatusBadRequest, &ErrorResponse{Error: fmt.Sprintf("malformed request: %v", err)})
+		return false
+	}
+	var err error
+	switch in := v.(type) {
+	case *SyncRequest:
+		if err = checkScope(in.Scope); err == nil {
+			err = calsync.Validate(in.Events)
+		}
+	case *FetchRequest:
+		err = checkScope(in.Scope)
+	}
+	if err != nil {
+		write(w, http.StatusBadRequest, &ErrorResponse{Error: err.Error()})
+		return false
+	}
+	return true
+}
+
+func checkScope(scope string) error {
+	switch {
+	case scope == "":
+		return fmt.Errorf("scope is required")
+	case len(scope) > calsync.MaxScopeLen:
+		return fmt.Errorf("scope is longer than %d bytes", calsync.MaxScopeLen)
+	}
+	return nil
+}
+
+func writeError(w http.ResponseWriter, err error, changes *calsync.Changes) {
+	code := http.StatusInternalServerError
+	switch err.(type) {
+	case *calsync.ConflictError, *calsync.DeleteLimitError:
+		code = http.StatusConflict
+	case *calsync.ValidationError:
+		code = http.StatusBadRequest
+	case *calsync.QuotaError:
+		code = http.StatusTooManyRequests
+	}
+	write(w, code, &ErrorResponse{Error: err.Error(), Changes: calsyncpb.FromChanges(changes)})
+}
+
+func write(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
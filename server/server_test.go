@@ -0,0 +1,110 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ginabythebay/calsync"
+	"github.com/ginabythebay/calsync/calsynctest"
+)
+
+func newEvents(n int) []*calsync.Event {
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	var events []*calsync.Event
+	for i := 0; i < n; i++ {
+		events = append(events, &calsync.Event{
+			Title: fmt.Sprintf("event %d", i),
+			Start: start.Add(time.Duration(i) * time.Hour),
+			End:   start.Add(time.Duration(i)*time.Hour + 30*time.Minute),
+			SrcID: fmt.Sprintf("src%d", i),
+		})
+	}
+	return events
+}
+
+func post(t *testing.T, h http.Handler, path string, in, out interface{}) int {
+	b, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", path, bytes.NewReader(b)))
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+		t.Errorf("%s: got content type %q", path, ct)
+	}
+	if err = json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+		t.Fatalf("%s: %v in %s", path, err, rec.Body)
+	}
+	return rec.Code
+}
+
+func TestServer(t *testing.T) {
+	cs := calsynctest.NewServer()
+	s := New(cs.Client())
+	events := newEvents(2)
+
+	planned := &SyncResponse{}
+	if code := post(t, s, "/v1/plan", &SyncRequest{Scope: "scope", Events: events}, planned); code != http.StatusOK {
+		t.Fatalf("plan: got status %d", code)
+	}
+	if n := len(planned.Changes.Adds); n != 2 {
+		t.Errorf("plan: got %d adds, want 2", n)
+	}
+	if n := len(cs.Events("primary")); n != 0 {
+		t.Errorf("plan: got %d events in the calendar, want 0", n)
+	}
+
+	synced := &SyncResponse{}
+	if code := post(t, s, "/v1/sync", &SyncRequest{Scope: "scope", Events: events}, synced); code != http.StatusOK {
+		t.Fatalf("sync: got status %d", code)
+	}
+	if n := len(synced.Changes.Adds); n != 2 {
+		t.Errorf("sync: got %d adds, want 2", n)
+	}
+
+	fetched := &FetchResponse{}
+	if code := post(t, s, "/v1/fetch", &FetchRequest{Scope: "scope"}, fetched); code != http.StatusOK {
+		t.Fatalf("fetch: got status %d", code)
+	}
+	if len(fetched.Events) != 2 || fetched.Events[0].SrcID != "src0" || !fetched.Events[0].Start.Equal(events[0].Start) {
+		t.Errorf("fetch: got %v, want the synced events", fetched.Events)
+	}
+}
+
+func TestServerErrors(t *testing.T) {
+	cs := calsynctest.NewServer()
+	s := New(cs.Client())
+
+	invalid := newEvents(2)
+	invalid[1].SrcID = invalid[0].SrcID
+	for _, tc := range []struct {
+		path string
+		in   interface{}
+		code int
+	}{
+		{"/v1/sync", &SyncRequest{Events: newEvents(1)}, http.StatusBadRequest},
+		{"/v1/sync", &SyncRequest{Scope: "scope", Events: invalid}, http.StatusBadRequest},
+		{"/v1/fetch", &FetchRequest{}, http.StatusBadRequest},
+		{"/v1/fetch", "not a request", http.StatusBadRequest},
+		{"/v1/sync", &SyncRequest{Scope: "a scope that is much too long to be allowed", Events: newEvents(1)}, http.StatusBadRequest},
+	} {
+		out := &ErrorResponse{}
+		if code := post(t, s, tc.path, tc.in, out); code != tc.code {
+			t.Errorf("%s %v: got status %d, want %d", tc.path, tc.in, code, tc.code)
+		}
+		if out.Error == "" {
+			t.Errorf("%s %v: expected an error message", tc.path, tc.in)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/v1/fetch", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET: got status %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,109 @@
+package calsync
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/context"
+)
+
+// SheetsScope is the oauth2 scope that SheetSource needs, besides
+// Scope, so that one client can both read a sheet and sync it.  A token
+// authorized before it was requested has to be authorized again.
+const SheetsScope = "https://www.googleapis.com/auth/spreadsheets.readonly"
+
+// sheetsURL is where the google sheets api is served.  Tests replace
+// it.
+var sheetsURL = "https://sheets.googleapis.com/v4/spreadsheets/"
+
+// SheetSource returns a Source that reads the rows of a google sheet,
+// such as a schedule that event owners keep up to date, as ReadCSV
+// reads the rows of a CSV file with mapping, so that it no longer
+// needs to be exported by hand.  The first row of sheetRange, such as
+// "Schedule!A1:F", must hold the column headers.  client must be
+// authorized for SheetsScope.
+//
+// Cells are read as the sheet formats them, so Layouts and DateLayout
+// must match how it displays dates and times.
+func SheetSource(client *http.Client, spreadsheetID, sheetRange string, mapping ColumnMap) Source {
+	return SourceFunc(func(ctx context.Context) ([]*Event, error) {
+		rows, err := readSheet(ctx, client, spreadsheetID, sheetRange)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read sheet %s: %v", spreadsheetID, err)
+		}
+		if len(rows) == 0 {
+			return nil, fmt.Errorf("sheet: no header row")
+		}
+		cols, err := mapping.columns(rows[0])
+		if err != nil {
+			return nil, fmt.Errorf("sheet: %v", err)
+		}
+		var events []*Event
+		for i, record := range rows[1:] {
+			if isBlank(record) {
+				continue
+			}
+			ev, err := mapping.event(cols, record)
+			if err != nil {
+				return nil, fmt.Errorf("sheet row %d: %v", i+2, err)
+			}
+			events = append(events, ev)
+		}
+		return events, nil
+	})
+}
+
+// pathEscape escapes s to be one segment of a url path, "/" included,
+// as url.PathEscape does, which go 1.7 lacks.
+func pathEscape(s string) string {
+	return strings.Replace((&url.URL{Path: s}).EscapedPath(), "/", "%2F", -1)
+}
+
+// readSheet returns the formatted values of the cells of sheetRange,
+// by row.  Rows end at their last non-empty cell.
+func readSheet(ctx context.Context, client *http.Client, spreadsheetID, sheetRange string) ([][]string, error) {
+	u := sheetsURL + pathEscape(spreadsheetID) + "/values/" + pathEscape(sheetRange) +
+		"?majorDimension=ROWS&valueRenderOption=FORMATTED_VALUE"
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Error.Message != "" {
+			return nil, fmt.Errorf("%s: %s", resp.Status, apiErr.Error.Message)
+		}
+		return nil, fmt.Errorf("%s", resp.Status)
+	}
+	var values struct {
+		Values [][]interface{} `json:"values"`
+	}
+	if err = json.Unmarshal(body, &values); err != nil {
+		return nil, err
+	}
+	rows := make([][]string, len(values.Values))
+	for i, row := range values.Values {
+		rows[i] = make([]string, len(row))
+		for j, cell := range row {
+			rows[i][j] = fmt.Sprint(cell)
+		}
+	}
+	return rows, nil
+}
@@ -0,0 +1,64 @@
+package calsync
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestSheetSource(t *testing.T) {
+	var gotPath string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.EscapedPath()
+		if strings.HasPrefix(r.URL.Path, "/missing/") {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error": {"code": 404, "message": "Requested entity was not found."}}`)
+			return
+		}
+		fmt.Fprint(w, `{"range": "Schedule!A1:D4", "majorDimension": "ROWS", "values": [
+			["Event", "Starts", "Venue", "Id"],
+			["Yoga", "5/1/2017 9:00", "Gym", "y1"],
+			[],
+			["Swim", "5/2/2017 9:00", "", 7]
+		]}`)
+	}))
+	defer srv.Close()
+	defer func(old string) { sheetsURL = old }(sheetsURL)
+	sheetsURL = srv.URL + "/"
+
+	mapping := ColumnMap{
+		Title:    "Event",
+		Start:    "Starts",
+		Where:    "Venue",
+		SrcID:    "Id",
+		Layouts:  []string{"1/2/2006 15:04"},
+		Location: time.UTC,
+		Duration: time.Hour,
+	}
+	ctx := context.Background()
+	events, err := SheetSource(http.DefaultClient, "sheet1", "Schedule!A1:D", mapping).Events(ctx)
+	ok(t, err)
+	equals(t, "/sheet1/values/Schedule%21A1:D", gotPath)
+	equals(t, 2, len(events))
+	equals(t, "Yoga", events[0].Title)
+	equals(t, "Gym", events[0].Where)
+	equals(t, time.Date(2017, 5, 1, 10, 0, 0, 0, time.UTC), events[0].End)
+	equals(t, "7", events[1].SrcID)
+
+	_, err = SheetSource(http.DefaultClient, "missing", "A1:D", mapping).Events(ctx)
+	assert(t, err != nil && strings.Contains(err.Error(), "Requested entity was not found"), "got %v", err)
+
+	mapping.Start = "Begins"
+	_, err = SheetSource(http.DefaultClient, "sheet1", "A1:D", mapping).Events(ctx)
+	assert(t, err != nil && strings.Contains(err.Error(), `no column "Begins"`), "got %v", err)
+}
+
+func TestPathEscape(t *testing.T) {
+	equals(t, "Schedule%21A1:D", pathEscape("Schedule!A1:D"))
+	equals(t, "%27Q1%2F2017%20%3F%27%21A:B", pathEscape("'Q1/2017 ?'!A:B"))
+}
@@ -0,0 +1,243 @@
+package calsync
+
+import (
+	"fmt"
+	"math/rand"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+// Performance targets, checked by TestSoak and measured by the
+// benchmarks in this file:
+//
+//   - After every sync, the calendar holds exactly the upcoming source
+//     events, however many syncs have come before.
+//   - The heap in use after a sync doesn't grow with the number of
+//     syncs that came before it.
+//   - The latency of a sync doesn't grow with the number of syncs that
+//     came before it.
+//   - A sync with nothing to do makes one api request to look up the
+//     calendar, plus one per page of events in scope, and no writes.
+//
+// Run the benchmarks with
+//
+//   go test -run NONE -bench . -benchmem
+
+const (
+	soakScope  = "soak"
+	soakEvents = 300
+)
+
+// soak simulates a source that changes a little every day, synced once
+// a day into a fake calendar.
+type soak struct {
+	server *calsynctest.Server
+	rnd    *rand.Rand
+	now    time.Time
+	src    []*Event
+	nextID int
+}
+
+func newSoak() *soak {
+	s := &soak{
+		server: calsynctest.NewServer(),
+		rnd:    rand.New(rand.NewSource(1)),
+		now:    time.Now(),
+	}
+	for i := 0; i < soakEvents; i++ {
+		s.src = append(s.src, s.newEvent())
+	}
+	return s
+}
+
+func (s *soak) newEvent() *Event {
+	s.nextID++
+	start := s.now.Add(time.Duration(1+s.rnd.Intn(90*24)) * time.Hour).Truncate(time.Minute)
+	return &Event{
+		Title:       fmt.Sprintf("event %d", s.nextID),
+		Start:       start,
+		End:         start.Add(time.Hour),
+		Where:       "somewhere",
+		Description: fmt.Sprintf("description %d", s.nextID),
+		SrcID:       fmt.Sprintf("id%d", s.nextID),
+	}
+}
+
+// churn removes, modifies and adds a few percent of the source events,
+// as a busy source might in a day.
+func (s *soak) churn() {
+	n := len(s.src) / 20
+	for i := 0; i < n; i++ {
+		j := s.rnd.Intn(len(s.src))
+		s.src = append(s.src[:j], s.src[j+1:]...)
+	}
+	for i := 0; i < n; i++ {
+		j := s.rnd.Intn(len(s.src))
+		changed := *s.src[j]
+		changed.Title += " (changed)"
+		changed.Start = changed.Start.Add(30 * time.Minute)
+		changed.End = changed.End.Add(30 * time.Minute)
+		s.src[j] = &changed
+	}
+	for i := 0; i < n; i++ {
+		s.src = append(s.src, s.newEvent())
+	}
+}
+
+func (s *soak) sync(ctx context.Context) (*Changes, error) {
+	return Sync(ctx, s.server.Client(), soakScope, s.src)
+}
+
+// verify checks that the calendar holds exactly the source events.
+func (s *soak) verify(ctx context.Context) error {
+	calEvents, err := Fetch(ctx, s.server.Client(), soakScope)
+	if err != nil {
+		return err
+	}
+	if len(calEvents) != len(s.src) {
+		return fmt.Errorf("calendar has %d events, source has %d", len(calEvents), len(s.src))
+	}
+	calMap := map[string]*Event{}
+	for _, ev := range calEvents {
+		calMap[ev.SrcID] = ev
+	}
+	for _, ev := range s.src {
+		calEv, ok := calMap[ev.SrcID]
+		if !ok {
+			return fmt.Errorf("%s is missing from the calendar", ev)
+		}
+		if !ev.equal(calEv) {
+			return fmt.Errorf("%s drifted to %s", ev, calEv)
+		}
+	}
+	return nil
+}
+
+func heapInUse() uint64 {
+	runtime.GC()
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return m.HeapInuse
+}
+
+func TestSoak(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping soak test in short mode")
+	}
+	const (
+		days   = 90
+		warmup = 10
+	)
+	ctx := context.Background()
+	s := newSoak()
+
+	var heapAfterWarmup uint64
+	var early, late time.Duration
+	for day := 0; day < days; day++ {
+		s.churn()
+		start := time.Now()
+		if _, err := s.sync(ctx); err != nil {
+			t.Fatalf("day %d: %v", day, err)
+		}
+		elapsed := time.Since(start)
+		if err := s.verify(ctx); err != nil {
+			t.Fatalf("day %d: %v", day, err)
+		}
+		s.server.Purge()
+
+		switch {
+		case day < warmup:
+		case day < 2*warmup:
+			early += elapsed
+		case day >= days-warmup:
+			late += elapsed
+		}
+		if day == warmup {
+			heapAfterWarmup = heapInUse()
+		}
+	}
+
+	if heap := heapInUse(); heap > 2*heapAfterWarmup+(4<<20) {
+		t.Errorf("heap grew from %d to %d bytes", heapAfterWarmup, heap)
+	}
+	if late > 3*early+warmup*10*time.Millisecond {
+		t.Errorf("syncs slowed down from %v to %v per %d days", early, late, warmup)
+	}
+}
+
+func TestSteadyStateRequests(t *testing.T) {
+	ctx := context.Background()
+	s := newSoak()
+	if _, err := s.sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	before := s.server.Requests()
+	changes, err := s.sync(ctx)
+	ok(t, err)
+	assert(t, changes.empty(), "expected no changes, got %s", changes)
+	// One to look up the calendar timezone, and one per page of events.
+	pages := (len(s.src) + calsynctest.DefaultPageSize - 1) / calsynctest.DefaultPageSize
+	equals(t, 1+pages, s.server.Requests()-before)
+}
+
+// BenchmarkSyncDailyChurn measures a day in the life of TestSoak: a
+// sync of soakEvents events, a few percent of which changed.
+func BenchmarkSyncDailyChurn(b *testing.B) {
+	ctx := context.Background()
+	s := newSoak()
+	if _, err := s.sync(ctx); err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		s.churn()
+		s.server.Purge()
+		b.StartTimer()
+		if _, err := s.sync(ctx); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkSyncNoChanges measures a sync of soakEvents events, none of
+// which changed.
+func BenchmarkSyncNoChanges(b *testing.B) {
+	ctx := context.Background()
+	s := newSoak()
+	if _, err := s.sync(ctx); err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := s.sync(ctx); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkGetOperations measures planning alone, for a large number
+// of events, without any api calls.
+func BenchmarkGetOperations(b *testing.B) {
+	s := newSoak()
+	for len(s.src) < 5000 {
+		s.src = append(s.src, s.newEvent())
+	}
+	var calEvents []*Event
+	for _, ev := range s.src {
+		calEvents = append(calEvents, syncedCalEvent(ev))
+	}
+	s.churn()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := (planner{}).getOperations(s.now, calEvents, s.src); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package calsync
+
+import (
+	"net/url"
+
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+// source returns the url and title of ev's source, as written to
+// google calendar: without a url, there is no title either.
+func (ev *Event) source() (link, title string) {
+	if ev.SourceURL == "" {
+		return "", ""
+	}
+	return ev.SourceURL, ev.SourceTitle
+}
+
+// makeSource returns the source of ev as google calendar records it,
+// or nil if ev has no SourceURL.
+func makeSource(ev *Event) *calendar.EventSource {
+	if ev.SourceURL == "" {
+		return nil
+	}
+	return &calendar.EventSource{Title: ev.SourceTitle, Url: ev.SourceURL}
+}
+
+// validSourceURL reports whether google calendar accepts u as the url
+// of an event's source, which must be http or https.
+func validSourceURL(u string) bool {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return false
+	}
+	return (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
@@ -0,0 +1,62 @@
+package calsync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+	calendar "google.golang.org/api/calendar/v3"
+
+	"golang.org/x/net/context"
+)
+
+func TestSourceURL(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	ev := newSrcEvent("a", time.Now().Add(time.Hour).Truncate(time.Second))
+	ev.SourceURL = "https://tickets.example.com/ABC-123"
+	ev.SourceTitle = "ABC-123"
+
+	_, err := Sync(ctx, s.Client(), "scope", []*Event{ev})
+	ok(t, err)
+	events := s.Events("primary")
+	equals(t, 1, len(events))
+	equals(t, "https://tickets.example.com/ABC-123", events[0].Source.Url)
+	equals(t, "ABC-123", events[0].Source.Title)
+
+	fetched, err := Fetch(ctx, s.Client(), "scope")
+	ok(t, err)
+	equals(t, ev.SourceURL, fetched[0].SourceURL)
+	equals(t, ev.SourceTitle, fetched[0].SourceTitle)
+
+	changes, err := Sync(ctx, s.Client(), "scope", []*Event{ev})
+	ok(t, err)
+	assert(t, changes.empty(), "expected no changes, got %s", changes)
+
+	ev.SourceTitle = "ABC-124"
+	changes, err = Sync(ctx, s.Client(), "scope", []*Event{ev})
+	ok(t, err)
+	equals(t, []string{"SourceTitle"}, changes.UpdateDetails()[0].Fields)
+	equals(t, "ABC-124", s.Events("primary")[0].Source.Title)
+
+	// Without a url, there is no source, and the title is ignored.
+	ev.SourceURL = ""
+	changes, err = Sync(ctx, s.Client(), "scope", []*Event{ev})
+	ok(t, err)
+	equals(t, []string{"SourceURL", "SourceTitle"}, changes.UpdateDetails()[0].Fields)
+	equals(t, (*calendar.EventSource)(nil), s.Events("primary")[0].Source)
+	changes, err = Sync(ctx, s.Client(), "scope", []*Event{ev})
+	ok(t, err)
+	assert(t, changes.empty(), "expected no changes, got %s", changes)
+}
+
+func TestValidateSourceURL(t *testing.T) {
+	for _, u := range []string{"ftp://example.com/x", "example.com", "javascript:alert(1)"} {
+		ev := newSrcEvent("a", time.Now())
+		ev.SourceURL = u
+		assert(t, Validate([]*Event{ev}) != nil, "expected %q to be invalid", u)
+	}
+	ev := newSrcEvent("a", time.Now())
+	ev.SourceURL = "http://example.com/x"
+	ok(t, Validate([]*Event{ev}))
+}
@@ -0,0 +1,126 @@
+package calsync
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// StateStore persists state between syncs, such as the sync token used
+// by Incremental.  Keys are chosen by this package and values are
+// opaque to the store.
+type StateStore interface {
+	// Get returns the value stored under key.  If nothing has been
+	// stored under key, it returns a nil value and a nil error.
+	Get(key string) ([]byte, error)
+
+	// Put stores value under key, replacing any previous value.  An
+	// empty value may be dropped, as Get treats it like a missing one.
+	Put(key string, value []byte) error
+}
+
+type memoryStore struct {
+	mu     sync.Mutex
+	values map[string][]byte
+}
+
+// NewMemoryStore returns a StateStore that keeps everything in memory.
+// It is useful for tests and for long-running processes that sync
+// repeatedly and don't need to survive a restart.
+func NewMemoryStore() StateStore {
+	return &memoryStore{values: map[string][]byte{}}
+}
+
+func (s *memoryStore) Get(key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.values[key]
+	if !ok {
+		return nil, nil
+	}
+	return append([]byte(nil), v...), nil
+}
+
+func (s *memoryStore) Put(key string, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = append([]byte(nil), value...)
+	return nil
+}
+
+type fileStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// NewFileStore returns a StateStore that keeps each value in a file of
+// its own in dir, which is created if needed, so that state survives
+// restarts, as Incremental and Journal need.  Values are replaced
+// atomically, so a crash leaves either the old value or the new one.
+// Storing an empty value removes the file.
+func NewFileStore(dir string) StateStore {
+	return &fileStore{dir: dir}
+}
+
+// path returns the file key is kept in.  Keys hold slashes, which are
+// escaped along with anything else that isn't safe in a file name.
+func (s *fileStore) path(key string) string {
+	return filepath.Join(s.dir, url.QueryEscape(key))
+}
+
+func (s *fileStore) Get(key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := ioutil.ReadFile(s.path(key))
+	if os.IsNotExist(err) || len(b) == 0 {
+		return nil, nil
+	}
+	return b, err
+}
+
+func (s *fileStore) Put(key string, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	path := s.path(key)
+	if len(value) == 0 {
+		err := os.Remove(path)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return err
+	}
+	return replaceFile(path, value)
+}
+
+// replaceFile atomically replaces the file at path with one holding
+// value, so that a crash leaves either the old file or the new one.
+func replaceFile(path string, value []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(value); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err = f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err = os.Rename(f.Name(), path); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
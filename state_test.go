@@ -0,0 +1,68 @@
+package calsync
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+func testStore(t *testing.T, store StateStore) {
+	got, err := store.Get("calsync/primary/scope/sync")
+	ok(t, err)
+	equals(t, []byte(nil), got)
+
+	ok(t, store.Put("calsync/primary/scope/sync", []byte("one")))
+	ok(t, store.Put("calsync/primary/other/sync", []byte("two")))
+	got, err = store.Get("calsync/primary/scope/sync")
+	ok(t, err)
+	equals(t, "one", string(got))
+
+	ok(t, store.Put("calsync/primary/scope/sync", []byte("three")))
+	got, err = store.Get("calsync/primary/scope/sync")
+	ok(t, err)
+	equals(t, "three", string(got))
+
+	ok(t, store.Put("calsync/primary/scope/sync", nil))
+	got, err = store.Get("calsync/primary/scope/sync")
+	ok(t, err)
+	equals(t, 0, len(got))
+	got, err = store.Get("calsync/primary/other/sync")
+	ok(t, err)
+	equals(t, "two", string(got))
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "calsync")
+	ok(t, err)
+	defer os.RemoveAll(dir)
+	dir = filepath.Join(dir, "state")
+	testStore(t, NewFileStore(dir))
+
+	// Only the remaining value is left, with no temporary files.
+	files, err := ioutil.ReadDir(dir)
+	ok(t, err)
+	equals(t, 1, len(files))
+
+	// State survives a new store, as it would a restart.
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	src := []*Event{newSrcEvent("a", time.Now().Add(time.Hour).Truncate(time.Second))}
+	_, err = Sync(ctx, s.Client(), "scope", src, Incremental(NewFileStore(dir)))
+	ok(t, err)
+	saved, err := NewFileStore(dir).Get("calsync/primary/scope/sync")
+	ok(t, err)
+	assert(t, len(saved) != 0, "expected the sync state to be saved")
+	changes, err := Sync(ctx, s.Client(), "scope", src, Incremental(NewFileStore(dir)))
+	ok(t, err)
+	assert(t, changes.empty(), "expected no changes, got %s", changes)
+}
@@ -0,0 +1,47 @@
+package calsync
+
+// EventStatus is the status of an event, as google calendar records it.
+type EventStatus string
+
+const (
+	// EventConfirmed is the status of events that will happen.  An
+	// empty Status means the same.
+	EventConfirmed EventStatus = "confirmed"
+
+	// EventTentative is the status of events that may happen.
+	EventTentative EventStatus = "tentative"
+
+	// EventCancelled is the status of events that won't happen after
+	// all.  Sync cancels the calendar event, rather than deleting it,
+	// so that google calendar sends attendees a cancellation, and
+	// cancelled events that were never synced aren't added.  Google
+	// calendar then hides the event, and it is no longer fetched.
+	EventCancelled EventStatus = "cancelled"
+)
+
+// status returns the status of ev, EventConfirmed if it isn't set.
+func (ev *Event) status() EventStatus {
+	if ev.Status == "" {
+		return EventConfirmed
+	}
+	return ev.Status
+}
+
+// known reports whether s is one of the statuses google calendar
+// accepts, or empty.
+func (s EventStatus) known() bool {
+	switch s {
+	case "", EventConfirmed, EventTentative, EventCancelled:
+		return true
+	}
+	return false
+}
+
+// parseStatus returns the Status of an event google calendar says has
+// status s, leaving it empty if the event is confirmed.
+func parseStatus(s string) EventStatus {
+	if EventStatus(s) == EventConfirmed {
+		return ""
+	}
+	return EventStatus(s)
+}
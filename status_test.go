@@ -0,0 +1,59 @@
+package calsync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+func TestStatus(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	a, b := newSrcEvent("a", start), newSrcEvent("b", start)
+	a.Status = EventTentative
+	b.Status = EventCancelled
+
+	// b was never synced, so there is nothing to cancel.
+	changes, err := Sync(ctx, s.Client(), "scope", []*Event{a, b})
+	ok(t, err)
+	equals(t, 1, len(changes.Adds))
+	events := s.Events("primary")
+	equals(t, 1, len(events))
+	equals(t, "tentative", events[0].Status)
+
+	fetched, err := Fetch(ctx, s.Client(), "scope")
+	ok(t, err)
+	equals(t, EventTentative, fetched[0].Status)
+
+	a.Status = EventConfirmed
+	changes, err = Sync(ctx, s.Client(), "scope", []*Event{a, b})
+	ok(t, err)
+	equals(t, []string{"Status"}, changes.UpdateDetails()[0].Fields)
+	fetched, err = Fetch(ctx, s.Client(), "scope")
+	ok(t, err)
+	equals(t, EventStatus(""), fetched[0].Status)
+
+	// Cancelling a synced event updates it, rather than deleting it.
+	a.Status = EventCancelled
+	changes, err = Sync(ctx, s.Client(), "scope", []*Event{a, b})
+	ok(t, err)
+	equals(t, 0, len(changes.Deletes))
+	equals(t, []string{"Status"}, changes.UpdateDetails()[0].Fields)
+	// Google calendar hides cancelled events.
+	equals(t, 0, len(s.Events("primary")))
+
+	changes, err = Sync(ctx, s.Client(), "scope", []*Event{a, b})
+	ok(t, err)
+	assert(t, changes.empty(), "expected no changes, got %s", changes)
+}
+
+func TestValidateStatus(t *testing.T) {
+	ev := newSrcEvent("a", time.Now())
+	ev.Status = "postponed"
+	err := Validate([]*Event{ev})
+	assert(t, err != nil, "expected an error for an unknown Status")
+}
@@ -0,0 +1,86 @@
+package calsync
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// runnerStatus is what StatusHandler serves.
+type runnerStatus struct {
+	Scope       string     `json:"scope"`
+	Healthy     bool       `json:"healthy"`
+	Runs        int        `json:"runs"`
+	LastRun     *time.Time `json:"last_run,omitempty"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	Failures    int        `json:"failures"`
+	Next        *time.Time `json:"next,omitempty"`
+
+	// LastChanges counts the operations of each kind made by the latest
+	// sync.
+	LastChanges *changeCounts `json:"last_changes,omitempty"`
+
+	Running bool `json:"running"`
+	Done    int  `json:"done"`
+	Total   int  `json:"total"`
+}
+
+type changeCounts struct {
+	Deletes   int `json:"deletes"`
+	Updates   int `json:"updates"`
+	Adds      int `json:"adds"`
+	Conflicts int `json:"conflicts"`
+	Orphans   int `json:"orphans"`
+}
+
+// StatusHandler returns an http.Handler that serves r's Status as JSON,
+// for example as a health probe for a service that syncs continuously:
+//
+//	http.Handle("/statusz", r.StatusHandler())
+//
+// The response is a 503 once more than maxFailures syncs in a row have
+// failed, and a 200 otherwise.  The times are omitted until there is
+// one to report.
+func (r *Runner) StatusHandler(maxFailures int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		s := r.Status()
+		out := &runnerStatus{
+			Scope:       r.scope,
+			Healthy:     s.Failures <= maxFailures,
+			Runs:        s.Runs,
+			LastRun:     optionalTime(s.LastRun),
+			LastSuccess: optionalTime(s.LastSuccess),
+			Failures:    s.Failures,
+			Next:        optionalTime(s.Next),
+			Running:     s.Running,
+			Done:        s.Done,
+			Total:       s.Total,
+		}
+		if s.LastErr != nil {
+			out.LastError = s.LastErr.Error()
+		}
+		if c := s.LastChanges; c != nil {
+			out.LastChanges = &changeCounts{
+				Deletes:   len(c.Deletes),
+				Updates:   len(c.Updates),
+				Adds:      len(c.Adds),
+				Conflicts: len(c.Conflicts),
+				Orphans:   len(c.Orphans),
+			}
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-cache")
+		if !out.Healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(out)
+	})
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
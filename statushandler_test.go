@@ -0,0 +1,65 @@
+package calsync
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+func TestStatusHandler(t *testing.T) {
+	s := calsynctest.NewServer()
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	src := []*Event{newSrcEvent("a", start), newSrcEvent("b", start.Add(time.Hour))}
+	var srcErr error
+	r := NewRunner(s.Client(), "scope", time.Hour, SourceFunc(func(context.Context) ([]*Event, error) {
+		return src, srcErr
+	}))
+	h := r.StatusHandler(1)
+	get := func(wantCode int) map[string]interface{} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/statusz", nil))
+		equals(t, wantCode, rec.Code)
+		equals(t, "application/json; charset=utf-8", rec.Header().Get("Content-Type"))
+		got := map[string]interface{}{}
+		ok(t, json.Unmarshal(rec.Body.Bytes(), &got))
+		return got
+	}
+
+	got := get(http.StatusOK)
+	equals(t, "scope", got["scope"])
+	equals(t, 0.0, got["runs"])
+	_, found := got["last_run"]
+	assert(t, !found, "expected no last_run before any run, got %v", got)
+
+	_, err := r.RunOnce(context.Background())
+	ok(t, err)
+	got = get(http.StatusOK)
+	equals(t, 1.0, got["runs"])
+	equals(t, true, got["healthy"])
+	equals(t, 2.0, got["done"])
+	equals(t, 2.0, got["total"])
+	equals(t, false, got["running"])
+	equals(t, map[string]interface{}{
+		"deletes": 0.0, "updates": 0.0, "adds": 2.0, "conflicts": 0.0, "orphans": 0.0,
+	}, got["last_changes"])
+	_, found = got["last_success"]
+	assert(t, found, "expected a last_success, got %v", got)
+
+	srcErr = errors.New("source unavailable")
+	r.RunOnce(context.Background())
+	got = get(http.StatusOK)
+	equals(t, "source unavailable", got["last_error"])
+	equals(t, 1.0, got["failures"])
+
+	r.RunOnce(context.Background())
+	got = get(http.StatusServiceUnavailable)
+	equals(t, false, got["healthy"])
+	equals(t, 2.0, got["failures"])
+}
@@ -0,0 +1,133 @@
+/*
+Package strategies holds ready made calsync.ConflictResolvers, for use
+with calsync.ResolveConflicts.
+
+Resolvers that can't always decide take another resolver to fall back
+on, so they can be composed.  For example, to take whichever side was
+edited most recently, except that the calendar's location always wins,
+and the source wins if we can't tell which is newer:
+
+	newest := strategies.NewestWins(sourceUpdated, strategies.SourceWins)
+	r := strategies.FieldMerge(map[string]calsync.ConflictResolver{
+		"Where": strategies.CalendarWins,
+	}, newest)
+	changes, err := calsync.Sync(ctx, client, scope, events, calsync.ResolveConflicts(r))
+*/
+package strategies
+
+import (
+	"time"
+
+	"github.com/ginabythebay/calsync"
+)
+
+// SourceWins overwrites the calendar edits with the source, deleting
+// the event if it is no longer in the source.
+var SourceWins calsync.ConflictResolver = calsync.ConflictResolverFunc(
+	func(c *calsync.Conflict) (*calsync.Event, error) {
+		return c.Source, nil
+	})
+
+// CalendarWins keeps the calendar edits, even if the event is no longer
+// in the source.
+var CalendarWins calsync.ConflictResolver = calsync.ConflictResolverFunc(
+	func(c *calsync.Conflict) (*calsync.Event, error) {
+		return c.Calendar, nil
+	})
+
+// NewestWins picks whichever of the source and the calendar changed the
+// event most recently.  sourceUpdated returns when a source event was
+// last changed.  If either time is unknown, which is the zero time, or
+// they are the same, or the event is no longer in the source, tie
+// decides.
+func NewestWins(sourceUpdated func(*calsync.Event) time.Time, tie calsync.ConflictResolver) calsync.ConflictResolver {
+	return calsync.ConflictResolverFunc(func(c *calsync.Conflict) (*calsync.Event, error) {
+		if c.Source == nil {
+			return tie.Resolve(c)
+		}
+		src, cal := sourceUpdated(c.Source), c.CalendarUpdated()
+		switch {
+		case src.IsZero() || cal.IsZero() || src.Equal(cal):
+			return tie.Resolve(c)
+		case src.After(cal):
+			return c.Source, nil
+		}
+		return c.Calendar, nil
+	})
+}
+
+// FieldMerge builds an event field by field, taking each field that
+// differs from the side its rule picks.  rules maps field names, as
+// found in calsync.Conflict.Fields, to the resolver that picks a side
+// for that field.  Fields without a rule use fallback.  A rule that
+// picks the calendar takes the field from the calendar; any other
+// choice takes it from the source.  The rule for "AllDay" also decides
+// "Start" and "End" when AllDay differs, as they only make sense
+// together.
+//
+// If the event is no longer in the source, fallback decides.
+func FieldMerge(rules map[string]calsync.ConflictResolver, fallback calsync.ConflictResolver) calsync.ConflictResolver {
+	return calsync.ConflictResolverFunc(func(c *calsync.Conflict) (*calsync.Event, error) {
+		if c.Source == nil {
+			return fallback.Resolve(c)
+		}
+		fromCalendar := map[string]bool{}
+		for _, field := range c.Fields {
+			rule, ok := rules[field]
+			if !ok {
+				rule = fallback
+			}
+			pick, err := rule.Resolve(c)
+			if err != nil {
+				return nil, err
+			}
+			fromCalendar[field] = pick == c.Calendar
+		}
+		if allDay, ok := fromCalendar["AllDay"]; ok {
+			fromCalendar["Start"] = allDay
+			fromCalendar["End"] = allDay
+		}
+
+		merged := *c.Source
+		if fromCalendar["Title"] {
+			merged.Title = c.Calendar.Title
+		}
+		if fromCalendar["AllDay"] {
+			merged.AllDay = c.Calendar.AllDay
+		}
+		if fromCalendar["Start"] {
+			merged.Start = c.Calendar.Start
+		}
+		if fromCalendar["End"] {
+			merged.End = c.Calendar.End
+		}
+		if fromCalendar["Where"] {
+			merged.Where = c.Calendar.Where
+		}
+		if fromCalendar["Description"] {
+			merged.Description = c.Calendar.Description
+		}
+		if fromCalendar["Attendees"] {
+			merged.Attendees = c.Calendar.Attendees
+		}
+		return &merged, nil
+	})
+}
+
+// AskHuman asks approve whether to overwrite each calendar edit with the
+// source, or to delete the event if it is no longer in the source.  If
+// approve returns false, the calendar edits are kept.  approve may, for
+// example, prompt on a terminal or wait for a review.  An error from
+// approve makes Sync fail without modifying anything.
+func AskHuman(approve func(c *calsync.Conflict) (bool, error)) calsync.ConflictResolver {
+	return calsync.ConflictResolverFunc(func(c *calsync.Conflict) (*calsync.Event, error) {
+		ok, err := approve(c)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return c.Source, nil
+		}
+		return c.Calendar, nil
+	})
+}
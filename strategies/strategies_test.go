@@ -0,0 +1,141 @@
+package strategies
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ginabythebay/calsync"
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+var start = time.Date(2017, 5, 1, 10, 0, 0, 0, time.UTC)
+
+func newConflict() *calsync.Conflict {
+	src := &calsync.Event{
+		Title: "lunch",
+		Start: start,
+		End:   start.Add(time.Hour),
+		Where: "cafe",
+		SrcID: "lunch",
+	}
+	cal := *src
+	cal.Start = start.Add(time.Hour)
+	cal.End = start.Add(2 * time.Hour)
+	cal.Where = "park"
+	return &calsync.Conflict{Source: src, Calendar: &cal, Fields: []string{"Start", "End", "Where"}}
+}
+
+func resolve(t *testing.T, r calsync.ConflictResolver, c *calsync.Conflict) *calsync.Event {
+	ev, err := r.Resolve(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ev
+}
+
+func TestSides(t *testing.T) {
+	c := newConflict()
+	if got := resolve(t, SourceWins, c); got != c.Source {
+		t.Errorf("SourceWins picked %v", got)
+	}
+	if got := resolve(t, CalendarWins, c); got != c.Calendar {
+		t.Errorf("CalendarWins picked %v", got)
+	}
+}
+
+func TestFieldMerge(t *testing.T) {
+	c := newConflict()
+	r := FieldMerge(map[string]calsync.ConflictResolver{
+		"Where": CalendarWins,
+	}, SourceWins)
+	got := resolve(t, r, c)
+	if got.Where != "park" {
+		t.Errorf("got Where %q, want the calendar's", got.Where)
+	}
+	if !got.Start.Equal(c.Source.Start) || !got.End.Equal(c.Source.End) {
+		t.Errorf("got %v to %v, want the source's times", got.Start, got.End)
+	}
+	if c.Source.Where != "cafe" {
+		t.Errorf("modified the source")
+	}
+
+	// AllDay decides the times too.
+	c.Calendar.AllDay = true
+	c.Fields = append(c.Fields, "AllDay")
+	r = FieldMerge(map[string]calsync.ConflictResolver{
+		"AllDay": CalendarWins,
+	}, SourceWins)
+	got = resolve(t, r, c)
+	if !got.AllDay || !got.Start.Equal(c.Calendar.Start) || !got.End.Equal(c.Calendar.End) {
+		t.Errorf("got %+v, want the calendar's all day times", got)
+	}
+
+	c.Source = nil
+	if got = resolve(t, r, c); got != nil {
+		t.Errorf("got %v, want the fallback to delete", got)
+	}
+}
+
+func TestAskHuman(t *testing.T) {
+	c := newConflict()
+	for _, approve := range []bool{true, false} {
+		r := AskHuman(func(*calsync.Conflict) (bool, error) { return approve, nil })
+		want := c.Calendar
+		if approve {
+			want = c.Source
+		}
+		if got := resolve(t, r, c); got != want {
+			t.Errorf("approve %v: got %v", approve, got)
+		}
+	}
+	r := AskHuman(func(*calsync.Conflict) (bool, error) { return false, errors.New("interrupted") })
+	if _, err := r.Resolve(c); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestNewestWins(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	ev := newConflict().Source
+	ev.Start = time.Now().Add(time.Hour).Truncate(time.Second)
+	ev.End = ev.Start.Add(time.Hour)
+	src := []*calsync.Event{ev}
+	if _, err := calsync.Sync(ctx, s.Client(), "scope", src); err != nil {
+		t.Fatal(err)
+	}
+	edited := s.Events("primary")[0]
+	edited.Location = "park"
+	if _, err := s.Put("primary", edited); err != nil {
+		t.Fatal(err)
+	}
+	src[0].Title = "early lunch"
+
+	// The source changed before the calendar edit, so the edit stays.
+	var sourceUpdated time.Time
+	r := NewestWins(func(*calsync.Event) time.Time { return sourceUpdated }, SourceWins)
+	sourceUpdated = time.Now().Add(-time.Hour)
+	changes, err := calsync.Sync(ctx, s.Client(), "scope", src, calsync.ResolveConflicts(r))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes.Conflicts) != 1 || len(changes.Updates) != 0 {
+		t.Errorf("got %s, want the calendar to win", changes)
+	}
+
+	// Without a source time, the tie breaker decides.
+	sourceUpdated = time.Time{}
+	changes, err = calsync.Sync(ctx, s.Client(), "scope", src, calsync.ResolveConflicts(r))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes.Updates) != 1 {
+		t.Fatalf("got %s, want the source to win", changes)
+	}
+	if got := s.Events("primary")[0]; got.Summary != "early lunch" || got.Location != "cafe" {
+		t.Errorf("got %q at %q, want the source", got.Summary, got.Location)
+	}
+}
@@ -0,0 +1,51 @@
+package calsync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+func TestSyncAll(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	sources := map[string][]*Event{
+		"meetup": {newSrcEvent("a", start), newSrcEvent("b", start)},
+		"school": {newSrcEvent("c", start)},
+	}
+
+	all, err := SyncAll(ctx, s.Client(), sources)
+	ok(t, err)
+	equals(t, 2, len(all["meetup"].Adds))
+	equals(t, 1, len(all["school"].Adds))
+	equals(t, "school", all["school"].Manifest.Scope)
+	equals(t, 3, len(s.Events("primary")))
+
+	// Each scope only touches its own events.
+	sources["meetup"] = sources["meetup"][:1]
+	all, err = SyncAll(ctx, s.Client(), sources)
+	ok(t, err)
+	equals(t, 1, len(all["meetup"].Deletes))
+	assert(t, all["school"].empty(), "expected no changes, got %s", all["school"])
+	equals(t, 2, len(s.Events("primary")))
+
+	// A conflict in one scope stops every scope.
+	edited := s.Events("primary")[0]
+	edited.Summary = "edited"
+	_, err = s.Put("primary", edited)
+	ok(t, err)
+	sources["meetup"][0].Title = "changed"
+	sources["school"][0].Title = "changed"
+	_, err = SyncAll(ctx, s.Client(), sources, OnConflict(FailOnConflict))
+	assert(t, err != nil, "expected a conflict")
+	for _, ev := range s.Events("primary") {
+		assert(t, ev.Summary != "changed", "expected nothing to be modified")
+	}
+
+	_, err = SyncAll(ctx, s.Client(), map[string][]*Event{"this scope is far too long to be used": nil})
+	assert(t, err != nil, "expected an error for a long scope")
+}
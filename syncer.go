@@ -0,0 +1,75 @@
+package calsync
+
+import (
+	"fmt"
+	"net/http"
+
+	"golang.org/x/net/context"
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+// Syncer makes the google calendar service once, and reuses it for
+// each call, with any scope, so that programs that sync often, or sync
+// many scopes, don't pay for a new service, and its connections, each
+// time:
+//
+//	s, err := calsync.NewSyncer(client, calsync.CalendarName("Classes"))
+//	...
+//	changes, err := s.Sync(ctx, "yoga", yogaEvents)
+//	changes, err = s.Sync(ctx, "swim", swimEvents, calsync.MaxDeletes(5))
+//
+// Its methods work as the functions of the same name, with the options
+// given to NewSyncer followed by those given to each call.  It is safe
+// for concurrent use.
+type Syncer struct {
+	// svc is nil without a client.  The client is still passed along,
+	// for QuotaUser.
+	client *http.Client
+	svc    *calendar.Service
+	opts   []Opt
+}
+
+// NewSyncer returns a Syncer that uses client, and opts.  client may be
+// nil with WithBackend.
+func NewSyncer(client *http.Client, opts ...Opt) (*Syncer, error) {
+	s := &Syncer{client: client, opts: opts}
+	if client != nil {
+		var err error
+		if s.svc, err = calendar.New(client); err != nil {
+			return nil, fmt.Errorf("failed creating service: %v", err)
+		}
+	}
+	return s, nil
+}
+
+// Sync is Sync, with s.
+func (s *Syncer) Sync(ctx context.Context, scope string, srcEvents []*Event, opts ...Opt) (*Changes, error) {
+	return Sync(ctx, s.client, scope, srcEvents, s.with(opts)...)
+}
+
+// Plan is Plan, with s.
+func (s *Syncer) Plan(ctx context.Context, scope string, srcEvents []*Event, opts ...Opt) (*Changes, error) {
+	return Plan(ctx, s.client, scope, srcEvents, s.with(opts)...)
+}
+
+// Apply is Apply, with s.
+func (s *Syncer) Apply(ctx context.Context, scope string, plan *Changes, opts ...Opt) (*Changes, error) {
+	return Apply(ctx, s.client, scope, plan, s.with(opts)...)
+}
+
+// Fetch is Fetch, with s.
+func (s *Syncer) Fetch(ctx context.Context, scope string, opts ...Opt) ([]*Event, error) {
+	return Fetch(ctx, s.client, scope, s.with(opts)...)
+}
+
+// with returns the options for a call given opts: the service, if any,
+// then the options of s, then opts, in a new slice, as calls may append to
+// it.
+func (s *Syncer) with(opts []Opt) []Opt {
+	all := make([]Opt, 0, 1+len(s.opts)+len(opts))
+	if s.svc != nil {
+		all = append(all, WithService(s.svc))
+	}
+	all = append(all, s.opts...)
+	return append(all, opts...)
+}
@@ -0,0 +1,52 @@
+package calsync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+func TestSyncer(t *testing.T) {
+	srv := calsynctest.NewServer()
+	srv.AddCalendar("classes", "Classes", "UTC")
+	ctx := context.Background()
+	s, err := NewSyncer(srv.Client(), CalendarName("Classes"))
+	ok(t, err)
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	yoga := []*Event{newSrcEvent("yoga", start)}
+	swim := []*Event{newSrcEvent("swim", start), newSrcEvent("swim2", start.Add(time.Hour))}
+
+	changes, err := s.Sync(ctx, "yoga", yoga)
+	ok(t, err)
+	equals(t, 1, len(changes.Adds))
+	changes, err = s.Sync(ctx, "swim", swim)
+	ok(t, err)
+	equals(t, 2, len(changes.Adds))
+	equals(t, 3, len(srv.Events("classes")))
+
+	// Options given to a call follow those given to NewSyncer.
+	_, err = s.Sync(ctx, "swim", nil, MaxDeletes(1))
+	assert(t, err != nil, "expected a delete limit error")
+	plan, err := s.Plan(ctx, "swim", swim[:1])
+	ok(t, err)
+	equals(t, 1, len(plan.Deletes))
+	_, err = s.Apply(ctx, "swim", plan)
+	ok(t, err)
+
+	events, err := s.Fetch(ctx, "swim")
+	ok(t, err)
+	equals(t, 1, len(events))
+}
+
+func TestSyncerBackend(t *testing.T) {
+	b := newMemBackend()
+	s, err := NewSyncer(nil, WithBackend(b))
+	ok(t, err)
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	_, err = s.Sync(context.Background(), "scope", []*Event{newSrcEvent("a", start)})
+	ok(t, err)
+	equals(t, 1, len(b.ops))
+}
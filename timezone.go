@@ -0,0 +1,176 @@
+package calsync
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	calendar "google.golang.org/api/calendar/v3"
+
+	"golang.org/x/net/context"
+)
+
+const dateLayout = "2006-01-02"
+
+// CalendarSettings holds the google calendar settings that affect how
+// times should be interpreted and presented.
+type CalendarSettings struct {
+	// Location is the timezone of the calendar.  If google calendar
+	// reports a timezone we can't load, this will be UTC.
+	Location *time.Location
+
+	// WeekStart is the day the user's weeks start on.
+	WeekStart time.Weekday
+}
+
+// Settings fetches the settings of the calendar that Sync would use,
+// given opts.
+func Settings(ctx context.Context, client *http.Client, opts ...Opt) (*CalendarSettings, error) {
+	c, err := configure(client, "", opts)
+	if err != nil {
+		return nil, err
+	}
+	// Settings only reads, so it doesn't create a calendar for
+	// EnsureCalendar.
+	c.nop = true
+	if err = c.findCalendar(ctx); err != nil {
+		return nil, err
+	}
+	if err = c.ensureCalendar(ctx); err != nil {
+		return nil, err
+	}
+	if err = c.loadLocation(ctx); err != nil {
+		return nil, err
+	}
+	setting, err := c.svc.Settings.Get("weekStart").Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve week start: %v", err)
+	}
+	day, err := strconv.Atoi(setting.Value)
+	if err != nil || day < 0 || day > 6 {
+		return nil, fmt.Errorf("unexpected week start %q", setting.Value)
+	}
+	return &CalendarSettings{
+		Location:  c.loc,
+		WeekStart: time.Weekday(day),
+	}, nil
+}
+
+// WeekOf returns the start of the week containing t, in the calendar's
+// timezone.  It is useful for bucketing events into weeks the way the
+// calendar's owner sees them.
+func (s *CalendarSettings) WeekOf(t time.Time) time.Time {
+	t = t.In(s.Location)
+	days := (int(t.Weekday()) - int(s.WeekStart) + 7) % 7
+	y, m, d := t.Date()
+	return time.Date(y, m, d-days, 0, 0, 0, 0, s.Location)
+}
+
+// loadLocation looks up the timezone of the calendar.  Timezones we
+// can't load, for example because the system timezone database is
+// missing or out of date, fall back to UTC rather than failing the sync,
+// since we only need the timezone to position all day events, which we
+// compare by date anyway.
+func (c *cal) loadLocation(ctx context.Context) error {
+	if c.missing {
+		c.loc = c.missingLocation()
+		return nil
+	}
+	entry, err := c.svc.CalendarList.Get(c.calID).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("unable to retrieve calendar %q: %v", c.calID, err)
+	}
+	loc, err := time.LoadLocation(entry.TimeZone)
+	if err != nil {
+		loc = time.UTC
+	}
+	c.loc = loc
+	return nil
+}
+
+func (c cal) location() *time.Location {
+	if c.loc == nil {
+		return time.UTC
+	}
+	return c.loc
+}
+
+func (c cal) formatEventTime(t time.Time, allDay bool) *calendar.EventDateTime {
+	if allDay {
+		return &calendar.EventDateTime{Date: t.Format(dateLayout)}
+	}
+	if c.wallClock {
+		if name := zoneName(t); name != "" {
+			return &calendar.EventDateTime{
+				DateTime: t.Format(time.RFC3339),
+				TimeZone: name,
+			}
+		}
+	}
+	return &calendar.EventDateTime{DateTime: t.Format(time.RFC3339)}
+}
+
+// parseEventTime parses in, reporting whether it is a date rather than
+// a time.  Dates are returned as midnight in the calendar's timezone.
+// Times are returned in the calendar's timezone too, so that they
+// display the way the calendar's owner sees them, except that under
+// WallClock they are returned in the event's own timezone, if it has
+// one.
+func (c cal) parseEventTime(in *calendar.EventDateTime) (time.Time, bool, error) {
+	if in == nil {
+		return time.Time{}, false, fmt.Errorf("missing time")
+	}
+	if in.Date != "" {
+		t, err := time.ParseInLocation(dateLayout, in.Date, c.location())
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("%q: %v", in.Date, err)
+		}
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, in.DateTime)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("%q: %v", in.DateTime, err)
+	}
+	loc := c.location()
+	if c.wallClock && in.TimeZone != "" {
+		if eventLoc, err := time.LoadLocation(in.TimeZone); err == nil {
+			loc = eventLoc
+		}
+	}
+	return t.In(loc), false, nil
+}
+
+// zoneName returns the name of t's location if time.LoadLocation
+// knows it, so that google calendar will too, or "" if not, for
+// example for the Local location or a fixed offset named "PDT".  Only
+// the name is checked, so a fixed offset named after an IANA zone is
+// reported as that zone.
+func zoneName(t time.Time) string {
+	name := t.Location().String()
+	if name == "" || name == "Local" {
+		return ""
+	}
+	if _, err := time.LoadLocation(name); err != nil {
+		return ""
+	}
+	return name
+}
+
+// sameWallClock reports whether a and b show the same local time in
+// the same named timezone.  If a has no named timezone, only the
+// instants are compared.
+func sameWallClock(a, b time.Time) bool {
+	name := zoneName(a)
+	if name == "" {
+		return a.Equal(b)
+	}
+	const layout = "2006-01-02T15:04:05"
+	return name == zoneName(b) && a.Format(layout) == b.Format(layout)
+}
+
+// sameDate reports whether a and b fall on the same date, each in its
+// own location.
+func sameDate(a, b time.Time) bool {
+	return a.Format(dateLayout) == b.Format(dateLayout)
+}
@@ -0,0 +1,172 @@
+package calsync
+
+import (
+	"testing"
+	"time"
+
+	calendar "google.golang.org/api/calendar/v3"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+// loadLocation returns the named location, skipping t if there is no
+// timezone database to load it from.
+func loadLocation(t *testing.T, name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("no timezone database: %v", err)
+	}
+	return loc
+}
+
+// loadKathmandu returns Asia/Kathmandu, whose non-integer offset
+// catches code that assumes whole hours.
+func loadKathmandu(t *testing.T) *time.Location {
+	return loadLocation(t, "Asia/Kathmandu")
+}
+
+func TestAllDayRoundTrip(t *testing.T) {
+	kathmandu := loadKathmandu(t)
+	c := cal{scope: "test", loc: kathmandu}
+
+	// Midnight on the west coast is already mid afternoon in
+	// Kathmandu, but the dates should carry over unchanged.
+	src := newSrcEvent("allDay", when("2017-04-29T00:00:00-07:00"))
+	src.End = src.Start.AddDate(0, 0, 1)
+	src.AllDay = true
+
+	item := c.makeCalEvent(src)
+	equals(t, "2017-04-29", item.Start.Date)
+	equals(t, "2017-04-30", item.End.Date)
+	equals(t, "", item.Start.DateTime)
+
+	calEv, err := c.parseEvent(item)
+	ok(t, err)
+	assert(t, calEv.AllDay, "expected an all day event")
+	equals(t, time.Date(2017, 4, 29, 0, 0, 0, 0, kathmandu), calEv.Start)
+	assert(t, src.equal(calEv), "expected %v to equal %v", src, calEv)
+	assert(t, !planner{}.edited(calEv), "expected unedited event")
+
+	timed := *src
+	timed.AllDay = false
+	assert(t, !timed.equal(calEv), "expected timed event to differ from all day event")
+}
+
+func TestTimedEventInCalendarZone(t *testing.T) {
+	kathmandu := loadKathmandu(t)
+	c := cal{scope: "test", loc: kathmandu}
+
+	// 8pm on the west coast is the next morning in Kathmandu.
+	src := newSrcEvent("timed", when("2017-04-29T20:00:00-07:00"))
+	calEv, err := c.parseEvent(c.makeCalEvent(src))
+	ok(t, err)
+	assert(t, src.equal(calEv), "expected %v to equal %v", src, calEv)
+	equals(t, "2017/04/30: timed title", calEv.String())
+}
+
+func TestWeekOf(t *testing.T) {
+	kathmandu := loadKathmandu(t)
+	s := &CalendarSettings{Location: kathmandu, WeekStart: time.Monday}
+
+	// Sunday evening UTC is already early Monday in Kathmandu.
+	equals(t,
+		time.Date(2017, 5, 1, 0, 0, 0, 0, kathmandu),
+		s.WeekOf(when("2017-04-30T20:00:00Z")))
+	equals(t,
+		time.Date(2017, 4, 24, 0, 0, 0, 0, kathmandu),
+		s.WeekOf(when("2017-04-30T18:00:00Z")))
+
+	s.WeekStart = time.Sunday
+	equals(t,
+		time.Date(2017, 4, 30, 0, 0, 0, 0, kathmandu),
+		s.WeekOf(when("2017-04-30T20:00:00Z")))
+}
+
+func TestWallClockAcrossDST(t *testing.T) {
+	kathmandu := loadKathmandu(t)
+	la := loadLocation(t, "America/Los_Angeles")
+	denver := loadLocation(t, "America/Denver")
+	now := when("2017-03-01T00:00:00-08:00")
+
+	// Clocks spring forward at 2am, so this is two hours on the wall
+	// but only one hour long.
+	src := &Event{
+		Title: "overnight",
+		Start: time.Date(2017, 3, 12, 1, 30, 0, 0, la),
+		End:   time.Date(2017, 3, 12, 3, 30, 0, 0, la),
+		SrcID: "overnight",
+	}
+	equals(t, time.Hour, src.End.Sub(src.Start))
+
+	c := cal{scope: "test", loc: kathmandu, planner: planner{wallClock: true}}
+	item := c.makeCalEvent(src)
+	equals(t, "America/Los_Angeles", item.Start.TimeZone)
+	equals(t, "2017-03-12T03:30:00-07:00", item.End.DateTime)
+
+	calEv, err := c.parseEvent(item)
+	ok(t, err)
+	equals(t, la, calEv.Start.Location())
+	equals(t, "03:30", calEv.End.Format("15:04"))
+
+	changes, err := c.getOperations(now, []*Event{calEv}, []*Event{src})
+	ok(t, err)
+	assert(t, changes.empty(), "expected no changes, got %s", changes)
+
+	// The same instants, in a different timezone, are a change to the
+	// wall clock times.
+	moved := *src
+	moved.Start = src.Start.In(denver)
+	moved.End = src.End.In(denver)
+	changes, err = c.getOperations(now, []*Event{calEv}, []*Event{&moved})
+	ok(t, err)
+	equals(t, 1, len(changes.Updates))
+
+	// But not to the absolute times.
+	changes, err = planner{}.getOperations(now, []*Event{calEv}, []*Event{&moved})
+	ok(t, err)
+	assert(t, changes.empty(), "expected no changes, got %s", changes)
+}
+
+func TestZoneName(t *testing.T) {
+	la := loadLocation(t, "America/Los_Angeles")
+	equals(t, "America/Los_Angeles", zoneName(time.Date(2017, 3, 12, 1, 30, 0, 0, la)))
+	equals(t, "UTC", zoneName(time.Date(2017, 3, 12, 1, 30, 0, 0, time.UTC)))
+	equals(t, "", zoneName(time.Date(2017, 3, 12, 1, 30, 0, 0, time.Local)))
+	equals(t, "", zoneName(time.Date(2017, 3, 12, 1, 30, 0, 0, time.FixedZone("", -7*60*60))))
+	equals(t, "", zoneName(time.Date(2017, 3, 12, 1, 30, 0, 0, time.FixedZone("PDT", -7*60*60))))
+
+	// Only the name is looked at, so a fixed offset named after a zone
+	// is taken to be that zone.
+	named := time.FixedZone("America/Los_Angeles", -7*60*60)
+	equals(t, "America/Los_Angeles", zoneName(time.Date(2017, 3, 12, 1, 30, 0, 0, named)))
+}
+
+func TestAbsoluteTimesHaveNoTimeZone(t *testing.T) {
+	kathmandu := loadKathmandu(t)
+	la := loadLocation(t, "America/Los_Angeles")
+	src := newSrcEvent("absolute", time.Date(2017, 3, 12, 1, 30, 0, 0, la))
+
+	c := cal{scope: "test", loc: kathmandu}
+	item := c.makeCalEvent(src)
+	equals(t, "", item.Start.TimeZone)
+
+	calEv, err := c.parseEvent(item)
+	ok(t, err)
+	equals(t, kathmandu, calEv.Start.Location())
+	assert(t, src.equal(calEv), "expected %v to equal %v", src, calEv)
+}
+
+func TestSettingsWithService(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	_, err := Settings(ctx, nil, WithService(nil))
+	assert(t, err != nil, "expected an error for a nil service")
+
+	svc, err := calendar.New(s.Client())
+	ok(t, err)
+	settings, err := Settings(ctx, nil, WithService(svc))
+	ok(t, err)
+	assert(t, settings.Location != nil, "expected a location")
+}
@@ -0,0 +1,48 @@
+package calsync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+func TestTrailingComments(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	ev := newSrcEvent("a", time.Now().Add(time.Hour).Truncate(time.Second))
+
+	_, err := Sync(ctx, s.Client(), "scope", []*Event{ev}, TrailingComments())
+	ok(t, err)
+	events := s.Events("primary")
+	equals(t, 1, len(events))
+	equals(t, delim+"\na description\n"+delim+"\n", events[0].Description)
+
+	events[0].Description = "agenda\n" + events[0].Description + "action items"
+	_, err = s.Put("primary", events[0])
+	ok(t, err)
+	changes, err := Sync(ctx, s.Client(), "scope", []*Event{ev}, TrailingComments())
+	ok(t, err)
+	assert(t, changes.empty(), "expected no changes, got %s", changes)
+
+	// Trailers are kept, even without TrailingComments.
+	ev.Description = "new description"
+	changes, err = Sync(ctx, s.Client(), "scope", []*Event{ev})
+	ok(t, err)
+	equals(t, 1, len(changes.Updates))
+	equals(t, "agenda\n"+delim+"\nnew description\n"+delim+"\naction items", s.Events("primary")[0].Description)
+}
+
+func TestTrailingCommentsLayout(t *testing.T) {
+	l, err := compileLayout(Layout{Delimiter: "--- {{.Source}} ---", Footer: "footer", Source: "Acme"})
+	ok(t, err)
+	ev := &Event{Description: "body"}
+	exported := l.export(ev, time.Now(), true)
+	equals(t, "--- Acme ---\nbody\nfooter\n--- Acme ---\n", exported)
+
+	ev.Description = l.canonical("notes\n" + exported + "after")
+	equals(t, &description{"notes", "body", "after", true}, parseDescription(ev.Description))
+	equals(t, "notes\n--- Acme ---\nbody\nfooter\n--- Acme ---\nafter", l.export(ev, time.Now(), false))
+}
@@ -0,0 +1,30 @@
+package calsync
+
+// Update explains one of Changes.Updates, for example for audit logs.
+type Update struct {
+	// Previous is the event as it was in google calendar before the
+	// update.  It is nil for updates from ResumePlan.
+	Previous *Event
+
+	// Next is the event as the update writes it.
+	Next *Event
+
+	// Fields names the fields of Next that differ from Previous, as in
+	// Edit.Fields.  It is empty for updates that only record calendar
+	// edits as synced, or if Previous is nil.
+	Fields []string
+}
+
+// UpdateDetails returns an Update for each of c.Updates, in the same
+// order.
+func (c *Changes) UpdateDetails() []*Update {
+	updates := make([]*Update, len(c.Updates))
+	for i, ev := range c.Updates {
+		u := &Update{Previous: ev.previous, Next: ev}
+		if ev.previous != nil {
+			u.Fields = changedFields(ev.previous, ev)
+		}
+		updates[i] = u
+	}
+	return updates
+}
@@ -0,0 +1,32 @@
+package calsync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+func TestUserNote(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	ev := newSrcEvent("a", time.Now().Add(time.Hour).Truncate(time.Second))
+	_, err := Sync(ctx, s.Client(), "scope", []*Event{ev})
+	ok(t, err)
+
+	fetched, err := Fetch(ctx, s.Client(), "scope")
+	ok(t, err)
+	equals(t, 1, len(fetched))
+	equals(t, "", fetched[0].UserNote)
+
+	events := s.Events("primary")
+	events[0].Description = "room changed\nbring a laptop\n" + events[0].Description
+	_, err = s.Put("primary", events[0])
+	ok(t, err)
+	fetched, err = Fetch(ctx, s.Client(), "scope")
+	ok(t, err)
+	equals(t, "room changed\nbring a laptop", fetched[0].UserNote)
+	equals(t, events[0].Description, fetched[0].Description)
+}
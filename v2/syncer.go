@@ -0,0 +1,127 @@
+/*
+Package calsync is version 2 of the calsync api.  It syncs events into
+a google calendar, as version 1 does, through a Syncer that is set up
+once, with an Options struct, and that takes a context from the
+standard library:
+
+	s, err := calsync.New(client, "myscope", calsync.Options{CalendarName: "Classes"})
+	...
+	changes, err := s.Sync(ctx, events)
+
+Events, Changes and the policies are those of version 1, imported here
+as v1, so that both versions can be used together while moving from
+one to the other.
+*/
+package calsync
+
+import (
+	"context"
+	"net/http"
+
+	v1 "github.com/ginabythebay/calsync"
+)
+
+// Options configures a Syncer.  The zero value syncs into the primary
+// calendar with the defaults of version 1.
+type Options struct {
+	// CalendarID is the calendar to sync into.  Empty means the
+	// primary calendar.
+	CalendarID string
+
+	// CalendarName, if set, is used in place of CalendarID, as for
+	// v1.CalendarName.
+	CalendarName string
+
+	// OnConflict and OnDelete are as for v1.OnConflict and
+	// v1.OnDelete.
+	OnConflict v1.ConflictPolicy
+	OnDelete   v1.DeletePolicy
+
+	// MaxDeletes and MaxDeleteFraction are as for v1.MaxDeletes and
+	// v1.MaxDeleteFraction.  Zero means no limit.
+	MaxDeletes        int
+	MaxDeleteFraction float64
+
+	// SendUpdates is as for v1.SendUpdates.
+	SendUpdates v1.Notify
+
+	// Journal, Audit and Backend are as for v1.Journal, v1.Audit and
+	// v1.WithBackend, when not nil.
+	Journal v1.StateStore
+	Audit   v1.AuditWriter
+	Backend v1.Backend
+
+	// Opts are applied after the fields above, for the options of
+	// version 1 that Options has no field for.
+	Opts []v1.Opt
+}
+
+// opts returns o as options of version 1.
+func (o Options) opts() []v1.Opt {
+	opts := []v1.Opt{v1.OnConflict(o.OnConflict), v1.OnDelete(o.OnDelete), v1.SendUpdates(o.SendUpdates)}
+	if o.CalendarID != "" {
+		opts = append(opts, v1.CalendarID(o.CalendarID))
+	}
+	if o.CalendarName != "" {
+		opts = append(opts, v1.CalendarName(o.CalendarName))
+	}
+	if o.MaxDeletes != 0 {
+		opts = append(opts, v1.MaxDeletes(o.MaxDeletes))
+	}
+	if o.MaxDeleteFraction != 0 {
+		opts = append(opts, v1.MaxDeleteFraction(o.MaxDeleteFraction))
+	}
+	if o.Journal != nil {
+		opts = append(opts, v1.Journal(o.Journal))
+	}
+	if o.Audit != nil {
+		opts = append(opts, v1.Audit(o.Audit))
+	}
+	if o.Backend != nil {
+		opts = append(opts, v1.WithBackend(o.Backend))
+	}
+	return append(opts, o.Opts...)
+}
+
+// Syncer syncs events into one scope.  It makes the google calendar
+// service once, rather than for each call, as v1.Syncer does.  It is
+// safe for concurrent use, though syncs of the same scope should not
+// overlap.
+type Syncer struct {
+	scope string
+	s     *v1.Syncer
+}
+
+// New returns a Syncer for scope, which uses client to call google
+// calendar.  client may be nil with a Backend.
+func New(client *http.Client, scope string, o Options) (*Syncer, error) {
+	s, err := v1.NewSyncer(client, o.opts()...)
+	if err != nil {
+		return nil, err
+	}
+	return &Syncer{scope: scope, s: s}, nil
+}
+
+// Scope returns the scope s syncs into.
+func (s *Syncer) Scope() string { return s.scope }
+
+// Sync makes the calendar match events, as v1.Sync does.
+func (s *Syncer) Sync(ctx context.Context, events []*v1.Event) (*v1.Changes, error) {
+	return s.s.Sync(ctx, s.scope, events)
+}
+
+// Plan returns the changes Sync would make, without making them, as
+// v1.Plan does.
+func (s *Syncer) Plan(ctx context.Context, events []*v1.Event) (*v1.Changes, error) {
+	return s.s.Plan(ctx, s.scope, events)
+}
+
+// Apply makes the changes of plan, as v1.Apply does.
+func (s *Syncer) Apply(ctx context.Context, plan *v1.Changes) (*v1.Changes, error) {
+	return s.s.Apply(ctx, s.scope, plan)
+}
+
+// Fetch returns the events of the scope, as v1.Fetch does.
+func (s *Syncer) Fetch(ctx context.Context) ([]*v1.Event, error) {
+	return s.s.Fetch(ctx, s.scope)
+}
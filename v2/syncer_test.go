@@ -0,0 +1,98 @@
+package calsync
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	v1 "github.com/ginabythebay/calsync"
+	"github.com/ginabythebay/calsync/calsynctest"
+)
+
+func newEvent(id string, start time.Time) *v1.Event {
+	return &v1.Event{Title: id + " title", Start: start, End: start.Add(time.Hour), SrcID: id}
+}
+
+func TestSyncer(t *testing.T) {
+	srv := calsynctest.NewServer()
+	srv.AddCalendar("classes", "Classes", "UTC")
+	ctx := context.Background()
+	s, err := New(srv.Client(), "scope", Options{CalendarName: "Classes", MaxDeletes: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	events := []*v1.Event{newEvent("a", start), newEvent("b", start.Add(time.Hour))}
+
+	changes, err := s.Sync(ctx, events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes.Adds) != 2 {
+		t.Errorf("got %d adds, want 2", len(changes.Adds))
+	}
+	if n := len(srv.Events("classes")); n != 2 {
+		t.Errorf("got %d events in the named calendar, want 2", n)
+	}
+
+	plan, err := s.Plan(ctx, events[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Deletes) != 1 {
+		t.Errorf("got %d deletes, want 1", len(plan.Deletes))
+	}
+	if _, err = s.Apply(ctx, plan); err != nil {
+		t.Fatal(err)
+	}
+	fetched, err := s.Fetch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fetched) != 1 || fetched[0].SrcID != "a" {
+		t.Errorf("got %v, want event a", fetched)
+	}
+
+	// MaxDeletes applies.
+	if _, err = s.Sync(ctx, events); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.Sync(ctx, nil); err == nil {
+		t.Error("expected a delete limit error")
+	}
+}
+
+func TestSyncerBackend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "calsync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s, err := New(nil, "scope", Options{Backend: v1.NewFileBackend(filepath.Join(dir, "events.json"))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	if _, err = s.Sync(context.Background(), []*v1.Event{newEvent("a", start)}); err != nil {
+		t.Fatal(err)
+	}
+	fetched, err := s.Fetch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fetched) != 1 {
+		t.Errorf("got %d events, want 1", len(fetched))
+	}
+
+	// Without a client, only a Backend will do.
+	s, err = New(nil, "scope", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.Fetch(context.Background()); err == nil {
+		t.Error("expected an error without a client")
+	}
+}
@@ -0,0 +1,105 @@
+package calsync
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// MaxTitleLen is the longest Title, in bytes, that Validate accepts.
+	MaxTitleLen = 1024
+
+	// MaxDescriptionLen is the longest Description, in bytes, that
+	// Validate accepts.  Google calendar rejects much longer ones.
+	MaxDescriptionLen = 8192
+)
+
+// Invalid describes what is wrong with one source event.
+type Invalid struct {
+	// Index is the position of Event in the events passed to Validate.
+	Index int
+	Event *Event
+
+	// Problems describes each problem, such as "SrcID is empty".
+	Problems []string
+}
+
+func (v *Invalid) String() string {
+	return fmt.Sprintf("event %d (%q): %s", v.Index, v.Event.Title, strings.Join(v.Problems, ", "))
+}
+
+// ValidationError is returned by Validate, and by Sync with
+// ValidateSource, when source events are invalid.
+type ValidationError struct {
+	Invalid []*Invalid
+}
+
+func (e *ValidationError) Error() string {
+	var lines []string
+	for _, v := range e.Invalid {
+		lines = append(lines, v.String())
+	}
+	return fmt.Sprintf("%d invalid source event(s): %s", len(e.Invalid), strings.Join(lines, "; "))
+}
+
+// Validate checks events before they are synced, so that bad source
+// rows are reported together and up front, rather than as errors from
+// google calendar partway through a sync.  It returns a
+// *ValidationError listing each event with an empty SrcID, a SrcID
+// longer than MaxSrcIDLen or already used by an earlier event, a zero
+// Start or End, a Start after its End, a Title or Description longer
+// than MaxTitleLen or MaxDescriptionLen, a SourceURL that isn't http or
+// https, an unknown Status, or Metadata that can't be written.  Otherwise it returns nil.
+func Validate(events []*Event) error {
+	var invalid []*Invalid
+	first := map[string]int{}
+	for i, ev := range events {
+		var problems []string
+		problem := func(format string, args ...interface{}) {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+		if ev.SrcID == "" {
+			problem("SrcID is empty")
+		} else if len(ev.SrcID) > MaxSrcIDLen {
+			problem("SrcID is longer than %d bytes", MaxSrcIDLen)
+		} else if j, ok := first[ev.SrcID]; ok {
+			problem("SrcID %q is the same as event %d's", ev.SrcID, j)
+		} else {
+			first[ev.SrcID] = i
+		}
+		if ev.Start.IsZero() {
+			problem("Start is zero")
+		}
+		if ev.End.IsZero() {
+			problem("End is zero")
+		}
+		if ev.Start.After(ev.End) {
+			problem("Start is after End")
+		}
+		if len(ev.Title) > MaxTitleLen {
+			problem("Title is longer than %d bytes", MaxTitleLen)
+		}
+		if len(ev.Description) > MaxDescriptionLen {
+			problem("Description is longer than %d bytes", MaxDescriptionLen)
+		}
+		if ev.SourceURL != "" && !validSourceURL(ev.SourceURL) {
+			problem("SourceURL %q is not http or https", ev.SourceURL)
+		}
+		if !ev.Status.known() {
+			problem("Status %q is unknown", ev.Status)
+		}
+		for _, k := range metadataKeys(ev.Metadata) {
+			v := ev.Metadata[k]
+			if k == "" || strings.ContainsAny(k, ":\n") || strings.Contains(v, "\n") {
+				problem("Metadata %q: %q is invalid", k, v)
+			}
+		}
+		if len(problems) != 0 {
+			invalid = append(invalid, &Invalid{i, ev, problems})
+		}
+	}
+	if len(invalid) != 0 {
+		return &ValidationError{invalid}
+	}
+	return nil
+}
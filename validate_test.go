@@ -0,0 +1,57 @@
+package calsync
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+func TestValidate(t *testing.T) {
+	now := when("2017-04-29T20:00:00-07:00")
+	good := newSrcEvent("good", now)
+	noID := newSrcEvent("noID", now)
+	noID.SrcID = ""
+	dup := newSrcEvent("good", now.Add(time.Hour))
+	backwards := newSrcEvent("backwards", now)
+	backwards.Start, backwards.End = backwards.End, backwards.Start
+	zero := newSrcEvent("zero", now)
+	zero.Start = time.Time{}
+	long := newSrcEvent("long", now)
+	long.Title = strings.Repeat("x", MaxTitleLen+1)
+	long.Description = strings.Repeat("x", MaxDescriptionLen+1)
+
+	ok(t, Validate([]*Event{good}))
+	ok(t, Validate(nil))
+
+	err := Validate([]*Event{good, noID, dup, backwards, zero, long})
+	verr, isValidation := err.(*ValidationError)
+	assert(t, isValidation, "expected *ValidationError, got %v", err)
+	var got []string
+	for _, v := range verr.Invalid {
+		got = append(got, v.String())
+	}
+	equals(t, []string{
+		`event 1 ("noID title"): SrcID is empty`,
+		`event 2 ("good title"): SrcID "good srcId" is the same as event 0's`,
+		`event 3 ("backwards title"): Start is after End`,
+		`event 4 ("zero title"): Start is zero`,
+		`event 5 ("` + long.Title + `"): Title is longer than 1024 bytes, Description is longer than 8192 bytes`,
+	}, got)
+	equals(t, long, verr.Invalid[4].Event)
+}
+
+func TestValidateSource(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	bad := newSrcEvent("a", time.Now().Add(time.Hour).Truncate(time.Second))
+	bad.SrcID = ""
+
+	_, err := Sync(ctx, s.Client(), "scope", []*Event{bad}, ValidateSource())
+	_, isValidation := err.(*ValidationError)
+	assert(t, isValidation, "expected *ValidationError, got %v", err)
+	equals(t, 0, len(s.Events("primary")))
+}
@@ -0,0 +1,144 @@
+package calsync
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// Drift describes how the upcoming events that a scope owns in google
+// calendar differ from the source.  See Verify.
+type Drift struct {
+	// Missing holds source events that are not in google calendar.
+	Missing []*Event
+
+	// Extra holds calendar events that are no longer in the source.
+	Extra []*Event
+
+	// Modified holds events that differ from the source, though nobody
+	// edited them in google calendar since they were synced: the source
+	// changed, and the next Sync will update them.
+	Modified []*Mismatch
+
+	// Edited holds events that were edited in google calendar since
+	// they were synced, and that differ from the source.
+	Edited []*Mismatch
+}
+
+// Mismatch is an event whose content in google calendar differs from
+// the source.
+type Mismatch struct {
+	// Source is the event as the source has it.
+	Source *Event
+
+	// Calendar is the event as it now is in google calendar.
+	Calendar *Event
+
+	// Fields names the fields of Calendar that differ from Source, such
+	// as "Start" or "Description".
+	Fields []string
+}
+
+// InSync reports whether no drift was found.
+func (d *Drift) InSync() bool {
+	return len(d.Missing) == 0 && len(d.Extra) == 0 &&
+		len(d.Modified) == 0 && len(d.Edited) == 0
+}
+
+func (d *Drift) String() string {
+	var lines []string
+	for _, ev := range d.Missing {
+		lines = append(lines, fmt.Sprintf("Missing %s", ev))
+	}
+	for _, ev := range d.Extra {
+		lines = append(lines, fmt.Sprintf("Extra %s", ev))
+	}
+	for _, m := range d.Modified {
+		lines = append(lines, fmt.Sprintf("Modified %s (%s)", m.Calendar, strings.Join(m.Fields, ", ")))
+	}
+	for _, m := range d.Edited {
+		lines = append(lines, fmt.Sprintf("Edited %s (%s)", m.Calendar, strings.Join(m.Fields, ", ")))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Verify compares the upcoming events in scope against srcEvents, as
+// Sync would, and reports the drift between them, for example to alert
+// when a calendar was edited by hand, or when syncs have stopped
+// running.  Nothing is modified, whatever opts are given; as with Nop,
+// a calendar that EnsureCalendar would create is taken to be empty.
+func Verify(
+	ctx context.Context,
+	client *http.Client,
+	scope string,
+	srcEvents []*Event,
+	opts ...Opt) (*Drift, error) {
+	opts = append(append([]Opt(nil), opts...), Nop())
+	c, err := setup(ctx, client, scope, opts)
+	if err != nil {
+		return nil, err
+	}
+	now := c.now()
+	calEvents, err := c.fetch(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	return c.verify(now, calEvents, srcEvents), nil
+}
+
+func (p planner) verify(now time.Time, calEvents, srcEvents []*Event) *Drift {
+	// Compare against each source event as Sync writes it.
+	if p.privateCopies {
+		srcEvents = privateCopies(srcEvents)
+	}
+	if p.route != nil {
+		srcEvents = p.routed(srcEvents)
+	}
+	srcMap := map[string]*Event{}
+	for _, ev := range srcEvents {
+		if ev.End.Before(now) {
+			continue
+		}
+		srcMap[p.eventKey(ev)] = ev
+	}
+
+	d := &Drift{}
+	for _, calEv := range calEvents {
+		srcEv, ok := srcMap[p.eventKey(calEv)]
+		delete(srcMap, p.eventKey(calEv))
+		switch {
+		case !ok:
+			d.Extra = append(d.Extra, calEv)
+		case p.equal(srcEv, calEv):
+		case p.edited(calEv):
+			d.Edited = append(d.Edited, &Mismatch{srcEv, calEv, changedFields(srcEv, calEv)})
+		default:
+			d.Modified = append(d.Modified, &Mismatch{srcEv, calEv, changedFields(srcEv, calEv)})
+		}
+	}
+	for _, srcEv := range srcMap {
+		if srcEv.status() == EventCancelled {
+			// Cancelled events are not in google calendar to begin with.
+			continue
+		}
+		d.Missing = append(d.Missing, srcEv)
+	}
+
+	sort.Sort(byStart(d.Missing))
+	sort.Sort(byStart(d.Extra))
+	sort.Sort(mismatchesByStart(d.Modified))
+	sort.Sort(mismatchesByStart(d.Edited))
+	return d
+}
+
+type mismatchesByStart []*Mismatch
+
+func (s mismatchesByStart) Len() int { return len(s) }
+func (s mismatchesByStart) Less(i, j int) bool {
+	return byStart{s[i].Calendar, s[j].Calendar}.Less(0, 1)
+}
+func (s mismatchesByStart) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
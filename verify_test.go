@@ -0,0 +1,71 @@
+package calsync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+)
+
+func TestVerifyPlanner(t *testing.T) {
+	now := when("2017-04-29T20:00:00-07:00")
+
+	same := newSrcEvent("same", now.Add(time.Hour))
+	missing := newSrcEvent("missing", now.Add(2*time.Hour))
+	extra := newSrcEvent("extra", now.Add(3*time.Hour))
+	modified := newSrcEvent("modified", now.Add(4*time.Hour))
+	edited := newSrcEvent("edited", now.Add(5*time.Hour))
+	past := newSrcEvent("past", now.Add(-2*time.Hour))
+	cancelled := newSrcEvent("cancelled", now.Add(6*time.Hour))
+	cancelled.Status = EventCancelled
+
+	modifiedSrc := *modified
+	modifiedSrc.Title = "new title"
+
+	d := planner{}.verify(now,
+		[]*Event{syncedCalEvent(same), syncedCalEvent(extra), syncedCalEvent(modified), editedCalEvent(edited)},
+		[]*Event{same, missing, &modifiedSrc, edited, past, cancelled})
+
+	assert(t, !d.InSync(), "expected drift")
+	equals(t, []*Event{missing}, d.Missing)
+	equals(t, 1, len(d.Extra))
+	equals(t, extra.SrcID, d.Extra[0].SrcID)
+	equals(t, 1, len(d.Modified))
+	equals(t, &modifiedSrc, d.Modified[0].Source)
+	equals(t, []string{"Title"}, d.Modified[0].Fields)
+	equals(t, 1, len(d.Edited))
+	equals(t, edited, d.Edited[0].Source)
+	equals(t, []string{"Start"}, d.Edited[0].Fields)
+
+	d = planner{}.verify(now, []*Event{syncedCalEvent(same)}, []*Event{same, past})
+	assert(t, d.InSync(), "expected no drift, got %s", d)
+}
+
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+	s := calsynctest.NewServer()
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	src := []*Event{newSrcEvent("a", start), newSrcEvent("b", start.Add(time.Hour))}
+
+	// A calendar that would be created is taken to be empty.
+	d, err := Verify(ctx, s.Client(), "scope", src, EnsureCalendar("Synced", "", ""))
+	ok(t, err)
+	equals(t, 2, len(d.Missing))
+	equals(t, 1, len(s.Calendars()))
+
+	_, err = Sync(ctx, s.Client(), "scope", src)
+	ok(t, err)
+	d, err = Verify(ctx, s.Client(), "scope", src)
+	ok(t, err)
+	assert(t, d.InSync(), "expected no drift, got %s", d)
+
+	requests := s.Requests()
+	d, err = Verify(ctx, s.Client(), "scope", src[:1])
+	ok(t, err)
+	equals(t, 1, len(d.Extra))
+	equals(t, src[1].SrcID, d.Extra[0].SrcID)
+	assert(t, s.Requests() > requests, "expected the calendar to be listed")
+	equals(t, 2, len(s.Events("primary")))
+}
@@ -0,0 +1,206 @@
+package calsync
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+// renewBefore is how long before a notification channel expires that
+// Watcher replaces it.
+const renewBefore = time.Hour
+
+// Watcher registers a google calendar push notification channel for
+// the calendar a scope syncs into, and calls a function each time its
+// events change, so that a service can sync, or check for conflicts,
+// when something changed, rather than polling an idle calendar:
+//
+//	w, err := calsync.NewWatcher(ctx, client, "myscope", "https://example.com/notify",
+//		func(ctx context.Context) { runner.RunOnce(ctx) })
+//	...
+//	http.Handle("/notify", w)
+//	err = w.Run(ctx)
+//
+// Google calendar sends notifications to address, which must be https,
+// on a domain verified for the project, and served by the Watcher.
+// Notifications say only that something changed, not what, and also
+// follow the changes Sync makes itself.
+type Watcher struct {
+	// TTL is how long each channel is asked to last.  Google calendar
+	// may cap it.  Channels are renewed shortly before they expire.
+	// NewWatcher sets it to a week.
+	TTL time.Duration
+
+	svc      *calendar.Service
+	calID    string
+	address  string
+	onChange func(ctx context.Context)
+
+	// changed holds a pending change, so that notifications arriving
+	// while onChange runs are coalesced into one more call.
+	changed chan struct{}
+
+	mu       sync.Mutex
+	channels map[string]*calendar.Channel
+}
+
+// NewWatcher returns a Watcher for the calendar that scope syncs into,
+// found with client and opts as Sync finds it, which calls onChange
+// when its events change.  With RouteTo, only the default calendar is
+// watched.
+func NewWatcher(
+	ctx context.Context,
+	client *http.Client,
+	scope string,
+	address string,
+	onChange func(ctx context.Context),
+	opts ...Opt) (*Watcher, error) {
+	c, err := setup(ctx, client, scope, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.needsGoogle("NewWatcher"); err != nil {
+		return nil, err
+	}
+	return &Watcher{
+		TTL:      7 * 24 * time.Hour,
+		svc:      c.svc,
+		calID:    c.calID,
+		address:  address,
+		onChange: onChange,
+		changed:  make(chan struct{}, 1),
+		channels: map[string]*calendar.Channel{},
+	}, nil
+}
+
+// Run registers a channel, calls onChange for the notifications that
+// arrive, and renews the channel before it expires, until ctx is done.
+// It then stops the channel, and returns ctx.Err().  It returns early
+// if registering or renewing the channel fails.
+func (w *Watcher) Run(ctx context.Context) error {
+	ch, err := w.watch(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		w.stop(ch)
+	}()
+	for {
+		renew := time.NewTimer(renewAfter(ch))
+		select {
+		case <-ctx.Done():
+			renew.Stop()
+			return ctx.Err()
+		case <-w.changed:
+			renew.Stop()
+			w.onChange(ctx)
+		case <-renew.C:
+			next, err := w.watch(ctx)
+			if err != nil {
+				return err
+			}
+			// The old channel is stopped only once the new one is up,
+			// so that no change goes unnoticed.
+			w.stop(ch)
+			ch = next
+		}
+	}
+}
+
+// renewAfter returns how long until ch should be renewed.
+func renewAfter(ch *calendar.Channel) time.Duration {
+	expires := time.Unix(0, ch.Expiration*int64(time.Millisecond))
+	d := expires.Sub(time.Now()) - renewBefore
+	if d < time.Minute {
+		// Google calendar gave a short ttl; renew at half of it rather
+		// than right away.
+		d = expires.Sub(time.Now()) / 2
+	}
+	return d
+}
+
+// watch registers a new channel.
+func (w *Watcher) watch(ctx context.Context) (*calendar.Channel, error) {
+	id, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+	token, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+	ch, err := w.svc.Events.Watch(w.calID, &calendar.Channel{
+		Id:      id,
+		Type:    "web_hook",
+		Address: w.address,
+		Token:   token,
+		Params:  map[string]string{"ttl": strconv.FormatInt(int64(w.TTL/time.Second), 10)},
+	}).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("unable to watch calendar %s: %v", w.calID, err)
+	}
+	// The response doesn't echo the token.
+	ch.Token = token
+	w.mu.Lock()
+	w.channels[ch.Id] = ch
+	w.mu.Unlock()
+	return ch, nil
+}
+
+// stop stops ch, on a best effort basis: a channel that isn't stopped
+// expires by itself, and its notifications are ignored meanwhile.
+func (w *Watcher) stop(ch *calendar.Channel) {
+	w.mu.Lock()
+	delete(w.channels, ch.Id)
+	w.mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	w.svc.Channels.Stop(&calendar.Channel{Id: ch.Id, ResourceId: ch.ResourceId}).Context(ctx).Do()
+}
+
+// ServeHTTP receives notifications.  Those for channels that Run didn't
+// register, or with the wrong token, are refused.  The "sync" message
+// that google calendar sends when a channel is registered doesn't
+// count as a change.
+func (w *Watcher) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.mu.Lock()
+	ch, ok := w.channels[r.Header.Get("X-Goog-Channel-ID")]
+	w.mu.Unlock()
+	if !ok {
+		http.Error(rw, "unknown channel", http.StatusNotFound)
+		return
+	}
+	token := r.Header.Get("X-Goog-Channel-Token")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(ch.Token)) != 1 {
+		http.Error(rw, "bad channel token", http.StatusForbidden)
+		return
+	}
+	if r.Header.Get("X-Goog-Resource-State") != "sync" {
+		select {
+		case w.changed <- struct{}{}:
+		default:
+			// A change is already pending.
+		}
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
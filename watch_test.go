@@ -0,0 +1,94 @@
+package calsync
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ginabythebay/calsync/calsynctest"
+
+	"golang.org/x/net/context"
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+func TestWatcher(t *testing.T) {
+	s := calsynctest.NewServer()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changes := make(chan bool, 10)
+	mux := http.NewServeMux()
+	hook := httptest.NewServer(mux)
+	defer hook.Close()
+	w, err := NewWatcher(ctx, s.Client(), "scope", hook.URL+"/notify", func(context.Context) {
+		changes <- true
+	})
+	ok(t, err)
+	mux.Handle("/notify", w)
+
+	done := make(chan error)
+	go func() { done <- w.Run(ctx) }()
+	var channels []*calendar.Channel
+	for i := 0; i < 100 && len(channels) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		channels = s.Channels()
+	}
+	equals(t, 1, len(channels))
+	assert(t, channels[0].Expiration > 0, "expected the channel to expire")
+
+	ok(t, s.Notify("primary"))
+	select {
+	case <-changes:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a call for the notification")
+	}
+	ok(t, s.Notify("other"))
+
+	// A forged notification is refused.
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/notify", nil)
+	req.Header.Set("X-Goog-Channel-ID", channels[0].Id)
+	req.Header.Set("X-Goog-Channel-Token", "guessed")
+	w.ServeHTTP(rec, req)
+	equals(t, http.StatusForbidden, rec.Code)
+
+	// As is one for another channel.
+	rec = httptest.NewRecorder()
+	req.Header.Set("X-Goog-Channel-ID", "unknown")
+	w.ServeHTTP(rec, req)
+	equals(t, http.StatusNotFound, rec.Code)
+
+	cancel()
+	equals(t, context.Canceled, <-done)
+	equals(t, 0, len(s.Channels()))
+	equals(t, 0, len(changes))
+}
+
+func TestWatcherCoalesces(t *testing.T) {
+	w := &Watcher{
+		changed:  make(chan struct{}, 1),
+		channels: map[string]*calendar.Channel{"id": {Id: "id", Token: "token"}},
+	}
+	for _, state := range []string{"sync", "exists", "exists", "not_exists"} {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/notify", nil)
+		req.Header.Set("X-Goog-Channel-ID", "id")
+		req.Header.Set("X-Goog-Channel-Token", "token")
+		req.Header.Set("X-Goog-Resource-State", state)
+		w.ServeHTTP(rec, req)
+		equals(t, http.StatusNoContent, rec.Code)
+	}
+	equals(t, 1, len(w.changed))
+}
+
+func TestRenewAfter(t *testing.T) {
+	expiring := func(d time.Duration) *calendar.Channel {
+		return &calendar.Channel{Expiration: time.Now().Add(d).UnixNano() / int64(time.Millisecond)}
+	}
+	d := renewAfter(expiring(7 * 24 * time.Hour))
+	assert(t, d > 7*24*time.Hour-renewBefore-time.Minute && d <= 7*24*time.Hour-renewBefore,
+		"got %s, want an hour before expiry", d)
+	d = renewAfter(expiring(time.Hour))
+	assert(t, d > 29*time.Minute && d <= 30*time.Minute, "got %s, want half the ttl", d)
+}